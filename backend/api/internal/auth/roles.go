@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// the roles a user can have, every new user starts out as RoleUser
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var ValidRoles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Permission is something a role allows beyond what every user can do
+// with the things they own.
+type Permission string
+
+const (
+	// removing posts, projects and comments written by other users
+	PermissionRemoveContent Permission = "content:remove"
+	// deleting other accounts, suspending them and changing their role
+	PermissionManageUsers Permission = "users:manage"
+	// handing a project over to another owner
+	PermissionReassignProjects Permission = "projects:reassign"
+	// editing their own comments after the edit window has closed
+	PermissionEditAnytime Permission = "content:edit-anytime"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermissionRemoveContent, PermissionEditAnytime},
+	RoleAdmin:     {PermissionRemoveContent, PermissionEditAnytime, PermissionManageUsers, PermissionReassignProjects},
+}
+
+// HasPermission reports whether a role grants a permission.
+// Unknown roles are treated as having no permissions at all.
+func HasPermission(role string, permission Permission) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// ValidateRole checks that a role is one we know about.
+//
+// Parameters:
+//   - role: The role to check.
+//
+// Returns:
+//   - error: An error if the role is unknown, nil otherwise.
+func ValidateRole(role string) error {
+	if !slices.Contains(ValidRoles, role) {
+		return fmt.Errorf("Unknown role '%v', expected one of %v", role, strings.Join(ValidRoles, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// The auth package issues and verifies the credentials that the
+// api hands out to clients. Access tokens are short lived and
+// HMAC-signed so they can be checked without a database lookup,
+// refresh tokens are long lived random strings that are only ever
+// stored hashed.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how long each kind of token stays valid
+var (
+	AccessTokenDuration  = 15 * time.Minute
+	RefreshTokenDuration = 30 * 24 * time.Hour
+)
+
+// the header never changes since we only support one algorithm, so
+// tokens with any other header (like `alg: none`) are rejected outright
+var encodedHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var signingKey []byte
+
+// Claims is the payload carried by an access token.
+type Claims struct {
+	Subject   int64  `json:"sub"`
+	SessionId string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SetSigningKey sets the secret used to sign and verify access tokens.
+// It has to be called before any tokens are issued.
+func SetSigningKey(key []byte) {
+	signingKey = key
+}
+
+// NewRandomToken generates a url safe random string, used for refresh
+// tokens and session ids.
+//
+// Returns:
+//   - string: The random token.
+//   - error: An error if the system random source fails.
+func NewRandomToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("Failed to generate token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// HashToken hashes a token so the raw credential never has to be stored.
+// Tokens are long and random, so a plain sha256 is enough here.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IssueAccessToken creates a signed access token for a user's session.
+//
+// Parameters:
+//   - userId: The id of the user the token is for.
+//   - sessionId: The id of the session (refresh token family) the token belongs to.
+//
+// Returns:
+//   - string: The signed token.
+//   - time.Time: When the token expires.
+//   - error: An error if no signing key is set or the claims cannot be encoded.
+func IssueAccessToken(userId int64, sessionId string) (string, time.Time, error) {
+	if len(signingKey) == 0 {
+		return "", time.Time{}, fmt.Errorf("No token signing key has been set")
+	}
+
+	issuedAt := time.Now().UTC()
+	expiresAt := issuedAt.Add(AccessTokenDuration)
+	payload, err := json.Marshal(Claims{
+		Subject:   userId,
+		SessionId: sessionId,
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Failed to encode token claims: %v", err)
+	}
+
+	unsigned := encodedHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned), expiresAt, nil
+}
+
+// ParseAccessToken verifies an access token's signature and expiration.
+//
+// Parameters:
+//   - token: The token sent by the client.
+//
+// Returns:
+//   - *Claims: The claims carried by the token.
+//   - error: An error if the token is malformed, forged or expired.
+func ParseAccessToken(token string) (*Claims, error) {
+	if len(signingKey) == 0 {
+		return nil, fmt.Errorf("No token signing key has been set")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != encodedHeader {
+		return nil, fmt.Errorf("Malformed access token")
+	}
+
+	expected := sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, fmt.Errorf("Invalid access token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed access token")
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("Malformed access token")
+	}
+
+	if time.Now().UTC().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("Access token has expired")
+	}
+
+	return &claims, nil
+}
+
+// UnsubscribeToken signs the token in the unsubscribe link of the emails a
+// user gets at `email`. It can only turn their digests off, and stops
+// working once they change address.
+//
+// Parameters:
+//   - userId: The id of the user the emails are for.
+//   - email: The address they are sent to.
+//
+// Returns:
+//   - string: The token, the user id followed by the signature.
+func UnsubscribeToken(userId int64, email string) string {
+	return fmt.Sprintf("%v.%v", userId, sign(fmt.Sprintf("unsubscribe:%v:%v", userId, email)))
+}
+
+// UnsubscribeTokenUser returns the user an unsubscribe token claims to be
+// for. The claim is only checked by CheckUnsubscribeToken, with their
+// current address.
+//
+// Returns:
+//   - int64: The id of the user.
+//   - bool: Whether the token is well formed.
+func UnsubscribeTokenUser(token string) (int64, bool) {
+	id, _, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	userId, err := strconv.ParseInt(id, 10, 64)
+	return userId, err == nil
+}
+
+// CheckUnsubscribeToken reports whether `token` was signed for the emails
+// a user gets at `email`.
+func CheckUnsubscribeToken(token string, userId int64, email string) bool {
+	return hmac.Equal([]byte(token), []byte(UnsubscribeToken(userId, email)))
+}
@@ -0,0 +1,145 @@
+// The autocomplete package suggests users and projects by the start of
+// their names, fast enough to run on every key press. Names are kept in
+// memory, sorted, so the names starting with a prefix sit next to each
+// other and are found with a binary search.
+package autocomplete
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"backend/api/internal/types"
+)
+
+type entry struct {
+	// the name lowercased, which is what the entries are sorted by
+	key       string
+	id        int64
+	name      string
+	followers int64
+}
+
+// Index is a prefix index over names, safe for concurrent use. Adding or
+// renaming an entry shifts the entries after it, which is cheap next to
+// the database write that comes with it.
+type Index struct {
+	mu     sync.RWMutex
+	sorted []*entry
+	byId   map[int64]*entry
+}
+
+// New builds an index holding the given names and follower counts.
+func New(suggestions []types.Suggestion) *Index {
+	index := &Index{}
+	index.Reset(suggestions)
+	return index
+}
+
+// Reset replaces every entry of the index.
+func (index *Index) Reset(suggestions []types.Suggestion) {
+	sorted := make([]*entry, 0, len(suggestions))
+	byId := make(map[int64]*entry, len(suggestions))
+	for _, suggestion := range suggestions {
+		e := &entry{key: strings.ToLower(suggestion.Name), id: suggestion.ID, name: suggestion.Name, followers: suggestion.Followers}
+		sorted = append(sorted, e)
+		byId[e.id] = e
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].less(sorted[j]) })
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.sorted = sorted
+	index.byId = byId
+}
+
+func (e *entry) less(other *entry) bool {
+	if e.key != other.key {
+		return e.key < other.key
+	}
+	return e.id < other.id
+}
+
+// search returns where an entry belongs in the sorted entries.
+func (index *Index) search(e *entry) int {
+	return sort.Search(len(index.sorted), func(i int) bool { return !index.sorted[i].less(e) })
+}
+
+// Put adds an entry, or renames it if the id is already in the index.
+// A new entry starts without followers.
+func (index *Index) Put(id int64, name string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	e := &entry{key: strings.ToLower(name), id: id, name: name}
+	if existing, ok := index.byId[id]; ok {
+		index.remove(existing)
+		e.followers = existing.followers
+	}
+	i := index.search(e)
+	index.sorted = append(index.sorted, nil)
+	copy(index.sorted[i+1:], index.sorted[i:])
+	index.sorted[i] = e
+	index.byId[id] = e
+}
+
+// Remove takes an entry out of the index, if it is there.
+func (index *Index) Remove(id int64) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if existing, ok := index.byId[id]; ok {
+		index.remove(existing)
+	}
+}
+
+func (index *Index) remove(e *entry) {
+	i := index.search(e)
+	index.sorted = append(index.sorted[:i], index.sorted[i+1:]...)
+	delete(index.byId, e.id)
+}
+
+// AddFollowers changes the follower count of an entry by delta.
+func (index *Index) AddFollowers(id int64, delta int64) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if e, ok := index.byId[id]; ok {
+		e.followers = max(e.followers+delta, 0)
+	}
+}
+
+// Complete returns up to limit entries whose name starts with prefix,
+// ignoring case. The ones in following come first, then the most followed,
+// then the shortest, so an exact match beats the longer names it starts.
+func (index *Index) Complete(prefix string, limit int, following map[int64]bool) []types.Suggestion {
+	index.mu.RLock()
+	key := strings.ToLower(prefix)
+	start := sort.Search(len(index.sorted), func(i int) bool { return index.sorted[i].key >= key })
+	var matches []types.Suggestion
+	for _, e := range index.sorted[start:] {
+		if !strings.HasPrefix(e.key, key) {
+			break
+		}
+		matches = append(matches, types.Suggestion{ID: e.id, Name: e.name, Followers: e.followers, Following: following[e.id]})
+	}
+	index.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Following != b.Following {
+			return a.Following
+		}
+		if a.Followers != b.Followers {
+			return a.Followers > b.Followers
+		}
+		return len(a.Name) < len(b.Name)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []types.Suggestion{}
+	}
+	return matches
+}
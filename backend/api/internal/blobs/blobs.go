@@ -0,0 +1,79 @@
+// The blobs package keeps the files users upload, like profile pictures
+// and project images. A Storage puts them somewhere they can be served
+// from, a directory on the api's own disk or a bucket of an S3
+// compatible service, and hands back the URL each one is served at.
+package blobs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Storage keeps blobs under keys like "users/1/picture-abc.png". Keys are
+// made up by the api, never by users, and only use slash separated
+// segments of letters, digits, dots, dashes and underscores.
+type Storage interface {
+	// Put stores `data` under `key`, replacing whatever was there, and
+	// returns the URL it is served at.
+	Put(key string, contentType string, data []byte) (string, error)
+	// Delete removes the blob under `key`, succeeding if there is none.
+	Delete(key string) error
+	// Key returns the key of the blob served at `url`, false for URLs
+	// this storage does not serve, like pictures linked from elsewhere.
+	Key(url string) (string, bool)
+}
+
+// the image types uploads may have, by the extension they are stored
+// with. SVG is left out on purpose, since it can carry scripts
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// SniffImage works out the type of an uploaded image from its content,
+// ignoring whatever type the client claimed it has.
+//
+// Parameters:
+//   - data: The uploaded file.
+//
+// Returns:
+//   - string: The content type of the image, or of the file if it is not one.
+//   - string: The extension to store the image with, like ".png".
+//   - bool: Whether the file is an image of a type uploads may have.
+func SniffImage(data []byte) (string, string, bool) {
+	contentType := http.DetectContentType(data)
+	extension, ok := imageExtensions[contentType]
+	return contentType, extension, ok
+}
+
+// checkKey refuses keys that could escape their storage, like ones with
+// ".." segments, or that would need escaping in a URL.
+func checkKey(key string) error {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("Invalid blob key '%v'", key)
+		}
+	}
+	for _, char := range key {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '/' || char == '.' || char == '-' || char == '_':
+		default:
+			return fmt.Errorf("Invalid blob key '%v'", key)
+		}
+	}
+	return nil
+}
+
+// keyUnder returns the key of the blob served at `url` by a storage that
+// serves every blob under `base`.
+func keyUnder(base string, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, strings.TrimSuffix(base, "/")+"/")
+	if !ok || checkKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
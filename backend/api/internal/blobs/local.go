@@ -0,0 +1,109 @@
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps blobs as files in a directory, served under a public URL
+// either by the api itself or by a proxy in front of it.
+type Local struct {
+	directory string
+	// the path the api serves the blobs under, empty if something else does
+	servePath string
+	publicURL string
+}
+
+// the path local blobs are served under when no public URL is given
+const DefaultServePath = "/media"
+
+// NewLocal returns a storage keeping blobs in `directory`, creating it if
+// needed, that are served under `publicURL`. A path like "/media" is
+// served by the api at `apiURL`, its own public URL, an absolute URL has
+// to be served by something else. An empty `publicURL` serves them under
+// DefaultServePath. Either way blobs get absolute URLs, so clients never
+// have to work out what they are relative to.
+func NewLocal(directory string, publicURL string, apiURL string) (*Local, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("Failed to create upload directory: %v", err)
+	}
+	if publicURL == "" {
+		publicURL = DefaultServePath
+	}
+	local := &Local{directory: directory, publicURL: strings.TrimSuffix(publicURL, "/")}
+	if strings.HasPrefix(local.publicURL, "/") {
+		local.servePath = local.publicURL
+		local.publicURL = strings.TrimSuffix(apiURL, "/") + local.servePath
+	}
+	return local, nil
+}
+
+// Directory is where the blobs are kept.
+func (l *Local) Directory() string {
+	return l.directory
+}
+
+// ServePath is the path the api should serve the blobs under, empty if
+// they are served from an absolute URL by something else.
+func (l *Local) ServePath() string {
+	return l.servePath
+}
+
+// Put writes the blob to a temporary file next to where it goes and
+// moves it into place, so a blob is never served half written.
+func (l *Local) Put(key string, contentType string, data []byte) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	target := filepath.Join(l.directory, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("Failed to create blob directory: %v", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create blob: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return "", fmt.Errorf("Failed to write blob: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("Failed to write blob: %v", err)
+	}
+	// temporary files are only readable by their owner
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("Failed to write blob: %v", err)
+	}
+	if err := os.Rename(file.Name(), target); err != nil {
+		return "", fmt.Errorf("Failed to write blob: %v", err)
+	}
+	return l.publicURL + "/" + key, nil
+}
+
+func (l *Local) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(l.directory, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Failed to delete blob: %v", err)
+	}
+	return nil
+}
+
+// Key also takes the bare paths blobs served by the api were once
+// recorded with, so their files can still be deleted.
+func (l *Local) Key(url string) (string, bool) {
+	if key, ok := keyUnder(l.publicURL, url); ok {
+		return key, true
+	}
+	if l.servePath != "" && strings.HasPrefix(url, "/") {
+		return keyUnder(l.servePath, url)
+	}
+	return "", false
+}
@@ -0,0 +1,162 @@
+package blobs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 keeps blobs in a bucket of an S3 compatible service, like AWS S3 or
+// MinIO, addressing it by path so any endpoint works without DNS set up
+// per bucket. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	publicURL string
+	client    *http.Client
+}
+
+// NewS3 returns a storage keeping blobs in `bucket` at `endpoint`, like
+// "https://s3.eu-west-1.amazonaws.com". Blobs are served under
+// `publicURL`, a CDN for example, or straight from the bucket if it is
+// empty, which then has to allow anonymous reads. Every request has to
+// be answered within `timeout`.
+func NewS3(endpoint string, region string, bucket string, accessKey string, secretKey string, publicURL string, timeout time.Duration) (*S3, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("Invalid S3 endpoint '%v', must be an absolute http or https url", endpoint)
+	}
+	if publicURL == "" {
+		publicURL = parsed.String() + "/" + bucket
+	}
+	return &S3{
+		endpoint:  parsed,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *S3) Put(key string, contentType string, data []byte) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	if err := s.do(http.MethodPut, key, contentType, data, http.StatusOK); err != nil {
+		return "", fmt.Errorf("Failed to upload blob: %v", err)
+	}
+	return s.publicURL + "/" + key, nil
+}
+
+func (s *S3) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// S3 answers 204 whether or not there was anything to delete, some
+	// compatible services answer 404 when there was not
+	if err := s.do(http.MethodDelete, key, "", nil, http.StatusNoContent, http.StatusOK, http.StatusNotFound); err != nil {
+		return fmt.Errorf("Failed to delete blob: %v", err)
+	}
+	return nil
+}
+
+func (s *S3) Key(url string) (string, bool) {
+	return keyUnder(s.publicURL, url)
+}
+
+// do sends a signed request for the object under `key`, failing unless
+// the service answers with one of `expected`.
+func (s *S3) do(method string, key string, contentType string, body []byte, expected ...int) error {
+	target := *s.endpoint
+	// keys never need escaping, see checkKey
+	target.Path = s.endpoint.Path + "/" + s.bucket + "/" + key
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	// S3 explains errors in a short XML document
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 answered %v: %v", resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+// sign adds the headers of AWS Signature Version 4 to `req`, signing its
+// method, path, query, every header set so far and a hash of `body`.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%v:%v\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		// the query is not escaped the way S3 expects, but no request has one
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%v/%v/s3/aws4_request", now.Format("20060102"), s.region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{now.Format("20060102"), s.region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,503 @@
+// The config package loads the api's settings. Settings start out at
+// the defaults used for local development, are then read from an
+// optional YAML or TOML file, and finally overridden by environment
+// variables, so each deployment only has to set what is different.
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// every environment override starts with this prefix
+const EnvPrefix = "DEVBITS_"
+
+// the smallest signing key we accept outside of debug mode
+const MinTokenSecretLength = 32
+
+// the most times a webhook delivery can be tried
+const MaxWebhookAttempts = 20
+
+type Config struct {
+	Debug         bool           `yaml:"debug" toml:"debug"`
+	ListenAddress string         `yaml:"listen_address" toml:"listen_address"`
+	LogLevel      string         `yaml:"log_level" toml:"log_level"`
+	Database      DatabaseConfig `yaml:"database" toml:"database"`
+	CORS          CORSConfig     `yaml:"cors" toml:"cors"`
+	Auth          AuthConfig     `yaml:"auth" toml:"auth"`
+	Limits        Limits         `yaml:"limits" toml:"limits"`
+	Events        EventsConfig   `yaml:"events" toml:"events"`
+	Webhooks      WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	Mail          MailConfig     `yaml:"mail" toml:"mail"`
+	Storage       StorageConfig  `yaml:"storage" toml:"storage"`
+}
+
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" toml:"driver"`
+	DSN    string `yaml:"dsn" toml:"dsn"`
+	// apply pending migrations on startup instead of running `migrate up` by hand
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+	// how long deleted items are kept before they are purged for good,
+	// and how often the purge runs
+	PurgeAfter    Duration `yaml:"purge_after" toml:"purge_after"`
+	PurgeInterval Duration `yaml:"purge_interval" toml:"purge_interval"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+}
+
+type AuthConfig struct {
+	// when empty in debug mode a random key is generated on startup
+	TokenSecret     string   `yaml:"token_secret" toml:"token_secret"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+}
+
+// EventsConfig sets up the event streams of GET /events.
+type EventsConfig struct {
+	// how many of the latest events are kept for streams resuming with Last-Event-ID
+	History int `yaml:"history" toml:"history"`
+	// how often an idle stream sends a comment so proxies do not close it
+	KeepAlive Duration `yaml:"keepalive" toml:"keepalive"`
+}
+
+// WebhooksConfig sets up how the deliveries of project webhooks are sent.
+type WebhooksConfig struct {
+	// how many times a delivery is tried before it is given up on, and how
+	// long the first retry waits, every later one waiting twice as long
+	MaxAttempts  int      `yaml:"max_attempts" toml:"max_attempts"`
+	RetryBackoff Duration `yaml:"retry_backoff" toml:"retry_backoff"`
+	// how long a receiver has to answer a delivery
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// how often due retries are looked for
+	PollInterval Duration `yaml:"poll_interval" toml:"poll_interval"`
+	// let webhooks deliver to loopback and private network addresses,
+	// which is only safe when every project owner is trusted
+	AllowPrivateAddresses bool `yaml:"allow_private_addresses" toml:"allow_private_addresses"`
+}
+
+// MailConfig sets up how email, like notification digests, is sent.
+type MailConfig struct {
+	// log only logs every email, smtp sends them through the SMTP server
+	Driver string `yaml:"driver" toml:"driver"`
+	// the sender of every email, optionally with a name like "DevBits <no-reply@example.com>"
+	From         string   `yaml:"from" toml:"from"`
+	SMTPHost     string   `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     int      `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUsername string   `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string   `yaml:"smtp_password" toml:"smtp_password"`
+	SMTPTimeout  Duration `yaml:"smtp_timeout" toml:"smtp_timeout"`
+	// how often due digests are looked for, digests go out up to this late
+	DigestInterval Duration `yaml:"digest_interval" toml:"digest_interval"`
+	// the public url of the api, which confirmation and unsubscribe links
+	// point at, as do the urls of uploads the api serves itself
+	LinkURL string `yaml:"link_url" toml:"link_url"`
+}
+
+// StorageConfig sets up where uploads, like profile pictures and project
+// images, are kept.
+type StorageConfig struct {
+	// local keeps uploads in a directory, s3 in a bucket of an S3 compatible service
+	Driver    string `yaml:"driver" toml:"driver"`
+	Directory string `yaml:"directory" toml:"directory"`
+	// the URL uploads are served under. The api serves local uploads
+	// itself when it is a path, under /media when it is empty, at
+	// mail.link_url, and s3 uploads are served straight from the bucket
+	// when it is empty
+	PublicURL   string   `yaml:"public_url" toml:"public_url"`
+	S3Endpoint  string   `yaml:"s3_endpoint" toml:"s3_endpoint"`
+	S3Region    string   `yaml:"s3_region" toml:"s3_region"`
+	S3Bucket    string   `yaml:"s3_bucket" toml:"s3_bucket"`
+	S3AccessKey string   `yaml:"s3_access_key" toml:"s3_access_key"`
+	S3SecretKey string   `yaml:"s3_secret_key" toml:"s3_secret_key"`
+	S3Timeout   Duration `yaml:"s3_timeout" toml:"s3_timeout"`
+}
+
+// Limits caps what a single request is allowed to ask for or create.
+type Limits struct {
+	MaxFeedCount     int `yaml:"max_feed_count" toml:"max_feed_count"`
+	MaxPostLength    int `yaml:"max_post_length" toml:"max_post_length"`
+	MaxCommentLength int `yaml:"max_comment_length" toml:"max_comment_length"`
+	MaxThreadDepth   int `yaml:"max_thread_depth" toml:"max_thread_depth"`
+	// how long after posting a comment its author can still edit it,
+	// zero lets comments be edited at any time
+	CommentEditWindow Duration `yaml:"comment_edit_window" toml:"comment_edit_window"`
+	// how long after deleting something its owner can still restore it
+	RestoreWindow Duration `yaml:"restore_window" toml:"restore_window"`
+	// how many webhooks a project can have
+	MaxWebhooks int `yaml:"max_webhooks" toml:"max_webhooks"`
+	// the largest picture or image that can be uploaded, in bytes, and
+	// how many images a project can have
+	MaxUploadSize    int `yaml:"max_upload_size" toml:"max_upload_size"`
+	MaxProjectImages int `yaml:"max_project_images" toml:"max_project_images"`
+}
+
+// Duration is a time.Duration written as a string like "15m" or "720h"
+// in config files and environment variables.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Default returns the settings used for local development, which match
+// how the api ran before it could be configured.
+func Default() *Config {
+	return &Config{
+		Debug:         true,
+		ListenAddress: "localhost:8080",
+		LogLevel:      "info",
+		Database: DatabaseConfig{
+			Driver: "sqlite3",
+			DSN:    "./api/internal/database/dev.sqlite3",
+
+			PurgeAfter:    Duration{90 * 24 * time.Hour},
+			PurgeInterval: Duration{time.Hour},
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:8081"},
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  Duration{15 * time.Minute},
+			RefreshTokenTTL: Duration{30 * 24 * time.Hour},
+		},
+		Limits: Limits{
+			MaxFeedCount:      100,
+			MaxPostLength:     5000,
+			MaxCommentLength:  2000,
+			MaxThreadDepth:    10,
+			CommentEditWindow: Duration{2 * time.Minute},
+			RestoreWindow:     Duration{30 * 24 * time.Hour},
+			MaxWebhooks:       10,
+			MaxUploadSize:     5 << 20,
+			MaxProjectImages:  10,
+		},
+		Events: EventsConfig{
+			History:   1000,
+			KeepAlive: Duration{30 * time.Second},
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:  5,
+			RetryBackoff: Duration{30 * time.Second},
+			Timeout:      Duration{10 * time.Second},
+			PollInterval: Duration{10 * time.Second},
+		},
+		Mail: MailConfig{
+			Driver:         "log",
+			From:           "DevBits <no-reply@devbits.local>",
+			SMTPPort:       587,
+			SMTPTimeout:    Duration{10 * time.Second},
+			DigestInterval: Duration{time.Hour},
+			LinkURL:        "http://localhost:8080",
+		},
+		Storage: StorageConfig{
+			Driver:    "local",
+			Directory: "./uploads",
+			S3Region:  "us-east-1",
+			S3Timeout: Duration{30 * time.Second},
+		},
+	}
+}
+
+// Load builds the config from the defaults, the file at `path` and the
+// environment, in that order, and validates the result.
+//
+// Parameters:
+//   - path: The config file to read, either .yml/.yaml or .toml. An empty
+//     path skips the file and only applies environment overrides.
+//
+// Returns:
+//   - *Config: The validated config.
+//   - error: An error if the file cannot be read or parsed, an override
+//     is malformed, or the final config is invalid.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) readFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read config file '%v': %v", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(contents, cfg)
+	case ".toml":
+		err = toml.Unmarshal(contents, cfg)
+	default:
+		return fmt.Errorf("Unsupported config file type '%v', expected .yml, .yaml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to parse config file '%v': %v", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides settings with any DEVBITS_* variables that are set.
+func (cfg *Config) applyEnv() error {
+	stringFields := map[string]*string{
+		"LISTEN_ADDRESS":     &cfg.ListenAddress,
+		"LOG_LEVEL":          &cfg.LogLevel,
+		"DB_DRIVER":          &cfg.Database.Driver,
+		"DB_DSN":             &cfg.Database.DSN,
+		"TOKEN_SECRET":       &cfg.Auth.TokenSecret,
+		"MAIL_DRIVER":        &cfg.Mail.Driver,
+		"MAIL_FROM":          &cfg.Mail.From,
+		"SMTP_HOST":          &cfg.Mail.SMTPHost,
+		"SMTP_USERNAME":      &cfg.Mail.SMTPUsername,
+		"SMTP_PASSWORD":      &cfg.Mail.SMTPPassword,
+		"MAIL_LINK_URL":      &cfg.Mail.LinkURL,
+		"STORAGE_DRIVER":     &cfg.Storage.Driver,
+		"STORAGE_DIRECTORY":  &cfg.Storage.Directory,
+		"STORAGE_PUBLIC_URL": &cfg.Storage.PublicURL,
+		"S3_ENDPOINT":        &cfg.Storage.S3Endpoint,
+		"S3_REGION":          &cfg.Storage.S3Region,
+		"S3_BUCKET":          &cfg.Storage.S3Bucket,
+		"S3_ACCESS_KEY":      &cfg.Storage.S3AccessKey,
+		"S3_SECRET_KEY":      &cfg.Storage.S3SecretKey,
+	}
+	for name, field := range stringFields {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			*field = value
+		}
+	}
+
+	intFields := map[string]*int{
+		"MAX_FEED_COUNT":       &cfg.Limits.MaxFeedCount,
+		"MAX_POST_LENGTH":      &cfg.Limits.MaxPostLength,
+		"MAX_COMMENT_LENGTH":   &cfg.Limits.MaxCommentLength,
+		"MAX_THREAD_DEPTH":     &cfg.Limits.MaxThreadDepth,
+		"EVENT_HISTORY":        &cfg.Events.History,
+		"MAX_WEBHOOKS":         &cfg.Limits.MaxWebhooks,
+		"WEBHOOK_MAX_ATTEMPTS": &cfg.Webhooks.MaxAttempts,
+		"SMTP_PORT":            &cfg.Mail.SMTPPort,
+		"MAX_UPLOAD_SIZE":      &cfg.Limits.MaxUploadSize,
+		"MAX_PROJECT_IMAGES":   &cfg.Limits.MaxProjectImages,
+	}
+	for name, field := range intFields {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("Invalid %v%v: %v", EnvPrefix, name, err)
+			}
+			*field = parsed
+		}
+	}
+
+	durationFields := map[string]*Duration{
+		"ACCESS_TOKEN_TTL":      &cfg.Auth.AccessTokenTTL,
+		"REFRESH_TOKEN_TTL":     &cfg.Auth.RefreshTokenTTL,
+		"COMMENT_EDIT_WINDOW":   &cfg.Limits.CommentEditWindow,
+		"RESTORE_WINDOW":        &cfg.Limits.RestoreWindow,
+		"DB_PURGE_AFTER":        &cfg.Database.PurgeAfter,
+		"DB_PURGE_INTERVAL":     &cfg.Database.PurgeInterval,
+		"EVENT_KEEPALIVE":       &cfg.Events.KeepAlive,
+		"WEBHOOK_RETRY_BACKOFF": &cfg.Webhooks.RetryBackoff,
+		"WEBHOOK_TIMEOUT":       &cfg.Webhooks.Timeout,
+		"WEBHOOK_POLL_INTERVAL": &cfg.Webhooks.PollInterval,
+		"SMTP_TIMEOUT":          &cfg.Mail.SMTPTimeout,
+		"DIGEST_INTERVAL":       &cfg.Mail.DigestInterval,
+		"S3_TIMEOUT":            &cfg.Storage.S3Timeout,
+	}
+	for name, field := range durationFields {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			if err := field.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("Invalid %v%v: %v", EnvPrefix, name, err)
+			}
+		}
+	}
+
+	boolFields := map[string]*bool{
+		"DEBUG":                 &cfg.Debug,
+		"AUTO_MIGRATE":          &cfg.Database.AutoMigrate,
+		"WEBHOOK_ALLOW_PRIVATE": &cfg.Webhooks.AllowPrivateAddresses,
+	}
+	for name, field := range boolFields {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("Invalid %v%v: %v", EnvPrefix, name, err)
+			}
+			*field = parsed
+		}
+	}
+
+	// a comma separated list, since environment variables are flat
+	if value, ok := os.LookupEnv(EnvPrefix + "CORS_ORIGINS"); ok {
+		cfg.CORS.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.CORS.AllowedOrigins = append(cfg.CORS.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the config can actually be used to start the api.
+//
+// Returns:
+//   - error: An error describing the first problem found, nil otherwise.
+func (cfg *Config) Validate() error {
+	if cfg.ListenAddress == "" {
+		return fmt.Errorf("Invalid config: listen_address cannot be empty")
+	}
+
+	if _, err := logrus.ParseLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("Invalid config: log_level: %v", err)
+	}
+
+	if cfg.Database.Driver != "sqlite3" && cfg.Database.Driver != "postgres" {
+		return fmt.Errorf("Invalid config: database.driver '%v' is not supported, expected sqlite3 or postgres", cfg.Database.Driver)
+	}
+	if cfg.Database.DSN == "" {
+		return fmt.Errorf("Invalid config: database.dsn cannot be empty")
+	}
+
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		return fmt.Errorf("Invalid config: cors.allowed_origins needs at least one origin")
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("Invalid config: cors.allowed_origins entry '%v' is not an origin like http://host:port", origin)
+		}
+	}
+
+	if !cfg.Debug && len(cfg.Auth.TokenSecret) < MinTokenSecretLength {
+		return fmt.Errorf("Invalid config: auth.token_secret must be at least %v characters outside of debug mode", MinTokenSecretLength)
+	}
+	if cfg.Auth.AccessTokenTTL.Duration <= 0 || cfg.Auth.RefreshTokenTTL.Duration <= 0 {
+		return fmt.Errorf("Invalid config: auth token ttls must be positive")
+	}
+	if cfg.Auth.AccessTokenTTL.Duration >= cfg.Auth.RefreshTokenTTL.Duration {
+		return fmt.Errorf("Invalid config: auth.access_token_ttl must be shorter than auth.refresh_token_ttl")
+	}
+
+	if cfg.Limits.MaxFeedCount <= 0 || cfg.Limits.MaxPostLength <= 0 || cfg.Limits.MaxCommentLength <= 0 ||
+		cfg.Limits.MaxThreadDepth <= 0 || cfg.Limits.MaxWebhooks <= 0 || cfg.Limits.MaxUploadSize <= 0 ||
+		cfg.Limits.MaxProjectImages <= 0 {
+		return fmt.Errorf("Invalid config: limits must be positive")
+	}
+	if cfg.Limits.CommentEditWindow.Duration < 0 {
+		return fmt.Errorf("Invalid config: limits.comment_edit_window cannot be negative")
+	}
+	if cfg.Limits.RestoreWindow.Duration <= 0 {
+		return fmt.Errorf("Invalid config: limits.restore_window must be positive")
+	}
+	if cfg.Database.PurgeAfter.Duration <= 0 || cfg.Database.PurgeInterval.Duration <= 0 {
+		return fmt.Errorf("Invalid config: database.purge_after and database.purge_interval must be positive")
+	}
+	if cfg.Database.PurgeAfter.Duration < cfg.Limits.RestoreWindow.Duration {
+		return fmt.Errorf("Invalid config: database.purge_after cannot be shorter than limits.restore_window")
+	}
+	if cfg.Events.History <= 0 || cfg.Events.KeepAlive.Duration <= 0 {
+		return fmt.Errorf("Invalid config: events.history and events.keepalive must be positive")
+	}
+	// the backoff doubles per attempt, so too many attempts would wait forever
+	if cfg.Webhooks.MaxAttempts <= 0 || cfg.Webhooks.MaxAttempts > MaxWebhookAttempts {
+		return fmt.Errorf("Invalid config: webhooks.max_attempts must be between 1 and %v", MaxWebhookAttempts)
+	}
+	if cfg.Webhooks.RetryBackoff.Duration <= 0 || cfg.Webhooks.Timeout.Duration <= 0 || cfg.Webhooks.PollInterval.Duration <= 0 {
+		return fmt.Errorf("Invalid config: webhooks.retry_backoff, webhooks.timeout and webhooks.poll_interval must be positive")
+	}
+
+	if cfg.Mail.Driver != "log" && cfg.Mail.Driver != "smtp" {
+		return fmt.Errorf("Invalid config: mail.driver '%v' is not supported, expected log or smtp", cfg.Mail.Driver)
+	}
+	if _, err := mail.ParseAddress(cfg.Mail.From); err != nil {
+		return fmt.Errorf("Invalid config: mail.from: %v", err)
+	}
+	if cfg.Mail.Driver == "smtp" && cfg.Mail.SMTPHost == "" {
+		return fmt.Errorf("Invalid config: mail.smtp_host is required to send mail over smtp")
+	}
+	if cfg.Mail.SMTPPort <= 0 || cfg.Mail.SMTPPort > 65535 {
+		return fmt.Errorf("Invalid config: mail.smtp_port must be between 1 and 65535")
+	}
+	if cfg.Mail.SMTPTimeout.Duration <= 0 || cfg.Mail.DigestInterval.Duration <= 0 {
+		return fmt.Errorf("Invalid config: mail.smtp_timeout and mail.digest_interval must be positive")
+	}
+	if link, err := url.Parse(cfg.Mail.LinkURL); err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		return fmt.Errorf("Invalid config: mail.link_url '%v' must be an absolute url", cfg.Mail.LinkURL)
+	}
+
+	switch cfg.Storage.Driver {
+	case "local":
+		if cfg.Storage.Directory == "" {
+			return fmt.Errorf("Invalid config: storage.directory cannot be empty")
+		}
+		if cfg.Storage.PublicURL != "" && !isPathOrAbsoluteURL(cfg.Storage.PublicURL) {
+			return fmt.Errorf("Invalid config: storage.public_url '%v' must be a path like /media or an absolute url", cfg.Storage.PublicURL)
+		}
+	case "s3":
+		if endpoint, err := url.Parse(cfg.Storage.S3Endpoint); err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			return fmt.Errorf("Invalid config: storage.s3_endpoint '%v' must be an absolute url", cfg.Storage.S3Endpoint)
+		}
+		if cfg.Storage.S3Region == "" || cfg.Storage.S3Bucket == "" || cfg.Storage.S3AccessKey == "" || cfg.Storage.S3SecretKey == "" {
+			return fmt.Errorf("Invalid config: storage.s3_region, storage.s3_bucket, storage.s3_access_key and storage.s3_secret_key are required to store uploads in s3")
+		}
+		// nothing would serve a path, the api does not proxy the bucket
+		if cfg.Storage.PublicURL != "" && (!isPathOrAbsoluteURL(cfg.Storage.PublicURL) || strings.HasPrefix(cfg.Storage.PublicURL, "/")) {
+			return fmt.Errorf("Invalid config: storage.public_url '%v' must be an absolute url to store uploads in s3", cfg.Storage.PublicURL)
+		}
+		if cfg.Storage.S3Timeout.Duration <= 0 {
+			return fmt.Errorf("Invalid config: storage.s3_timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("Invalid config: storage.driver '%v' is not supported, expected local or s3", cfg.Storage.Driver)
+	}
+
+	return nil
+}
+
+// isPathOrAbsoluteURL reports whether `value` is an absolute path below / like
+// /media or an absolute URL like https://cdn.example.com/media.
+func isPathOrAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" && parsed.Host == "" {
+		return len(parsed.Path) > 1 && strings.HasPrefix(parsed.Path, "/") && !strings.HasPrefix(value, "//")
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// QueryUserStatus fetches the role and suspension state of a user.
+//
+// Parameters:
+//   - username: The username of the user.
+//
+// Returns:
+//   - *types.UserStatus: The user's role and when they were suspended, if they are.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the user does not exist or the query fails.
+func (db *Database) QueryUserStatus(username string) (*types.UserStatus, int, error) {
+	query := `SELECT username, role, suspension_date FROM Users WHERE username = ?;`
+
+	var status types.UserStatus
+	var suspensionDate sql.NullTime
+	err := db.QueryRow(query, username).Scan(&status.Username, &status.Role, &suspensionDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+		}
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to fetch user '%v': %v", username, err)
+	}
+
+	if suspensionDate.Valid {
+		status.SuspensionDate = &suspensionDate.Time
+	}
+	return &status, http.StatusOK, nil
+}
+
+// QuerySetUserRole changes the role of a user.
+//
+// Parameters:
+//   - username: The username of the user.
+//   - role: The new role, which has to be one of auth.ValidRoles.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the user does not exist or the update fails.
+func (db *Database) QuerySetUserRole(username string, role string) (int, error) {
+	rowsAffected, err := db.ExecUpdate(`UPDATE Users SET role = ? WHERE username = ?;`, role, username)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to update role for user '%v': %v", username, err)
+	}
+	if rowsAffected == 0 {
+		return http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+	}
+	return http.StatusOK, nil
+}
+
+// QuerySuspendUser suspends or reinstates a user. Suspending a user also
+// revokes all of their sessions so they are logged out everywhere.
+//
+// Parameters:
+//   - username: The username of the user.
+//   - suspend: True to suspend the user, false to lift the suspension.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the user does not exist or the update fails.
+func (db *Database) QuerySuspendUser(username string, suspend bool) (httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit suspension of user '%v': %v", username, err)
+		}
+	}()
+
+	currentTime := time.Now().UTC()
+	var suspensionDate interface{}
+	if suspend {
+		suspensionDate = currentTime
+	}
+
+	res, err := tx.Exec(`UPDATE Users SET suspension_date = ? WHERE username = ?;`, suspensionDate, username)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to update user '%v': %v", username, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
+	}
+	if rowsAffected == 0 {
+		return http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+	}
+
+	if suspend {
+		query := `UPDATE TokenFamilies SET revocation_date = ?
+                  WHERE user_id = (SELECT id FROM Users WHERE username = ?) AND revocation_date IS NULL;`
+		_, err = tx.Exec(query, currentTime, username)
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("Failed to revoke sessions for user '%v': %v", username, err)
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// QueryReassignProject hands a project over to a new owner.
+//
+// Parameters:
+//   - projectId: The id of the project.
+//   - newOwner: The id of the user that will own the project.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the project or new owner does not exist, or the update fails.
+func (db *Database) QueryReassignProject(projectId int64, newOwner int64) (int, error) {
+	username, err := db.GetUsernameById(newOwner)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to verify new owner: %v", err)
+	}
+	if username == "" {
+		return http.StatusBadRequest, fmt.Errorf("User with id '%v' not found", newOwner)
+	}
+
+	rowsAffected, err := db.ExecUpdate(`UPDATE Projects SET owner = ? WHERE id = ? AND deletion_date IS NULL;`, newOwner, projectId)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to reassign project with id '%v': %v", projectId, err)
+	}
+	if rowsAffected == 0 {
+		return http.StatusNotFound, fmt.Errorf("Project with id '%v' not found", projectId)
+	}
+	return http.StatusOK, nil
+}
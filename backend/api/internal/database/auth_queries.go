@@ -0,0 +1,390 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt only looks at the first 72 bytes of a password, so anything
+// longer would silently be truncated
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ValidatePassword checks that a password is usable before it gets hashed.
+//
+// Parameters:
+//   - password: The plain text password.
+//
+// Returns:
+//   - error: An error describing why the password is not allowed, nil otherwise.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("Password must be at least %v characters long", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("Password must be at most %v bytes long", MaxPasswordLength)
+	}
+	return nil
+}
+
+// a hash of a password nobody has, compared against when a login names
+// an unknown user so it takes as long as one with a wrong password
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not the password of any user"), bcrypt.DefaultCost)
+
+// HashPassword salts and hashes a password with bcrypt so it can be stored.
+//
+// Parameters:
+//   - password: The plain text password.
+//
+// Returns:
+//   - string: The bcrypt hash, which includes its own salt and cost.
+//   - error: An error if hashing fails.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+// QueryRegisterUser creates a new user along with their login info.
+// Both rows are written in a single transaction so a user never
+// exists without credentials or the other way around.
+//
+// Parameters:
+//   - registration: The user data and plain text password.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the registration fails.
+func (db *Database) QueryRegisterUser(registration *types.UserRegistration) (httpCode int, err error) {
+	if err := ValidatePassword(registration.Password); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// deleted users keep their username until they are purged
+	var taken bool
+	err = db.QueryRow(`SELECT EXISTS (SELECT 1 FROM Users WHERE username = ?)`, registration.Username).Scan(&taken)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to check for existing user: %v", err)
+	}
+	if taken {
+		return http.StatusConflict, fmt.Errorf("Username '%v' is already taken", registration.Username)
+	}
+
+	passwordHash, err := HashPassword(registration.Password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	linksJSON, err := json.Marshal(registration.Links)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Failed to marshal links for user '%v': %v", registration.Username, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit registration of user '%v': %v", registration.Username, err)
+		}
+	}()
+
+	query := `INSERT INTO Users (username, picture, bio, links, creation_date)
+	VALUES (?, ?, ?, ?, ?);`
+	_, err = tx.Exec(query, registration.Username, registration.Picture, registration.Bio, string(linksJSON), time.Now().UTC())
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to create user '%v': %v", registration.Username, err)
+	}
+
+	query = `INSERT INTO UserLoginInfo (username, password) VALUES (?, ?);`
+	_, err = tx.Exec(query, registration.Username, passwordHash)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to store login info for user '%v': %v", registration.Username, err)
+	}
+
+	return http.StatusCreated, nil
+}
+
+// QueryVerifyLogin checks a username and password against UserLoginInfo.
+// Unknown users and wrong passwords produce the same error, and take as
+// long to check, so callers cannot use the response or its timing to find
+// out which usernames exist.
+//
+// Parameters:
+//   - username: The username to log in as.
+//   - password: The plain text password to verify.
+//
+// Returns:
+//   - int64: The id of the user on success.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the credentials are invalid, the account is suspended or the query fails.
+func (db *Database) QueryVerifyLogin(username string, password string) (int64, int, error) {
+	query := `SELECT u.id, l.password, u.suspension_date IS NOT NULL
+              FROM UserLoginInfo l
+              JOIN Users u ON u.username = l.username
+              WHERE l.username = ? AND u.deletion_date IS NULL;`
+
+	var userId int64
+	var passwordHash string
+	var suspended bool
+	err := db.QueryRow(query, username).Scan(&userId, &passwordHash, &suspended)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			return -1, http.StatusUnauthorized, fmt.Errorf("Invalid username or password")
+		}
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to fetch login info: %v", err)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+	if err != nil {
+		return -1, http.StatusUnauthorized, fmt.Errorf("Invalid username or password")
+	}
+
+	// only checked once the password matched, so the response does not
+	// tell anyone without the password that the account is suspended
+	if suspended {
+		return -1, http.StatusForbidden, fmt.Errorf("Account '%v' is suspended", username)
+	}
+
+	return userId, http.StatusOK, nil
+}
+
+// QueryCreateTokenFamily starts a new refresh token family for a user.
+// A family is created on every login and every refresh token rotated
+// from that login belongs to it, so it doubles as the session id.
+//
+// Parameters:
+//   - userId: The id of the user logging in.
+//   - familyId: The random id of the new family.
+//
+// Returns:
+//   - error: An error if the family could not be created.
+func (db *Database) QueryCreateTokenFamily(userId int64, familyId string) error {
+	query := `INSERT INTO TokenFamilies (id, user_id, creation_date) VALUES (?, ?, ?);`
+	_, err := db.Exec(query, familyId, userId, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to create session: %v", err)
+	}
+	return nil
+}
+
+// QueryStoreRefreshToken stores the hash of a newly issued refresh token.
+//
+// Parameters:
+//   - familyId: The family the token belongs to.
+//   - tokenHash: The hash of the refresh token, the raw token is never stored.
+//   - expirationDate: When the token stops being accepted.
+//
+// Returns:
+//   - error: An error if the token could not be stored.
+func (db *Database) QueryStoreRefreshToken(familyId string, tokenHash string, expirationDate time.Time) error {
+	query := `INSERT INTO RefreshTokens (token_hash, family_id, creation_date, expiration_date)
+              VALUES (?, ?, ?, ?);`
+	_, err := db.Exec(query, tokenHash, familyId, time.Now().UTC(), expirationDate)
+	if err != nil {
+		return fmt.Errorf("Failed to store refresh token: %v", err)
+	}
+	return nil
+}
+
+// QueryUseRefreshToken marks a refresh token as used so it can be rotated.
+// Refresh tokens can only be used once. If a token that was already used
+// shows up again it has most likely been stolen, so the whole family is
+// revoked, logging out both the attacker and the legitimate client.
+//
+// Parameters:
+//   - tokenHash: The hash of the refresh token sent by the client.
+//
+// Returns:
+//   - int64: The id of the user the token belongs to.
+//   - string: The id of the family the token belongs to.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the token is unknown, expired, reused or revoked.
+func (db *Database) QueryUseRefreshToken(tokenHash string) (userId int64, familyId string, httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, "", http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	// only a failed query rolls back. Rejected tokens are committed on
+	// purpose, since revoking the family of a reused token has to stick
+	var queryErr error
+	defer func() {
+		if queryErr != nil {
+			tx.Rollback()
+		} else if commitErr := tx.Commit(); commitErr != nil {
+			userId, familyId, httpCode, err = -1, "", http.StatusInternalServerError, fmt.Errorf("Failed to commit refresh token use: %v", commitErr)
+		}
+	}()
+
+	query := `SELECT f.id, f.user_id, f.revocation_date, r.expiration_date, r.used_date
+              FROM RefreshTokens r
+              JOIN TokenFamilies f ON f.id = r.family_id
+              WHERE r.token_hash = ?;`
+
+	var revocationDate, usedDate sql.NullTime
+	var expirationDate time.Time
+	queryErr = tx.QueryRow(query, tokenHash).Scan(&familyId, &userId, &revocationDate, &expirationDate, &usedDate)
+	if queryErr == sql.ErrNoRows {
+		queryErr = nil
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Invalid refresh token")
+	}
+	if queryErr != nil {
+		return -1, "", http.StatusInternalServerError, fmt.Errorf("Failed to fetch refresh token: %v", queryErr)
+	}
+
+	currentTime := time.Now().UTC()
+	switch {
+	case revocationDate.Valid:
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Session has been revoked")
+	case !usedDate.Valid && currentTime.After(expirationDate):
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Refresh token has expired")
+	}
+
+	// the token is only marked as used if it was not already, so when two
+	// requests race with the same token exactly one of them rotates it and
+	// the other is treated as a reuse
+	res, queryErr := tx.Exec(`UPDATE RefreshTokens SET used_date = ? WHERE token_hash = ? AND used_date IS NULL;`, currentTime, tokenHash)
+	if queryErr != nil {
+		return -1, "", http.StatusInternalServerError, fmt.Errorf("Failed to rotate refresh token: %v", queryErr)
+	}
+	rowsAffected, queryErr := res.RowsAffected()
+	if queryErr != nil {
+		return -1, "", http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", queryErr)
+	}
+	if rowsAffected == 0 {
+		_, queryErr = tx.Exec(`UPDATE TokenFamilies SET revocation_date = ? WHERE id = ?;`, currentTime, familyId)
+		if queryErr != nil {
+			return -1, "", http.StatusInternalServerError, fmt.Errorf("Failed to revoke session: %v", queryErr)
+		}
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Refresh token has already been used, the session has been revoked")
+	}
+
+	return userId, familyId, http.StatusOK, nil
+}
+
+// QueryRevokeTokenFamily revokes a single session, which invalidates its
+// refresh tokens and every access token issued for it.
+//
+// Parameters:
+//   - familyId: The id of the family to revoke.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (db *Database) QueryRevokeTokenFamily(familyId string) error {
+	query := `UPDATE TokenFamilies SET revocation_date = ? WHERE id = ? AND revocation_date IS NULL;`
+	_, err := db.ExecUpdate(query, time.Now().UTC(), familyId)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke session: %v", err)
+	}
+	return nil
+}
+
+// QueryRevokeAllUserTokens revokes every session a user has open.
+//
+// Parameters:
+//   - userId: The id of the user.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (db *Database) QueryRevokeAllUserTokens(userId int64) error {
+	query := `UPDATE TokenFamilies SET revocation_date = ? WHERE user_id = ? AND revocation_date IS NULL;`
+	_, err := db.ExecUpdate(query, time.Now().UTC(), userId)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke sessions for user with id '%v': %v", userId, err)
+	}
+	return nil
+}
+
+// QuerySessionUser resolves a session to the user it belongs to. Access
+// tokens are checked against this so a revoked session stops working
+// right away instead of when its access tokens expire.
+//
+// Parameters:
+//   - familyId: The session id carried by the access token.
+//
+// Returns:
+//   - *types.Caller: The user the session belongs to.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the session is unknown, revoked or the query fails.
+func (db *Database) QuerySessionUser(familyId string) (*types.Caller, int, error) {
+	query := `SELECT u.id, u.username, u.role, u.suspension_date IS NOT NULL
+              FROM TokenFamilies f
+              JOIN Users u ON u.id = f.user_id
+              WHERE f.id = ? AND f.revocation_date IS NULL AND u.deletion_date IS NULL;`
+
+	var caller types.Caller
+	err := db.QueryRow(query, familyId).Scan(&caller.ID, &caller.Username, &caller.Role, &caller.Suspended)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, http.StatusUnauthorized, fmt.Errorf("Session has been revoked")
+		}
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to fetch session: %v", err)
+	}
+
+	return &caller, http.StatusOK, nil
+}
+
+// QueryChangePassword replaces a user's password and revokes all of
+// their sessions in the same transaction.
+//
+// Parameters:
+//   - userId: The id of the user.
+//   - username: The username of the user, which keys UserLoginInfo.
+//   - password: The new plain text password.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the password is not allowed or the update fails.
+func (db *Database) QueryChangePassword(userId int64, username string, password string) (httpCode int, err error) {
+	if err := ValidatePassword(password); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit password change for user '%v': %v", username, err)
+		}
+	}()
+
+	_, err = tx.Exec(`UPDATE UserLoginInfo SET password = ? WHERE username = ?;`, passwordHash, username)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to update password for user '%v': %v", username, err)
+	}
+
+	query := `UPDATE TokenFamilies SET revocation_date = ? WHERE user_id = ? AND revocation_date IS NULL;`
+	_, err = tx.Exec(query, time.Now().UTC(), userId)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to revoke sessions for user '%v': %v", username, err)
+	}
+
+	return http.StatusOK, nil
+}
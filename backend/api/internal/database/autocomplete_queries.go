@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+
+	"backend/api/internal/types"
+)
+
+// QueryUserFollowerCounts retrieves every username along with how many
+// users follow them, which is what the autocomplete index is built from.
+//
+// Returns:
+//   - []types.Suggestion: Every user, ordered by id.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryUserFollowerCounts() ([]types.Suggestion, int, error) {
+	query := `SELECT u.id, u.username, COUNT(uf.follower_id)
+              FROM Users u
+              LEFT JOIN UserFollows uf ON uf.follows_id = u.id
+              WHERE u.deletion_date IS NULL
+              GROUP BY u.id, u.username
+              ORDER BY u.id;`
+	return db.queryFollowerCounts(query)
+}
+
+// QueryProjectFollowerCounts retrieves every project name along with how
+// many users follow the project, which is what the autocomplete index is
+// built from.
+//
+// Returns:
+//   - []types.Suggestion: Every project, ordered by id.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryProjectFollowerCounts() ([]types.Suggestion, int, error) {
+	query := `SELECT p.id, p.name, COUNT(pf.user_id)
+              FROM Projects p
+              LEFT JOIN ProjectFollows pf ON pf.project_id = p.id
+              WHERE p.deletion_date IS NULL
+              GROUP BY p.id, p.name
+              ORDER BY p.id;`
+	return db.queryFollowerCounts(query)
+}
+
+func (db *Database) queryFollowerCounts(query string) ([]types.Suggestion, int, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to count followers: %v", err)
+	}
+	defer rows.Close()
+
+	suggestions := []types.Suggestion{}
+	for rows.Next() {
+		var suggestion types.Suggestion
+		if err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Followers); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan follower count: %v", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return suggestions, http.StatusOK, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"backend/api/internal/trending"
 	"backend/api/internal/types"
 )
 
@@ -18,9 +19,9 @@ import (
 // Returns:
 //   - *types.Comment: The comment details if found.
 //   - error: An error if the query fails. Returns nil for both if no comment exists.
-func QueryComment(id int) (*types.Comment, error) {
-	query := `SELECT id, user_id, content, likes, creation_date, parent_comment_id FROM Comments WHERE id = ?;`
-	row := DB.QueryRow(query, id)
+func (db *Database) QueryComment(id int) (*types.Comment, error) {
+	query := `SELECT id, user_id, content, likes, creation_date, parent_comment_id, edit_date, deletion_date FROM Comments WHERE id = ?;`
+	row := db.QueryRow(query, id)
 	var comment types.Comment
 
 	err := row.Scan(
@@ -30,6 +31,8 @@ func QueryComment(id int) (*types.Comment, error) {
 		&comment.Likes,
 		&comment.CreationDate,
 		&comment.ParentComment,
+		&comment.EditedAt,
+		&comment.DeletedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -38,9 +41,21 @@ func QueryComment(id int) (*types.Comment, error) {
 		return nil, err
 	}
 
+	HideDeletedComment(&comment)
 	return &comment, nil
 }
 
+// HideDeletedComment hides who wrote a deleted comment and what it said,
+// leaving its place in the thread. Other comments are left as they are.
+func HideDeletedComment(comment *types.Comment) {
+	if comment.DeletedAt == nil {
+		return
+	}
+	comment.User = -1
+	comment.Content = "This comment was deleted."
+	comment.EditedAt = nil
+}
+
 // QueryCommentsByUserId retrieves a set of comments by its owning user id from the database.
 //
 // Parameters:
@@ -49,7 +64,7 @@ func QueryComment(id int) (*types.Comment, error) {
 // Returns:
 //   - []types.Post: The post details if found.
 //   - error: An error if the query fails. Returns nil for both if no comments exists.
-func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
+func (db *Database) QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 	query := `
             SELECT 
                 c.id AS comment_id,
@@ -57,13 +72,16 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
                 c.content,
                 c.likes,
                 c.creation_date,
-                c.parent_comment_id
+                c.parent_comment_id,
+                c.edit_date,
+                c.deletion_date
             FROM Comments c
             JOIN PostComments pc ON c.id = pc.comment_id
-            WHERE c.user_id = ?;
+            WHERE c.user_id = ? AND c.deletion_date IS NULL
+            ORDER BY c.id;
     `
 
-	postRows, err := DB.Query(query, userId)
+	postRows, err := db.Query(query, userId)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -76,12 +94,15 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
                 c.content,
                 (SELECT COUNT(*) FROM CommentLikes cl WHERE cl.comment_id = c.id) AS likes,
                 c.creation_date,
-                c.parent_comment_id
+                c.parent_comment_id,
+                c.edit_date,
+                c.deletion_date
             FROM Comments c
             JOIN ProjectComments pc ON c.id = pc.comment_id
-            WHERE pc.project_id = ?;
+            WHERE pc.project_id = ? AND c.deletion_date IS NULL
+            ORDER BY c.id;
     `
-	projRows, err := DB.Query(query, userId)
+	projRows, err := db.Query(query, userId)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -97,6 +118,8 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 			&comment.Likes,
 			&comment.CreationDate,
 			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
 		)
 
 		if err != nil {
@@ -105,6 +128,7 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 			}
 			return nil, http.StatusInternalServerError, err
 		}
+		HideDeletedComment(&comment)
 		comments = append(comments, comment)
 	}
 	for postRows.Next() {
@@ -116,6 +140,8 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 			&comment.Likes,
 			&comment.CreationDate,
 			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
 		)
 
 		if err != nil {
@@ -124,6 +150,7 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 			}
 			return nil, http.StatusInternalServerError, err
 		}
+		HideDeletedComment(&comment)
 		comments = append(comments, comment)
 	}
 
@@ -138,7 +165,7 @@ func QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
 // Returns:
 //   - *types.Comment: The comment details if found.
 //   - error: An error if the query fails. Returns nil for both if no comment exists.
-func QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
+func (db *Database) QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
 	query := `
             SELECT 
                 c.id AS comment_id,
@@ -146,12 +173,15 @@ func QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
                 c.content,
                 c.likes,
                 c.creation_date,
-                c.parent_comment_id
+                c.parent_comment_id,
+                c.edit_date,
+                c.deletion_date
             FROM Comments c
             JOIN ProjectComments pc ON c.id = pc.comment_id
-            WHERE pc.project_id = ?;
+            WHERE pc.project_id = ?
+            ORDER BY c.id;
     `
-	rows, err := DB.Query(query, id)
+	rows, err := db.Query(query, id)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -167,6 +197,8 @@ func QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
 			&comment.Likes,
 			&comment.CreationDate,
 			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
 		)
 
 		if err != nil {
@@ -175,20 +207,23 @@ func QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
 			}
 			return nil, http.StatusInternalServerError, err
 		}
+		HideDeletedComment(&comment)
 		comments = append(comments, comment)
 	}
 	return comments, http.StatusOK, nil
 }
 
-// QueryCommentsByPostId retrieves a comment by its post ID from the database.
+// QueryCommentsByPostId retrieves a page of comments by their post ID from the database.
 //
 // Parameters:
 //   - id: The unique identifier of the post to query.
+//   - page: The cursor to continue after and the amount of comments to return.
 //
 // Returns:
 //   - *types.Comment: The comment details if found.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - error: An error if the query fails. Returns nil for both if no comment exists.
-func QueryCommentsByPostId(id int) ([]types.Comment, int, error) {
+func (db *Database) QueryCommentsByPostId(id int, page types.Page) ([]types.Comment, *types.Cursor, int, error) {
 	query := `
             SELECT 
                 c.id AS comment_id,
@@ -196,14 +231,18 @@ func QueryCommentsByPostId(id int) ([]types.Comment, int, error) {
                 c.content,
                 c.likes,
                 c.creation_date,
-                c.parent_comment_id
+                c.parent_comment_id,
+                c.edit_date,
+                c.deletion_date
             FROM Comments c
             JOIN PostComments pc ON c.id = pc.comment_id
-            WHERE pc.post_id = ?;
+            WHERE pc.post_id = ? AND c.id > ?
+            ORDER BY c.id
+            LIMIT ?;
     `
-	rows, err := DB.Query(query, id)
+	rows, err := db.Query(query, id, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 	var comments []types.Comment
@@ -217,17 +256,21 @@ func QueryCommentsByPostId(id int) ([]types.Comment, int, error) {
 			&comment.Likes,
 			&comment.CreationDate,
 			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
 		)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return nil, http.StatusOK, nil
+				return nil, nil, http.StatusOK, nil
 			}
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
+		HideDeletedComment(&comment)
 		comments = append(comments, comment)
 	}
-	return comments, http.StatusOK, nil
+	comments, next := CutPage(comments, page, commentCursor)
+	return comments, next, http.StatusOK, nil
 }
 
 // QueryCommentsByCommentId retrieves a comment by its comment ID from the database.
@@ -238,7 +281,7 @@ func QueryCommentsByPostId(id int) ([]types.Comment, int, error) {
 // Returns:
 //   - *types.Comment: The comment details if found.
 //   - error: An error if the query fails. Returns nil for both if no comment exists.
-func QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
+func (db *Database) QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
 	query := `
             SELECT 
                 c.id AS comment_id,
@@ -246,11 +289,14 @@ func QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
                 c.content,
                 c.likes,
                 c.creation_date,
-                c.parent_comment_id
+                c.parent_comment_id,
+                c.edit_date,
+                c.deletion_date
             FROM Comments c
-            WHERE c.parent_comment_id = ?;
+            WHERE c.parent_comment_id = ?
+            ORDER BY c.id;
     `
-	rows, err := DB.Query(query, id)
+	rows, err := db.Query(query, id)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -266,6 +312,8 @@ func QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
 			&comment.Likes,
 			&comment.CreationDate,
 			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
 		)
 
 		if err != nil {
@@ -274,6 +322,7 @@ func QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
 			}
 			return nil, http.StatusInternalServerError, err
 		}
+		HideDeletedComment(&comment)
 		comments = append(comments, comment)
 	}
 	return comments, http.StatusOK, nil
@@ -288,8 +337,8 @@ func QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
 // Returns:
 //   - int64: The ID of the newly created comment.
 //   - error: An error if the operation fails.
-func QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error) {
-	tx, err := DB.Begin()
+func (db *Database) QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -307,17 +356,11 @@ func QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error)
 	query := `INSERT INTO Comments (user_id, content, parent_comment_id, likes, creation_date) 
               VALUES (?, ?, ?, ?, ?);`
 
-	res, err := tx.Exec(query, comment.User, comment.Content, comment.ParentComment, 0, currentTime)
-
+	lastId, err := tx.InsertReturningId(query, comment.User, comment.Content, comment.ParentComment, 0, currentTime)
 	if err != nil {
 		return -1, fmt.Errorf("Failed to create comment: %v", err)
 	}
 
-	lastId, err := res.LastInsertId()
-	if err != nil {
-		return -1, fmt.Errorf("Failed to ensure post was created: %v", err)
-	}
-
 	query = `INSERT INTO PostComments (user_id, post_id, comment_id)
              VALUES (?, ?, ?)`
 
@@ -326,6 +369,21 @@ func QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error)
 		return -1, fmt.Errorf("Failed to link comment to post: %v", err)
 	}
 
+	err = addTrendingEvent(tx, "Posts", int64(postId), trending.Event{Weight: trending.CommentWeight, Time: currentTime})
+	if err != nil {
+		return -1, err
+	}
+
+	// a reply lets the author of the comment replied to know instead
+	if comment.ParentComment.Valid {
+		err = notify(tx, NotifyCommentReply, comment.User, comment.ParentComment.Int64, &lastId, currentTime)
+	} else {
+		err = notify(tx, NotifyPostComment, comment.User, int64(postId), &lastId, currentTime)
+	}
+	if err != nil {
+		return -1, err
+	}
+
 	return lastId, nil
 }
 
@@ -338,8 +396,8 @@ func QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error)
 // Returns:
 //   - int64: The ID of the newly created comment.
 //   - error: An error if the operation fails.
-func QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error) {
-	tx, err := DB.Begin()
+func (db *Database) QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -356,17 +414,11 @@ func QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, e
 	query := `INSERT INTO Comments (user_id, content, parent_comment_id, likes, creation_date) 
               VALUES (?, ?, ?, ?, ?);`
 
-	res, err := tx.Exec(query, comment.User, comment.Content, comment.ParentComment, 0, currentTime)
-
+	lastId, err := tx.InsertReturningId(query, comment.User, comment.Content, comment.ParentComment, 0, currentTime)
 	if err != nil {
 		return -1, fmt.Errorf("Failed to create comment: %v", err)
 	}
 
-	lastId, err := res.LastInsertId()
-	if err != nil {
-		return -1, fmt.Errorf("Failed to ensure project was created: %v", err)
-	}
-
 	query = `INSERT INTO ProjectComments (user_id, project_id, comment_id)
              VALUES (?, ?, ?)`
 
@@ -375,6 +427,21 @@ func QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, e
 		return -1, fmt.Errorf("Failed to link comment to project: %v", err)
 	}
 
+	err = addTrendingEvent(tx, "Projects", int64(projectId), trending.Event{Weight: trending.CommentWeight, Time: currentTime})
+	if err != nil {
+		return -1, err
+	}
+
+	// a reply lets the author of the comment replied to know instead
+	if comment.ParentComment.Valid {
+		err = notify(tx, NotifyCommentReply, comment.User, comment.ParentComment.Int64, &lastId, currentTime)
+	} else {
+		err = notify(tx, NotifyProjectComment, comment.User, int64(projectId), &lastId, currentTime)
+	}
+	if err != nil {
+		return -1, err
+	}
+
 	return lastId, nil
 }
 
@@ -387,38 +454,51 @@ func QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, e
 // Returns:
 //   - int64: The ID of the newly created comment.
 //   - error: An error if the operation fails.
-func QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error) {
+func (db *Database) QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
 	currentTime := time.Now().UTC()
 
 	query := `INSERT INTO Comments (user_id, content, parent_comment_id, likes, creation_date) 
               VALUES (?, ?, ?, ?, ?);`
 
-	res, err := DB.Exec(query, comment.User, comment.Content, commentId, 0, currentTime)
-
+	lastId, err := tx.InsertReturningId(query, comment.User, comment.Content, commentId, 0, currentTime)
 	if err != nil {
 		return -1, fmt.Errorf("Failed to create comment: %v", err)
 	}
 
-	lastId, err := res.LastInsertId()
+	err = notify(tx, NotifyCommentReply, comment.User, int64(commentId), &lastId, currentTime)
 	if err != nil {
-		return -1, fmt.Errorf("Failed to ensure comment was created: %v", err)
+		return -1, err
 	}
 
 	return lastId, nil
 }
 
-// QueryDeleteComment soft deletes a comment
+// QueryDeleteComment soft deletes a comment. It stays in its thread with
+// its author and content hidden until it is purged, so it can be restored.
 //
 // Parameters:
 //   - id: The id of the comment to be deleted
+//   - deletedBy: The id of the user deleting it
 //
 // Returns:
 //   - int16: http status code
 //   - error: An error if the operation fails.
-func QueryDeleteComment(id int) (int16, error) {
-	tx, err := DB.Begin()
+func (db *Database) QueryDeleteComment(id int, deletedBy int64) (int16, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+		return http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
 	}
 
 	defer func() {
@@ -429,46 +509,45 @@ func QueryDeleteComment(id int) (int16, error) {
 		}
 	}()
 
-	_, err = tx.Exec(`UPDATE PostComments SET user_id = -1 WHERE comment_id = ?`, id)
-	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to update PostComments for deleted comment: %v", err)
-	}
-
-	_, err = tx.Exec(`UPDATE ProjectComments SET user_id = -1 WHERE comment_id = ?`, id)
-	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to update ProjectComments for deleted comment: %v", err)
-	}
-
-	query := `UPDATE Comments SET user_id = -1, content = "This comment was deleted.", likes = 0, creation_date = ? WHERE id = ?`
-	res, err := tx.Exec(query, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), id)
+	deleted, err := tombstone(tx, "comment", int64(id), deletionTime(), deletedBy)
 	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("Failed to soft delete comment `%v`: %v", id, err)
+		return http.StatusInternalServerError, fmt.Errorf("Failed to soft delete comment `%v`: %v", id, err)
 	}
-
-	rowsAffected, err := res.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		return http.StatusNotFound, fmt.Errorf("Comment not found or already marked as deleted")
-	} else if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
 	}
 
 	return http.StatusOK, nil
 }
 
-// QueryUpdateCommentContent updates comment's content
+// QueryUpdateCommentContent updates comment's content, keeping what it said before
 //
 // Parameters:
 //   - id: The id of the comment to be updated
 //   - newContent: the updated content
+//   - editWindow: how long after posting the comment can be edited, zero for no limit
 //
 // Returns:
 //   - int16: http status code
 //   - error: An error if the operation fails.
-func QueryUpdateCommentContent(id int, newContent string) (int16, error) {
+func (db *Database) QueryUpdateCommentContent(id int, newContent string, editWindow time.Duration) (httpCode int16, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit update of comment %v: %v", id, err)
+		}
+	}()
+
 	// get comment creation time to validate time diff
 	var createdAt time.Time
-	query := `SELECT creation_date FROM Comments WHERE id = ?`
-	err := DB.QueryRow(query, id).Scan(&createdAt)
+	query := `SELECT creation_date FROM Comments WHERE id = ?` + tx.Dialect.ForUpdate()
+	err = tx.QueryRow(query, id).Scan(&createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, fmt.Errorf("Comment not found")
@@ -477,22 +556,22 @@ func QueryUpdateCommentContent(id int, newContent string) (int16, error) {
 	}
 
 	now := time.Now().UTC()
-	// now check if the comment is older than 2 minutes
-	if now.Sub(createdAt) > 2*time.Minute {
-		return http.StatusBadRequest, fmt.Errorf("Cannot update comment. More than 2 minutes have passed since posting.")
+	if !EditWindowOpen(createdAt, now, editWindow) {
+		err = EditWindowClosedError(editWindow)
+		return http.StatusBadRequest, err
 	}
 
-	query = `UPDATE Comments SET content = ? WHERE id = ?`
-	res, err := DB.Exec(query, newContent, id)
+	edited, err := saveRevision(tx, commentRevisions, id, newContent, now)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to update comment content: %v", err)
+		return http.StatusInternalServerError, err
+	}
+	if !edited {
+		return http.StatusOK, nil
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if rowsAffected == 0 {
-		return http.StatusNotFound, fmt.Errorf("Comment not found or no changes made")
-	} else if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
+	_, err = tx.Exec(`UPDATE Comments SET content = ?, edit_date = ? WHERE id = ?`, newContent, now, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to update comment content: %v", err)
 	}
 
 	return http.StatusOK, nil
@@ -507,9 +586,9 @@ func QueryUpdateCommentContent(id int, newContent string) (int16, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the comment.
-func CreateCommentLike(username string, strCommentId string) (int, error) {
+func (db *Database) CreateCommentLike(username string, strCommentId string) (int, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -521,7 +600,7 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 	}
 
 	// verify comment exists
-	_, err = QueryComment(commentId)
+	_, err = db.QueryComment(commentId)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred verifying the comment exists: %v", err)
 	}
@@ -531,7 +610,7 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM CommentLikes WHERE user_id = ? AND comment_id = ?
               )`
-	err = DB.QueryRow(query, user_id, commentId).Scan(&exists)
+	err = db.QueryRow(query, user_id, commentId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
@@ -539,7 +618,7 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 		// like already exists, but we return success to keep it idempotent
 		return http.StatusOK, nil
 	}
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -553,8 +632,9 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 	}()
 
 	// insert the like
-	insertQuery := `INSERT INTO CommentLikes (user_id, comment_id) VALUES (?, ?)`
-	_, err = tx.Exec(insertQuery, user_id, commentId)
+	currentTime := time.Now().UTC()
+	insertQuery := `INSERT INTO CommentLikes (user_id, comment_id, creation_date) VALUES (?, ?, ?)`
+	_, err = tx.Exec(insertQuery, user_id, commentId, currentTime)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to insert comment like: %v", err)
 	}
@@ -566,6 +646,11 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to update likes count: %v", err)
 	}
 
+	err = notify(tx, NotifyCommentLike, int64(user_id), int64(commentId), nil, currentTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusCreated, nil
 }
 
@@ -578,9 +663,9 @@ func CreateCommentLike(username string, strCommentId string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the comment.
-func RemoveCommentLike(username string, strCommentId string) (int, error) {
+func (db *Database) RemoveCommentLike(username string, strCommentId string) (int, error) {
 	// get user ID
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -591,13 +676,13 @@ func RemoveCommentLike(username string, strCommentId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred parsing username id: %v", err)
 	}
 
-	// verify post exists
-	_, err = QueryPost(commentId)
+	// verify comment exists
+	_, err = db.QueryComment(commentId)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred verifying the comment exists: %v", err)
 	}
 
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -648,9 +733,9 @@ func RemoveCommentLike(username string, strCommentId string) (int, error) {
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - bool: is the comment liked
 //   - error: An error if the operation fails or.
-func QueryCommentLike(username string, strCommId string) (int, bool, error) {
+func (db *Database) QueryCommentLike(username string, strCommId string) (int, bool, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -662,7 +747,7 @@ func QueryCommentLike(username string, strCommId string) (int, bool, error) {
 	}
 
 	// verify post exists
-	_, err = QueryComment(commId)
+	_, err = db.QueryComment(commId)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred verifying the comment exists: %v", err)
 	}
@@ -672,7 +757,7 @@ func QueryCommentLike(username string, strCommId string) (int, bool, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM CommentLikes WHERE user_id = ? AND comment_id = ?
               )`
-	err = DB.QueryRow(query, user_id, commId).Scan(&exists)
+	err = db.QueryRow(query, user_id, commId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
@@ -688,13 +773,14 @@ func QueryCommentLike(username string, strCommId string) (int, bool, error) {
 //
 // Parameters:
 //   - commentID: The ID of the comment
+//   - editWindow: how long after posting the comment can be edited, zero for no limit
 //
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - bool: if comment is still editable
 //   - error: An error if the operation fails or.
 
-func QueryIsCommentEditable(strCommId string) (int, bool, error) {
+func (db *Database) QueryIsCommentEditable(strCommId string, editWindow time.Duration) (int, bool, error) {
 	commId, err := strconv.Atoi(strCommId)
 	if err != nil {
 		return http.StatusInternalServerError, false, err
@@ -702,7 +788,7 @@ func QueryIsCommentEditable(strCommId string) (int, bool, error) {
 
 	var createdAt time.Time
 	query := `SELECT creation_date FROM Comments WHERE id = ?`
-	err = DB.QueryRow(query, commId).Scan(&createdAt)
+	err = db.QueryRow(query, commId).Scan(&createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return http.StatusNotFound, false, fmt.Errorf("Comment not found")
@@ -710,11 +796,5 @@ func QueryIsCommentEditable(strCommId string) (int, bool, error) {
 		return http.StatusInternalServerError, false, fmt.Errorf("Failed to fetch comment creation date: %v", err)
 	}
 
-	now := time.Now().UTC()
-	// now check if the comment is older than 2 minutes
-	if now.Sub(createdAt) > 2*time.Minute {
-		return http.StatusOK, false, nil
-	} else {
-		return http.StatusOK, true, nil
-	}
+	return http.StatusOK, EditWindowOpen(createdAt, time.Now().UTC(), editWindow), nil
 }
@@ -6,6 +6,21 @@ INSERT INTO Users (username, picture, bio, links, creation_date) VALUES
     ('backend_guru4', 'https://example.com/backend_guru4.jpg', 'Backend expert specializing in scalable systems.', '["https://github.com/backend_guru4"]', '2024-01-15 00:00:00'),
     ('ui_designer5', 'https://example.com/ui_designer5.jpg', 'UI/UX designer with a love for user-friendly apps.', '["https://portfolio.uidesigner5.com"]', '2023-05-10 00:00:00');
 
+-- Staff accounts
+INSERT INTO Users (username, picture, bio, links, creation_date, role) VALUES
+    ('site_admin6', 'https://example.com/site_admin6.jpg', 'Keeps the lights on.', '[]', '2022-01-01 00:00:00', 'admin'),
+    ('moderator7', 'https://example.com/moderator7.jpg', 'Community moderator.', '[]', '2022-06-01 00:00:00', 'moderator');
+
+-- UserLoginInfo (every test user's password is 'password123')
+INSERT INTO UserLoginInfo (username, password) VALUES
+    ('site_admin6', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('moderator7', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('dev_user1', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('tech_writer2', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('data_scientist3', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('backend_guru4', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK'),
+    ('ui_designer5', '$2a$10$468A.xKlx4BryxEfDKCyeezqJM8q2lCfUFAfOS79jM0jWL8wo1VyK');
+
 -- Projects
 INSERT INTO Projects (name, description, status, likes, tags, links, owner, creation_date) VALUES
     ('OpenAPI Toolkit', 'A toolkit for generating and testing OpenAPI specs.', 1, 120, '["OpenAPI", "Go", "Tooling"]', '["https://github.com/dev_user1/openapi-toolkit"]', (SELECT id FROM Users WHERE username = 'dev_user1'), '2023-06-13 00:00:00'),
@@ -13,6 +28,20 @@ INSERT INTO Projects (name, description, status, likes, tags, links, owner, crea
     ('ML Research', 'Research repository for various machine learning algorithms.', 1, 45, '["Machine Learning", "Python", "Research"]', '["https://github.com/data_scientist3/ml-research"]', (SELECT id FROM Users WHERE username = 'data_scientist3'), '2024-09-13 00:00:00'),
     ('ScaleDB', 'A scalable database system for modern apps.', 1, 70, '["Database", "Scalability", "Backend"]', '["https://github.com/backend_guru4/scaledb"]', (SELECT id FROM Users WHERE username = 'backend_guru4'), '2024-03-15 00:00:00');
 
+-- Project Tags (the tags of the projects above, one row per tag)
+INSERT INTO ProjectTags (project_id, tag) VALUES
+    ((SELECT id FROM Projects WHERE name = 'OpenAPI Toolkit'), 'OpenAPI'),
+    ((SELECT id FROM Projects WHERE name = 'OpenAPI Toolkit'), 'Go'),
+    ((SELECT id FROM Projects WHERE name = 'OpenAPI Toolkit'), 'Tooling'),
+    ((SELECT id FROM Projects WHERE name = 'DocuHelper'), 'Documentation'),
+    ((SELECT id FROM Projects WHERE name = 'DocuHelper'), 'Python'),
+    ((SELECT id FROM Projects WHERE name = 'ML Research'), 'Machine Learning'),
+    ((SELECT id FROM Projects WHERE name = 'ML Research'), 'Python'),
+    ((SELECT id FROM Projects WHERE name = 'ML Research'), 'Research'),
+    ((SELECT id FROM Projects WHERE name = 'ScaleDB'), 'Database'),
+    ((SELECT id FROM Projects WHERE name = 'ScaleDB'), 'Scalability'),
+    ((SELECT id FROM Projects WHERE name = 'ScaleDB'), 'Backend');
+
 -- Posts
 INSERT INTO Posts (content, project_id, creation_date, user_id, likes) VALUES
     ('Excited to release the first version of OpenAPI Toolkit!', (SELECT id FROM Projects WHERE name = 'OpenAPI Toolkit'), '2024-09-13 00:00:00', (SELECT id FROM Users WHERE username = 'dev_user1'), 40),
@@ -1,25 +1,24 @@
 package database
 
 import (
-	"database/sql"
 	"log"
 )
 
-var DB *sql.DB // Global database instance
-
-// Connect initializes a database connection
-func Connect(dsn string, driverName string) {
-	var err error
-	DB, err = sql.Open(driverName, dsn)
+// Connect opens and pings the database, exiting if it cannot be reached.
+// Every query in this package is a method on the returned *Database, so
+// any number of databases can be open in the same process.
+func Connect(dsn string, driverName string) *Database {
+	db, err := Open(driverName, dsn)
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 
 	// Verify connection
-	err = DB.Ping()
+	err = db.Ping()
 	if err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 
-	log.Println("Database connected successfully")
+	log.Printf("Database connected successfully (%v)", db.Dialect)
+	return db
 }
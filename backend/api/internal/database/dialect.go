@@ -0,0 +1,188 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect is the flavour of SQL spoken by the database behind a driver.
+// Queries in this package are written once, with `?` placeholders, and
+// the dialect rewrites them for databases that expect something else.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// Dialects lists every supported dialect, named after its database/sql driver.
+var Dialects = []Dialect{SQLite, Postgres}
+
+// DialectFor returns the dialect used by a database/sql driver.
+//
+// Parameters:
+//   - driverName: The driver name passed to sql.Open.
+//
+// Returns:
+//   - Dialect: The matching dialect.
+//   - error: An error if the driver is not supported.
+func DialectFor(driverName string) (Dialect, error) {
+	for _, dialect := range Dialects {
+		if string(dialect) == driverName {
+			return dialect, nil
+		}
+	}
+	return "", fmt.Errorf("Unsupported database driver '%v', expected sqlite3 or postgres", driverName)
+}
+
+// Rebind rewrites the `?` placeholders in a query to the style the
+// dialect expects. SQLite queries are returned untouched, Postgres ones
+// are numbered $1, $2, ... Question marks inside string literals and
+// quoted identifiers are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var rebound strings.Builder
+	rebound.Grow(len(query) + 8)
+
+	var quote rune
+	placeholder := 0
+	for _, char := range query {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '?':
+			placeholder++
+			rebound.WriteString("$" + strconv.Itoa(placeholder))
+			continue
+		}
+		rebound.WriteRune(char)
+	}
+	return rebound.String()
+}
+
+// Time wraps a timestamp column or placeholder so it compares and sorts by
+// time. SQLite stores timestamps as text, in whichever format they were
+// written with, so there they are compared as julian days instead.
+func (d Dialect) Time(expr string) string {
+	if d == SQLite {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// insertReturningId runs an INSERT and returns the id of the new row. SQLite
+// reports it through LastInsertId, which Postgres drivers do not support, so
+// there the query asks for it with RETURNING id instead.
+func insertReturningId(d Dialect, exec func(string, ...interface{}) (sql.Result, error),
+	queryRow func(string, ...interface{}) *sql.Row, query string, args ...interface{}) (int64, error) {
+	if d == Postgres {
+		var id int64
+		query = strings.TrimSuffix(strings.TrimSpace(query), ";") + " RETURNING id;"
+		err := queryRow(query, args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Database is a connection pool that rewrites queries for its dialect, so
+// query functions can use it exactly like a *sql.DB.
+type Database struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Open opens a database with the given driver and picks the matching dialect.
+//
+// Parameters:
+//   - driverName: The database/sql driver, sqlite3 or postgres.
+//   - dsn: The data source name passed to the driver.
+//
+// Returns:
+//   - *Database: The opened database, not yet pinged.
+//   - error: An error if the driver is unsupported or cannot be opened.
+func Open(driverName string, dsn string) (*Database, error) {
+	dialect, err := DialectFor(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{DB: db, Dialect: dialect}, nil
+}
+
+func (db *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.Rebind(query), args...)
+}
+
+func (db *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.Rebind(query), args...)
+}
+
+func (db *Database) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.Rebind(query), args...)
+}
+
+func (db *Database) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: db.Dialect}, nil
+}
+
+// InsertReturningId runs an INSERT into a table with an `id` column and
+// returns the id of the new row.
+func (db *Database) InsertReturningId(query string, args ...interface{}) (int64, error) {
+	return insertReturningId(db.Dialect, db.Exec, db.QueryRow, query, args...)
+}
+
+// Tx is a transaction that rewrites queries for its dialect.
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.Dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.Dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.Dialect.Rebind(query), args...)
+}
+
+// InsertReturningId runs an INSERT into a table with an `id` column and
+// returns the id of the new row.
+func (tx *Tx) InsertReturningId(query string, args ...interface{}) (int64, error) {
+	return insertReturningId(tx.Dialect, tx.Exec, tx.QueryRow, query, args...)
+}
+
+// ForUpdate is appended to a SELECT whose rows the transaction is about to
+// update, so no other transaction changes them in between. SQLite only
+// lets one transaction write at a time, so it needs nothing.
+func (d Dialect) ForUpdate() string {
+	if d == Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// how many groups of notifications a digest lists besides its counts
+const DigestHighlights = 5
+
+// how long a user has to wait before another confirmation link is sent
+const ConfirmationResendInterval = 10 * time.Minute
+
+// AddToDigest counts notifications of a kind towards the follower, like
+// or reply count of a digest.
+//
+// Parameters:
+//   - digest: The digest to count them in.
+//   - kind: The kind of the notifications, one of the Notify constants.
+//   - count: How many notifications of the kind there are.
+func AddToDigest(digest *types.Digest, kind string, count int64) {
+	switch kind {
+	case NotifyUserFollow, NotifyProjectFollow:
+		digest.Followers += count
+	case NotifyPostLike, NotifyProjectLike, NotifyCommentLike:
+		digest.Likes += count
+	case NotifyPostComment, NotifyProjectComment, NotifyCommentReply:
+		digest.Replies += count
+	}
+}
+
+// QueryEmailPreferences retrieves where a user is emailed, how often and
+// whether they confirmed the address. Users who never set them get no digests.
+//
+// Parameters:
+//   - userId: The id of the user.
+//
+// Returns:
+//   - types.EmailPreferences: The user's preferences.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryEmailPreferences(userId int64) (types.EmailPreferences, int, error) {
+	preferences := types.EmailPreferences{Digest: types.DigestOff}
+	err := db.QueryRow(`SELECT email, digest, confirmed_date IS NOT NULL FROM EmailPreferences WHERE user_id = ?`, userId).
+		Scan(&preferences.Email, &preferences.Digest, &preferences.Confirmed)
+	if err != nil && err != sql.ErrNoRows {
+		return preferences, http.StatusInternalServerError, fmt.Errorf("Failed to query email preferences: %v", err)
+	}
+	return preferences, http.StatusOK, nil
+}
+
+// QuerySetEmailPreferences sets where a user is emailed and how often.
+// Turning digests on starts their schedule from `now`, so the first digest
+// comes a day or week later and leaves out what the user was notified of
+// before. A new address, or one that is not confirmed yet, has to be
+// confirmed with the token hashed to `confirmationHash`, which replaces
+// any sent before. Links are sent at most once per
+// ConfirmationResendInterval, so the preferences cannot be used to flood
+// someone else's inbox.
+//
+// Parameters:
+//   - userId: The id of the user.
+//   - preferences: The email address and digest frequency.
+//   - confirmationHash: The hash of the token that would confirm the address.
+//   - now: The current time.
+//
+// Returns:
+//   - bool: Whether the token has to be sent to the address to confirm it.
+//   - int: HTTP status code.
+//   - error: An error if another address was sent a link too recently, or the preferences cannot be saved.
+func (db *Database) QuerySetEmailPreferences(userId int64, preferences types.EmailPreferences, confirmationHash string, now time.Time) (sendLink bool, httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			sendLink, httpCode, err = false, http.StatusInternalServerError, fmt.Errorf("Failed to commit email preferences: %v", err)
+		}
+	}()
+
+	var previous, previousEmail string
+	var confirmed bool
+	var linkSent sql.NullTime
+	err = tx.QueryRow(`SELECT digest, email, confirmed_date IS NOT NULL, confirmation_date FROM EmailPreferences WHERE user_id = ?`, userId).
+		Scan(&previous, &previousEmail, &confirmed, &linkSent)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return false, http.StatusInternalServerError, fmt.Errorf("Failed to query email preferences: %v", err)
+	}
+	sameEmail := exists && previousEmail == preferences.Email
+	sendLink = !(sameEmail && confirmed)
+	if sendLink && linkSent.Valid && linkSent.Time.After(now.Add(-ConfirmationResendInterval)) {
+		// the link already sent to the address still confirms it
+		if !sameEmail {
+			err = fmt.Errorf("A confirmation link was sent less than %v ago, try again later", ConfirmationResendInterval)
+			return false, http.StatusTooManyRequests, err
+		}
+		sendLink = false
+	}
+
+	var latest int64
+	err = tx.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM Notifications WHERE user_id = ?`, userId).Scan(&latest)
+	if err != nil {
+		return false, http.StatusInternalServerError, fmt.Errorf("Failed to query notifications: %v", err)
+	}
+
+	switch {
+	case !exists:
+		_, err = tx.Exec(`INSERT INTO EmailPreferences (user_id, email, digest, last_digest_date, last_notification)
+                          VALUES (?, ?, ?, ?, ?)`, userId, preferences.Email, preferences.Digest, now, latest)
+	case previous == types.DigestOff && preferences.Digest != types.DigestOff:
+		_, err = tx.Exec(`UPDATE EmailPreferences SET email = ?, digest = ?, last_digest_date = ?, last_notification = ?
+                          WHERE user_id = ?`, preferences.Email, preferences.Digest, now, latest, userId)
+	default:
+		_, err = tx.Exec(`UPDATE EmailPreferences SET email = ?, digest = ? WHERE user_id = ?`,
+			preferences.Email, preferences.Digest, userId)
+	}
+	if err == nil && sendLink {
+		_, err = tx.Exec(`UPDATE EmailPreferences SET confirmation_hash = ?, confirmation_date = ?, confirmed_date = NULL
+                          WHERE user_id = ?`, confirmationHash, now, userId)
+	}
+	if err != nil {
+		return false, http.StatusInternalServerError, fmt.Errorf("Failed to save email preferences: %v", err)
+	}
+	return sendLink, http.StatusOK, nil
+}
+
+// QueryConfirmEmail confirms the address a confirmation token was sent to.
+// Each token only works once, and only until the address is set again.
+//
+// Parameters:
+//   - confirmationHash: The hash of the token from the confirmation link.
+//   - now: The current time.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if no address is waiting for the token or it cannot be confirmed.
+func (db *Database) QueryConfirmEmail(confirmationHash string, now time.Time) (int, error) {
+	result, err := db.Exec(`UPDATE EmailPreferences SET confirmed_date = ?, confirmation_hash = NULL
+                            WHERE confirmation_hash = ?`, now, confirmationHash)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to confirm email address: %v", err)
+	}
+	confirmed, err := result.RowsAffected()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to confirm email address: %v", err)
+	}
+	if confirmed == 0 {
+		return http.StatusNotFound, fmt.Errorf("The confirmation link is invalid or was already used")
+	}
+	return http.StatusOK, nil
+}
+
+// QueryDueDigests retrieves the digests of users who get them at
+// `frequency`, at a confirmed address, and whose last one was due at or
+// before `sentBefore`. Each
+// summarizes the unread notifications that came after the last digest,
+// leaving out what the user already saw in the app.
+//
+// Parameters:
+//   - frequency: The digest frequency, daily or weekly.
+//   - sentBefore: The time the last digest must have been due by, now minus the frequency.
+//   - limit: The most digests to retrieve.
+//
+// Returns:
+//   - []types.Digest: The due digests, some of which may have nothing in them.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryDueDigests(frequency string, sentBefore time.Time, limit int) ([]types.Digest, int, error) {
+	query := fmt.Sprintf(`SELECT p.user_id, u.username, p.email, p.last_notification
+              FROM EmailPreferences p
+              JOIN Users u ON u.id = p.user_id
+              WHERE p.digest = ? AND p.confirmed_date IS NOT NULL AND u.deletion_date IS NULL
+                AND (p.last_digest_date IS NULL OR %v <= %v)
+              ORDER BY p.user_id
+              LIMIT ?`, db.Dialect.Time("p.last_digest_date"), db.Dialect.Time("?"))
+
+	rows, err := db.Query(query, frequency, sentBefore, limit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query due digests: %v", err)
+	}
+	digests := []types.Digest{}
+	for rows.Next() {
+		var digest types.Digest
+		if err = rows.Scan(&digest.User, &digest.Username, &digest.Email, &digest.Since); err != nil {
+			rows.Close()
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan digest: %v", err)
+		}
+		digest.Through = digest.Since
+		digests = append(digests, digest)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read due digests: %v", err)
+	}
+
+	for i := range digests {
+		if err = db.summarizeDigest(&digests[i]); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	return digests, http.StatusOK, nil
+}
+
+// the unread notifications of a user since their last digest
+const digestNotifications = `user_id = ? AND id > ? AND read_date IS NULL AND ` + activeActors
+
+// summarizeDigest counts and lists the notifications a digest covers.
+func (db *Database) summarizeDigest(digest *types.Digest) error {
+	rows, err := db.Query(`SELECT kind, COUNT(*), MAX(id) FROM Notifications
+                           WHERE `+digestNotifications+` GROUP BY kind`, digest.User, digest.Since)
+	if err != nil {
+		return fmt.Errorf("Failed to count digest notifications: %v", err)
+	}
+	for rows.Next() {
+		var kind string
+		var count, latest int64
+		if err = rows.Scan(&kind, &count, &latest); err != nil {
+			rows.Close()
+			return fmt.Errorf("Failed to scan digest count: %v", err)
+		}
+		AddToDigest(digest, kind, count)
+		digest.Through = max(digest.Through, latest)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("Failed to read digest counts: %v", err)
+	}
+
+	query := `SELECT g.latest, g.kind, g.target_id, g.actors, n.comment_id, u.username, n.creation_date
+              FROM (
+                  SELECT kind, target_id, MAX(id) AS latest, COUNT(DISTINCT actor_id) AS actors
+                  FROM Notifications
+                  WHERE ` + digestNotifications + `
+                  GROUP BY kind, target_id
+              ) g
+              JOIN Notifications n ON n.id = g.latest
+              JOIN Users u ON u.id = n.actor_id
+              ORDER BY g.latest DESC
+              LIMIT ?`
+	rows, err = db.Query(query, digest.User, digest.Since, DigestHighlights)
+	if err != nil {
+		return fmt.Errorf("Failed to query digest notifications: %v", err)
+	}
+	defer rows.Close()
+
+	digest.Highlights = []types.Notification{}
+	for rows.Next() {
+		var notification types.Notification
+		var comment sql.NullInt64
+		err = rows.Scan(&notification.ID, &notification.Kind, &notification.Target, &notification.Actors,
+			&comment, &notification.Actor, &notification.CreationDate)
+		if err != nil {
+			return fmt.Errorf("Failed to scan digest notification: %v", err)
+		}
+		if comment.Valid {
+			notification.Comment = &comment.Int64
+		}
+		notification.Message = NotificationMessage(notification.Kind, notification.Actor, notification.Actors)
+		digest.Highlights = append(digest.Highlights, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("Failed to read digest notifications: %v", err)
+	}
+	return nil
+}
+
+// QueryMarkDigestSent records that a user's digest was due at `now`, and
+// that it covered the notifications up to `through`. A digest that could
+// not be sent is marked with its Since instead, so what it had in it is
+// carried over to the next one.
+//
+// Parameters:
+//   - userId: The id of the user.
+//   - through: The latest notification covered.
+//   - now: When the digest was due.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the digest cannot be marked.
+func (db *Database) QueryMarkDigestSent(userId int64, through int64, now time.Time) (int, error) {
+	_, err := db.Exec(`UPDATE EmailPreferences SET last_digest_date = ?, last_notification = ? WHERE user_id = ?`,
+		now, through, userId)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to mark digest sent: %v", err)
+	}
+	return http.StatusOK, nil
+}
@@ -2,84 +2,120 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 
 	"backend/api/internal/types"
 )
 
-// GetPostByTimeFeed retrieves a set of posts for the feed given a type
-// it also paginates the results, sorted by most recent
+// GetPostByTimeFeed retrieves a page of posts for the feed,
+// sorted by most recent
 //
 // Parameters:
-//   - start: the int id to start at
-//   - count: the amount of posts to return
+//   - page: the cursor to continue after and the amount of posts to return
 //
 // Returns:
 //   - []types.Post: the list of posts for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: http status code
 //   - error: An error if the function fails, nil otherwise
-func GetPostByTimeFeed(start int, count int) ([]types.Post, int, error) {
-	query := `SELECT id, user_id, project_id, content, likes, creation_date
+func (db *Database) GetPostByTimeFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := fmt.Sprintf(`SELECT id, user_id, project_id, content, likes, creation_date, edit_date, trending_score
               FROM Posts
-              ORDER BY creation_date DESC
-              LIMIT ? OFFSET ?;`
+              WHERE deletion_date IS NULL AND (%[1]v < %[2]v OR (%[1]v = %[2]v AND id < ?))
+              ORDER BY %[1]v DESC, id DESC
+              LIMIT ?;`, db.Dialect.Time("creation_date"), db.Dialect.Time("?"))
 
-	rows, err := DB.Query(query, count, start)
-	if err != nil {
-		return nil, http.StatusNotFound, err
-	}
-	defer rows.Close()
-	var posts []types.Post
+	return db.queryPostFeed(page, query, after.Time, after.Time, after.ID, fetchLimit(page))
+}
 
-	for rows.Next() {
-		var post types.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.User,
-			&post.Project,
-			&post.Content,
-			&post.Likes,
-			&post.CreationDate,
-		)
+// GetPostByLikesFeed retrieves a page of posts for the feed,
+// sorted by most liked
+//
+// Parameters:
+//   - page: the cursor to continue after and the amount of posts to return
+//
+// Returns:
+//   - []types.Post: the list of posts for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
+//   - int: http status code
+//   - error: An error if the function fails, nil otherwise
+func (db *Database) GetPostByLikesFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := `SELECT id, user_id, project_id, content, likes, creation_date, edit_date, trending_score
+              FROM Posts
+              WHERE deletion_date IS NULL AND (likes < ? OR (likes = ? AND id < ?))
+              ORDER BY likes DESC, id DESC
+              LIMIT ?;`
 
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return []types.Post{}, http.StatusOK, nil
-			}
-			return nil, http.StatusInternalServerError, err
-		}
-		posts = append(posts, post)
-	}
+	return db.queryPostFeed(page, query, after.Likes, after.Likes, after.ID, fetchLimit(page))
+}
 
-	return posts, http.StatusOK, nil
+// GetPostByTrendingFeed retrieves a page of posts for the feed, sorted
+// by trending score, which favours posts liked and commented on lately
+//
+// Parameters:
+//   - page: the cursor to continue after and the amount of posts to return
+//
+// Returns:
+//   - []types.Post: the list of posts for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
+//   - int: http status code
+//   - error: An error if the function fails, nil otherwise
+func (db *Database) GetPostByTrendingFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := `SELECT id, user_id, project_id, content, likes, creation_date, edit_date, trending_score
+              FROM Posts
+              WHERE deletion_date IS NULL AND (trending_score < ? OR (trending_score = ? AND id < ?))
+              ORDER BY trending_score DESC, id DESC
+              LIMIT ?;`
+
+	return db.queryPostFeed(page, query, after.Score, after.Score, after.ID, fetchLimit(page))
 }
 
-// GetPostByLikesFeed retrieves a set of posts for the feed given a type
-// it also paginates the results, sorted by most liked
+// GetPostByFollowingFeed retrieves a page of a user's home feed, the posts
+// written by the users they follow or attached to the projects they follow,
+// sorted by most recent
 //
 // Parameters:
-//   - start: the int id to start at
-//   - count: the amount of posts to return
+//   - userId: the id of the user the feed is for
+//   - page: the cursor to continue after and the amount of posts to return
 //
 // Returns:
 //   - []types.Post: the list of posts for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: http status code
 //   - error: An error if the function fails, nil otherwise
-func GetPostByLikesFeed(start int, count int) ([]types.Post, int, error) {
-	query := `SELECT id, user_id, project_id, content, likes, creation_date
+func (db *Database) GetPostByFollowingFeed(userId int64, page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := fmt.Sprintf(`SELECT id, user_id, project_id, content, likes, creation_date, edit_date, trending_score
               FROM Posts
-              ORDER BY likes DESC
-              LIMIT ? OFFSET ?;`
+              WHERE deletion_date IS NULL
+                AND (user_id IN (SELECT follows_id FROM UserFollows WHERE follower_id = ?)
+                     OR project_id IN (SELECT project_id FROM ProjectFollows WHERE user_id = ?))
+                AND (%[1]v < %[2]v OR (%[1]v = %[2]v AND id < ?))
+              ORDER BY %[1]v DESC, id DESC
+              LIMIT ?;`, db.Dialect.Time("creation_date"), db.Dialect.Time("?"))
 
-	rows, err := DB.Query(query, count, start)
+	return db.queryPostFeed(page, query, userId, userId, after.Time, after.Time, after.ID, fetchLimit(page))
+}
+
+// queryPostFeed runs one of the post feed queries and scans the page of
+// posts it returns. The queries select the trending score last, for the
+// cursor of the trending feed.
+func (db *Database) queryPostFeed(page types.Page, query string, args ...interface{}) ([]types.Post, *types.Cursor, int, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 	var posts []types.Post
+	scores := map[int64]float64{}
 
 	for rows.Next() {
 		var post types.Post
+		var score sql.NullFloat64
 		err := rows.Scan(
 			&post.ID,
 			&post.User,
@@ -87,103 +123,110 @@ func GetPostByLikesFeed(start int, count int) ([]types.Post, int, error) {
 			&post.Content,
 			&post.Likes,
 			&post.CreationDate,
+			&post.EditedAt,
+			&score,
 		)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return []types.Post{}, http.StatusOK, nil
+				return []types.Post{}, nil, http.StatusOK, nil
 			}
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
 		posts = append(posts, post)
+		scores[post.ID] = score.Float64
 	}
 
-	return posts, http.StatusOK, nil
+	posts, next := CutPage(posts, page, func(post types.Post) types.Cursor {
+		cursor := postCursor(post)
+		cursor.Score = scores[post.ID]
+		return cursor
+	})
+	return posts, next, http.StatusOK, nil
 }
 
-// GetProjectByTimeFeed retrieves a set of projects for the feed given a type
-// it also paginates the results, sorted by most recent
+// GetProjectByTimeFeed retrieves a page of projects for the feed,
+// sorted by most recent
 //
 // Parameters:
-//   - start: the int id to start at
-//   - count: the amount of projects to return
+//   - page: the cursor to continue after and the amount of projects to return
 //
 // Returns:
 //   - []types.Project: the list of projects for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: http status code
 //   - error: An error if the function fails, nil otherwise
-func GetProjectByTimeFeed(start int, count int) ([]types.Project, int, error) {
-	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date
-              FROM Project
-              ORDER BY likes DESC
-              LIMIT ? OFFSET ?;`
-
-	rows, err := DB.Query(query, count, start)
-	if err != nil {
-		return nil, http.StatusNotFound, err
-	}
-	var projects []types.Project
-	defer rows.Close()
+func (db *Database) GetProjectByTimeFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := fmt.Sprintf(`SELECT id, name, description, status, likes, links, tags, owner, creation_date, trending_score
+              FROM Projects
+              WHERE deletion_date IS NULL AND (%[1]v < %[2]v OR (%[1]v = %[2]v AND id < ?))
+              ORDER BY %[1]v DESC, id DESC
+              LIMIT ?;`, db.Dialect.Time("creation_date"), db.Dialect.Time("?"))
 
-	for rows.Next() {
-		var project types.Project
-		var linksJSON, tagsJSON string
-		err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.Description,
-			&project.Status,
-			&project.Likes,
-			&linksJSON,
-			&tagsJSON,
-			&project.Owner,
-			&project.CreationDate,
-		)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, http.StatusOK, nil
-			}
-			return nil, http.StatusInternalServerError, err
-		}
+	return db.queryProjectFeed(page, query, after.Time, after.Time, after.ID, fetchLimit(page))
+}
 
-		if err := UnmarshalFromJSON(linksJSON, &project.Links); err != nil {
-			return nil, http.StatusBadRequest, err
-		}
-		if err := UnmarshalFromJSON(tagsJSON, &project.Tags); err != nil {
-			return nil, http.StatusBadRequest, err
-		}
-	}
+// GetProjectByLikesFeed retrieves a page of projects for the feed,
+// sorted by most liked
+//
+// Parameters:
+//   - page: the cursor to continue after and the amount of projects to return
+//
+// Returns:
+//   - []types.Project: the list of projects for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
+//   - int: http status code
+//   - error: An error if the function fails, nil otherwise
+func (db *Database) GetProjectByLikesFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date, trending_score
+              FROM Projects
+              WHERE deletion_date IS NULL AND (likes < ? OR (likes = ? AND id < ?))
+              ORDER BY likes DESC, id DESC
+              LIMIT ?;`
 
-	return projects, http.StatusOK, nil
+	return db.queryProjectFeed(page, query, after.Likes, after.Likes, after.ID, fetchLimit(page))
 }
 
-// GetProjectByLikesFeed retrieves a set of projects for the feed given a type
-// it also paginates the results, sorted by most liked
+// GetProjectByTrendingFeed retrieves a page of projects for the feed, sorted
+// by trending score, which favours projects liked, commented on and followed lately
 //
 // Parameters:
-//   - start: the int id to start at
-//   - count: the amount of projects to return
+//   - page: the cursor to continue after and the amount of projects to return
 //
 // Returns:
 //   - []types.Project: the list of projects for the feed
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: http status code
 //   - error: An error if the function fails, nil otherwise
-func GetProjectByLikesFeed(start int, count int) ([]types.Project, int, error) {
-	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date
-              FROM Project
-              ORDER BY likes DESC
-              LIMIT ? OFFSET ?;`
+func (db *Database) GetProjectByTrendingFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	after := descendingFrom(page)
+	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date, trending_score
+              FROM Projects
+              WHERE deletion_date IS NULL AND (trending_score < ? OR (trending_score = ? AND id < ?))
+              ORDER BY trending_score DESC, id DESC
+              LIMIT ?;`
+
+	return db.queryProjectFeed(page, query, after.Score, after.Score, after.ID, fetchLimit(page))
+}
 
-	rows, err := DB.Query(query, count, start)
+// queryProjectFeed runs one of the project feed queries and scans the page
+// of projects it returns. Like the post feeds, the queries select the
+// trending score last.
+func (db *Database) queryProjectFeed(page types.Page, query string, args ...interface{}) ([]types.Project, *types.Cursor, int, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	var projects []types.Project
+	scores := map[int64]float64{}
 	defer rows.Close()
 
 	for rows.Next() {
 		var project types.Project
 		var linksJSON, tagsJSON string
+		var score sql.NullFloat64
 		err := rows.Scan(
 			&project.ID,
 			&project.Name,
@@ -194,21 +237,29 @@ func GetProjectByLikesFeed(start int, count int) ([]types.Project, int, error) {
 			&tagsJSON,
 			&project.Owner,
 			&project.CreationDate,
+			&score,
 		)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return nil, http.StatusOK, nil
+				return nil, nil, http.StatusOK, nil
 			}
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
 
 		if err := UnmarshalFromJSON(linksJSON, &project.Links); err != nil {
-			return nil, http.StatusBadRequest, err
+			return nil, nil, http.StatusBadRequest, err
 		}
 		if err := UnmarshalFromJSON(tagsJSON, &project.Tags); err != nil {
-			return nil, http.StatusBadRequest, err
+			return nil, nil, http.StatusBadRequest, err
 		}
+		projects = append(projects, project)
+		scores[project.ID] = score.Float64
 	}
 
-	return projects, http.StatusOK, nil
+	projects, next := CutPage(projects, page, func(project types.Project) types.Cursor {
+		cursor := projectCursor(project)
+		cursor.Score = scores[project.ID]
+		return cursor
+	})
+	return projects, next, http.StatusOK, nil
 }
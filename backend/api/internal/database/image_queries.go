@@ -0,0 +1,188 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// QuerySetUserPicture points the picture of a user at a new URL.
+//
+// Parameters:
+//   - username: The user whose picture changes.
+//   - picture: The URL of the new picture.
+//
+// Returns:
+//   - string: The URL of the picture it replaces, empty if there was none.
+//   - int: HTTP status code.
+//   - error: An error if the user does not exist or cannot be updated.
+func (db *Database) QuerySetUserPicture(username string, picture string) (previousPicture string, httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			previousPicture, httpCode, err = "", http.StatusInternalServerError, fmt.Errorf("Failed to commit picture of user '%v': %v", username, err)
+		}
+	}()
+
+	var previous sql.NullString
+	err = tx.QueryRow(`SELECT picture FROM Users WHERE username = ? AND deletion_date IS NULL`, username).Scan(&previous)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("Cannot find user with username '%v'", username)
+		return "", http.StatusNotFound, err
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Failed to fetch user '%v': %v", username, err)
+	}
+
+	_, err = tx.Exec(`UPDATE Users SET picture = ? WHERE username = ? AND deletion_date IS NULL`, picture, username)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Failed to update picture of user '%v': %v", username, err)
+	}
+	return previous.String, http.StatusOK, nil
+}
+
+// QueryCreateProjectImage records an image uploaded to a project, unless
+// the project already has as many images as it may have. The images are
+// counted in the same transaction, with the project locked, so uploads
+// racing each other cannot go over the limit.
+//
+// Parameters:
+//   - image: The image, with its project, URL, content type and size.
+//   - maxImages: How many images a project may have.
+//
+// Returns:
+//   - int64: The id of the new image.
+//   - int: HTTP status code.
+//   - error: An error if the project is full, does not exist or the image cannot be recorded.
+func (db *Database) QueryCreateProjectImage(image *types.ProjectImage, maxImages int) (id int64, httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			id, httpCode, err = -1, http.StatusInternalServerError, fmt.Errorf("Failed to commit project image: %v", err)
+		}
+	}()
+
+	var projectId int64
+	err = tx.QueryRow(`SELECT id FROM Projects WHERE id = ? AND deletion_date IS NULL`+tx.Dialect.ForUpdate(), image.Project).Scan(&projectId)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("Project with id '%v' not found", image.Project)
+		return -1, http.StatusNotFound, err
+	}
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to fetch project: %v", err)
+	}
+
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM ProjectImages WHERE project_id = ?`, image.Project).Scan(&count)
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to count project images: %v", err)
+	}
+	if count >= maxImages {
+		err = fmt.Errorf("Projects can have at most %v images", maxImages)
+		return -1, http.StatusBadRequest, err
+	}
+
+	query := `INSERT INTO ProjectImages (project_id, url, content_type, size, creation_date)
+              VALUES (?, ?, ?, ?, ?)`
+	id, err = tx.InsertReturningId(query, image.Project, image.URL, image.ContentType, image.Size, time.Now().UTC())
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to create project image: %v", err)
+	}
+	return id, http.StatusCreated, nil
+}
+
+const projectImageColumns = `id, project_id, url, content_type, size, creation_date`
+
+func scanProjectImage(row interface{ Scan(...interface{}) error }) (types.ProjectImage, error) {
+	var image types.ProjectImage
+	err := row.Scan(&image.ID, &image.Project, &image.URL, &image.ContentType, &image.Size, &image.CreationDate)
+	return image, err
+}
+
+// QueryProjectImages retrieves the images of a project.
+//
+// Parameters:
+//   - projectId: The id of the project.
+//
+// Returns:
+//   - []types.ProjectImage: The images, oldest first.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryProjectImages(projectId int64) ([]types.ProjectImage, int, error) {
+	rows, err := db.Query(`SELECT `+projectImageColumns+` FROM ProjectImages WHERE project_id = ? ORDER BY id`, projectId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query project images: %v", err)
+	}
+	defer rows.Close()
+
+	images := []types.ProjectImage{}
+	for rows.Next() {
+		image, err := scanProjectImage(rows)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan project image: %v", err)
+		}
+		images = append(images, image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read project images: %v", err)
+	}
+	return images, http.StatusOK, nil
+}
+
+// QueryProjectImage retrieves an image of any project.
+//
+// Parameters:
+//   - id: The id of the image.
+//
+// Returns:
+//   - *types.ProjectImage: The image, nil if it does not exist.
+//   - error: An error if the query fails.
+func (db *Database) QueryProjectImage(id int64) (*types.ProjectImage, error) {
+	image, err := scanProjectImage(db.QueryRow(`SELECT `+projectImageColumns+` FROM ProjectImages WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query project image: %v", err)
+	}
+	return &image, nil
+}
+
+// QueryDeleteProjectImage forgets an image of a project. Removing the
+// file itself is up to the storage it was uploaded to.
+//
+// Parameters:
+//   - id: The id of the image.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the image does not exist or cannot be deleted.
+func (db *Database) QueryDeleteProjectImage(id int64) (int, error) {
+	result, err := db.Exec(`DELETE FROM ProjectImages WHERE id = ?`, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete project image: %v", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete project image: %v", err)
+	}
+	if deleted == 0 {
+		return http.StatusNotFound, fmt.Errorf("Project image with id %v not found", id)
+	}
+	return http.StatusOK, nil
+}
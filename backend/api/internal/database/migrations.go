@@ -0,0 +1,287 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// every schema change lives in migrations/<dialect>/ as a numbered pair of
+// files, NNNN_name.up.sql and NNNN_name.down.sql, and is compiled into the
+// binary. Each dialect gets the same versions, written in its own SQL.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change and the statements that undo it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied to a database.
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// nil while the migration is still pending
+	AppliedDate *time.Time
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_date TIMESTAMP NOT NULL
+);`
+
+// Migrations returns every embedded migration for a dialect, ordered by version.
+//
+// Parameters:
+//   - dialect: The dialect the migrations are written for.
+//
+// Returns:
+//   - []Migration: The migrations, oldest first.
+//   - error: An error if a file is misnamed, a version is used twice, or a
+//     migration is missing its up or down file.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := "migrations/" + string(dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read migrations: %v", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Migration file '%v' must end in .up.sql or .down.sql", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("Migration file '%v' must be named like 0001_name.%v.sql", fileName, direction)
+		}
+
+		contents, err := migrationFiles.ReadFile(dir + "/" + fileName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read migration '%v': %v", fileName, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("Migration version %v is used by both '%v' and '%v'", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migration %04d_%v needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations creates the schema_migrations table if needed and
+// returns when each recorded version was applied.
+func appliedMigrations(db *Database) (map[int]time.Time, error) {
+	_, err := db.Exec(createMigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create schema_migrations: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT version, applied_date FROM schema_migrations;`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedDate time.Time
+		if err := rows.Scan(&version, &appliedDate); err != nil {
+			return nil, fmt.Errorf("Failed to scan applied migration: %v", err)
+		}
+		applied[version] = appliedDate
+	}
+	return applied, rows.Err()
+}
+
+// checkKnownVersions stops a binary from touching a database that a newer
+// build has already migrated past.
+func checkKnownVersions(migrations []Migration, applied map[int]time.Time) error {
+	known := map[int]bool{}
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("Database has migration %v applied, which this build does not know about", version)
+		}
+	}
+	return nil
+}
+
+// runMigration executes one direction of a migration and records it in
+// schema_migrations, in a single transaction so a failing statement
+// leaves the schema as it was.
+func runMigration(db *Database, migration Migration, up bool) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	statements, record := migration.Down, `DELETE FROM schema_migrations WHERE version = ?;`
+	args := []interface{}{migration.Version}
+	if up {
+		statements, record = migration.Up, `INSERT INTO schema_migrations (version, name, applied_date) VALUES (?, ?, ?);`
+		args = append(args, migration.Name, time.Now().UTC())
+	}
+
+	_, err = tx.Exec(statements)
+	if err != nil {
+		return fmt.Errorf("Migration %04d_%v failed: %v", migration.Version, migration.Name, err)
+	}
+	_, err = tx.Exec(record, args...)
+	if err != nil {
+		return fmt.Errorf("Failed to record migration %04d_%v: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration, oldest first.
+//
+// Parameters:
+//   - db: The database to migrate.
+//
+// Returns:
+//   - []Migration: The migrations that were applied, empty if the schema was up to date.
+//   - error: An error if a migration fails. Migrations applied before the
+//     failing one stay applied.
+func MigrateUp(db *Database) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKnownVersions(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := runMigration(db, migration, true); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// MigrateDown rolls back the most recently applied migrations.
+//
+// Parameters:
+//   - db: The database to migrate.
+//   - steps: How many migrations to roll back, newest first.
+//
+// Returns:
+//   - []Migration: The migrations that were rolled back.
+//   - error: An error if steps is not positive or a rollback fails.
+func MigrateDown(db *Database, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("Steps must be positive, got %v", steps)
+	}
+
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKnownVersions(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for i := len(migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok {
+			continue
+		}
+		if err := runMigration(db, migrations[i], false); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migrations[i])
+	}
+	return ran, nil
+}
+
+// QueryMigrationStatus lists every embedded migration and when it was applied.
+//
+// Parameters:
+//   - db: The database to inspect.
+//
+// Returns:
+//   - []MigrationStatus: One entry per migration, oldest first.
+//   - error: An error if the migrations or schema_migrations cannot be read.
+func QueryMigrationStatus(db *Database) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedDate, ok := applied[migration.Version]; ok {
+			status.AppliedDate = &appliedDate
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, checkKnownVersions(migrations, applied)
+}
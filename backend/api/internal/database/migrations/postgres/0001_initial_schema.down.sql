@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS UserLoginInfo;
+DROP TABLE IF EXISTS TokenFamilies;
+DROP TABLE IF EXISTS RefreshTokens;
+DROP TABLE IF EXISTS PersonalAccessTokens;
+
+DROP TABLE IF EXISTS Users;
+DROP TABLE IF EXISTS UserFollows;
+
+DROP TABLE IF EXISTS Projects;
+DROP TABLE IF EXISTS ProjectLikes;
+DROP TABLE IF EXISTS ProjectFollows;
+DROP TABLE IF EXISTS ProjectComments;
+
+DROP TABLE IF EXISTS Posts;
+DROP TABLE IF EXISTS PostLikes;
+DROP TABLE IF EXISTS PostComments;
+
+DROP TABLE IF EXISTS Comments;
+DROP TABLE IF EXISTS CommentLikes;
@@ -0,0 +1,139 @@
+-- The same schema as migrations/sqlite3/0001_initial_schema.up.sql.
+-- Foreign keys are left out on purpose: SQLite does not enforce them unless
+-- asked to, and the queries rely on that, for example deleted comments point
+-- at user -1 and deleting a user keeps their projects and posts around.
+-- Declaring them here would make the two databases behave differently.
+
+-- UserLoginInfo
+CREATE TABLE IF NOT EXISTS UserLoginInfo (
+    username VARCHAR(50) UNIQUE NOT NULL,
+    password VARCHAR(100) NOT NULL,
+    PRIMARY KEY(username)
+);
+
+-- TokenFamilies (one per login, every refresh token rotated from that login shares it)
+CREATE TABLE IF NOT EXISTS TokenFamilies (
+    id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    revocation_date TIMESTAMP,
+    PRIMARY KEY(id)
+);
+
+-- RefreshTokens (stored hashed, used_date is set once a token has been rotated)
+CREATE TABLE IF NOT EXISTS RefreshTokens (
+    token_hash VARCHAR(64) NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    expiration_date TIMESTAMP NOT NULL,
+    used_date TIMESTAMP,
+    PRIMARY KEY(token_hash)
+);
+
+-- PersonalAccessTokens (long lived, scoped tokens for scripts, stored hashed)
+CREATE TABLE IF NOT EXISTS PersonalAccessTokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes JSONB NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    last_used_date TIMESTAMP
+);
+
+-- Users Table
+CREATE TABLE IF NOT EXISTS Users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    picture TEXT,
+    bio TEXT,
+    links JSONB,
+    creation_date TIMESTAMP NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    suspension_date TIMESTAMP
+);
+
+-- Projects Table
+CREATE TABLE IF NOT EXISTS Projects (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    status INTEGER,
+    likes INTEGER DEFAULT 0,
+    links JSONB,
+    tags JSONB,
+    owner INTEGER NOT NULL,
+    creation_date TIMESTAMP NOT NULL
+);
+
+-- Project Comments Table (Normalizing comments relationship)
+CREATE TABLE IF NOT EXISTS ProjectComments (
+    project_id INTEGER NOT NULL,
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (project_id, comment_id)
+);
+
+-- Posts Table
+CREATE TABLE IF NOT EXISTS Posts (
+    id SERIAL PRIMARY KEY,
+    content TEXT NOT NULL,
+    project_id INTEGER NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    user_id INTEGER NOT NULL,
+    likes INTEGER DEFAULT 0
+);
+
+-- Project Comments Table (Normalizing comments relationship)
+CREATE TABLE IF NOT EXISTS PostComments (
+    post_id INTEGER NOT NULL,
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, comment_id)
+);
+
+-- Comments Table
+CREATE TABLE IF NOT EXISTS Comments (
+    id SERIAL PRIMARY KEY,
+    content TEXT NOT NULL,
+    parent_comment_id INTEGER,
+    likes INTEGER DEFAULT 0,
+    creation_date TIMESTAMP NOT NULL,
+    user_id INTEGER NOT NULL
+);
+
+-- Likes for Projects
+CREATE TABLE IF NOT EXISTS ProjectLikes (
+    project_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (project_id, user_id)
+);
+
+-- Likes for Posts
+CREATE TABLE IF NOT EXISTS PostLikes (
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, user_id)
+);
+
+-- Likes for Comments
+CREATE TABLE IF NOT EXISTS CommentLikes (
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+-- Follows between Users (User Following)
+CREATE TABLE IF NOT EXISTS UserFollows (
+    follower_id INTEGER NOT NULL,
+    follows_id INTEGER NOT NULL,
+    PRIMARY KEY (follower_id, follows_id),
+    CHECK (follower_id != follows_id)
+);
+
+-- Follows for Projects (User Following a Project)
+CREATE TABLE IF NOT EXISTS ProjectFollows (
+    project_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (project_id, user_id)
+);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_posts_trending;
+DROP INDEX IF EXISTS idx_projects_trending;
+
+ALTER TABLE Posts DROP COLUMN trending_score;
+ALTER TABLE Projects DROP COLUMN trending_score;
+
+ALTER TABLE PostLikes DROP COLUMN creation_date;
+ALTER TABLE ProjectLikes DROP COLUMN creation_date;
+ALTER TABLE CommentLikes DROP COLUMN creation_date;
+ALTER TABLE ProjectFollows DROP COLUMN creation_date;
+ALTER TABLE UserFollows DROP COLUMN creation_date;
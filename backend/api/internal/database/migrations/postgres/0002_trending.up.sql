@@ -0,0 +1,22 @@
+-- likes and follows remember when they were made, so trending scores can
+-- tell recent interactions from old ones
+ALTER TABLE PostLikes ADD COLUMN creation_date TIMESTAMP;
+ALTER TABLE ProjectLikes ADD COLUMN creation_date TIMESTAMP;
+ALTER TABLE CommentLikes ADD COLUMN creation_date TIMESTAMP;
+ALTER TABLE ProjectFollows ADD COLUMN creation_date TIMESTAMP;
+ALTER TABLE UserFollows ADD COLUMN creation_date TIMESTAMP;
+
+-- the rows from before then are dated to when what they point at was created
+UPDATE PostLikes SET creation_date = (SELECT creation_date FROM Posts WHERE Posts.id = PostLikes.post_id);
+UPDATE ProjectLikes SET creation_date = (SELECT creation_date FROM Projects WHERE Projects.id = ProjectLikes.project_id);
+UPDATE CommentLikes SET creation_date = (SELECT creation_date FROM Comments WHERE Comments.id = CommentLikes.comment_id);
+UPDATE ProjectFollows SET creation_date = (SELECT creation_date FROM Projects WHERE Projects.id = ProjectFollows.project_id);
+UPDATE UserFollows SET creation_date = (SELECT creation_date FROM Users WHERE Users.id = UserFollows.follows_id);
+
+-- trending scores are updated as interactions come in, NULL until the
+-- api computes the first one when it starts
+ALTER TABLE Posts ADD COLUMN trending_score DOUBLE PRECISION;
+ALTER TABLE Projects ADD COLUMN trending_score DOUBLE PRECISION;
+
+CREATE INDEX IF NOT EXISTS idx_posts_trending ON Posts (trending_score, id);
+CREATE INDEX IF NOT EXISTS idx_projects_trending ON Projects (trending_score, id);
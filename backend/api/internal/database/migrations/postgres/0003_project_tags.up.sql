@@ -0,0 +1,20 @@
+-- every tag of every project, so projects can be looked up by tag
+-- without parsing the tags column of every row
+CREATE TABLE IF NOT EXISTS ProjectTags (
+    project_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (project_id, tag),
+    FOREIGN KEY (project_id) REFERENCES Projects(id) ON DELETE CASCADE
+);
+
+-- tags are matched regardless of case
+CREATE INDEX IF NOT EXISTS idx_project_tags_tag ON ProjectTags (lower(tag), project_id);
+CREATE INDEX IF NOT EXISTS idx_projects_status ON Projects (status);
+CREATE INDEX IF NOT EXISTS idx_projects_owner ON Projects (owner);
+
+INSERT INTO ProjectTags (project_id, tag)
+    SELECT DISTINCT p.id, t.value
+    FROM Projects p
+    CROSS JOIN LATERAL jsonb_array_elements_text(
+        CASE WHEN jsonb_typeof(p.tags) = 'array' THEN p.tags ELSE '[]'::jsonb END
+    ) t(value);
@@ -0,0 +1,15 @@
+DROP TRIGGER IF EXISTS comments_search_sync ON Comments;
+DROP FUNCTION IF EXISTS comments_search_sync();
+DROP TABLE IF EXISTS CommentSearch;
+
+DROP TRIGGER IF EXISTS posts_search_sync ON Posts;
+DROP FUNCTION IF EXISTS posts_search_sync();
+DROP TABLE IF EXISTS PostSearch;
+
+DROP TRIGGER IF EXISTS projects_search_sync ON Projects;
+DROP FUNCTION IF EXISTS projects_search_sync();
+DROP TABLE IF EXISTS ProjectSearch;
+
+DROP TRIGGER IF EXISTS users_search_sync ON Users;
+DROP FUNCTION IF EXISTS users_search_sync();
+DROP TABLE IF EXISTS UserSearch;
@@ -0,0 +1,118 @@
+-- full text indexes of what can be searched for, one per kind of item
+-- keyed by the item's id. Every item has a title, such as a project's
+-- name, weighted above its body, and triggers keep them in sync with the
+-- tables they index. The simple configuration leaves words unstemmed, so
+-- prefixes of names match the same way they do on sqlite.
+
+CREATE TABLE IF NOT EXISTS UserSearch (
+    id INTEGER PRIMARY KEY,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_search ON UserSearch USING GIN (document);
+
+INSERT INTO UserSearch (id, document)
+    SELECT id, setweight(to_tsvector('simple', coalesce(username, '')), 'A')
+            || setweight(to_tsvector('simple', coalesce(bio, '')), 'B')
+    FROM Users;
+
+CREATE OR REPLACE FUNCTION users_search_sync() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM UserSearch WHERE id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO UserSearch (id, document) VALUES (NEW.id, setweight(to_tsvector('simple', coalesce(NEW.username, '')), 'A')
+            || setweight(to_tsvector('simple', coalesce(NEW.bio, '')), 'B'));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_search_sync ON Users;
+CREATE TRIGGER users_search_sync AFTER INSERT OR UPDATE OF username, bio OR DELETE ON Users
+    FOR EACH ROW EXECUTE FUNCTION users_search_sync();
+
+CREATE TABLE IF NOT EXISTS ProjectSearch (
+    id INTEGER PRIMARY KEY,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_projects_search ON ProjectSearch USING GIN (document);
+
+INSERT INTO ProjectSearch (id, document)
+    SELECT id, setweight(to_tsvector('simple', coalesce(name, '')), 'A')
+            || setweight(to_tsvector('simple', coalesce(description || ' ' || coalesce(tags::text, ''), '')), 'B')
+    FROM Projects;
+
+CREATE OR REPLACE FUNCTION projects_search_sync() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM ProjectSearch WHERE id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO ProjectSearch (id, document) VALUES (NEW.id, setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A')
+            || setweight(to_tsvector('simple', coalesce(NEW.description || ' ' || coalesce(NEW.tags::text, ''), '')), 'B'));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS projects_search_sync ON Projects;
+CREATE TRIGGER projects_search_sync AFTER INSERT OR UPDATE OF name, description, tags OR DELETE ON Projects
+    FOR EACH ROW EXECUTE FUNCTION projects_search_sync();
+
+CREATE TABLE IF NOT EXISTS PostSearch (
+    id INTEGER PRIMARY KEY,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_search ON PostSearch USING GIN (document);
+
+INSERT INTO PostSearch (id, document)
+    SELECT id, setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+    FROM Posts;
+
+CREATE OR REPLACE FUNCTION posts_search_sync() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM PostSearch WHERE id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO PostSearch (id, document) VALUES (NEW.id, setweight(to_tsvector('simple', coalesce(NEW.content, '')), 'B'));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_search_sync ON Posts;
+CREATE TRIGGER posts_search_sync AFTER INSERT OR UPDATE OF content OR DELETE ON Posts
+    FOR EACH ROW EXECUTE FUNCTION posts_search_sync();
+
+CREATE TABLE IF NOT EXISTS CommentSearch (
+    id INTEGER PRIMARY KEY,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_search ON CommentSearch USING GIN (document);
+
+INSERT INTO CommentSearch (id, document)
+    SELECT id, setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+    FROM Comments
+    WHERE user_id <> -1;
+
+CREATE OR REPLACE FUNCTION comments_search_sync() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM CommentSearch WHERE id = OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' AND NEW.user_id <> -1 THEN
+        INSERT INTO CommentSearch (id, document) VALUES (NEW.id, setweight(to_tsvector('simple', coalesce(NEW.content, '')), 'B'));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_search_sync ON Comments;
+CREATE TRIGGER comments_search_sync AFTER INSERT OR UPDATE OF content, user_id OR DELETE ON Comments
+    FOR EACH ROW EXECUTE FUNCTION comments_search_sync();
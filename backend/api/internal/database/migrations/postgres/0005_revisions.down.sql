@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_comment_revisions_comment;
+DROP INDEX IF EXISTS idx_post_revisions_post;
+
+DROP TABLE IF EXISTS CommentRevisions;
+DROP TABLE IF EXISTS PostRevisions;
+
+ALTER TABLE Comments DROP COLUMN edit_date;
+ALTER TABLE Posts DROP COLUMN edit_date;
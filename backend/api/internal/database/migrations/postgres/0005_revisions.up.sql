@@ -0,0 +1,23 @@
+-- when the content of a post or comment last changed, NULL if it never has
+ALTER TABLE Posts ADD COLUMN edit_date TIMESTAMP;
+ALTER TABLE Comments ADD COLUMN edit_date TIMESTAMP;
+
+-- what a post or comment said before each of its edits, dated to the edit
+CREATE TABLE IF NOT EXISTS PostRevisions (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    revision_date TIMESTAMP NOT NULL,
+    FOREIGN KEY (post_id) REFERENCES Posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS CommentRevisions (
+    id SERIAL PRIMARY KEY,
+    comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    revision_date TIMESTAMP NOT NULL,
+    FOREIGN KEY (comment_id) REFERENCES Comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post ON PostRevisions (post_id, id);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment ON CommentRevisions (comment_id, id);
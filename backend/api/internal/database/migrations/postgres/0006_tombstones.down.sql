@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_comments_deletion;
+DROP INDEX IF EXISTS idx_posts_deletion;
+DROP INDEX IF EXISTS idx_projects_deletion;
+DROP INDEX IF EXISTS idx_users_deletion;
+
+ALTER TABLE Comments DROP COLUMN deletion_date;
+ALTER TABLE Posts DROP COLUMN deletion_date;
+ALTER TABLE Projects DROP COLUMN deletion_date;
+ALTER TABLE Users DROP COLUMN deletion_date;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notifications_target;
+DROP INDEX IF EXISTS idx_notifications_user;
+
+DROP TABLE IF EXISTS Notifications;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook;
+DROP INDEX IF EXISTS idx_webhooks_project;
+
+DROP TABLE IF EXISTS WebhookDeliveries;
+DROP TABLE IF EXISTS Webhooks;
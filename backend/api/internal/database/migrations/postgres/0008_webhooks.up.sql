@@ -0,0 +1,35 @@
+-- a URL a project owner asked to hear about the project's events at.
+-- events is a JSON array of the event names it gets, and secret signs
+-- every payload sent to it
+CREATE TABLE IF NOT EXISTS Webhooks (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES Projects(id) ON DELETE CASCADE
+);
+
+-- every payload sent or to be sent to a webhook. status is pending until
+-- it is delivered or runs out of attempts, next_attempt is when a pending
+-- delivery is tried again, and redelivery_of the delivery it was copied from
+CREATE TABLE IF NOT EXISTS WebhookDeliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    redelivery_of INTEGER,
+    creation_date TIMESTAMP NOT NULL,
+    next_attempt TIMESTAMP,
+    delivered_date TIMESTAMP,
+    FOREIGN KEY (webhook_id) REFERENCES Webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_project ON Webhooks (project_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON WebhookDeliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON WebhookDeliveries (status, next_attempt);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_email_preferences_digest;
+
+DROP TABLE IF EXISTS EmailPreferences;
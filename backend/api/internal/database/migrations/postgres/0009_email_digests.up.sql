@@ -0,0 +1,14 @@
+-- where and how often a user wants to be emailed. digest is off, daily or
+-- weekly, last_digest_date is when the last digest was due and
+-- last_notification the latest notification it covered, so the next digest
+-- only summarizes what came after
+CREATE TABLE IF NOT EXISTS EmailPreferences (
+    user_id INTEGER PRIMARY KEY,
+    email TEXT NOT NULL,
+    digest TEXT NOT NULL DEFAULT 'off',
+    last_digest_date TIMESTAMP,
+    last_notification INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_preferences_digest ON EmailPreferences (digest, last_digest_date);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_project_images_project;
+
+DROP TABLE IF EXISTS ProjectImages;
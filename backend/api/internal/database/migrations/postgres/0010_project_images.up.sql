@@ -0,0 +1,13 @@
+-- images uploaded to projects. url is where the storage serves the
+-- image, pictures of users are kept in Users.picture instead
+CREATE TABLE IF NOT EXISTS ProjectImages (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES Projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_images_project ON ProjectImages (project_id);
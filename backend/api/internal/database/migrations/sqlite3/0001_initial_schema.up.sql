@@ -1,40 +1,61 @@
--- Drop tables if they already exist
-DROP TABLE IF EXISTS UserLoginInfo;
-
-DROP TABLE IF EXISTS Users;
-DROP TABLE IF EXISTS UserFollows;
-
-DROP TABLE IF EXISTS Projects;
-DROP TABLE IF EXISTS ProjectLikes;
-DROP TABLE IF EXISTS ProjectFollows;
-DROP TABLE IF EXISTS ProjectComments;
-
-DROP TABLE IF EXISTS Posts;
-DROP TABLE IF EXISTS PostLikes;
-DROP TABLE IF EXISTS PostComments;
-
-DROP TABLE IF EXISTS Comments;
-DROP TABLE IF EXISTS CommentLikes;
+-- The schema as it was before migrations were introduced. Tables are only
+-- created if they are missing, so databases set up from the old
+-- create_tables.sql can adopt migrations without losing their data.
 
 -- UserLoginInfo
-CREATE TABLE UserLoginInfo (
+CREATE TABLE IF NOT EXISTS UserLoginInfo (
     username VARCHAR(50) UNIQUE NOT NULL,
     password VARCHAR(100) NOT NULL,
     PRIMARY KEY(username)
 );
 
+-- TokenFamilies (one per login, every refresh token rotated from that login shares it)
+CREATE TABLE IF NOT EXISTS TokenFamilies (
+    id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    revocation_date TIMESTAMP,
+    PRIMARY KEY(id),
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+-- RefreshTokens (stored hashed, used_date is set once a token has been rotated)
+CREATE TABLE IF NOT EXISTS RefreshTokens (
+    token_hash VARCHAR(64) NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    expiration_date TIMESTAMP NOT NULL,
+    used_date TIMESTAMP,
+    PRIMARY KEY(token_hash),
+    FOREIGN KEY (family_id) REFERENCES TokenFamilies(id) ON DELETE CASCADE
+);
+
+-- PersonalAccessTokens (long lived, scoped tokens for scripts, stored hashed)
+CREATE TABLE IF NOT EXISTS PersonalAccessTokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes JSON NOT NULL,
+    creation_date TIMESTAMP NOT NULL,
+    last_used_date TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
 -- Users Table
-CREATE TABLE Users (
+CREATE TABLE IF NOT EXISTS Users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     username VARCHAR(50) UNIQUE NOT NULL,
     picture TEXT,
     bio TEXT,
     links JSON,
-    creation_date TIMESTAMP NOT NULL
+    creation_date TIMESTAMP NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    suspension_date TIMESTAMP
 );
 
 -- Projects Table
-CREATE TABLE Projects (
+CREATE TABLE IF NOT EXISTS Projects (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name VARCHAR(255) NOT NULL,
     description TEXT,
@@ -48,7 +69,7 @@ CREATE TABLE Projects (
 );
 
 -- Project Comments Table (Normalizing comments relationship)
-CREATE TABLE ProjectComments (
+CREATE TABLE IF NOT EXISTS ProjectComments (
     project_id INTEGER NOT NULL,
     comment_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
@@ -59,7 +80,7 @@ CREATE TABLE ProjectComments (
 );
 
 -- Posts Table
-CREATE TABLE Posts (
+CREATE TABLE IF NOT EXISTS Posts (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     content TEXT NOT NULL,
     project_id INTEGER NOT NULL,
@@ -71,7 +92,7 @@ CREATE TABLE Posts (
 );
 
 -- Project Comments Table (Normalizing comments relationship)
-CREATE TABLE PostComments (
+CREATE TABLE IF NOT EXISTS PostComments (
     post_id INTEGER NOT NULL,
     comment_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
@@ -82,7 +103,7 @@ CREATE TABLE PostComments (
 );
 
 -- Comments Table
-CREATE TABLE Comments (
+CREATE TABLE IF NOT EXISTS Comments (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     content TEXT NOT NULL,
     parent_comment_id INTEGER,
@@ -94,7 +115,7 @@ CREATE TABLE Comments (
 );
 
 -- Likes for Projects
-CREATE TABLE ProjectLikes (
+CREATE TABLE IF NOT EXISTS ProjectLikes (
     project_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
     PRIMARY KEY (project_id, user_id),
@@ -103,7 +124,7 @@ CREATE TABLE ProjectLikes (
 );
 
 -- Likes for Posts
-CREATE TABLE PostLikes (
+CREATE TABLE IF NOT EXISTS PostLikes (
     post_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
     PRIMARY KEY (post_id, user_id),
@@ -112,7 +133,7 @@ CREATE TABLE PostLikes (
 );
 
 -- Likes for Comments
-CREATE TABLE CommentLikes (
+CREATE TABLE IF NOT EXISTS CommentLikes (
     comment_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
     PRIMARY KEY (comment_id, user_id),
@@ -121,7 +142,7 @@ CREATE TABLE CommentLikes (
 );
 
 -- Follows between Users (User Following)
-CREATE TABLE UserFollows (
+CREATE TABLE IF NOT EXISTS UserFollows (
     follower_id INTEGER NOT NULL,
     follows_id INTEGER NOT NULL,
     PRIMARY KEY (follower_id, follows_id),
@@ -131,7 +152,7 @@ CREATE TABLE UserFollows (
 );
 
 -- Follows for Projects (User Following a Project)
-CREATE TABLE ProjectFollows (
+CREATE TABLE IF NOT EXISTS ProjectFollows (
     project_id INTEGER NOT NULL,
     user_id INTEGER NOT NULL,
     PRIMARY KEY (project_id, user_id),
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_projects_owner;
+DROP INDEX IF EXISTS idx_projects_status;
+DROP INDEX IF EXISTS idx_project_tags_tag;
+DROP TABLE IF EXISTS ProjectTags;
@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS comments_search_insert;
+DROP TRIGGER IF EXISTS comments_search_update;
+DROP TRIGGER IF EXISTS comments_search_delete;
+DROP TABLE IF EXISTS CommentSearch;
+
+DROP TRIGGER IF EXISTS posts_search_insert;
+DROP TRIGGER IF EXISTS posts_search_update;
+DROP TRIGGER IF EXISTS posts_search_delete;
+DROP TABLE IF EXISTS PostSearch;
+
+DROP TRIGGER IF EXISTS projects_search_insert;
+DROP TRIGGER IF EXISTS projects_search_update;
+DROP TRIGGER IF EXISTS projects_search_delete;
+DROP TABLE IF EXISTS ProjectSearch;
+
+DROP TRIGGER IF EXISTS users_search_insert;
+DROP TRIGGER IF EXISTS users_search_update;
+DROP TRIGGER IF EXISTS users_search_delete;
+DROP TABLE IF EXISTS UserSearch;
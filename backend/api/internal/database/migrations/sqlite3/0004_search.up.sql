@@ -0,0 +1,80 @@
+-- full text indexes of what can be searched for, one per kind of item
+-- with the item's id as the docid. Every item has a title, such as a
+-- project's name, and a body, and triggers keep them in sync with the
+-- tables they index. FTS4 is used as it is built into the sqlite driver
+-- by default, unlike FTS5.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS UserSearch USING fts4(title, body, prefix="2,3", tokenize=unicode61);
+
+INSERT INTO UserSearch (docid, title, body)
+    SELECT id, username, bio FROM Users;
+
+CREATE TRIGGER IF NOT EXISTS users_search_insert AFTER INSERT ON Users BEGIN
+    INSERT INTO UserSearch (docid, title, body) VALUES (NEW.id, NEW.username, NEW.bio);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_search_update AFTER UPDATE OF username, bio ON Users BEGIN
+    DELETE FROM UserSearch WHERE docid = OLD.id;
+    INSERT INTO UserSearch (docid, title, body) VALUES (NEW.id, NEW.username, NEW.bio);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_search_delete AFTER DELETE ON Users BEGIN
+    DELETE FROM UserSearch WHERE docid = OLD.id;
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS ProjectSearch USING fts4(title, body, prefix="2,3", tokenize=unicode61);
+
+INSERT INTO ProjectSearch (docid, title, body)
+    SELECT id, name, description || ' ' || coalesce(tags, '') FROM Projects;
+
+CREATE TRIGGER IF NOT EXISTS projects_search_insert AFTER INSERT ON Projects BEGIN
+    INSERT INTO ProjectSearch (docid, title, body) VALUES (NEW.id, NEW.name, NEW.description || ' ' || coalesce(NEW.tags, ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS projects_search_update AFTER UPDATE OF name, description, tags ON Projects BEGIN
+    DELETE FROM ProjectSearch WHERE docid = OLD.id;
+    INSERT INTO ProjectSearch (docid, title, body) VALUES (NEW.id, NEW.name, NEW.description || ' ' || coalesce(NEW.tags, ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS projects_search_delete AFTER DELETE ON Projects BEGIN
+    DELETE FROM ProjectSearch WHERE docid = OLD.id;
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS PostSearch USING fts4(title, body, prefix="2,3", tokenize=unicode61);
+
+INSERT INTO PostSearch (docid, title, body)
+    SELECT id, NULL, content FROM Posts;
+
+CREATE TRIGGER IF NOT EXISTS posts_search_insert AFTER INSERT ON Posts BEGIN
+    INSERT INTO PostSearch (docid, title, body) VALUES (NEW.id, NULL, NEW.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_search_update AFTER UPDATE OF content ON Posts BEGIN
+    DELETE FROM PostSearch WHERE docid = OLD.id;
+    INSERT INTO PostSearch (docid, title, body) VALUES (NEW.id, NULL, NEW.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_search_delete AFTER DELETE ON Posts BEGIN
+    DELETE FROM PostSearch WHERE docid = OLD.id;
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS CommentSearch USING fts4(title, body, prefix="2,3", tokenize=unicode61);
+
+INSERT INTO CommentSearch (docid, title, body)
+    SELECT id, NULL, content FROM Comments
+    WHERE user_id <> -1;
+
+CREATE TRIGGER IF NOT EXISTS comments_search_insert AFTER INSERT ON Comments BEGIN
+    INSERT INTO CommentSearch (docid, title, body) SELECT NEW.id, NULL, NEW.content
+        WHERE NEW.user_id <> -1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_search_update AFTER UPDATE OF content, user_id ON Comments BEGIN
+    DELETE FROM CommentSearch WHERE docid = OLD.id;
+    INSERT INTO CommentSearch (docid, title, body) SELECT NEW.id, NULL, NEW.content
+        WHERE NEW.user_id <> -1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_search_delete AFTER DELETE ON Comments BEGIN
+    DELETE FROM CommentSearch WHERE docid = OLD.id;
+END;
@@ -0,0 +1,16 @@
+-- deleted users, projects, posts and comments are kept until they are
+-- purged, so they can be restored. Everything deleted along with an item
+-- shares its deletion_date, which is how a restore finds it again
+ALTER TABLE Users ADD COLUMN deletion_date TIMESTAMP;
+ALTER TABLE Projects ADD COLUMN deletion_date TIMESTAMP;
+ALTER TABLE Posts ADD COLUMN deletion_date TIMESTAMP;
+ALTER TABLE Comments ADD COLUMN deletion_date TIMESTAMP;
+
+-- comments deleted before then had their author and date overwritten,
+-- they keep that date so they are past restoring and get purged
+UPDATE Comments SET deletion_date = creation_date WHERE user_id = -1;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion ON Users (deletion_date);
+CREATE INDEX IF NOT EXISTS idx_projects_deletion ON Projects (deletion_date);
+CREATE INDEX IF NOT EXISTS idx_posts_deletion ON Posts (deletion_date);
+CREATE INDEX IF NOT EXISTS idx_comments_deletion ON Comments (deletion_date);
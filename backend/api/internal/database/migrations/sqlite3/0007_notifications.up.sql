@@ -0,0 +1,18 @@
+-- something a user did that another user should hear about. user_id is
+-- who hears about it and actor_id who did it, kind says what they did
+-- to target_id, and comment_id is the comment written, for comments and replies
+CREATE TABLE IF NOT EXISTS Notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    actor_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    target_id INTEGER NOT NULL,
+    comment_id INTEGER,
+    creation_date TIMESTAMP NOT NULL,
+    read_date TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON Notifications (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_target ON Notifications (kind, target_id);
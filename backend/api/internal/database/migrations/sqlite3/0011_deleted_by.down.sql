@@ -0,0 +1,4 @@
+ALTER TABLE Comments DROP COLUMN deleted_by;
+ALTER TABLE Posts DROP COLUMN deleted_by;
+ALTER TABLE Projects DROP COLUMN deleted_by;
+ALTER TABLE Users DROP COLUMN deleted_by;
@@ -0,0 +1,8 @@
+-- who deleted an item, so owners can only restore what they deleted
+-- themselves and not what a moderator took down. Everything deleted along
+-- with an item shares its deleted_by. Items deleted before then have none,
+-- and are left for moderators to restore
+ALTER TABLE Users ADD COLUMN deleted_by INTEGER;
+ALTER TABLE Projects ADD COLUMN deleted_by INTEGER;
+ALTER TABLE Posts ADD COLUMN deleted_by INTEGER;
+ALTER TABLE Comments ADD COLUMN deleted_by INTEGER;
@@ -0,0 +1,3 @@
+ALTER TABLE EmailPreferences DROP COLUMN confirmed_date;
+ALTER TABLE EmailPreferences DROP COLUMN confirmation_date;
+ALTER TABLE EmailPreferences DROP COLUMN confirmation_hash;
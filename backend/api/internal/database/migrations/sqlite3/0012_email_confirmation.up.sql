@@ -0,0 +1,8 @@
+-- digests only go to addresses their owner confirmed through the link
+-- emailed to them, so nobody can sign someone else's inbox up.
+-- confirmation_hash is the hash of the token in the latest link,
+-- confirmation_date when it was sent and confirmed_date when it was
+-- followed. Addresses set before then have to be set again to be confirmed
+ALTER TABLE EmailPreferences ADD COLUMN confirmation_hash TEXT;
+ALTER TABLE EmailPreferences ADD COLUMN confirmation_date TIMESTAMP;
+ALTER TABLE EmailPreferences ADD COLUMN confirmed_date TIMESTAMP;
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// the kinds of notifications, named after what was done to what
+const (
+	NotifyPostLike       = "post_like"
+	NotifyProjectLike    = "project_like"
+	NotifyCommentLike    = "comment_like"
+	NotifyUserFollow     = "user_follow"
+	NotifyProjectFollow  = "project_follow"
+	NotifyPostComment    = "post_comment"
+	NotifyProjectComment = "project_comment"
+	NotifyCommentReply   = "comment_reply"
+)
+
+// a kind of notification. `recipient` selects who gets it from the id of
+// what was acted on, and `verb` says what happened in the message.
+type notificationKind struct {
+	verb      string
+	recipient string
+}
+
+var notificationKinds = map[string]notificationKind{
+	NotifyPostLike:       {"liked your post", `SELECT user_id FROM Posts WHERE id = ? AND deletion_date IS NULL`},
+	NotifyProjectLike:    {"liked your project", `SELECT owner FROM Projects WHERE id = ? AND deletion_date IS NULL`},
+	NotifyCommentLike:    {"liked your comment", `SELECT user_id FROM Comments WHERE id = ? AND deletion_date IS NULL`},
+	NotifyUserFollow:     {"followed you", `SELECT id FROM Users WHERE id = ? AND deletion_date IS NULL`},
+	NotifyProjectFollow:  {"followed your project", `SELECT owner FROM Projects WHERE id = ? AND deletion_date IS NULL`},
+	NotifyPostComment:    {"commented on your post", `SELECT user_id FROM Posts WHERE id = ? AND deletion_date IS NULL`},
+	NotifyProjectComment: {"commented on your project", `SELECT owner FROM Projects WHERE id = ? AND deletion_date IS NULL`},
+	NotifyCommentReply:   {"replied to your comment", `SELECT user_id FROM Comments WHERE id = ? AND deletion_date IS NULL`},
+}
+
+// NotificationMessage describes a group of notifications by its latest
+// actor, like "dev_user1 and 4 others liked your post".
+//
+// Parameters:
+//   - kind: The kind of the notifications.
+//   - actor: The username of the latest actor.
+//   - actors: How many users acted, counting the latest one.
+//
+// Returns:
+//   - string: The message shown for the group.
+func NotificationMessage(kind string, actor string, actors int64) string {
+	verb := notificationKinds[kind].verb
+	switch {
+	case actors <= 1:
+		return fmt.Sprintf("%v %v", actor, verb)
+	case actors == 2:
+		return fmt.Sprintf("%v and 1 other %v", actor, verb)
+	default:
+		return fmt.Sprintf("%v and %v others %v", actor, actors-1, verb)
+	}
+}
+
+// notify lets the owner of `target` know that `actor` acted on it, as part
+// of the transaction that records the action. Nobody is notified of what
+// they did to their own things.
+//
+// Parameters:
+//   - tx: The transaction recording the action.
+//   - kind: What was done, one of the Notify constants.
+//   - actor: The id of the user who acted.
+//   - target: The id of the post, project, comment or user acted on.
+//   - comment: The id of the comment written, nil unless one was.
+//   - now: When the action was made.
+//
+// Returns:
+//   - error: An error if the notification cannot be recorded.
+func notify(tx *Tx, kind string, actor int64, target int64, comment *int64, now time.Time) error {
+	var recipient int64
+	err := tx.QueryRow(notificationKinds[kind].recipient, target).Scan(&recipient)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to find who to notify: %v", err)
+	}
+	if recipient == actor {
+		return nil
+	}
+
+	query := `INSERT INTO Notifications (user_id, actor_id, kind, target_id, comment_id, creation_date)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = tx.Exec(query, recipient, actor, kind, target, comment, now)
+	if err != nil {
+		return fmt.Errorf("Failed to create notification: %v", err)
+	}
+	return nil
+}
+
+// notifications from deleted users are left out until they are restored
+const activeActors = `actor_id IN (SELECT id FROM Users WHERE deletion_date IS NULL)`
+
+// QueryNotifications retrieves a page of a user's notifications, grouped
+// by what they are about, latest group first.
+//
+// Parameters:
+//   - userId: The id of the user the notifications are for.
+//   - page: The page to retrieve, which continues after the id of a group's latest notification.
+//
+// Returns:
+//   - []types.Notification: The groups of notifications on the page.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryNotifications(userId int64, page types.Page) ([]types.Notification, *types.Cursor, int, error) {
+	query := `SELECT g.latest, g.kind, g.target_id, g.actors, g.unread, n.comment_id, u.username, n.creation_date
+              FROM (
+                  SELECT kind, target_id, MAX(id) AS latest, COUNT(DISTINCT actor_id) AS actors,
+                         SUM(CASE WHEN read_date IS NULL THEN 1 ELSE 0 END) AS unread
+                  FROM Notifications
+                  WHERE user_id = ? AND ` + activeActors + `
+                  GROUP BY kind, target_id
+                  HAVING MAX(id) < ?
+              ) g
+              JOIN Notifications n ON n.id = g.latest
+              JOIN Users u ON u.id = n.actor_id
+              ORDER BY g.latest DESC
+              LIMIT ?`
+
+	rows, err := db.Query(query, userId, descendingFrom(page).ID, fetchLimit(page))
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []types.Notification{}
+	for rows.Next() {
+		var notification types.Notification
+		var unread int64
+		var comment sql.NullInt64
+		err = rows.Scan(&notification.ID, &notification.Kind, &notification.Target, &notification.Actors,
+			&unread, &comment, &notification.Actor, &notification.CreationDate)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan notification: %v", err)
+		}
+		if comment.Valid {
+			notification.Comment = &comment.Int64
+		}
+		notification.Read = unread == 0
+		notification.Message = NotificationMessage(notification.Kind, notification.Actor, notification.Actors)
+		notifications = append(notifications, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to read notifications: %v", err)
+	}
+
+	notifications, next := CutPage(notifications, page, notificationCursor)
+	return notifications, next, http.StatusOK, nil
+}
+
+// QueryUnreadNotificationCount counts the groups of notifications a user
+// has not read yet.
+//
+// Parameters:
+//   - userId: The id of the user the notifications are for.
+//
+// Returns:
+//   - int64: How many groups have unread notifications.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryUnreadNotificationCount(userId int64) (int64, int, error) {
+	query := `SELECT COUNT(*) FROM (
+                  SELECT kind, target_id FROM Notifications
+                  WHERE user_id = ? AND read_date IS NULL AND ` + activeActors + `
+                  GROUP BY kind, target_id
+              ) g`
+
+	var unread int64
+	err := db.QueryRow(query, userId).Scan(&unread)
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("Failed to count unread notifications: %v", err)
+	}
+	return unread, http.StatusOK, nil
+}
+
+// QueryMarkNotificationRead marks the group of a notification read, up to
+// and including that notification, so anything newer stays unread.
+//
+// Parameters:
+//   - userId: The id of the user the notification is for.
+//   - id: The id of the notification, usually the latest of its group.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the notification is not the user's or the update fails.
+func (db *Database) QueryMarkNotificationRead(userId int64, id int64) (int, error) {
+	var kind string
+	var target int64
+	query := `SELECT kind, target_id FROM Notifications WHERE id = ? AND user_id = ?`
+	err := db.QueryRow(query, id, userId).Scan(&kind, &target)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("Notification with id %v not found", id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to query notification: %v", err)
+	}
+
+	query = `UPDATE Notifications SET read_date = ?
+             WHERE user_id = ? AND kind = ? AND target_id = ? AND id <= ? AND read_date IS NULL`
+	_, err = db.Exec(query, time.Now().UTC(), userId, kind, target, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to mark notification read: %v", err)
+	}
+	return http.StatusOK, nil
+}
+
+// QueryMarkAllNotificationsRead marks every notification of a user read.
+//
+// Parameters:
+//   - userId: The id of the user the notifications are for.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the update fails.
+func (db *Database) QueryMarkAllNotificationsRead(userId int64) (int, error) {
+	query := `UPDATE Notifications SET read_date = ? WHERE user_id = ? AND read_date IS NULL`
+	_, err := db.Exec(query, time.Now().UTC(), userId)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to mark notifications read: %v", err)
+	}
+	return http.StatusOK, nil
+}
+
+// QueryLastNotificationId returns the id of the latest notification sent
+// to anyone, 0 if there are none.
+//
+// Returns:
+//   - int64: The latest notification id.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryLastNotificationId() (int64, int, error) {
+	var last sql.NullInt64
+	err := db.QueryRow(`SELECT MAX(id) FROM Notifications`).Scan(&last)
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("Failed to query latest notification: %v", err)
+	}
+	return last.Int64, http.StatusOK, nil
+}
+
+// QueryNotificationsAfter retrieves every notification sent to anyone
+// after the notification with id `after`, oldest first, ungrouped.
+//
+// Parameters:
+//   - after: The id of the last notification already seen.
+//
+// Returns:
+//   - []types.SentNotification: The notifications along with who they are for.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryNotificationsAfter(after int64) ([]types.SentNotification, int, error) {
+	query := `SELECT n.id, n.user_id, n.kind, n.target_id, n.comment_id, u.username, n.creation_date
+              FROM Notifications n
+              JOIN Users u ON u.id = n.actor_id
+              WHERE n.id > ? AND u.deletion_date IS NULL
+              ORDER BY n.id`
+
+	rows, err := db.Query(query, after)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	sent := []types.SentNotification{}
+	for rows.Next() {
+		var notification types.SentNotification
+		var comment sql.NullInt64
+		err = rows.Scan(&notification.ID, &notification.Recipient, &notification.Kind, &notification.Target,
+			&comment, &notification.Actor, &notification.CreationDate)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan notification: %v", err)
+		}
+		if comment.Valid {
+			notification.Comment = &comment.Int64
+		}
+		notification.Actors = 1
+		notification.Message = NotificationMessage(notification.Kind, notification.Actor, 1)
+		sent = append(sent, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read notifications: %v", err)
+	}
+	return sent, http.StatusOK, nil
+}
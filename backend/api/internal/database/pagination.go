@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// topCursor sorts before every row of a list in descending order, so the
+// first page of those lists starts after it
+var topCursor = types.Cursor{
+	Time:  time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+	Likes: math.MaxInt64,
+	Score: math.MaxFloat64,
+	ID:    math.MaxInt64,
+}
+
+// descendingFrom returns the cursor a list sorted in descending order
+// continues after.
+//
+// Parameters:
+//   - page: The page being queried.
+//
+// Returns:
+//   - types.Cursor: The page's cursor, or one before every row on the first page.
+func descendingFrom(page types.Page) types.Cursor {
+	if page.After == nil {
+		return topCursor
+	}
+	return *page.After
+}
+
+// ascendingFrom returns the id a list sorted by ascending id continues after.
+//
+// Parameters:
+//   - page: The page being queried.
+//
+// Returns:
+//   - int64: The page's cursor id, or 0 on the first page.
+func ascendingFrom(page types.Page) int64 {
+	if page.After == nil {
+		return 0
+	}
+	return page.After.ID
+}
+
+// fetchLimit returns the LIMIT to query a page with. It asks for one row
+// more than the page holds, which tells CutPage whether another page follows.
+//
+// Parameters:
+//   - page: The page being queried.
+//
+// Returns:
+//   - int: The number of rows to query.
+func fetchLimit(page types.Page) int {
+	if page.Limit <= 0 {
+		return math.MaxInt32
+	}
+	return page.Limit + 1
+}
+
+// CutPage trims the extra item fetched for a page off a sorted list and
+// returns the cursor of the page that follows.
+//
+// Parameters:
+//   - items: The items queried with fetchLimit, in list order.
+//   - page: The page being queried.
+//   - cursorOf: Builds the cursor pointing at an item.
+//
+// Returns:
+//   - []T: The items on the page.
+//   - *types.Cursor: The cursor of the next page, nil if this is the last one.
+func CutPage[T any](items []T, page types.Page, cursorOf func(T) types.Cursor) ([]T, *types.Cursor) {
+	if page.Limit <= 0 || len(items) <= page.Limit {
+		return items, nil
+	}
+	items = items[:page.Limit]
+	next := cursorOf(items[len(items)-1])
+	return items, &next
+}
+
+// postCursor points at a post by its creation date, likes and id.
+func postCursor(post types.Post) types.Cursor {
+	return types.Cursor{Time: post.CreationDate, Likes: post.Likes, ID: post.ID}
+}
+
+// projectCursor points at a project by its creation date, likes and id.
+func projectCursor(project types.Project) types.Cursor {
+	return types.Cursor{Time: project.CreationDate, Likes: project.Likes, ID: project.ID}
+}
+
+// commentCursor points at a comment by its creation date, likes and id.
+func commentCursor(comment types.Comment) types.Cursor {
+	return types.Cursor{Time: comment.CreationDate, Likes: comment.Likes, ID: comment.ID}
+}
+
+// notificationCursor points at a group of notifications by its latest one.
+func notificationCursor(notification types.Notification) types.Cursor {
+	return types.Cursor{ID: notification.ID}
+}
+
+// deliveryCursor points at a webhook delivery by its id.
+func deliveryCursor(delivery types.WebhookDelivery) types.Cursor {
+	return types.Cursor{ID: delivery.ID}
+}
+
+// idCursor points at an item of a list sorted by id alone.
+func idCursor(id int) types.Cursor {
+	return types.Cursor{ID: int64(id)}
+}
+
+// scanNamedPage scans a page of `id, name` rows, sorted by id, and
+// returns the names along with the cursor of the next page.
+//
+// Parameters:
+//   - rows: The rows of the query, fetched with fetchLimit.
+//   - page: The page being queried.
+//
+// Returns:
+//   - []string: The names on the page.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if scanning fails.
+func scanNamedPage(rows *sql.Rows, page types.Page) ([]string, *types.Cursor, int, error) {
+	var ids []int
+	var names []string
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	ids, next := CutPage(ids, page, idCursor)
+	return names[:len(ids)], next, http.StatusOK, nil
+}
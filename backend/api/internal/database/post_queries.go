@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"backend/api/internal/trending"
 	"backend/api/internal/types"
 )
 
@@ -18,9 +19,9 @@ import (
 // Returns:
 //   - *types.Post: The post details if found.
 //   - error: An error if the query fails. Returns nil for both if no post exists.
-func QueryPost(id int) (*types.Post, error) {
-	query := `SELECT id, user_id, project_id, content, likes, creation_date FROM Posts WHERE id = ?;`
-	row := DB.QueryRow(query, id)
+func (db *Database) QueryPost(id int) (*types.Post, error) {
+	query := `SELECT id, user_id, project_id, content, likes, creation_date, edit_date FROM Posts WHERE id = ? AND deletion_date IS NULL;`
+	row := db.QueryRow(query, id)
 	var post types.Post
 
 	err := row.Scan(
@@ -30,6 +31,7 @@ func QueryPost(id int) (*types.Post, error) {
 		&post.Content,
 		&post.Likes,
 		&post.CreationDate,
+		&post.EditedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -49,51 +51,57 @@ func QueryPost(id int) (*types.Post, error) {
 // Returns:
 //   - int64: The ID of the newly created post.
 //   - error: An error if the operation fails.
-func QueryCreatePost(post *types.Post) (int64, error) {
+func (db *Database) QueryCreatePost(post *types.Post) (int64, error) {
 	currentTime := time.Now().UTC()
 
-	query := `INSERT INTO Posts (user_id, project_id, content, likes, creation_date) 
-              VALUES (?, ?, ?, ?, ?);`
+	query := `INSERT INTO Posts (user_id, project_id, content, likes, creation_date, trending_score) 
+              VALUES (?, ?, ?, ?, ?, ?);`
 
-	res, err := DB.Exec(query, post.ID, post.User, post.Project, post.Content, post.Likes, currentTime)
+	lastId, err := db.InsertReturningId(query, post.User, post.Project, post.Content, post.Likes, currentTime, trending.Initial(currentTime))
 	if err != nil {
 		return -1, fmt.Errorf("Failed to create post: %v", err)
 	}
 
-	lastId, err := res.LastInsertId()
-	if err != nil {
-		return -1, fmt.Errorf("Failed to ensure post was created: %v", err)
-	}
-
 	return lastId, nil
 }
 
-// QueryDeletePost deletes a post by its ID.
+// QueryDeletePost deletes a post by its ID, along with the comments on it.
+// They are kept as tombstones until they are purged, so they can be restored.
 //
 // Parameters:
 //   - id: The unique identifier of the post to delete.
+//   - deletedBy: The id of the user deleting it.
 //
 // Returns:
 //   - int16: http status code indicating the result of the operation.
 //   - error: An error if the operation fails or no post is found.
-func QueryDeletePost(id int) (int16, error) {
-	query := `DELETE from Posts WHERE id=?;`
-	res, err := DB.Exec(query, id)
+func (db *Database) QueryDeletePost(id int, deletedBy int64) (int16, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("Failed to delete post `%v`: %v", id, err)
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if rowsAffected == 0 {
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	deleted, err := tombstone(tx, "post", int64(id), deletionTime(), deletedBy)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete post `%v`: %v", id, err)
+	}
+	if !deleted {
 		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
-	} else if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
 	}
 
 	return http.StatusOK, nil
 }
 
-// QueryUpdateProject updates an existing post in the database.
+// QueryUpdateProject updates an existing post in the database. When the
+// content changes, what the post said before is kept as a revision.
 //
 // Parameters:
 //   - id: The unique identifier of the post to update.
@@ -101,7 +109,32 @@ func QueryDeletePost(id int) (int16, error) {
 //
 // Returns:
 //   - error: An error if the operation fails or no post is found.
-func QueryUpdatePost(id int, updatedData map[string]interface{}) error {
+func (db *Database) QueryUpdatePost(id int, updatedData map[string]interface{}) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("Failed to commit update of post %v: %v", id, err)
+		}
+	}()
+
+	if content, ok := updatedData["content"]; ok {
+		now := time.Now().UTC()
+		var edited bool
+		edited, err = saveRevision(tx, postRevisions, id, content, now)
+		if err != nil {
+			return err
+		}
+		if edited {
+			updatedData = withField(updatedData, "edit_date", now)
+		}
+	}
+
 	query := `UPDATE Posts SET `
 	var args []interface{}
 
@@ -113,31 +146,38 @@ func QueryUpdatePost(id int, updatedData map[string]interface{}) error {
 	query += queryParams + " WHERE id = ?"
 	args = append(args, id)
 
-	rowsAffected, err := ExecUpdate(query, args...)
+	res, err := tx.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("Error executing update query: %v", err)
 	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Error checking rows affected: %v", err)
+	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("No post found with id `%d` to update", id)
+		err = fmt.Errorf("No post found with id `%d` to update", id)
+		return err
 	}
 
 	return nil
 }
 
-// QueryPostsByUserId retrieves a set of posts by its owning user id from the database.
+// QueryPostsByUserId retrieves a page of posts by its owning user id from the database.
 //
 // Parameters:
 //   - id: The unique identifier of the user to query.
+//   - page: The cursor to continue after and the amount of posts to return.
 //
 // Returns:
 //   - []types.Post: The post details if found.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - error: An error if the query fails. Returns nil for both if no post exists.
-func QueryPostsByUserId(userId int) ([]types.Post, int, error) {
-	query := `SELECT id, user_id, project_id, content, likes, creation_date FROM Posts WHERE user_id = ?;`
+func (db *Database) QueryPostsByUserId(userId int, page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	query := `SELECT id, user_id, project_id, content, likes, creation_date, edit_date FROM Posts WHERE user_id = ? AND deletion_date IS NULL AND id > ? ORDER BY id LIMIT ?;`
 
-	rows, err := DB.Query(query, userId)
+	rows, err := db.Query(query, userId, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 
@@ -152,18 +192,20 @@ func QueryPostsByUserId(userId int) ([]types.Post, int, error) {
 			&post.Content,
 			&post.Likes,
 			&post.CreationDate,
+			&post.EditedAt,
 		)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return []types.Post{}, http.StatusOK, nil
+				return []types.Post{}, nil, http.StatusOK, nil
 			}
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
 		posts = append(posts, post)
 	}
 
-	return posts, http.StatusOK, nil
+	posts, next := CutPage(posts, page, postCursor)
+	return posts, next, http.StatusOK, nil
 }
 
 // QueryPostsByProjectId retrieves a set of posts by its owning project id from the database.
@@ -174,10 +216,10 @@ func QueryPostsByUserId(userId int) ([]types.Post, int, error) {
 // Returns:
 //   - *types.Post: The post details if found.
 //   - error: An error if the query fails. Returns nil for both if no post exists.
-func QueryPostsByProjectId(projId int) ([]types.Post, int, error) {
-	query := `SELECT id, user_id, project_id, content, likes, creation_date FROM Posts WHERE project_id = ?;`
+func (db *Database) QueryPostsByProjectId(projId int) ([]types.Post, int, error) {
+	query := `SELECT id, user_id, project_id, content, likes, creation_date, edit_date FROM Posts WHERE project_id = ? AND deletion_date IS NULL ORDER BY id;`
 
-	rows, err := DB.Query(query, projId)
+	rows, err := db.Query(query, projId)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -194,6 +236,7 @@ func QueryPostsByProjectId(projId int) ([]types.Post, int, error) {
 			&post.Content,
 			&post.Likes,
 			&post.CreationDate,
+			&post.EditedAt,
 		)
 
 		if err != nil {
@@ -216,9 +259,9 @@ func QueryPostsByProjectId(projId int) ([]types.Post, int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the post.
-func CreatePostLike(username string, strPostId string) (int, error) {
+func (db *Database) CreatePostLike(username string, strPostId string) (int, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -230,7 +273,7 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 	}
 
 	// verify post exists
-	post, err := QueryPost(postId)
+	post, err := db.QueryPost(postId)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred verifying the post exists: %v", err)
 	} else if post == nil {
@@ -242,7 +285,7 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM PostLikes WHERE user_id = ? AND post_id = ?
               )`
-	err = DB.QueryRow(query, user_id, postId).Scan(&exists)
+	err = db.QueryRow(query, user_id, postId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
@@ -250,7 +293,7 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 		// like already exists, but we return success to keep it idempotent
 		return http.StatusOK, nil
 	}
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -263,8 +306,9 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 		}
 	}()
 	// insert the like
-	insertQuery := `INSERT INTO PostLikes (user_id, post_id) VALUES (?, ?)`
-	_, err = tx.Exec(insertQuery, user_id, postId)
+	currentTime := time.Now().UTC()
+	insertQuery := `INSERT INTO PostLikes (user_id, post_id, creation_date) VALUES (?, ?, ?)`
+	_, err = tx.Exec(insertQuery, user_id, postId, currentTime)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to insert post like: %v", err)
 	}
@@ -276,6 +320,16 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to update likes count: %v", err)
 	}
 
+	err = addTrendingEvent(tx, "Posts", int64(postId), trending.Event{Weight: trending.LikeWeight, Time: currentTime})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = notify(tx, NotifyPostLike, int64(user_id), int64(postId), nil, currentTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusCreated, nil
 }
 
@@ -288,9 +342,9 @@ func CreatePostLike(username string, strPostId string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the post.
-func RemovePostLike(username string, strPostId string) (int, error) {
+func (db *Database) RemovePostLike(username string, strPostId string) (int, error) {
 	// get user ID
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -302,11 +356,11 @@ func RemovePostLike(username string, strPostId string) (int, error) {
 	}
 
 	// verify post exists
-	_, err = QueryPost(postId)
+	_, err = db.QueryPost(postId)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred verifying the post exists: %v", err)
 	}
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -318,6 +372,16 @@ func RemovePostLike(username string, strPostId string) (int, error) {
 			tx.Commit()
 		}
 	}()
+	// remember when the like was made, to take it back out of the trending score
+	var likedAt sql.NullTime
+	dateQuery := `SELECT creation_date FROM PostLikes WHERE user_id = ? AND post_id = ?`
+	err = tx.QueryRow(dateQuery, user_id, postId).Scan(&likedAt)
+	if err == sql.ErrNoRows {
+		err = nil
+	} else if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to query post like: %v", err)
+	}
+
 	// perform the delete operation
 	deleteQuery := `DELETE FROM PostLikes WHERE user_id = ? AND post_id = ?`
 	result, err := tx.Exec(deleteQuery, user_id, postId)
@@ -343,6 +407,11 @@ func RemovePostLike(username string, strPostId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to update likes count: %v", err)
 	}
 
+	err = removeTrendingEvent(tx, "Posts", int64(postId), trending.LikeWeight, likedAt)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusOK, nil
 }
 
@@ -355,9 +424,9 @@ func RemovePostLike(username string, strPostId string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or.
-func QueryPostLike(username string, strPostId string) (int, bool, error) {
+func (db *Database) QueryPostLike(username string, strPostId string) (int, bool, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -369,7 +438,7 @@ func QueryPostLike(username string, strPostId string) (int, bool, error) {
 	}
 
 	// verify post exists
-	_, err = QueryPost(postId)
+	_, err = db.QueryPost(postId)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred verifying the post exists: %v", err)
 	}
@@ -379,7 +448,7 @@ func QueryPostLike(username string, strPostId string) (int, bool, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM PostLikes WHERE user_id = ? AND post_id = ?
               )`
-	err = DB.QueryRow(query, user_id, postId).Scan(&exists)
+	err = db.QueryRow(query, user_id, postId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
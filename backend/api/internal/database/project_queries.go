@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"backend/api/internal/trending"
 	"backend/api/internal/types"
 )
 
@@ -19,9 +20,9 @@ import (
 // Returns:
 //   - *types.Project: The project details if found.
 //   - error: An error if the query fails. Returns nil for both if no project exists.
-func QueryProject(id int) (*types.Project, error) {
-	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date FROM Projects WHERE id = ?;`
-	row := DB.QueryRow(query, id)
+func (db *Database) QueryProject(id int) (*types.Project, error) {
+	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date FROM Projects WHERE id = ? AND deletion_date IS NULL;`
+	row := db.QueryRow(query, id)
 	var project types.Project
 	var linksJSON, tagsJSON string
 
@@ -61,9 +62,9 @@ func QueryProject(id int) (*types.Project, error) {
 // Returns:
 //   - *[]types.Project: A list of the projects' details if found.
 //   - error: An error if the query fails. Returns nil for both if no project exists.
-func QueryProjectsByUserId(userId int) ([]types.Project, int, error) {
-	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date FROM Projects WHERE owner = ?;`
-	rows, err := DB.Query(query, userId)
+func (db *Database) QueryProjectsByUserId(userId int) ([]types.Project, int, error) {
+	query := `SELECT id, name, description, status, likes, links, tags, owner, creation_date FROM Projects WHERE owner = ? AND deletion_date IS NULL ORDER BY id;`
+	rows, err := db.Query(query, userId)
 	if err != nil {
 		return nil, http.StatusNotFound, err
 	}
@@ -97,6 +98,7 @@ func QueryProjectsByUserId(userId int) ([]types.Project, int, error) {
 		if err := UnmarshalFromJSON(tagsJSON, &project.Tags); err != nil {
 			return nil, http.StatusBadRequest, err
 		}
+		projects = append(projects, project)
 	}
 
 	return projects, http.StatusOK, nil
@@ -110,7 +112,7 @@ func QueryProjectsByUserId(userId int) ([]types.Project, int, error) {
 // Returns:
 //   - int64: The ID of the newly created project.
 //   - error: An error if the operation fails.
-func QueryCreateProject(proj *types.Project) (int64, error) {
+func (db *Database) QueryCreateProject(proj *types.Project) (int64, error) {
 	linksJSON, err := MarshalToJSON(proj.Links)
 	if err != nil {
 		return -1, err
@@ -121,44 +123,68 @@ func QueryCreateProject(proj *types.Project) (int64, error) {
 		return -1, err
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
 	currentTime := time.Now().UTC()
 
-	query := `INSERT INTO Projects (name, description, status, links, tags, owner, creation_date)
-              VALUES (?, ?, ?, ?, ?, ?, ?);`
+	query := `INSERT INTO Projects (name, description, status, links, tags, owner, creation_date, trending_score)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
 
-	res, err := DB.Exec(query, proj.Name, proj.Description, proj.Status, string(linksJSON), string(tagsJSON), proj.Owner, currentTime)
+	lastId, err := tx.InsertReturningId(query, proj.Name, proj.Description, proj.Status, string(linksJSON), string(tagsJSON), proj.Owner, currentTime, trending.Initial(currentTime))
 	if err != nil {
 		return -1, fmt.Errorf("Failed to create project '%v': %v", proj.Name, err)
 	}
 
-	lastId, err := res.LastInsertId()
+	err = replaceProjectTags(tx, lastId, proj.Tags)
 	if err != nil {
-		return -1, fmt.Errorf("Failed to ensure project was created: %v", err)
+		return -1, err
 	}
 
 	return lastId, nil
 }
 
-// QueryDeleteProject deletes a project by its ID.
+// QueryDeleteProject deletes a project by its ID, along with its posts and
+// the comments on both. They are kept as tombstones until they are purged,
+// so they can be restored.
 //
 // Parameters:
 //   - id: The unique identifier of the project to delete.
+//   - deletedBy: The id of the user deleting it.
 //
 // Returns:
 //   - int16: http status code indicating the result of the operation.
 //   - error: An error if the operation fails or no project is found.
-func QueryDeleteProject(id int) (int16, error) {
-	query := `DELETE from Projects WHERE id=?;`
-	res, err := DB.Exec(query, id)
+func (db *Database) QueryDeleteProject(id int, deletedBy int64) (int16, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("Failed to delete project `%v`: %v", id, err)
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if rowsAffected == 0 {
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	deleted, err := tombstone(tx, "project", int64(id), deletionTime(), deletedBy)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete project `%v`: %v", id, err)
+	}
+	if !deleted {
 		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
-	} else if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
 	}
 
 	return http.StatusOK, nil
@@ -172,7 +198,7 @@ func QueryDeleteProject(id int) (int16, error) {
 //
 // Returns:
 //   - error: An error if the operation fails or no project is found.
-func QueryUpdateProject(id int, updatedData map[string]interface{}) error {
+func (db *Database) QueryUpdateProject(id int, updatedData map[string]interface{}) error {
 	query := `UPDATE Projects SET `
 	var args []interface{}
 
@@ -184,12 +210,47 @@ func QueryUpdateProject(id int, updatedData map[string]interface{}) error {
 	query += queryParams + " WHERE id = ?"
 	args = append(args, id)
 
-	rowsAffected, err := ExecUpdate(query, args...)
+	// new tags replace the project's rows in ProjectTags along with the update
+	var tags []string
+	value, updatesTags := updatedData["tags"]
+	if updatesTags {
+		tags, err = tagsOf(value)
+		if err != nil {
+			return fmt.Errorf("Error building query: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("Error executing update query: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("Error executing update query: %v", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("No project found with id `%d` to update", id)
+		err = fmt.Errorf("No project found with id `%d` to update", id)
+		return err
+	}
+
+	if updatesTags {
+		err = replaceProjectTags(tx, int64(id), tags)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -199,38 +260,46 @@ func QueryUpdateProject(id int, updatedData map[string]interface{}) error {
 //
 // Parameters:
 //   - projectID: The unique identifier of the project.
+//   - page: The cursor to continue after and the amount of IDs to return.
 //
 // Returns:
 //   - []int: A list of user IDs who follow the project.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func QueryGetProjectFollowers(projectID int) ([]int, int, error) {
+func (db *Database) QueryGetProjectFollowers(projectID int, page types.Page) ([]int, *types.Cursor, int, error) {
 	query := `
         SELECT u.id
         FROM Users u
         JOIN ProjectFollows pf ON u.id = pf.user_id
-        WHERE pf.project_id = ?`
+        WHERE pf.project_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getProjectFollowersOrFollowing(query, projectID)
+	return db.getProjectFollowersOrFollowing(query, projectID, page)
 }
 
 // QueryGetProjectFollowersUsernames retrieves the usernames of a project's followers.
 //
 // Parameters:
 //   - projectID: The unique identifier of the project.
+//   - page: The cursor to continue after and the amount of usernames to return.
 //
 // Returns:
 //   - []string: A list of usernames of the project's followers.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func QueryGetProjectFollowersUsernames(projectID int) ([]string, int, error) {
+func (db *Database) QueryGetProjectFollowersUsernames(projectID int, page types.Page) ([]string, *types.Cursor, int, error) {
 	query := `
-        SELECT u.username
+        SELECT u.id, u.username
         FROM Users u
         JOIN ProjectFollows pf ON u.id = pf.user_id
-        WHERE pf.project_id = ?`
+        WHERE pf.project_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getProjectFollowersOrFollowingUsernames(query, projectID)
+	return db.getProjectFollowersOrFollowingUsernames(query, projectID, page)
 }
 
 // QueryGetProjectFollowing retrieves the project IDs a user is following.
@@ -242,8 +311,8 @@ func QueryGetProjectFollowersUsernames(projectID int) ([]string, int, error) {
 //   - []int: A list of project IDs the user is following.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func QueryGetProjectFollowing(username string) ([]int, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetProjectFollowing(username string) ([]int, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return nil, 0, fmt.Errorf("Error fetching user id from username: %v", err)
 	}
@@ -252,9 +321,12 @@ func QueryGetProjectFollowing(username string) ([]int, int, error) {
         SELECT p.id
         FROM Projects p
         JOIN ProjectFollows pf ON p.id = pf.project_id
-        WHERE pf.user_id = ?`
+        WHERE pf.user_id = ? AND p.deletion_date IS NULL AND p.id > ?
+        ORDER BY p.id
+        LIMIT ?`
 
-	return getProjectFollowersOrFollowing(query, userID)
+	projectIDs, _, httpCode, err := db.getProjectFollowersOrFollowing(query, userID, types.Page{})
+	return projectIDs, httpCode, err
 }
 
 // QueryGetProjectFollowingNames retrieves the project names a user is following.
@@ -266,35 +338,40 @@ func QueryGetProjectFollowing(username string) ([]int, int, error) {
 //   - []string: A list of project names the user is following.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func QueryGetProjectFollowingNames(username string) ([]string, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetProjectFollowingNames(username string) ([]string, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return nil, 0, fmt.Errorf("Error fetching user id from username: %v", err)
 	}
 
 	query := `
-        SELECT p.name
+        SELECT p.id, p.name
         FROM Projects p
         JOIN ProjectFollows pf ON p.id = pf.project_id
-        WHERE pf.user_id = ?`
+        WHERE pf.user_id = ? AND p.deletion_date IS NULL AND p.id > ?
+        ORDER BY p.id
+        LIMIT ?`
 
-	return getProjectFollowersOrFollowingUsernames(query, userID)
+	names, _, httpCode, err := db.getProjectFollowersOrFollowingUsernames(query, userID, types.Page{})
+	return names, httpCode, err
 }
 
 // getProjectFollowersOrFollowing is a helper function for retrieving follower or following IDs.
 //
 // Parameters:
-//   - query: The SQL query string to execute.
+//   - query: The SQL query string to execute, taking the id, the id to continue after and a limit.
 //   - userID: The unique identifier of the user.
+//   - page: The page to retrieve.
 //
 // Returns:
 //   - []int: A list of IDs retrieved by the query.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func getProjectFollowersOrFollowing(query string, userID int) ([]int, int, error) {
-	rows, err := DB.Query(query, userID)
+func (db *Database) getProjectFollowersOrFollowing(query string, userID int, page types.Page) ([]int, *types.Cursor, int, error) {
+	rows, err := db.Query(query, userID, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 
@@ -302,49 +379,39 @@ func getProjectFollowersOrFollowing(query string, userID int) ([]int, int, error
 	for rows.Next() {
 		var projectID int
 		if err := rows.Scan(&projectID); err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
 		projectIDs = append(projectIDs, projectID)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, http.StatusInternalServerError, err
+		return nil, nil, http.StatusInternalServerError, err
 	}
 
-	return projectIDs, http.StatusOK, nil
+	projectIDs, next := CutPage(projectIDs, page, idCursor)
+	return projectIDs, next, http.StatusOK, nil
 }
 
 // getProjectFollowersOrFollowingUsernames is a helper function for retrieving follower or following usernames.
 //
 // Parameters:
-//   - query: The SQL query string to execute.
+//   - query: The SQL query string to execute, selecting ids and names.
 //   - projectID: The unique identifier of the project.
+//   - page: The page to retrieve.
 //
 // Returns:
 //   - []string: A list of usernames retrieved by the query.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the query fails.
-func getProjectFollowersOrFollowingUsernames(query string, projectID int) ([]string, int, error) {
-	rows, err := DB.Query(query, projectID)
+func (db *Database) getProjectFollowersOrFollowingUsernames(query string, projectID int, page types.Page) ([]string, *types.Cursor, int, error) {
+	rows, err := db.Query(query, projectID, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 
-	var projectNames []string
-	for rows.Next() {
-		var projectName string
-		if err := rows.Scan(&projectName); err != nil {
-			return nil, http.StatusInternalServerError, err
-		}
-		projectNames = append(projectNames, projectName)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, http.StatusInternalServerError, err
-	}
-
-	return projectNames, http.StatusOK, nil
+	return scanNamedPage(rows, page)
 }
 
 // CreateNewProjectFollow creates a follow relationship between a user and a project.
@@ -356,8 +423,8 @@ func getProjectFollowersOrFollowingUsernames(query string, projectID int) ([]str
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is already following the project.
-func CreateNewProjectFollow(username string, projectID string) (int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) CreateNewProjectFollow(username string, projectID string) (int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", username)
 	}
@@ -366,12 +433,12 @@ func CreateNewProjectFollow(username string, projectID string) (int, error) {
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred parsing project id: %v", projectID)
 	}
-	currFollowing, httpCode, err := QueryGetProjectFollowers(userID)
+	currFollowing, httpCode, err := db.QueryGetProjectFollowing(username)
 	if err != nil {
 		return httpCode, fmt.Errorf("Cannot retrieve user's following list: %v", err)
 	}
 
-    existingProj, err := QueryProject(intProjectID)
+    existingProj, err := db.QueryProject(intProjectID)
     if err != nil {
         return http.StatusInternalServerError, fmt.Errorf("Error querying for existing project: %v", err)
     }
@@ -384,14 +451,34 @@ func CreateNewProjectFollow(username string, projectID string) (int, error) {
 		return http.StatusConflict, fmt.Errorf("User is already following this project")
 	}
 
-	query := `INSERT INTO ProjectFollows (user_id, project_id) VALUES (?, ?)`
-	rowsAffected, err := ExecUpdate(query, userID, projectID)
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	currentTime := time.Now().UTC()
+	query := `INSERT INTO ProjectFollows (user_id, project_id, creation_date) VALUES (?, ?, ?)`
+	_, err = tx.Exec(query, userID, intProjectID, currentTime)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred adding project follow: %v", err)
 	}
 
-	if rowsAffected == 0 {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to add the follow relationship")
+	err = addTrendingEvent(tx, "Projects", int64(intProjectID), trending.Event{Weight: trending.FollowWeight, Time: currentTime})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = notify(tx, NotifyProjectFollow, int64(userID), int64(intProjectID), nil, currentTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
 	return http.StatusOK, nil
@@ -406,8 +493,8 @@ func CreateNewProjectFollow(username string, projectID string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not following the project.
-func RemoveProjectFollow(username string, projectID string) (int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) RemoveProjectFollow(username string, projectID string) (int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", username)
 	}
@@ -417,7 +504,7 @@ func RemoveProjectFollow(username string, projectID string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred parsing project id: %v", projectID)
 	}
 
-    existingProj, err := QueryProject(intProjectID)
+    existingProj, err := db.QueryProject(intProjectID)
     if err != nil {
         return http.StatusInternalServerError, fmt.Errorf("Error querying for existing project: %v", err)
     }
@@ -426,7 +513,7 @@ func RemoveProjectFollow(username string, projectID string) (int, error) {
         return http.StatusNotFound, fmt.Errorf("Project with id %v does not exist", intProjectID)
     }
 
-	currFollowing, httpCode, err := QueryGetProjectFollowers(userID)
+	currFollowing, httpCode, err := db.QueryGetProjectFollowing(username)
 	if err != nil {
 		return httpCode, fmt.Errorf("Error retrieving user's following list: %v", err)
 	}
@@ -435,14 +522,39 @@ func RemoveProjectFollow(username string, projectID string) (int, error) {
 		return http.StatusConflict, fmt.Errorf("User is not following this project")
 	}
 
-	query := `DELETE FROM ProjectFollows WHERE user_id = ? AND project_id = ?`
-	rowsAffected, err := ExecUpdate(query, userID, projectID)
+	tx, err := db.Begin()
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// remember when the follow was made, to take it back out of the trending score
+	var followedAt sql.NullTime
+	query := `SELECT creation_date FROM ProjectFollows WHERE user_id = ? AND project_id = ?`
+	err = tx.QueryRow(query, userID, intProjectID).Scan(&followedAt)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("No such follow relationship exists")
+		return http.StatusConflict, err
+	} else if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("An error occurred removing project follow: %v", err)
+	}
+
+	query = `DELETE FROM ProjectFollows WHERE user_id = ? AND project_id = ?`
+	_, err = tx.Exec(query, userID, intProjectID)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred removing project follow: %v", err)
 	}
 
-	if rowsAffected == 0 {
-		return http.StatusConflict, fmt.Errorf("No such follow relationship exists")
+	err = removeTrendingEvent(tx, "Projects", int64(intProjectID), trending.FollowWeight, followedAt)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
 	return http.StatusOK, nil
@@ -457,9 +569,9 @@ func RemoveProjectFollow(username string, projectID string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the project.
-func CreateProjectLike(username string, strProjId string) (int, error) {
+func (db *Database) CreateProjectLike(username string, strProjId string) (int, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -471,7 +583,7 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 	}
 
 	// verify project exists
-    existingProj, err := QueryProject(projId)
+    existingProj, err := db.QueryProject(projId)
     if err != nil {
         return http.StatusInternalServerError, fmt.Errorf("Error querying for existing project: %v", err)
     }
@@ -485,7 +597,7 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM ProjectLikes WHERE user_id = ? AND project_id = ?
               )`
-	err = DB.QueryRow(query, user_id, projId).Scan(&exists)
+	err = db.QueryRow(query, user_id, projId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
@@ -494,7 +606,7 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 		return http.StatusOK, nil
 	}
 
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -508,8 +620,9 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 	}()
 
 	// insert the like
-	insertQuery := `INSERT INTO ProjectLikes (user_id, project_id) VALUES (?, ?)`
-	_, err = tx.Exec(insertQuery, user_id, projId)
+	currentTime := time.Now().UTC()
+	insertQuery := `INSERT INTO ProjectLikes (user_id, project_id, creation_date) VALUES (?, ?, ?)`
+	_, err = tx.Exec(insertQuery, user_id, projId, currentTime)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to insert project like: %v", err)
 	}
@@ -521,6 +634,16 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to update likes count: %v", err)
 	}
 
+	err = addTrendingEvent(tx, "Projects", int64(projId), trending.Event{Weight: trending.LikeWeight, Time: currentTime})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = notify(tx, NotifyProjectLike, int64(user_id), int64(projId), nil, currentTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusCreated, nil
 }
 
@@ -533,9 +656,9 @@ func CreateProjectLike(username string, strProjId string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or the user is not liking the project.
-func RemoveProjectLike(username string, strProjId string) (int, error) {
+func (db *Database) RemoveProjectLike(username string, strProjId string) (int, error) {
 	// get user ID
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -547,7 +670,7 @@ func RemoveProjectLike(username string, strProjId string) (int, error) {
 	}
 
 	// verify project exists
-    existingProj, err := QueryProject(projId)
+    existingProj, err := db.QueryProject(projId)
     if err != nil {
         return http.StatusInternalServerError, fmt.Errorf("Error querying for existing project: %v", err)
     }
@@ -556,7 +679,7 @@ func RemoveProjectLike(username string, strProjId string) (int, error) {
         return http.StatusNotFound, fmt.Errorf("Project with id %v does not exist", projId)
     }
 
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return -1, fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -569,6 +692,16 @@ func RemoveProjectLike(username string, strProjId string) (int, error) {
 		}
 	}()
 
+	// remember when the like was made, to take it back out of the trending score
+	var likedAt sql.NullTime
+	dateQuery := `SELECT creation_date FROM ProjectLikes WHERE user_id = ? AND project_id = ?`
+	err = tx.QueryRow(dateQuery, user_id, projId).Scan(&likedAt)
+	if err == sql.ErrNoRows {
+		err = nil
+	} else if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to query project like: %v", err)
+	}
+
 	// perform the delete operation
 	deleteQuery := `DELETE FROM ProjectLikes WHERE user_id = ? AND project_id = ?`
 	result, err := tx.Exec(deleteQuery, user_id, projId)
@@ -594,6 +727,11 @@ func RemoveProjectLike(username string, strProjId string) (int, error) {
 		return http.StatusInternalServerError, fmt.Errorf("Failed to update likes count: %v", err)
 	}
 
+	err = removeTrendingEvent(tx, "Projects", int64(projId), trending.LikeWeight, likedAt)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusOK, nil
 }
 
@@ -606,9 +744,9 @@ func RemoveProjectLike(username string, strProjId string) (int, error) {
 // Returns:
 //   - int: HTTP-like status code indicating the result of the operation.
 //   - error: An error if the operation fails or.
-func QueryProjectLike(username string, strProjId string) (int, bool, error) {
+func (db *Database) QueryProjectLike(username string, strProjId string) (int, bool, error) {
 	// get user ID from username, implicitly checks if user exists
-	user_id, err := GetUserIdByUsername(username)
+	user_id, err := db.GetUserIdByUsername(username)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred getting id for username: %v", err)
 	}
@@ -620,7 +758,7 @@ func QueryProjectLike(username string, strProjId string) (int, bool, error) {
 	}
 
 	// verify project exists
-    existingProj, err := QueryProject(projId)
+    existingProj, err := db.QueryProject(projId)
 
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred verifying the project exists: %v", err)
@@ -634,7 +772,7 @@ func QueryProjectLike(username string, strProjId string) (int, bool, error) {
 	query := `SELECT EXISTS (
                  SELECT 1 FROM ProjectLikes WHERE user_id = ? AND project_id = ?
               )`
-	err = DB.QueryRow(query, user_id, projId).Scan(&exists)
+	err = db.QueryRow(query, user_id, projId).Scan(&exists)
 	if err != nil {
 		return http.StatusInternalServerError, false, fmt.Errorf("An error occurred checking like existence: %v", err)
 	}
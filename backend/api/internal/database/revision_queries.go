@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// something whose edits are kept, along with the table keeping them
+type revisable struct {
+	table string
+	// the table holding what the items said before each edit
+	revisions string
+	// the column of the revisions table holding the item's id
+	column string
+}
+
+var (
+	postRevisions    = revisable{table: "Posts", revisions: "PostRevisions", column: "post_id"}
+	commentRevisions = revisable{table: "Comments", revisions: "CommentRevisions", column: "comment_id"}
+)
+
+// EditWindowOpen reports whether something created at `created` can still
+// be edited at `now`. A window of zero never closes.
+func EditWindowOpen(created time.Time, now time.Time, window time.Duration) bool {
+	return window == 0 || now.Sub(created) <= window
+}
+
+// EditWindowClosedError is the error for an edit made after the window closed.
+func EditWindowClosedError(window time.Duration) error {
+	return fmt.Errorf("Cannot update comment. More than %v have passed since posting.", describeWindow(window))
+}
+
+// describeWindow writes a window the way people say it, like "2 minutes".
+func describeWindow(window time.Duration) string {
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{{24 * time.Hour, "day"}, {time.Hour, "hour"}, {time.Minute, "minute"}, {time.Second, "second"}} {
+		if window >= unit.size && window%unit.size == 0 {
+			count := int64(window / unit.size)
+			if count == 1 {
+				return "1 " + unit.name
+			}
+			return fmt.Sprintf("%v %vs", count, unit.name)
+		}
+	}
+	return window.String()
+}
+
+// saveRevision keeps what an item says before its content is replaced
+// with `content`, unless the new content is the same.
+//
+// Returns:
+//   - bool: Whether the content changes, so the item counts as edited.
+//   - error: An error if the revision cannot be saved.
+func saveRevision(tx *Tx, r revisable, id int, content interface{}, now time.Time) (bool, error) {
+	query := fmt.Sprintf(`INSERT INTO %v (%v, content, revision_date)
+            SELECT id, content, ? FROM %v WHERE id = ? AND content <> ?`, r.revisions, r.column, r.table)
+	res, err := tx.Exec(query, now, id, content)
+	if err != nil {
+		return false, fmt.Errorf("Failed to save revision: %v", err)
+	}
+	saved, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("Failed to fetch affected rows: %v", err)
+	}
+	return saved > 0, nil
+}
+
+// QueryPostRevisions retrieves what a post said before each of its edits.
+//
+// Parameters:
+//   - id: The id of the post.
+//
+// Returns:
+//   - []types.Revision: The post's earlier contents, newest first.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryPostRevisions(id int) ([]types.Revision, int, error) {
+	return db.queryRevisions(postRevisions, id)
+}
+
+// QueryCommentRevisions retrieves what a comment said before each of its edits.
+//
+// Parameters:
+//   - id: The id of the comment.
+//
+// Returns:
+//   - []types.Revision: The comment's earlier contents, newest first.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryCommentRevisions(id int) ([]types.Revision, int, error) {
+	return db.queryRevisions(commentRevisions, id)
+}
+
+func (db *Database) queryRevisions(r revisable, id int) ([]types.Revision, int, error) {
+	// the history of something deleted is hidden along with it
+	query := fmt.Sprintf(`SELECT id, content, revision_date FROM %v
+              WHERE %v = ? AND EXISTS (SELECT 1 FROM %v WHERE id = ? AND deletion_date IS NULL)
+              ORDER BY id DESC;`, r.revisions, r.column, r.table)
+	rows, err := db.Query(query, id, id)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := []types.Revision{}
+	for rows.Next() {
+		var revision types.Revision
+		if err := rows.Scan(&revision.ID, &revision.Content, &revision.EditedAt); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan revision: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return revisions, http.StatusOK, nil
+}
+
+// withField copies an update with one more field set, leaving the caller's map alone.
+func withField(updatedData map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	updated := make(map[string]interface{}, len(updatedData)+1)
+	for k, v := range updatedData {
+		updated[k] = v
+	}
+	updated[key] = value
+	return updated
+}
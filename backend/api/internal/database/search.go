@@ -0,0 +1,267 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"backend/api/internal/types"
+)
+
+// the kinds of items that can be searched for, along with the full text
+// index of each and the table it indexes. The title is what results are
+// listed by, posts and comments have none.
+var searchKinds = []struct {
+	kind   string
+	index  string
+	source string
+	title  string
+	body   string
+}{
+	{"user", "UserSearch", "Users", "src.username", "src.bio"},
+	{"project", "ProjectSearch", "Projects", "src.name", "src.description"},
+	{"post", "PostSearch", "Posts", "''", "src.content"},
+	{"comment", "CommentSearch", "Comments", "''", "src.content"},
+}
+
+// SearchKinds lists the kinds of items a search can be limited to.
+func SearchKinds() []string {
+	var kinds []string
+	for _, kind := range searchKinds {
+		kinds = append(kinds, kind.kind)
+	}
+	return kinds
+}
+
+// the most words of a search that are looked for
+const maxSearchTerms = 8
+
+// SearchTerms splits the text of a search into the lowercase words that are
+// looked for. Anything but letters and digits separates words, which also
+// keeps the query syntax of the full text indexes out of the terms.
+//
+// Parameters:
+//   - text: The search as it was typed.
+//
+// Returns:
+//   - []string: The words to look for, every one of them as a prefix.
+//   - error: An error if the text has no words in it.
+func SearchTerms(text string) ([]string, error) {
+	terms := strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("Search must contain at least one letter or digit")
+	}
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms, nil
+}
+
+// QuerySearch looks for users, projects, posts and comments containing
+// every word of a search, where the last letters of words may be left out,
+// so "scal" finds ScaleDB. Matches in titles rank above matches in bodies.
+//
+// Parameters:
+//   - text: The search as it was typed.
+//   - kind: The kind of items to look for, empty to look for every kind.
+//   - limit: The most results to return.
+//
+// Returns:
+//   - []types.SearchResult: The best matches, best first.
+//   - int: HTTP status code.
+//   - error: An error if the search is invalid or the query fails.
+func (db *Database) QuerySearch(text string, kind string, limit int) ([]types.SearchResult, int, error) {
+	terms, err := SearchTerms(text)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	results := []types.SearchResult{}
+	found := false
+	for _, searched := range searchKinds {
+		if kind != "" && kind != searched.kind {
+			continue
+		}
+		found = true
+
+		var matches []types.SearchResult
+		if db.Dialect == Postgres {
+			matches, err = db.searchTsvector(searched.kind, searched.index, searched.source, searched.title, searched.body, terms, limit)
+		} else {
+			matches, err = db.searchFts(searched.kind, searched.index, searched.source, searched.title, terms, limit)
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to search %vs: %v", searched.kind, err)
+		}
+		results = append(results, matches...)
+	}
+	if !found {
+		return nil, http.StatusBadRequest, fmt.Errorf("Invalid kind '%v', expected %v", kind, strings.Join(SearchKinds(), ", "))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, http.StatusOK, nil
+}
+
+// the most matches of each kind a sqlite search reads. FTS4 cannot rank
+// matches itself, so they are all read to be ranked with bm25 here, and
+// past this many only the newest ones are. GET /search documents the cap
+const maxSearchCandidates = 1000
+
+// searchFts searches one of the FTS4 indexes of sqlite. FTS4 is used as it
+// is built into the sqlite driver by default, unlike FTS5 and its bm25(),
+// so matches are ranked here with bm25 instead. Snippets are only made for
+// the `limit` best matches.
+func (db *Database) searchFts(kind, index, source, title string, terms []string, limit int) ([]types.SearchResult, error) {
+	match := strings.Join(terms, "* ") + "*"
+	query := fmt.Sprintf(`SELECT src.id, matchinfo(%[1]v, 'pcnalx')
+              FROM %[1]v
+              JOIN %[2]v src ON src.id = %[1]v.docid
+              WHERE %[1]v MATCH ? AND src.deletion_date IS NULL
+              ORDER BY %[1]v.docid DESC
+              LIMIT ?;`, index, source)
+
+	rows, err := db.Query(query, match, maxSearchCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []types.SearchResult
+	for rows.Next() {
+		result := types.SearchResult{Kind: kind}
+		var matchinfo []byte
+		if err := rows.Scan(&result.ID, &matchinfo); err != nil {
+			return nil, err
+		}
+		result.Rank = bm25(matchinfo, searchColumnWeights)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	ids := make([]interface{}, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	query = fmt.Sprintf(`SELECT src.id, %[3]v, snippet(%[1]v, '<mark>', '</mark>', '…', -1, 12)
+              FROM %[1]v
+              JOIN %[2]v src ON src.id = %[1]v.docid
+              WHERE %[1]v MATCH ? AND %[1]v.docid IN (%[4]v);`, index, source, title, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+
+	snippets, err := db.Query(query, append([]interface{}{match}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer snippets.Close()
+
+	found := map[int64]*types.SearchResult{}
+	for i := range results {
+		found[results[i].ID] = &results[i]
+	}
+	for snippets.Next() {
+		var id int64
+		var resultTitle, snippet string
+		if err := snippets.Scan(&id, &resultTitle, &snippet); err != nil {
+			return nil, err
+		}
+		if result, ok := found[id]; ok {
+			result.Title, result.Snippet = resultTitle, snippet
+		}
+	}
+	return results, snippets.Err()
+}
+
+// how much a match in each column of the indexes counts, titles first
+var searchColumnWeights = []float64{4, 1}
+
+// bm25 ranks a match of a full text search from the 'pcnalx' matchinfo of
+// FTS4, the same way FTS5 ranks them: rare words count for more than common
+// ones, and a word counts for more in a short column than in a long one.
+//
+// Parameters:
+//   - matchinfo: The matchinfo blob, 32 bit integers in native byte order.
+//   - weights: How much a match in each column counts.
+//
+// Returns:
+//   - float64: The rank of the match, higher is better.
+func bm25(matchinfo []byte, weights []float64) float64 {
+	const k1, b = 1.2, 0.75
+
+	info := make([]float64, len(matchinfo)/4)
+	for i := range info {
+		info[i] = float64(binary.NativeEndian.Uint32(matchinfo[i*4:]))
+	}
+	phrases, columns := int(info[0]), int(info[1])
+	rows := info[2]
+	averages, lengths := info[3:3+columns], info[3+columns:3+2*columns]
+	hits := info[3+2*columns:]
+
+	rank := 0.0
+	for phrase := 0; phrase < phrases; phrase++ {
+		for column := 0; column < columns && column < len(weights); column++ {
+			at := 3 * (phrase*columns + column)
+			inRow, rowsWithHits := hits[at], hits[at+2]
+			if inRow == 0 {
+				continue
+			}
+			idf := math.Log(1 + (rows-rowsWithHits+0.5)/(rowsWithHits+0.5))
+			length := 1.0
+			if averages[column] > 0 {
+				length = lengths[column] / averages[column]
+			}
+			rank += weights[column] * idf * inRow * (k1 + 1) / (inRow + k1*(1-b+b*length))
+		}
+	}
+	return rank
+}
+
+// searchTsvector searches one of the tsvector indexes of postgres.
+func (db *Database) searchTsvector(kind, index, source, title, body string, terms []string, limit int) ([]types.SearchResult, error) {
+	tsquery := strings.Join(terms, ":* & ") + ":*"
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	query := fmt.Sprintf(`SELECT src.id, %[3]v,
+                     ts_headline('simple', coalesce(%[4]v, ''), q, 'StartSel=<mark>, StopSel=</mark>, MaxWords=12, MinWords=4'),
+                     ts_rank(s.document, q)
+              FROM %[1]v s
+              JOIN %[2]v src ON src.id = s.id, to_tsquery('simple', ?) q
+              WHERE s.document @@ q AND src.deletion_date IS NULL
+              ORDER BY ts_rank(s.document, q) DESC
+              LIMIT ?;`, index, source, title, body)
+
+	rows, err := db.Query(query, tsquery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []types.SearchResult
+	for rows.Next() {
+		result := types.SearchResult{Kind: kind}
+		if err := rows.Scan(&result.ID, &result.Title, &result.Snippet, &result.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
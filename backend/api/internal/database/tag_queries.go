@@ -0,0 +1,172 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"backend/api/internal/types"
+)
+
+// replaceProjectTags makes the ProjectTags rows of a project match its
+// tags, as part of the transaction that writes them to the project.
+//
+// Parameters:
+//   - tx: The transaction writing the project.
+//   - projectId: The id of the project.
+//   - tags: The project's tags, repeats are only stored once.
+//
+// Returns:
+//   - error: An error if the tags cannot be written.
+func replaceProjectTags(tx *Tx, projectId int64, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM ProjectTags WHERE project_id = ?`, projectId)
+	if err != nil {
+		return fmt.Errorf("Failed to clear project tags: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+
+		_, err = tx.Exec(`INSERT INTO ProjectTags (project_id, tag) VALUES (?, ?)`, projectId, tag)
+		if err != nil {
+			return fmt.Errorf("Failed to add project tag '%v': %v", tag, err)
+		}
+	}
+	return nil
+}
+
+// tagsOf reads the tags out of the value of an update, which is whatever
+// the JSON of the request decoded to.
+func tagsOf(value interface{}) ([]string, error) {
+	tagsJSON, err := MarshalToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	if err := UnmarshalFromJSON(tagsJSON, &tags); err != nil {
+		return nil, fmt.Errorf("Tags must be a list of strings")
+	}
+	return tags, nil
+}
+
+// QueryTagCounts counts the projects using each tag.
+//
+// Returns:
+//   - []types.TagCount: Every tag in use, most used first.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryTagCounts() ([]types.TagCount, int, error) {
+	query := `SELECT pt.tag, COUNT(*)
+              FROM ProjectTags pt
+              JOIN Projects p ON p.id = pt.project_id
+              WHERE p.deletion_date IS NULL
+              GROUP BY pt.tag
+              ORDER BY COUNT(*) DESC, pt.tag;`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to count tags: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []types.TagCount{}
+	for rows.Next() {
+		var count types.TagCount
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan tag count: %v", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return counts, http.StatusOK, nil
+}
+
+// QueryProjects retrieves a page of the projects matching a filter.
+// Tags match regardless of case.
+//
+// Parameters:
+//   - filter: The tags, status and owner to match and the order to sort in.
+//   - page: The cursor to continue after and the amount of projects to return.
+//
+// Returns:
+//   - []types.Project: The matching projects.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if the filter is invalid or the query fails.
+func (db *Database) QueryProjects(filter types.ProjectFilter, page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	conditions := []string{"deletion_date IS NULL"}
+	var args []interface{}
+
+	if len(filter.Tags) > 0 {
+		tags := lowerTags(filter.Tags)
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+
+		if filter.MatchAllTags {
+			conditions = append(conditions, fmt.Sprintf(`id IN (SELECT project_id FROM ProjectTags
+                WHERE lower(tag) IN (%v) GROUP BY project_id HAVING COUNT(DISTINCT lower(tag)) = ?)`, placeholders))
+			args = append(args, len(tags))
+		} else {
+			conditions = append(conditions, fmt.Sprintf(`id IN (SELECT project_id FROM ProjectTags WHERE lower(tag) IN (%v))`, placeholders))
+		}
+	}
+	if filter.Status != nil {
+		conditions = append(conditions, "status = ?")
+		args = append(args, *filter.Status)
+	}
+	if filter.Owner != 0 {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, filter.Owner)
+	}
+
+	// continue after the cursor in the order the projects are sorted in
+	after := descendingFrom(page)
+	var column string
+	switch filter.Sort {
+	case "", "time":
+		column = db.Dialect.Time("creation_date")
+		args = append(args, after.Time, after.Time)
+		conditions = append(conditions, fmt.Sprintf("(%[1]v < %[2]v OR (%[1]v = %[2]v AND id < ?))", column, db.Dialect.Time("?")))
+	case "likes":
+		column = "likes"
+		args = append(args, after.Likes, after.Likes)
+		conditions = append(conditions, "(likes < ? OR (likes = ? AND id < ?))")
+	case "trending":
+		column = "trending_score"
+		args = append(args, after.Score, after.Score)
+		conditions = append(conditions, "(trending_score < ? OR (trending_score = ? AND id < ?))")
+	default:
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("Invalid sort '%v', expected time, likes or trending", filter.Sort)
+	}
+	args = append(args, after.ID, fetchLimit(page))
+
+	query := fmt.Sprintf(`SELECT id, name, description, status, likes, links, tags, owner, creation_date, trending_score
+              FROM Projects
+              WHERE %v
+              ORDER BY %v DESC, id DESC
+              LIMIT ?;`, strings.Join(conditions, " AND "), column)
+
+	return db.queryProjectFeed(page, query, args...)
+}
+
+// lowerTags lowercases tags and drops the repeats.
+func lowerTags(tags []string) []string {
+	var lowered []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			lowered = append(lowered, tag)
+		}
+	}
+	return lowered
+}
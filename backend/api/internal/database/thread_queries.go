@@ -0,0 +1,309 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"backend/api/internal/types"
+)
+
+// a thread is every comment linked to a post or project through its link
+// table, along with the replies to them. Replies are not linked, so a
+// reply belongs to the thread of its parent unless it is linked elsewhere.
+type thread struct {
+	// the table linking comments to the thread's post or project
+	links string
+	// the column of the link table holding the post or project id
+	column string
+	// the table linking comments to the other kind of thread
+	others string
+}
+
+var (
+	postThread    = thread{links: "PostComments", column: "post_id", others: "ProjectComments"}
+	projectThread = thread{links: "ProjectComments", column: "project_id", others: "PostComments"}
+)
+
+// a sort the comments of a thread can be listed in
+type threadOrder struct {
+	// compares a comment to the cursor's, taking the cursor as arguments
+	keyset  func(d Dialect, after types.Cursor) (string, []interface{})
+	orderBy string
+	less    func(a, b types.Comment) bool
+}
+
+var threadOrders = map[string]threadOrder{
+	"top": {
+		keyset: func(d Dialect, after types.Cursor) (string, []interface{}) {
+			return "c.likes < ? OR (c.likes = ? AND c.id < ?)", []interface{}{after.Likes, after.Likes, after.ID}
+		},
+		orderBy: "c.likes DESC, c.id DESC",
+		less: func(a, b types.Comment) bool {
+			if a.Likes != b.Likes {
+				return a.Likes > b.Likes
+			}
+			return a.ID > b.ID
+		},
+	},
+	"new": {
+		keyset: func(d Dialect, after types.Cursor) (string, []interface{}) {
+			keyset := fmt.Sprintf("%[1]v < %[2]v OR (%[1]v = %[2]v AND c.id < ?)", d.Time("c.creation_date"), d.Time("?"))
+			return keyset, []interface{}{after.Time, after.Time, after.ID}
+		},
+		orderBy: "c.creation_date DESC, c.id DESC",
+		less: func(a, b types.Comment) bool {
+			if !a.CreationDate.Equal(b.CreationDate) {
+				return a.CreationDate.After(b.CreationDate)
+			}
+			return a.ID > b.ID
+		},
+	},
+}
+
+func threadOrderOf(sort string) (threadOrder, error) {
+	order, ok := threadOrders[sort]
+	if !ok {
+		return threadOrder{}, fmt.Errorf("Invalid sort '%v', expected top or new", sort)
+	}
+	return order, nil
+}
+
+// ThreadComment is a comment of a thread, Depth levels below the listed ones.
+type ThreadComment struct {
+	types.Comment
+	Depth int
+}
+
+// QueryCommentTreeByPostId retrieves the comments on a post as a tree of replies.
+//
+// Parameters:
+//   - id: The id of the post.
+//   - query: The comment whose replies to list, how deep to go and in which order.
+//
+// Returns:
+//   - []types.CommentNode: The listed comments with their replies.
+//   - *types.Cursor: The cursor of the next page of the listed comments, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryCommentTreeByPostId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	return db.queryCommentTree(postThread, id, query)
+}
+
+// QueryCommentTreeByProjectId retrieves the comments on a project as a tree of replies.
+//
+// Parameters:
+//   - id: The id of the project.
+//   - query: The comment whose replies to list, how deep to go and in which order.
+//
+// Returns:
+//   - []types.CommentNode: The listed comments with their replies.
+//   - *types.Cursor: The cursor of the next page of the listed comments, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryCommentTreeByProjectId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	return db.queryCommentTree(projectThread, id, query)
+}
+
+// queryCommentTree walks a thread down from the listed comments with a
+// recursive CTE. Only the listed comments are paged in SQL, the levels
+// below are fetched whole, one level deeper than asked for so the tree
+// knows which of the deepest comments have replies, and cut in Go.
+func (db *Database) queryCommentTree(t thread, id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	order, err := threadOrderOf(query.Sort)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	// a reply is part of the thread unless it is linked to another one
+	inThread := fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %[3]v o WHERE o.comment_id = c.id)
+                AND NOT EXISTS (SELECT 1 FROM %[1]v l WHERE l.comment_id = c.id AND l.%[2]v <> ?)`,
+		t.links, t.column, t.others)
+
+	// the top of the thread is its linked comments that are not replies
+	// to another comment of the same thread
+	roots := fmt.Sprintf(`JOIN %[1]v l ON l.comment_id = c.id
+                WHERE l.%[2]v = ? AND (c.parent_comment_id IS NULL OR NOT EXISTS (
+                    SELECT 1 FROM %[1]v pl WHERE pl.comment_id = c.parent_comment_id AND pl.%[2]v = l.%[2]v))`,
+		t.links, t.column)
+	args := []interface{}{id}
+	if query.Parent != 0 {
+		roots = "WHERE c.parent_comment_id = ? AND " + inThread
+		args = []interface{}{query.Parent, id}
+	}
+
+	keyset, keysetArgs := order.keyset(db.Dialect, descendingFrom(query.Page))
+	args = append(args, keysetArgs...)
+	args = append(args, fetchLimit(query.Page), query.Depth, id)
+
+	sqlQuery := fmt.Sprintf(`
+            WITH RECURSIVE thread (id, depth) AS (
+                SELECT id, 0 FROM (
+                    SELECT c.id FROM Comments c
+                    %v AND (%v)
+                    ORDER BY %v
+                    LIMIT ?
+                ) listed
+                UNION ALL
+                SELECT c.id, t.depth + 1
+                FROM thread t
+                JOIN Comments c ON c.parent_comment_id = t.id
+                WHERE t.depth < ? AND %v
+            )
+            SELECT c.id, c.user_id, c.content, c.likes, c.creation_date, c.parent_comment_id, c.edit_date, c.deletion_date, t.depth
+            FROM thread t
+            JOIN Comments c ON c.id = t.id
+            ORDER BY t.depth, c.id;`, roots, keyset, order.orderBy, inThread)
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to query comment thread: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []ThreadComment
+	for rows.Next() {
+		var comment ThreadComment
+		err := rows.Scan(
+			&comment.ID,
+			&comment.User,
+			&comment.Content,
+			&comment.Likes,
+			&comment.CreationDate,
+			&comment.ParentComment,
+			&comment.EditedAt,
+			&comment.DeletedAt,
+			&comment.Depth,
+		)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan comment: %v", err)
+		}
+		HideDeletedComment(&comment.Comment)
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	nodes, next, err := BuildCommentTree(comments, query)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	return nodes, next, http.StatusOK, nil
+}
+
+// BuildCommentTree nests the comments of a thread under their parents.
+// Every level is sorted and cut to the page limit, and comments whose
+// replies were cut, or are deeper than asked for, get a Replies cursor.
+//
+// Parameters:
+//   - comments: The listed comments at depth 0 and their replies, down to query.Depth.
+//   - query: The thread query the comments were fetched for.
+//
+// Returns:
+//   - []types.CommentNode: The listed comments after the query's cursor, with their replies.
+//   - *types.Cursor: The cursor of the next page of the listed comments, nil on the last page.
+//   - error: An error if the sort is invalid.
+func BuildCommentTree(comments []ThreadComment, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, error) {
+	order, err := threadOrderOf(query.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var listed []types.Comment
+	replies := map[int64][]types.Comment{}
+	seen := map[int64]bool{}
+	for _, comment := range comments {
+		// a linked reply to an unlinked reply is reached both ways
+		if seen[comment.ID] {
+			continue
+		}
+		seen[comment.ID] = true
+
+		if comment.Depth == 0 {
+			if query.Page.After == nil || order.less(cursorComment(*query.Page.After), comment.Comment) {
+				listed = append(listed, comment.Comment)
+			}
+		} else if comment.Depth <= query.Depth {
+			parent := comment.ParentComment.Int64
+			replies[parent] = append(replies[parent], comment.Comment)
+		}
+	}
+
+	cursorOf := func(parent int64) func(types.Comment) types.Cursor {
+		return func(comment types.Comment) types.Cursor {
+			cursor := commentCursor(comment)
+			cursor.Parent = parent
+			return cursor
+		}
+	}
+
+	var build func(comments []types.Comment, parent int64, depth int, page types.Page) ([]types.CommentNode, *types.Cursor)
+	build = func(comments []types.Comment, parent int64, depth int, page types.Page) ([]types.CommentNode, *types.Cursor) {
+		sort.SliceStable(comments, func(i, j int) bool { return order.less(comments[i], comments[j]) })
+		comments, next := CutPage(comments, page, cursorOf(parent))
+
+		nodes := make([]types.CommentNode, 0, len(comments))
+		for _, comment := range comments {
+			node := types.CommentNode{Comment: comment, Children: []types.CommentNode{}}
+			if depth+1 < query.Depth {
+				node.Children, node.Replies = build(replies[comment.ID], comment.ID, depth+1, types.Page{Limit: query.Page.Limit})
+			} else if len(replies[comment.ID]) > 0 {
+				node.Replies = &types.Cursor{Parent: comment.ID}
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes, next
+	}
+
+	nodes, next := build(listed, query.Parent, 0, query.Page)
+	return nodes, next, nil
+}
+
+// cursorComment is the comment a cursor points at, as far as sorting goes.
+func cursorComment(cursor types.Cursor) types.Comment {
+	return types.Comment{ID: cursor.ID, Likes: cursor.Likes, CreationDate: cursor.Time}
+}
+
+// QueryCommentThread finds the thread a comment is part of, which is the
+// post or project linked to the comment or to its closest linked parent.
+//
+// Parameters:
+//   - id: The id of the comment.
+//
+// Returns:
+//   - string: The kind of the thread, post or project.
+//   - int64: The id of the post or project.
+//   - int: HTTP status code.
+//   - error: An error if the comment is in no thread or the query fails.
+func (db *Database) QueryCommentThread(id int) (string, int64, int, error) {
+	query := `
+            WITH RECURSIVE ancestors (id, parent, depth) AS (
+                SELECT id, parent_comment_id, 0 FROM Comments WHERE id = ?
+                UNION ALL
+                SELECT c.id, c.parent_comment_id, a.depth + 1
+                FROM ancestors a
+                JOIN Comments c ON c.id = a.parent
+            )
+            SELECT kind, thread_id FROM (
+                SELECT 'post' AS kind, l.post_id AS thread_id, a.depth
+                FROM ancestors a JOIN PostComments l ON l.comment_id = a.id
+                UNION ALL
+                SELECT 'project' AS kind, l.project_id AS thread_id, a.depth
+                FROM ancestors a JOIN ProjectComments l ON l.comment_id = a.id
+            ) linked
+            ORDER BY depth
+            LIMIT 1;`
+
+	var kind string
+	var threadId int64
+	err := db.QueryRow(query, id).Scan(&kind, &threadId)
+	if err == sql.ErrNoRows {
+		return "", 0, http.StatusNotFound, fmt.Errorf("Comment with id %v is not part of a thread", id)
+	}
+	if err != nil {
+		return "", 0, http.StatusInternalServerError, fmt.Errorf("Failed to find the thread of comment %v: %v", id, err)
+	}
+	return kind, threadId, http.StatusOK, nil
+}
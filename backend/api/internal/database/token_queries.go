@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// QueryCreatePersonalToken stores a new personal access token for a user.
+//
+// Parameters:
+//   - userId: The id of the user the token acts as.
+//   - newToken: The name and scopes of the token.
+//   - tokenHash: The hash of the raw token, the raw token is never stored.
+//
+// Returns:
+//   - *types.PersonalToken: The stored token metadata.
+//   - error: An error if the token could not be stored.
+func (db *Database) QueryCreatePersonalToken(userId int64, newToken *types.NewPersonalToken, tokenHash string) (*types.PersonalToken, error) {
+	scopesJSON, err := json.Marshal(newToken.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal scopes for token '%v': %v", newToken.Name, err)
+	}
+
+	currentTime := time.Now().UTC()
+	query := `INSERT INTO PersonalAccessTokens (user_id, name, token_hash, scopes, creation_date)
+              VALUES (?, ?, ?, ?, ?);`
+	tokenId, err := db.InsertReturningId(query, userId, newToken.Name, tokenHash, string(scopesJSON), currentTime)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create token '%v': %v", newToken.Name, err)
+	}
+
+	return &types.PersonalToken{
+		ID:           tokenId,
+		Name:         newToken.Name,
+		Scopes:       newToken.Scopes,
+		CreationDate: currentTime,
+	}, nil
+}
+
+// QueryPersonalTokens lists the personal access tokens a user has created.
+//
+// Parameters:
+//   - userId: The id of the user.
+//
+// Returns:
+//   - []types.PersonalToken: The token metadata, oldest first.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the query fails.
+func (db *Database) QueryPersonalTokens(userId int64) ([]types.PersonalToken, int, error) {
+	query := `SELECT id, name, scopes, creation_date, last_used_date
+              FROM PersonalAccessTokens
+              WHERE user_id = ?
+              ORDER BY id;`
+
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to fetch tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []types.PersonalToken{}
+	for rows.Next() {
+		var token types.PersonalToken
+		var scopesJSON string
+		var lastUsed sql.NullTime
+		err := rows.Scan(&token.ID, &token.Name, &scopesJSON, &token.CreationDate, &lastUsed)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan token: %v", err)
+		}
+
+		err = json.Unmarshal([]byte(scopesJSON), &token.Scopes)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to unmarshal scopes for token '%v': %v", token.Name, err)
+		}
+		if lastUsed.Valid {
+			token.LastUsed = &lastUsed.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, http.StatusOK, nil
+}
+
+// QueryDeletePersonalToken revokes one of a user's personal access tokens.
+//
+// Parameters:
+//   - userId: The id of the user that owns the token.
+//   - tokenId: The id of the token.
+//
+// Returns:
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the token does not exist or the deletion fails.
+func (db *Database) QueryDeletePersonalToken(userId int64, tokenId int64) (int, error) {
+	query := `DELETE FROM PersonalAccessTokens WHERE id = ? AND user_id = ?;`
+	rowsAffected, err := db.ExecUpdate(query, tokenId, userId)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete token with id '%v': %v", tokenId, err)
+	}
+	if rowsAffected == 0 {
+		return http.StatusNotFound, fmt.Errorf("Token with id '%v' not found", tokenId)
+	}
+	return http.StatusOK, nil
+}
+
+// QueryPersonalTokenUser resolves a personal access token to the user it
+// acts as, and records that the token was used.
+//
+// Parameters:
+//   - tokenHash: The hash of the token sent by the client.
+//
+// Returns:
+//   - *types.Caller: The user the token belongs to.
+//   - []string: The scopes the token was granted.
+//   - int: HTTP-like status code indicating the result.
+//   - error: An error if the token is unknown or the query fails.
+func (db *Database) QueryPersonalTokenUser(tokenHash string) (*types.Caller, []string, int, error) {
+	query := `SELECT u.id, u.username, u.role, u.suspension_date IS NOT NULL, t.scopes
+              FROM PersonalAccessTokens t
+              JOIN Users u ON u.id = t.user_id
+              WHERE t.token_hash = ? AND u.deletion_date IS NULL;`
+
+	var caller types.Caller
+	var scopesJSON string
+	err := db.QueryRow(query, tokenHash).Scan(&caller.ID, &caller.Username, &caller.Role, &caller.Suspended, &scopesJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, http.StatusUnauthorized, fmt.Errorf("Invalid personal access token")
+		}
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to fetch token: %v", err)
+	}
+
+	var scopes []string
+	err = json.Unmarshal([]byte(scopesJSON), &scopes)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to unmarshal token scopes: %v", err)
+	}
+
+	_, err = db.Exec(`UPDATE PersonalAccessTokens SET last_used_date = ? WHERE token_hash = ?;`, time.Now().UTC(), tokenHash)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to record token use: %v", err)
+	}
+
+	return &caller, scopes, http.StatusOK, nil
+}
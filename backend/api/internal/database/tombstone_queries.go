@@ -0,0 +1,353 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// the rows of a table deleted along with an item, `where` selects them
+// with the item's id in place of every ?
+type cascadeStep struct {
+	table string
+	where string
+}
+
+// a kind of item that can be deleted and restored. The first step of its
+// cascade is the item itself, the rest are what goes with it, like the
+// posts of a deleted project and the comments on them.
+type tombstoneKind struct {
+	table string
+	// the column holding the id of the user the item belongs to
+	owner   string
+	cascade []cascadeStep
+}
+
+// comments linked to a post or project, or to a post of a project, where
+// `posts` and `projects` are conditions on the post and project table
+func linkedComments(posts string, projects string) string {
+	var links []string
+	if posts != "" {
+		links = append(links, `id IN (SELECT pc.comment_id FROM PostComments pc JOIN Posts p ON p.id = pc.post_id WHERE `+posts+`)`)
+	}
+	if projects != "" {
+		links = append(links, `id IN (SELECT pc.comment_id FROM ProjectComments pc JOIN Projects p ON p.id = pc.project_id WHERE `+projects+`)`)
+	}
+	return strings.Join(links, " OR ")
+}
+
+var tombstoneKinds = map[string]tombstoneKind{
+	"user": {table: "Users", owner: "id", cascade: []cascadeStep{
+		{"Users", "id = ?"},
+		{"Projects", "owner = ?"},
+		{"Posts", "user_id = ? OR project_id IN (SELECT id FROM Projects WHERE owner = ?)"},
+		{"Comments", "user_id = ? OR " + linkedComments("p.user_id = ? OR p.project_id IN (SELECT id FROM Projects WHERE owner = ?)", "p.owner = ?")},
+	}},
+	"project": {table: "Projects", owner: "owner", cascade: []cascadeStep{
+		{"Projects", "id = ?"},
+		{"Posts", "project_id = ?"},
+		{"Comments", linkedComments("p.project_id = ?", "p.id = ?")},
+	}},
+	"post": {table: "Posts", owner: "user_id", cascade: []cascadeStep{
+		{"Posts", "id = ?"},
+		{"Comments", linkedComments("p.id = ?", "")},
+	}},
+	"comment": {table: "Comments", owner: "user_id", cascade: []cascadeStep{
+		{"Comments", "id = ?"},
+	}},
+}
+
+// KnownTombstoneKind reports whether `kind` names something that can be
+// deleted and restored.
+func KnownTombstoneKind(kind string) bool {
+	_, ok := tombstoneKinds[kind]
+	return ok
+}
+
+// tombstone deletes an item along with everything that goes with it,
+// dating them all to the same moment so a restore can tell them apart
+// from things that were deleted on their own. They all record
+// `deletedBy` as the user who deleted them.
+//
+// Returns:
+//   - bool: False if the item does not exist or was already deleted.
+//   - error: An error if the update fails.
+func tombstone(tx *Tx, kind string, id int64, now time.Time, deletedBy int64) (bool, error) {
+	for i, step := range tombstoneKinds[kind].cascade {
+		query := fmt.Sprintf(`UPDATE %v SET deletion_date = ?, deleted_by = ? WHERE deletion_date IS NULL AND (%v)`, step.table, step.where)
+		res, err := tx.Exec(query, append([]interface{}{now, deletedBy}, fill(step.where, id)...)...)
+		if err != nil {
+			return false, fmt.Errorf("Failed to delete from %v: %v", step.table, err)
+		}
+		if i > 0 {
+			continue
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("Failed to fetch affected rows: %v", err)
+		}
+		if rowsAffected == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// deletionTime is when a deletion happens, at the precision every database
+// stores timestamps with so the rows deleted together compare equal.
+func deletionTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// QueryTombstone retrieves a deleted item that has not been purged yet.
+//
+// Parameters:
+//   - kind: The kind of item, one of user, project, post or comment.
+//   - id: The id of the item.
+//
+// Returns:
+//   - *types.Tombstone: The deleted item, nil if it is not deleted or does not exist.
+//   - error: An error if the kind is unknown or the query fails.
+func (db *Database) QueryTombstone(kind string, id int64) (*types.Tombstone, error) {
+	tk, ok := tombstoneKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("Unknown kind '%v'", kind)
+	}
+	query := fmt.Sprintf(`SELECT id, %v, deletion_date, deleted_by FROM %v WHERE id = ? AND deletion_date IS NOT NULL;`, tk.owner, tk.table)
+	return db.queryTombstone(kind, query, id)
+}
+
+// QueryUserTombstone retrieves a deleted user that has not been purged yet,
+// whose username stays taken until then.
+//
+// Parameters:
+//   - username: The username of the user.
+//
+// Returns:
+//   - *types.Tombstone: The deleted user, nil if they are not deleted or do not exist.
+//   - error: An error if the query fails.
+func (db *Database) QueryUserTombstone(username string) (*types.Tombstone, error) {
+	query := `SELECT id, id, deletion_date, deleted_by FROM Users WHERE username = ? AND deletion_date IS NOT NULL;`
+	return db.queryTombstone("user", query, username)
+}
+
+func (db *Database) queryTombstone(kind string, query string, key interface{}) (*types.Tombstone, error) {
+	tombstone := types.Tombstone{Kind: kind}
+	var deletedBy sql.NullInt64
+	err := db.QueryRow(query, key).Scan(&tombstone.ID, &tombstone.Owner, &tombstone.DeletedAt, &deletedBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch deleted %v: %v", kind, err)
+	}
+	tombstone.DeletedBy = deletedBy.Int64
+	return &tombstone, nil
+}
+
+// QueryRestore brings back a deleted item along with everything that was
+// deleted with it. What was deleted on its own before or after stays deleted.
+//
+// Parameters:
+//   - kind: The kind of item, one of user, project, post or comment.
+//   - id: The id of the item.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the item is not deleted or the update fails.
+func (db *Database) QueryRestore(kind string, id int64) (httpCode int, err error) {
+	tk, ok := tombstoneKinds[kind]
+	if !ok {
+		return http.StatusBadRequest, fmt.Errorf("Unknown kind '%v'", kind)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit restore of %v %v: %v", kind, id, err)
+		}
+	}()
+
+	var deletedAt time.Time
+	query := fmt.Sprintf(`SELECT deletion_date FROM %v WHERE id = ? AND deletion_date IS NOT NULL%v`, tk.table, tx.Dialect.ForUpdate())
+	err = tx.QueryRow(query, id).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("Deleted %v with id %v not found", kind, id)
+		return http.StatusNotFound, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch deleted %v: %v", kind, err)
+	}
+
+	for _, step := range tk.cascade {
+		query := fmt.Sprintf(`UPDATE %v SET deletion_date = NULL, deleted_by = NULL WHERE deletion_date = ? AND (%v)`, step.table, step.where)
+		_, err = tx.Exec(query, append([]interface{}{deletedAt}, fill(step.where, id)...)...)
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("Failed to restore %v: %v", step.table, err)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// what purging removes along with the deleted rows of a table, where
+// each ? is the cutoff. Link tables are cleaned up by hand since SQLite
+// does not enforce foreign keys.
+var purgeSteps = []struct {
+	table string
+	// lists the URLs of the uploads the rows hold, so the files can be
+	// deleted along with them
+	uploads string
+	// statements run before the rows themselves are deleted
+	before []string
+}{
+	{"Comments", "", []string{
+		// comments with replies stay as tombstones to hold their thread
+		// together, but what they said is gone for good
+		`UPDATE Comments SET content = '', edit_date = NULL
+              WHERE deletion_date < ? AND id IN (SELECT parent_comment_id FROM Comments WHERE parent_comment_id IS NOT NULL)`,
+		`DELETE FROM CommentRevisions WHERE comment_id IN (SELECT id FROM Comments WHERE deletion_date < ?)`,
+		`DELETE FROM CommentLikes WHERE comment_id IN (SELECT id FROM Comments WHERE deletion_date < ?
+              AND id NOT IN (SELECT parent_comment_id FROM Comments WHERE parent_comment_id IS NOT NULL))`,
+		`DELETE FROM PostComments WHERE comment_id IN (SELECT id FROM Comments WHERE deletion_date < ?
+              AND id NOT IN (SELECT parent_comment_id FROM Comments WHERE parent_comment_id IS NOT NULL))`,
+		`DELETE FROM ProjectComments WHERE comment_id IN (SELECT id FROM Comments WHERE deletion_date < ?
+              AND id NOT IN (SELECT parent_comment_id FROM Comments WHERE parent_comment_id IS NOT NULL))`,
+		`DELETE FROM Notifications WHERE comment_id IN (SELECT id FROM Comments WHERE deletion_date < ?)
+              OR (kind IN ('comment_like', 'comment_reply') AND target_id IN (SELECT id FROM Comments WHERE deletion_date < ?))`,
+	}},
+	{"Posts", "", []string{
+		`DELETE FROM PostRevisions WHERE post_id IN (SELECT id FROM Posts WHERE deletion_date < ?)`,
+		`DELETE FROM PostLikes WHERE post_id IN (SELECT id FROM Posts WHERE deletion_date < ?)`,
+		`DELETE FROM PostComments WHERE post_id IN (SELECT id FROM Posts WHERE deletion_date < ?)`,
+		`DELETE FROM Notifications WHERE kind IN ('post_like', 'post_comment')
+              AND target_id IN (SELECT id FROM Posts WHERE deletion_date < ?)`,
+	}},
+	{"Projects", `SELECT url FROM ProjectImages WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`, []string{
+		`DELETE FROM ProjectTags WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectLikes WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectFollows WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectComments WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM Notifications WHERE kind IN ('project_like', 'project_follow', 'project_comment')
+              AND target_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM WebhookDeliveries WHERE webhook_id IN (SELECT id FROM Webhooks
+              WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?))`,
+		`DELETE FROM Webhooks WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectImages WHERE project_id IN (SELECT id FROM Projects WHERE deletion_date < ?)`,
+	}},
+	{"Users", `SELECT picture FROM Users WHERE deletion_date < ? AND picture IS NOT NULL AND picture <> ''`, []string{
+		`DELETE FROM UserLoginInfo WHERE username IN (SELECT username FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM PersonalAccessTokens WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM RefreshTokens WHERE family_id IN (SELECT id FROM TokenFamilies
+              WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?))`,
+		`DELETE FROM TokenFamilies WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM EmailPreferences WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM UserFollows WHERE follower_id IN (SELECT id FROM Users WHERE deletion_date < ?)
+              OR follows_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectFollows WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM ProjectLikes WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM PostLikes WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM CommentLikes WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+		`DELETE FROM Notifications WHERE user_id IN (SELECT id FROM Users WHERE deletion_date < ?)
+              OR actor_id IN (SELECT id FROM Users WHERE deletion_date < ?)`,
+	}},
+}
+
+// QueryPurge deletes for good everything that was deleted before `before`,
+// which can no longer be restored. Deleted comments that still have
+// replies keep their place in the thread with their content wiped.
+// The files uploaded for what was purged are left for the caller to
+// delete from the blob storage.
+//
+// Parameters:
+//   - before: The cutoff, usually now minus the retention period.
+//
+// Returns:
+//   - int64: How many users, projects, posts and comments were purged.
+//   - []string: The URLs of the pictures and project images that were purged.
+//   - error: An error if any of the deletes fail.
+func (db *Database) QueryPurge(before time.Time) (purged int64, uploads []string, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			purged, uploads, err = 0, nil, fmt.Errorf("Failed to commit purge: %v", err)
+		}
+	}()
+
+	for _, step := range purgeSteps {
+		if step.uploads != "" {
+			var urls []string
+			urls, err = queryStrings(tx, step.uploads, before)
+			if err != nil {
+				return 0, nil, fmt.Errorf("Failed to list uploads of purged %v: %v", step.table, err)
+			}
+			uploads = append(uploads, urls...)
+		}
+		for _, query := range step.before {
+			_, err = tx.Exec(query, fill(query, before)...)
+			if err != nil {
+				return 0, nil, fmt.Errorf("Failed to purge %v: %v", step.table, err)
+			}
+		}
+
+		query := fmt.Sprintf(`DELETE FROM %v WHERE deletion_date < ?`, step.table)
+		if step.table == "Comments" {
+			query += ` AND id NOT IN (SELECT parent_comment_id FROM Comments WHERE parent_comment_id IS NOT NULL)`
+		}
+		var res sql.Result
+		res, err = tx.Exec(query, before)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Failed to purge %v: %v", step.table, err)
+		}
+		var rowsAffected int64
+		rowsAffected, err = res.RowsAffected()
+		if err != nil {
+			return 0, nil, fmt.Errorf("Failed to fetch affected rows: %v", err)
+		}
+		purged += rowsAffected
+	}
+	return purged, uploads, nil
+}
+
+// queryStrings runs a query for a single column of strings.
+func queryStrings(tx *Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// fill passes `value` as every argument of a query.
+func fill(query string, value interface{}) []interface{} {
+	args := make([]interface{}, strings.Count(query, "?"))
+	for i := range args {
+		args[i] = value
+	}
+	return args
+}
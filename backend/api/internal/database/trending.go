@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/api/internal/trending"
+)
+
+// the tables with a trending score, along with the tables of the
+// interactions that count towards it and how much each of them weighs
+var trendingSources = map[string][]struct {
+	query  string
+	weight float64
+}{
+	"Posts": {
+		{`SELECT creation_date FROM PostLikes WHERE post_id = ?`, trending.LikeWeight},
+		{`SELECT c.creation_date FROM PostComments pc JOIN Comments c ON c.id = pc.comment_id WHERE pc.post_id = ?`, trending.CommentWeight},
+	},
+	"Projects": {
+		{`SELECT creation_date FROM ProjectLikes WHERE project_id = ?`, trending.LikeWeight},
+		{`SELECT c.creation_date FROM ProjectComments pc JOIN Comments c ON c.id = pc.comment_id WHERE pc.project_id = ?`, trending.CommentWeight},
+		{`SELECT creation_date FROM ProjectFollows WHERE project_id = ?`, trending.FollowWeight},
+	},
+}
+
+// addTrendingEvent counts an interaction towards the trending score of a
+// post or project, as part of the transaction that records it.
+//
+// Parameters:
+//   - tx: The transaction recording the interaction.
+//   - table: Posts or Projects.
+//   - id: The id of the post or project.
+//   - event: The interaction.
+//
+// Returns:
+//   - error: An error if the score cannot be updated.
+func addTrendingEvent(tx *Tx, table string, id int64, event trending.Event) error {
+	return updateTrendingScore(tx, table, id, func(score float64, created time.Time) float64 {
+		return trending.Add(score, event)
+	})
+}
+
+// removeTrendingEvent takes an interaction back out of the trending score
+// of a post or project, as part of the transaction that deletes it.
+//
+// Parameters:
+//   - tx: The transaction deleting the interaction.
+//   - table: Posts or Projects.
+//   - id: The id of the post or project.
+//   - weight: How much the interaction weighs.
+//   - at: When the interaction was made, NULL if that was never recorded.
+//
+// Returns:
+//   - error: An error if the score cannot be updated.
+func removeTrendingEvent(tx *Tx, table string, id int64, weight float64, at sql.NullTime) error {
+	return updateTrendingScore(tx, table, id, func(score float64, created time.Time) float64 {
+		event := trending.Event{Weight: weight, Time: created}
+		if at.Valid {
+			event.Time = at.Time
+		}
+		return trending.Remove(score, event, trending.Initial(created))
+	})
+}
+
+// updateTrendingScore replaces the trending score of a row with the one
+// `update` derives from it. Scores that were never computed are left for
+// BackfillTrendingScores, which counts every interaction there is anyway.
+func updateTrendingScore(tx *Tx, table string, id int64, update func(score float64, created time.Time) float64) error {
+	var score sql.NullFloat64
+	var created time.Time
+	query := fmt.Sprintf(`SELECT trending_score, creation_date FROM %v WHERE id = ?%v`, table, tx.Dialect.ForUpdate())
+	err := tx.QueryRow(query, id).Scan(&score, &created)
+	if err == sql.ErrNoRows || (err == nil && !score.Valid) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to read trending score: %v", err)
+	}
+
+	query = fmt.Sprintf(`UPDATE %v SET trending_score = ? WHERE id = ?`, table)
+	if _, err := tx.Exec(query, update(score.Float64, created), id); err != nil {
+		return fmt.Errorf("Failed to update trending score: %v", err)
+	}
+	return nil
+}
+
+// BackfillTrendingScores computes the trending score of every post and
+// project that does not have one yet, from every like, comment and follow
+// they have had. That is every row right after the migration adding the
+// scores, and rows inserted by hand, such as the test data.
+//
+// Returns:
+//   - int: The number of scores computed.
+//   - error: An error if a score cannot be computed.
+func (db *Database) BackfillTrendingScores() (int, error) {
+	computed := 0
+	for _, table := range []string{"Posts", "Projects"} {
+		ids, err := db.unscoredIds(table)
+		if err != nil {
+			return computed, err
+		}
+
+		for _, id := range ids {
+			if err := db.computeTrendingScore(table, id); err != nil {
+				return computed, err
+			}
+			computed++
+		}
+	}
+	return computed, nil
+}
+
+// unscoredIds lists the rows of a table without a trending score.
+func (db *Database) unscoredIds(table string) ([]int64, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT id FROM %v WHERE trending_score IS NULL`, table))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query unscored %v: %v", table, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("Failed to scan unscored %v: %v", table, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// computeTrendingScore computes the score of a single row from scratch.
+// Interactions recorded without a date count as made when the row was created.
+func (db *Database) computeTrendingScore(table string, id int64) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("Failed to commit trending score of %v %v: %v", table, id, err)
+		}
+	}()
+
+	var created time.Time
+	query := fmt.Sprintf(`SELECT creation_date FROM %v WHERE id = ?%v`, table, tx.Dialect.ForUpdate())
+	if err = tx.QueryRow(query, id).Scan(&created); err != nil {
+		return fmt.Errorf("Failed to read %v %v: %v", table, id, err)
+	}
+
+	var events []trending.Event
+	for _, source := range trendingSources[table] {
+		var dates []sql.NullTime
+		dates, err = queryDates(tx, source.query, id)
+		if err != nil {
+			return fmt.Errorf("Failed to query interactions with %v %v: %v", table, id, err)
+		}
+		for _, date := range dates {
+			event := trending.Event{Weight: source.weight, Time: created}
+			if date.Valid {
+				event.Time = date.Time
+			}
+			events = append(events, event)
+		}
+	}
+
+	query = fmt.Sprintf(`UPDATE %v SET trending_score = ? WHERE id = ?`, table)
+	if _, err = tx.Exec(query, trending.Score(created, events), id); err != nil {
+		return fmt.Errorf("Failed to update trending score: %v", err)
+	}
+	return nil
+}
+
+// queryDates runs a query for a single column of timestamps.
+func queryDates(tx *Tx, query string, args ...interface{}) ([]sql.NullTime, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dates []sql.NullTime
+	for rows.Next() {
+		var date sql.NullTime
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, rows.Err()
+}
@@ -19,10 +19,10 @@ import (
 // Returns:
 //   - string: The username if found.
 //   - error: An error if the query fails. Returns nil for both if no user exists.
-func GetUsernameById(id int64) (string, error) {
-	query := `SELECT username FROM Users WHERE id = ?;`
+func (db *Database) GetUsernameById(id int64) (string, error) {
+	query := `SELECT username FROM Users WHERE id = ? AND deletion_date IS NULL;`
 
-	row := DB.QueryRow(query, id)
+	row := db.QueryRow(query, id)
 
 	var retrievedUserName string
 	err := row.Scan(&retrievedUserName)
@@ -43,14 +43,15 @@ func GetUsernameById(id int64) (string, error) {
 //
 // Returns:
 //   - int: The user ID if found.
-//   - error: An error if the query fails or the username does not exist.
-func GetUserIdByUsername(username string) (int, error) {
-	query := `SELECT id FROM Users WHERE username = ?`
+//   - error: An error if the query fails or the username does not exist,
+//     which wraps sql.ErrNoRows so callers can tell the two apart.
+func (db *Database) GetUserIdByUsername(username string) (int, error) {
+	query := `SELECT id FROM Users WHERE username = ? AND deletion_date IS NULL`
 	var userID int
-	row := DB.QueryRow(query, username)
+	row := db.QueryRow(query, username)
 	err := row.Scan(&userID)
-	if err != nil { // TODO: Is there a way this can return a 404 vs 500 error? this could be a 404 or 500, but we cannot tell from an err here
-		return -1, fmt.Errorf("Error fetching user ID for username '%v' (this usually means username does not exist) : %v", username, err)
+	if err != nil {
+		return -1, fmt.Errorf("Error fetching user ID for username '%v' (this usually means username does not exist) : %w", username, err)
 	}
 	return userID, nil
 }
@@ -63,10 +64,10 @@ func GetUserIdByUsername(username string) (int, error) {
 // Returns:
 //   - *types.User: The user details if found.
 //   - error: An error if the query or data parsing fails.
-func QueryUsername(username string) (*types.User, error) {
-	query := `SELECT username, picture, bio, links, creation_date FROM Users WHERE username = ?;`
+func (db *Database) QueryUsername(username string) (*types.User, error) {
+	query := `SELECT username, picture, bio, links, creation_date FROM Users WHERE username = ? AND deletion_date IS NULL;`
 
-	row := DB.QueryRow(query, username)
+	row := db.QueryRow(query, username)
 
 	var user types.User
 	var linksJSON string
@@ -95,7 +96,7 @@ func QueryUsername(username string) (*types.User, error) {
 //
 // Returns:
 //   - error: An error if the user creation fails.
-func QueryCreateUser(user *types.User) error {
+func (db *Database) QueryCreateUser(user *types.User) error {
 	linksJSON, err := json.Marshal(user.Links)
 	if err != nil {
 		return fmt.Errorf("Failed to marshal links for user '%v': %v", user.Username, err)
@@ -106,39 +107,59 @@ func QueryCreateUser(user *types.User) error {
 	query := `INSERT INTO Users (username, picture, bio, links, creation_date)
 	VALUES (?, ?, ?, ?, ?);`
 
-	res, err := DB.Exec(query, user.Username, user.Picture, user.Bio, string(linksJSON), currentTime)
+	_, err = db.Exec(query, user.Username, user.Picture, user.Bio, string(linksJSON), currentTime)
 	if err != nil {
 		return fmt.Errorf("Failed to create user '%v': %v", user.Username, err)
 	}
 
-	_, err = res.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("Failed to ensure user was created: %v", err)
-	}
-
 	return nil
 }
 
-// QueryDeleteUser deletes a user by their username.
+// QueryDeleteUser deletes a user by their username, along with their
+// projects, posts and comments and what was posted on them. They are kept
+// as tombstones until they are purged, so they can be restored, but the
+// user is logged out everywhere.
 //
 // Parameters:
 //   - username: The username of the user to delete.
+//   - deletedBy: The id of the user deleting them, themselves or an admin.
 //
 // Returns:
 //   - int16: HTTP-like status code indicating the result.
 //   - error: An error if the deletion fails.
-func QueryDeleteUser(username string) (int16, error) {
-	query := `DELETE from Users WHERE username=?;`
-	res, err := DB.Exec(query, username)
+func (db *Database) QueryDeleteUser(username string, deletedBy int64) (int16, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("Failed to delete user '%v': %v", username, err)
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM Users WHERE username = ? AND deletion_date IS NULL;`, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("Deletion did not affect any records")
+		return http.StatusNotFound, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch user '%v': %v", username, err)
 	}
 
-	RowsAffected, err := res.RowsAffected()
-	if RowsAffected == 0 {
-		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
-	} else if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to fetch affected rows: %v", err)
+	now := deletionTime()
+	_, err = tx.Exec(`UPDATE TokenFamilies SET revocation_date = ? WHERE user_id = ? AND revocation_date IS NULL;`, now, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to revoke sessions for user '%v': %v", username, err)
+	}
+
+	_, err = tombstone(tx, "user", id, now, deletedBy)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Failed to delete user '%v': %v", username, err)
 	}
 
 	return http.StatusOK, nil
@@ -152,7 +173,7 @@ func QueryDeleteUser(username string) (int16, error) {
 //
 // Returns:
 //   - error: An error if the update fails or no user is found.
-func QueryUpdateUser(username string, updatedData map[string]interface{}) error {
+func (db *Database) QueryUpdateUser(username string, updatedData map[string]interface{}) error {
 
 	newUsername, usernameExists := updatedData["username"]
 	usernameStr, parseOk := newUsername.(string)
@@ -173,7 +194,7 @@ func QueryUpdateUser(username string, updatedData map[string]interface{}) error
 	query += queryParams + " WHERE username = ?"
 	args = append(args, username)
 
-	rowsAffected, err := ExecUpdate(query, args...)
+	rowsAffected, err := db.ExecUpdate(query, args...)
 	if err != nil {
 		return fmt.Errorf("Error checking rows affected: %v", err)
 	}
@@ -181,6 +202,14 @@ func QueryUpdateUser(username string, updatedData map[string]interface{}) error
 		return fmt.Errorf("No user found with username '%s' to update", username)
 	}
 
+	// keep the login info pointing at the renamed user
+	if usernameExists && parseOk && usernameStr != username {
+		_, err = db.ExecUpdate(`UPDATE UserLoginInfo SET username = ? WHERE username = ?`, usernameStr, username)
+		if err != nil {
+			return fmt.Errorf("Error updating login info: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -188,112 +217,130 @@ func QueryUpdateUser(username string, updatedData map[string]interface{}) error
 //
 // Parameters:
 //   - username: The username of the user.
+//   - page: The cursor to continue after and the amount of usernames to return.
 //
 // Returns:
 //   - []string: A list of usernames of the followers.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
 //   - int: HTTP-like status code.
 //   - error: An error if the query fails.
-func QueryGetUsersFollowersUsernames(username string) ([]string, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetUsersFollowersUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
-		return nil, http.StatusInternalServerError, err
+		return nil, nil, http.StatusInternalServerError, err
 	}
 
 	query := `
-        SELECT u.username
+        SELECT u.id, u.username
         FROM Users u
         JOIN UserFollows uf ON u.id = uf.follower_id
-        WHERE uf.follows_id = ?`
+        WHERE uf.follows_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getUsersFollowingOrFollowersUsernames(query, userID)
+	return db.getUsersFollowingOrFollowersUsernames(query, userID, page)
 }
 
 // function to retrieve the user ids of the users who follow the given user
 //
 // Parameters:
 //   - username (string): the user to retrieve
+//   - page (types.Page): the cursor to continue after and the amount of ids to return
 //
 // Returns:
 //   - []int: a list of user ids of users who follow the specified user
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: HTTP status code indicating the result of the operation
 //   - error: any error encountered during the query
-func QueryGetUsersFollowers(username string) ([]int, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetUsersFollowers(username string, page types.Page) ([]int, *types.Cursor, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
-		return nil, http.StatusInternalServerError, err
+		return nil, nil, http.StatusInternalServerError, err
 	}
 
 	query := `
         SELECT u.id 
         FROM Users u
         JOIN UserFollows uf ON u.id = uf.follower_id
-        WHERE uf.follows_id = ?`
+        WHERE uf.follows_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getUsersFollowingOrFollowers(query, userID)
+	return db.getUsersFollowingOrFollowers(query, userID, page)
 }
 
-// function to retrieve the usernames of the users who follow the given user
+// function to retrieve the usernames of the users the given user follows
 //
 // Parameters:
 //   - username (string): the user to retrieve
+//   - page (types.Page): the cursor to continue after and the amount of usernames to return
 //
 // Returns:
-//   - []string: a list of usernames of users who follow the specified user
+//   - []string: a list of usernames of users the specified user follows
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: HTTP status code indicating the result of the operation
 //   - error: any error encountered during the query
-func QueryGetUsersFollowingUsernames(username string) ([]string, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetUsersFollowingUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 
 	query := `
-        SELECT u.username 
+        SELECT u.id, u.username 
         FROM Users u
         JOIN UserFollows uf ON u.id = uf.follows_id
-        WHERE uf.follower_id = ?`
+        WHERE uf.follower_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getUsersFollowingOrFollowersUsernames(query, userID)
+	return db.getUsersFollowingOrFollowersUsernames(query, userID, page)
 }
 
-// function to retrieve the ids of the users who follow the given user
+// function to retrieve the ids of the users the given user follows
 //
 // Parameters:
 //   - username (string) - the user to retrieve
+//   - page (types.Page) - the cursor to continue after and the amount of ids to return
 //
 // Returns:
-//   - []int: a list of user IDs of users who follow the specified user
+//   - []int: a list of user IDs of users the specified user follows
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: HTTP status code indicating the result of the operation
 //   - error: any error encountered during the query
-func QueryGetUsersFollowing(username string) ([]int, int, error) {
-	userID, err := GetUserIdByUsername(username)
+func (db *Database) QueryGetUsersFollowing(username string, page types.Page) ([]int, *types.Cursor, int, error) {
+	userID, err := db.GetUserIdByUsername(username)
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 
 	query := `
         SELECT u.id 
         FROM Users u
         JOIN UserFollows uf ON u.id = uf.follows_id
-        WHERE uf.follower_id = ?`
+        WHERE uf.follower_id = ? AND u.deletion_date IS NULL AND u.id > ?
+        ORDER BY u.id
+        LIMIT ?`
 
-	return getUsersFollowingOrFollowers(query, userID)
+	return db.getUsersFollowingOrFollowers(query, userID, page)
 }
 
 // helper function to retrieve the followers or followings of a user by their IDs
 //
 // Parameters:
-//   - query (string): the SQL query to execute
+//   - query (string): the SQL query to execute, taking the user id, the id to continue after and a limit
 //   - userID (int): the ID of the user to find follow data for
+//   - page (types.Page): the page to retrieve
 //
 // Returns:
 //   - []int: a list of user IDs for the followers or followings
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: HTTP status code
 //   - error: any error encountered during the query
-func getUsersFollowingOrFollowers(query string, userID int) ([]int, int, error) {
-	rows, err := DB.Query(query, userID)
+func (db *Database) getUsersFollowingOrFollowers(query string, userID int, page types.Page) ([]int, *types.Cursor, int, error) {
+	rows, err := db.Query(query, userID, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 
@@ -301,49 +348,39 @@ func getUsersFollowingOrFollowers(query string, userID int) ([]int, int, error)
 	for rows.Next() {
 		var username int
 		if err := rows.Scan(&username); err != nil {
-			return nil, http.StatusInternalServerError, err
+			return nil, nil, http.StatusInternalServerError, err
 		}
 		users = append(users, username)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, http.StatusInternalServerError, err
+		return nil, nil, http.StatusInternalServerError, err
 	}
 
-	return users, http.StatusOK, nil
+	users, next := CutPage(users, page, idCursor)
+	return users, next, http.StatusOK, nil
 }
 
 // helper function to retrieve the followers or followings of a user by their usernames
 //
 // Parameters:
-//   - query (string): the SQL query to execute
+//   - query (string): the SQL query to execute, selecting ids and usernames
 //   - userID (int): the ID of the user to find follow data for
+//   - page (types.Page): the page to retrieve
 //
 // Returns:
 //   - []string: a list of usernames for the followers or followings
+//   - *types.Cursor: the cursor of the next page, nil on the last page
 //   - int: HTTP status code
 //   - error: any error encountered during the query
-func getUsersFollowingOrFollowersUsernames(query string, userID int) ([]string, int, error) {
-	rows, err := DB.Query(query, userID)
+func (db *Database) getUsersFollowingOrFollowersUsernames(query string, userID int, page types.Page) ([]string, *types.Cursor, int, error) {
+	rows, err := db.Query(query, userID, ascendingFrom(page), fetchLimit(page))
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, nil, http.StatusNotFound, err
 	}
 	defer rows.Close()
 
-	var users []string
-	for rows.Next() {
-		var username string
-		if err := rows.Scan(&username); err != nil {
-			return nil, http.StatusInternalServerError, err
-		}
-		users = append(users, username)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, http.StatusInternalServerError, err
-	}
-
-	return users, http.StatusOK, nil
+	return scanNamedPage(rows, page)
 }
 
 // function to create a follow relationship between two users
@@ -355,18 +392,18 @@ func getUsersFollowingOrFollowersUsernames(query string, userID int) ([]string,
 // Returns:
 //   - int: HTTP status code
 //   - error: any error encountered during the query
-func CreateNewUserFollow(user string, newFollow string) (int, error) {
-	userID, err := GetUserIdByUsername(user)
+func (db *Database) CreateNewUserFollow(user string, newFollow string) (int, error) {
+	userID, err := db.GetUserIdByUsername(user)
 	if err != nil {
 		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", user)
 	}
 
-	newFollowID, err := GetUserIdByUsername(newFollow)
+	newFollowID, err := db.GetUserIdByUsername(newFollow)
 	if err != nil {
 		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", newFollow)
 	}
 
-	currFollowers, httpCode, err := QueryGetUsersFollowing(user)
+	currFollowers, _, httpCode, err := db.QueryGetUsersFollowing(user, types.Page{})
 	if err != nil {
 		return httpCode, fmt.Errorf("Cannot retrieve user's following list: %v", err)
 	}
@@ -375,14 +412,29 @@ func CreateNewUserFollow(user string, newFollow string) (int, error) {
 		return http.StatusConflict, fmt.Errorf("User '%v' is already being followed", newFollow)
 	}
 
-	query := `INSERT INTO UserFollows (follower_id, follows_id) VALUES (?, ?)`
-	rowsAffected, err := ExecUpdate(query, userID, newFollowID)
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	currentTime := time.Now().UTC()
+	query := `INSERT INTO UserFollows (follower_id, follows_id, creation_date) VALUES (?, ?, ?)`
+	_, err = tx.Exec(query, userID, newFollowID, currentTime)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred adding follower: %v", err)
 	}
 
-	if rowsAffected == 0 {
-		return http.StatusInternalServerError, fmt.Errorf("Failed to add the follow relationship")
+	err = notify(tx, NotifyUserFollow, int64(userID), int64(newFollowID), nil, currentTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
 	return http.StatusOK, nil
@@ -397,18 +449,18 @@ func CreateNewUserFollow(user string, newFollow string) (int, error) {
 // Returns:
 //   - int: HTTP status code
 //   - error: any error encountered during the query
-func RemoveUserFollow(user string, unfollow string) (int, error) {
-	userID, err := GetUserIdByUsername(user)
+func (db *Database) RemoveUserFollow(user string, unfollow string) (int, error) {
+	userID, err := db.GetUserIdByUsername(user)
 	if err != nil {
 		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", user)
 	}
 
-	unfollowID, err := GetUserIdByUsername(unfollow)
+	unfollowID, err := db.GetUserIdByUsername(unfollow)
 	if err != nil {
 		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", unfollow)
 	}
 
-	currFollowers, httpCode, err := QueryGetUsersFollowing(user)
+	currFollowers, _, httpCode, err := db.QueryGetUsersFollowing(user, types.Page{})
 	if err != nil {
 		return httpCode, fmt.Errorf("Error retrieving user's following list: %v", err)
 	}
@@ -418,7 +470,7 @@ func RemoveUserFollow(user string, unfollow string) (int, error) {
 	}
 
 	query := `DELETE FROM UserFollows WHERE follower_id = ? AND follows_id = ?;`
-	rowsAffected, err := ExecUpdate(query, userID, unfollowID)
+	rowsAffected, err := db.ExecUpdate(query, userID, unfollowID)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("An error occurred removing follower: %v", err)
 	}
@@ -61,8 +61,8 @@ func UnmarshalFromJSON(data string, target interface{}) error {
 //      *sql.Rows - the affected rows
 //      error
 
-func ExecUpdate(query string, args ...interface{}) (int64, error) {
-	res, err := DB.Exec(query, args...)
+func (db *Database) ExecUpdate(query string, args ...interface{}) (int64, error) {
+	res, err := db.Exec(query, args...)
 	if err != nil {
 		logger.Log.Errorf("Error executing update query: %v", err)
 		return 0, fmt.Errorf("Error executing update query: %v", err)
@@ -0,0 +1,355 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// the statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// QueryCreateWebhook registers a webhook on a project.
+//
+// Parameters:
+//   - webhook: The webhook to create, with its project, URL, events and secret.
+//
+// Returns:
+//   - int64: The id of the new webhook.
+//   - error: An error if the webhook cannot be created.
+func (db *Database) QueryCreateWebhook(webhook *types.Webhook) (int64, error) {
+	eventsJSON, err := MarshalToJSON(webhook.Events)
+	if err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO Webhooks (project_id, url, secret, events, creation_date)
+              VALUES (?, ?, ?, ?, ?)`
+	id, err := db.InsertReturningId(query, webhook.Project, webhook.URL, webhook.Secret, eventsJSON, time.Now().UTC())
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create webhook: %v", err)
+	}
+	return id, nil
+}
+
+const webhookColumns = `id, project_id, url, secret, events, creation_date`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (types.Webhook, error) {
+	var webhook types.Webhook
+	var eventsJSON string
+	err := row.Scan(&webhook.ID, &webhook.Project, &webhook.URL, &webhook.Secret, &eventsJSON, &webhook.CreationDate)
+	if err != nil {
+		return webhook, err
+	}
+	return webhook, UnmarshalFromJSON(eventsJSON, &webhook.Events)
+}
+
+// QueryWebhooks retrieves the webhooks of a project, without their secrets.
+//
+// Parameters:
+//   - projectId: The id of the project.
+//
+// Returns:
+//   - []types.Webhook: The webhooks, oldest first.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryWebhooks(projectId int64) ([]types.Webhook, int, error) {
+	rows, err := db.Query(`SELECT `+webhookColumns+` FROM Webhooks WHERE project_id = ? ORDER BY id`, projectId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	webhooks := []types.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan webhook: %v", err)
+		}
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read webhooks: %v", err)
+	}
+	return webhooks, http.StatusOK, nil
+}
+
+// QueryWebhook retrieves a webhook along with its secret.
+//
+// Parameters:
+//   - id: The id of the webhook.
+//
+// Returns:
+//   - *types.Webhook: The webhook, nil if it does not exist.
+//   - error: An error if the query fails.
+func (db *Database) QueryWebhook(id int64) (*types.Webhook, error) {
+	webhook, err := scanWebhook(db.QueryRow(`SELECT `+webhookColumns+` FROM Webhooks WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query webhook: %v", err)
+	}
+	return &webhook, nil
+}
+
+// QueryDeleteWebhook deletes a webhook along with its delivery log.
+//
+// Parameters:
+//   - id: The id of the webhook.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the webhook does not exist or cannot be deleted.
+func (db *Database) QueryDeleteWebhook(id int64) (httpCode int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			httpCode, err = http.StatusInternalServerError, fmt.Errorf("Failed to commit deletion of webhook %v: %v", id, err)
+		}
+	}()
+
+	_, err = tx.Exec(`DELETE FROM WebhookDeliveries WHERE webhook_id = ?`, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete webhook deliveries: %v", err)
+	}
+	result, err := tx.Exec(`DELETE FROM Webhooks WHERE id = ?`, id)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete webhook: %v", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to delete webhook: %v", err)
+	}
+	if deleted == 0 {
+		err = fmt.Errorf("Webhook with id %v not found", id)
+		return http.StatusNotFound, err
+	}
+	return http.StatusOK, nil
+}
+
+// QueryQueueDeliveries queues a payload for every webhook of a project
+// that subscribed to its event, to be sent right away.
+//
+// Parameters:
+//   - projectId: The id of the project the event happened on.
+//   - event: The name of the event.
+//   - payload: The JSON payload to send.
+//   - now: When the event happened.
+//
+// Returns:
+//   - int64: How many deliveries were queued.
+//   - int: HTTP status code.
+//   - error: An error if the deliveries cannot be queued.
+func (db *Database) QueryQueueDeliveries(projectId int64, event string, payload []byte, now time.Time) (queued int64, httpCode int, err error) {
+	webhooks, httpCode, err := db.QueryWebhooks(projectId)
+	if err != nil {
+		return 0, httpCode, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit(); err != nil {
+			queued, httpCode, err = 0, http.StatusInternalServerError, fmt.Errorf("Failed to commit queued deliveries: %v", err)
+		}
+	}()
+
+	query := `INSERT INTO WebhookDeliveries (webhook_id, event, payload, status, creation_date, next_attempt)
+              VALUES (?, ?, ?, ?, ?, ?)`
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, event) {
+			continue
+		}
+		_, err = tx.Exec(query, webhook.ID, event, string(payload), DeliveryPending, now, now)
+		if err != nil {
+			return 0, http.StatusInternalServerError, fmt.Errorf("Failed to queue delivery: %v", err)
+		}
+		queued++
+	}
+	return queued, http.StatusOK, nil
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.response_code, d.error,
+                         d.redelivery_of, d.creation_date, d.next_attempt, d.delivered_date`
+
+func scanDelivery(rows *sql.Rows, extra ...interface{}) (types.WebhookDelivery, error) {
+	var delivery types.WebhookDelivery
+	var payload string
+	var redeliveryOf sql.NullInt64
+	var nextAttempt, deliveredDate sql.NullTime
+	err := rows.Scan(append([]interface{}{&delivery.ID, &delivery.Webhook, &delivery.Event, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseCode, &delivery.Error, &redeliveryOf, &delivery.CreationDate,
+		&nextAttempt, &deliveredDate}, extra...)...)
+	if err != nil {
+		return delivery, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = &redeliveryOf.Int64
+	}
+	if nextAttempt.Valid {
+		delivery.NextAttempt = &nextAttempt.Time
+	}
+	if deliveredDate.Valid {
+		delivery.DeliveredDate = &deliveredDate.Time
+	}
+	return delivery, nil
+}
+
+// QueryDueDeliveries retrieves the pending deliveries whose next attempt
+// is due, the longest overdue first.
+//
+// Parameters:
+//   - now: The current time.
+//   - limit: The most deliveries to retrieve.
+//
+// Returns:
+//   - []types.PendingDelivery: The due deliveries with the URL and secret of their webhook.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryDueDeliveries(now time.Time, limit int) ([]types.PendingDelivery, int, error) {
+	query := fmt.Sprintf(`SELECT %v, w.url, w.secret
+              FROM WebhookDeliveries d
+              JOIN Webhooks w ON w.id = d.webhook_id
+              WHERE d.status = ? AND %v <= %v
+              ORDER BY d.next_attempt, d.id
+              LIMIT ?`, deliveryColumns, db.Dialect.Time("d.next_attempt"), db.Dialect.Time("?"))
+
+	rows, err := db.Query(query, DeliveryPending, now, limit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to query due deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	due := []types.PendingDelivery{}
+	for rows.Next() {
+		var pending types.PendingDelivery
+		pending.WebhookDelivery, err = scanDelivery(rows, &pending.URL, &pending.Secret)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan delivery: %v", err)
+		}
+		due = append(due, pending)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read due deliveries: %v", err)
+	}
+	return due, http.StatusOK, nil
+}
+
+// QueryRecordAttempt records how an attempt to send a delivery went.
+//
+// Parameters:
+//   - attempt: The delivery tried, its new status, the response and when to try again.
+//
+// Returns:
+//   - int: HTTP status code.
+//   - error: An error if the attempt cannot be recorded.
+func (db *Database) QueryRecordAttempt(attempt types.DeliveryAttempt) (int, error) {
+	var deliveredDate *time.Time
+	if attempt.Status == DeliveryDelivered {
+		deliveredDate = &attempt.Time
+	}
+
+	query := `UPDATE WebhookDeliveries
+              SET status = ?, attempts = attempts + 1, response_code = ?, error = ?, next_attempt = ?, delivered_date = ?
+              WHERE id = ?`
+	_, err := db.Exec(query, attempt.Status, attempt.ResponseCode, attempt.Error, attempt.NextAttempt, deliveredDate, attempt.Delivery)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to record delivery attempt: %v", err)
+	}
+	return http.StatusOK, nil
+}
+
+// QueryDeliveries retrieves a page of the delivery log of a webhook, latest first.
+//
+// Parameters:
+//   - webhookId: The id of the webhook.
+//   - page: The page to retrieve, which continues after a delivery id.
+//
+// Returns:
+//   - []types.WebhookDelivery: The deliveries on the page.
+//   - *types.Cursor: The cursor of the next page, nil on the last page.
+//   - int: HTTP status code.
+//   - error: An error if the query fails.
+func (db *Database) QueryDeliveries(webhookId int64, page types.Page) ([]types.WebhookDelivery, *types.Cursor, int, error) {
+	query := `SELECT ` + deliveryColumns + `
+              FROM WebhookDeliveries d
+              WHERE d.webhook_id = ? AND d.id < ?
+              ORDER BY d.id DESC
+              LIMIT ?`
+
+	rows, err := db.Query(query, webhookId, descendingFrom(page).ID, fetchLimit(page))
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to query deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to scan delivery: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to read deliveries: %v", err)
+	}
+
+	deliveries, next := CutPage(deliveries, page, deliveryCursor)
+	return deliveries, next, http.StatusOK, nil
+}
+
+// QueryRedeliver queues a delivery of a webhook to be sent again, as a
+// new delivery, so the log keeps how the earlier one went.
+//
+// Parameters:
+//   - webhookId: The id of the webhook.
+//   - deliveryId: The id of the delivery to send again.
+//   - now: When to send it.
+//
+// Returns:
+//   - int64: The id of the new delivery.
+//   - int: HTTP status code.
+//   - error: An error if the delivery is not the webhook's or cannot be queued.
+func (db *Database) QueryRedeliver(webhookId int64, deliveryId int64, now time.Time) (int64, int, error) {
+	var event, payload string
+	query := `SELECT event, payload FROM WebhookDeliveries WHERE id = ? AND webhook_id = ?`
+	err := db.QueryRow(query, deliveryId, webhookId).Scan(&event, &payload)
+	if err == sql.ErrNoRows {
+		return -1, http.StatusNotFound, fmt.Errorf("Delivery with id %v not found", deliveryId)
+	}
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to query delivery: %v", err)
+	}
+
+	query = `INSERT INTO WebhookDeliveries (webhook_id, event, payload, status, redelivery_of, creation_date, next_attempt)
+             VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := db.InsertReturningId(query, webhookId, event, payload, DeliveryPending, deliveryId, now, now)
+	if err != nil {
+		return -1, http.StatusInternalServerError, fmt.Errorf("Failed to queue redelivery: %v", err)
+	}
+	return id, http.StatusOK, nil
+}
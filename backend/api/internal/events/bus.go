@@ -0,0 +1,203 @@
+// The events package is an in-process pub/sub bus. Writes publish events
+// to topics, such as a user's notifications or a post's likes, and every
+// open event stream subscribed to one of those topics receives them.
+// Recent events are kept so a stream that drops can resume where it left
+// off, as long as it comes back before they are pushed out.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many events a subscriber can fall behind by before it is dropped
+const subscriberBuffer = 64
+
+// Event is something that happened, sent to the subscribers of any of its topics.
+type Event struct {
+	// increases by one per event, restarting along with the process
+	Seq    int64
+	Type   string
+	Data   json.RawMessage
+	Topics []string
+}
+
+// Bus hands events to subscribers, safe for concurrent use.
+type Bus struct {
+	// how often idle streams should send something so proxies keep them open
+	KeepAlive time.Duration
+
+	mu sync.Mutex
+	// tells the event ids of this process apart from the ones handed
+	// out before a restart, which reuse the same sequence numbers
+	epoch   string
+	lastSeq int64
+	// the latest events, oldest first
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]bool
+}
+
+// NewBus returns a bus that keeps the last `historySize` events for
+// streams resuming after a drop.
+func NewBus(historySize int, keepAlive time.Duration) *Bus {
+	return &Bus{
+		KeepAlive:   keepAlive,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Id returns the id an event is sent with, which streams pass back as
+// Last-Event-ID to resume after it.
+func (bus *Bus) Id(event Event) string {
+	return fmt.Sprintf("%v-%v", bus.epoch, event.Seq)
+}
+
+// Publish sends `data`, encoded as JSON, to every subscriber of any of
+// `topics`. Subscribers too far behind to take it are dropped.
+func (bus *Bus) Publish(eventType string, data interface{}, topics ...string) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v event: %v", eventType, err)
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.lastSeq++
+	event := Event{Seq: bus.lastSeq, Type: eventType, Data: encoded, Topics: topics}
+	bus.history = append(bus.history, event)
+	if len(bus.history) > bus.historySize {
+		bus.history = bus.history[len(bus.history)-bus.historySize:]
+	}
+
+	bus.deliver(event)
+	return nil
+}
+
+// Broadcast sends `data`, encoded as JSON, to the current subscribers of
+// any of `topics` without keeping it for streams that resume later, for
+// events that only matter as they happen. Broadcast events have no Seq.
+func (bus *Bus) Broadcast(eventType string, data interface{}, topics ...string) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v event: %v", eventType, err)
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.deliver(Event{Type: eventType, Data: encoded, Topics: topics})
+	return nil
+}
+
+// deliver hands an event to its subscribers. The caller holds the lock.
+func (bus *Bus) deliver(event Event) {
+	for subscription := range bus.subscribers {
+		if !subscription.wants(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			// it resumes from its last event once it reconnects
+			bus.drop(subscription)
+		}
+	}
+}
+
+// Subscribe starts receiving the events of `topics`. If `lastEventId` is
+// the id of an event, the events after it are replayed first. It returns
+// false along with the subscription if those events are gone, or the id
+// is from before a restart, in which case the subscriber has to reload
+// whatever it is showing.
+func (bus *Bus) Subscribe(topics []string, lastEventId string) (*Subscription, bool) {
+	subscription := &Subscription{bus: bus, topics: map[string]bool{}}
+	for _, topic := range topics {
+		subscription.topics[topic] = true
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	var replay []Event
+	resumed := true
+	if lastEventId != "" {
+		after, ok := bus.parseId(lastEventId)
+		// the history has to reach back to the first missed event
+		oldest := bus.lastSeq - int64(len(bus.history)) + 1
+		if !ok || after > bus.lastSeq || after+1 < oldest {
+			resumed = false
+		} else {
+			for _, event := range bus.history {
+				if event.Seq > after && subscription.wants(event) {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+
+	subscription.events = make(chan Event, subscriberBuffer+len(replay))
+	for _, event := range replay {
+		subscription.events <- event
+	}
+	bus.subscribers[subscription] = true
+	return subscription, resumed
+}
+
+// parseId reads back the sequence number of an id made by Id, false if
+// the id is malformed or from another process.
+func (bus *Bus) parseId(id string) (int64, bool) {
+	epoch, strSeq, ok := strings.Cut(id, "-")
+	if !ok || epoch != bus.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(strSeq, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// drop unsubscribes a subscription and closes its channel. The caller holds the lock.
+func (bus *Bus) drop(subscription *Subscription) {
+	if bus.subscribers[subscription] {
+		delete(bus.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// Subscription receives the events of the topics it was made with.
+type Subscription struct {
+	bus    *Bus
+	topics map[string]bool
+	events chan Event
+}
+
+// Events delivers the subscribed events in order. It is closed once the
+// subscription is closed or dropped for falling behind.
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+// Close stops the subscription, it can be called more than once.
+func (subscription *Subscription) Close() {
+	subscription.bus.mu.Lock()
+	defer subscription.bus.mu.Unlock()
+	subscription.bus.drop(subscription)
+}
+
+func (subscription *Subscription) wants(event Event) bool {
+	for _, topic := range event.Topics {
+		if subscription.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package events
+
+import "fmt"
+
+// the types of events sent to streams
+const (
+	// a new notification, sent to the user it is for
+	TypeNotification = "notification"
+	// a new post, sent to the followers of its author and project
+	TypePost = "post"
+	// the new like count of a post, project or comment
+	TypeLikes = "likes"
+	// a new, edited or deleted comment, sent to the thread it is part of
+	TypeComment        = "comment"
+	TypeCommentEdited  = "comment_edited"
+	TypeCommentDeleted = "comment_deleted"
+	// someone is writing a comment on a thread, never kept for resuming
+	TypeTyping = "typing"
+	// sent first on a stream that could not resume, the client has to
+	// reload what it shows because events were missed
+	TypeReset = "reset"
+)
+
+// UserTopic carries the notifications of a user.
+func UserTopic(userId int64) string { return fmt.Sprintf("user:%v", userId) }
+
+// PostsByUserTopic carries the posts a user makes.
+func PostsByUserTopic(userId int64) string { return fmt.Sprintf("user-posts:%v", userId) }
+
+// PostsOnProjectTopic carries the posts made on a project.
+func PostsOnProjectTopic(projectId int64) string { return fmt.Sprintf("project-posts:%v", projectId) }
+
+// PostTopic, ProjectTopic and CommentTopic carry the like counts of an item.
+func PostTopic(postId int64) string { return fmt.Sprintf("post:%v", postId) }
+
+func ProjectTopic(projectId int64) string { return fmt.Sprintf("project:%v", projectId) }
+
+func CommentTopic(commentId int64) string { return fmt.Sprintf("comment:%v", commentId) }
+
+// ThreadTopic carries what happens to the comments of a post or project,
+// `kind` being post or project.
+func ThreadTopic(kind string, id int64) string { return fmt.Sprintf("thread:%v:%v", kind, id) }
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserStatus handles GET requests for a user's role and suspension state.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to manage users.
+// - 404 Not Found if no user is found with the given username.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the user's status in JSON format.
+func (s *Server) GetUserStatus(context *gin.Context) {
+	username := context.Param("username")
+
+	status, httpcode, err := s.Users.QueryUserStatus(username)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch user status: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, status)
+}
+
+// SetUserRole handles PUT requests to change a user's role.
+// It expects the `username` parameter in the URL and a JSON body with the new `role`.
+// Returns:
+// - 400 Bad Request if the JSON is invalid, the role is unknown or the caller targets themselves.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to manage users.
+// - 404 Not Found if no user is found with the given username.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the change.
+func (s *Server) SetUserRole(context *gin.Context) {
+	username := context.Param("username")
+	if !requireOtherUser(context, username, "change their own role") {
+		return
+	}
+
+	var update types.RoleUpdate
+	err := context.BindJSON(&update)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	err = auth.ValidateRole(update.Role)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to update role: %v", err))
+		return
+	}
+
+	httpcode, err := s.Users.QuerySetUserRole(username, update.Role)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to update role: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("User '%v' is now a %v.", username, update.Role)})
+}
+
+// SuspendUser handles POST requests to suspend a user's account.
+// Suspended users cannot log in, and all of their sessions are revoked.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the caller targets themselves.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to manage users.
+// - 404 Not Found if no user is found with the given username.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the suspension.
+func (s *Server) SuspendUser(context *gin.Context) {
+	username := context.Param("username")
+	if !requireOtherUser(context, username, "suspend themselves") {
+		return
+	}
+
+	httpcode, err := s.Users.QuerySuspendUser(username, true)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to suspend user: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("User '%v' suspended.", username)})
+}
+
+// UnsuspendUser handles POST requests to lift a user's suspension.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to manage users.
+// - 404 Not Found if no user is found with the given username.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the change.
+func (s *Server) UnsuspendUser(context *gin.Context) {
+	username := context.Param("username")
+
+	httpcode, err := s.Users.QuerySuspendUser(username, false)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to unsuspend user: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("User '%v' unsuspended.", username)})
+}
+
+// ReassignProjectOwner handles PUT requests to hand a project over to another user.
+// It expects the `project_id` parameter in the URL and a JSON body with the new `owner` id.
+// Returns:
+// - 400 Bad Request if the project id or JSON is invalid, or the new owner does not exist.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to reassign projects.
+// - 404 Not Found if no project is found with the given id.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the change.
+func (s *Server) ReassignProjectOwner(context *gin.Context) {
+	strId := context.Param("project_id")
+	projectId, err := strconv.ParseInt(strId, 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project_id: %v", err))
+		return
+	}
+
+	var update types.OwnerUpdate
+	err = context.BindJSON(&update)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	httpcode, err := s.Projects.QueryReassignProject(projectId, update.Owner)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to reassign project: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Project %v now belongs to user %v.", projectId, update.Owner)})
+}
+
+// requireOtherUser stops staff from locking themselves out of their own account.
+// If the caller is the target user, it responds with a 400 Bad Request and returns false.
+func requireOtherUser(context *gin.Context, username string, action string) bool {
+	_, callerUsername := GetCaller(context)
+	if callerUsername == username {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("User '%v' cannot %v", username, action))
+		return false
+	}
+	return true
+}
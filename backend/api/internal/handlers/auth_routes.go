@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register handles POST requests to create a new user with login credentials.
+// It expects a JSON body with the user details and a `password` field.
+// Returns:
+// - 400 Bad Request if the JSON is invalid or the password is not allowed.
+// - 409 Conflict if the username is already taken.
+// - 500 Internal Server Error if an error occurs while creating the user.
+// On success, responds with a 201 Created status and a message confirming the registration.
+func (s *Server) Register(context *gin.Context) {
+	var registration types.UserRegistration
+	err := context.BindJSON(&registration)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	httpcode, err := s.Auth.QueryRegisterUser(&registration)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to register user: %v", err))
+		return
+	}
+	context.JSON(http.StatusCreated, gin.H{"message": fmt.Sprintf("Registered new user: '%s'", registration.Username)})
+}
+
+// issueTokenPair hands out a fresh access token and refresh token for a session.
+func (s *Server) issueTokenPair(userId int64, sessionId string) (*types.TokenPair, error) {
+	refreshToken, err := auth.NewRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiration := time.Now().UTC().Add(auth.RefreshTokenDuration)
+	err = s.Auth.QueryStoreRefreshToken(sessionId, auth.HashToken(refreshToken), refreshExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, accessExpiration, err := auth.IssueAccessToken(userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenPair{
+		TokenType:         "Bearer",
+		AccessToken:       accessToken,
+		AccessExpiration:  accessExpiration,
+		RefreshToken:      refreshToken,
+		RefreshExpiration: refreshExpiration,
+	}, nil
+}
+
+// Login handles POST requests to log a user in.
+// It expects a JSON body with `username` and `password`.
+// Returns:
+// - 400 Bad Request if the JSON is invalid.
+// - 401 Unauthorized if the username or password is wrong.
+// - 500 Internal Server Error if the tokens could not be issued.
+// On success, responds with a 200 OK status and a new access and refresh token in JSON format.
+func (s *Server) Login(context *gin.Context) {
+	var credentials types.UserCredentials
+	err := context.BindJSON(&credentials)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	userId, httpcode, err := s.Auth.QueryVerifyLogin(credentials.Username, credentials.Password)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to log in: %v", err))
+		return
+	}
+
+	sessionId, err := auth.NewRandomToken()
+	if err == nil {
+		err = s.Auth.QueryCreateTokenFamily(userId, sessionId)
+	}
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to log in: %v", err))
+		return
+	}
+
+	tokens, err := s.issueTokenPair(userId, sessionId)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to log in: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Logged in as '%s'", credentials.Username), "tokens": tokens})
+}
+
+// RefreshTokens handles POST requests to trade a refresh token for a new token pair.
+// It expects a JSON body with a `refresh_token` field. Each refresh token can
+// only be used once; presenting a used one again revokes the whole session.
+// Returns:
+// - 400 Bad Request if the JSON is invalid.
+// - 401 Unauthorized if the refresh token is unknown, expired, reused or revoked.
+// - 500 Internal Server Error if the tokens could not be issued.
+// On success, responds with a 200 OK status and the new access and refresh token in JSON format.
+func (s *Server) RefreshTokens(context *gin.Context) {
+	var request types.RefreshRequest
+	err := context.BindJSON(&request)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	userId, sessionId, httpcode, err := s.Auth.QueryUseRefreshToken(auth.HashToken(request.RefreshToken))
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to refresh tokens: %v", err))
+		return
+	}
+
+	tokens, err := s.issueTokenPair(userId, sessionId)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to refresh tokens: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Refreshed tokens", "tokens": tokens})
+}
+
+// Logout handles POST requests to end the caller's current session.
+// The session's refresh tokens and any outstanding access tokens stop working.
+// Returns:
+// - 401 Unauthorized if the caller is not authenticated.
+// - 500 Internal Server Error if the session could not be revoked.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) Logout(context *gin.Context) {
+	err := s.Auth.QueryRevokeTokenFamily(GetCallerSession(context))
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to log out: %v", err))
+		return
+	}
+	_, username := GetCaller(context)
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Logged out '%s'", username)})
+}
+
+// LogoutEverywhere handles POST requests to end every session the caller
+// has open, including the current one, like after losing a device.
+// Returns:
+// - 401 Unauthorized if the caller is not authenticated.
+// - 500 Internal Server Error if the sessions could not be revoked.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) LogoutEverywhere(context *gin.Context) {
+	callerId, username := GetCaller(context)
+	err := s.Auth.QueryRevokeAllUserTokens(callerId)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to log out: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Logged out '%s' everywhere", username)})
+}
+
+// ChangePassword handles PUT requests to change the caller's password.
+// It expects a JSON body with `current_password` and `new_password`.
+// Every session the caller has open is revoked, including the current one.
+// Returns:
+// - 400 Bad Request if the JSON is invalid or the new password is not allowed.
+// - 401 Unauthorized if the caller is not authenticated or the current password is wrong.
+// - 500 Internal Server Error if the password could not be updated.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) ChangePassword(context *gin.Context) {
+	var change types.PasswordChange
+	err := context.BindJSON(&change)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	callerId, callerUsername := GetCaller(context)
+	_, httpcode, err := s.Auth.QueryVerifyLogin(callerUsername, change.CurrentPassword)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to change password: %v", err))
+		return
+	}
+
+	httpcode, err = s.Auth.QueryChangePassword(callerId, callerUsername, change.NewPassword)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to change password: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Changed password for '%s', all sessions were logged out", callerUsername)})
+}
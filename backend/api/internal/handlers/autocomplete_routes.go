@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"backend/api/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// suggestionLimit is how many suggestions autocomplete returns when no count is given.
+const suggestionLimit = 10
+
+// GetUserSuggestions handles GET requests to autocomplete usernames, such as
+// for @mentions. It expects the `prefix` URL parameter and an optional `count`.
+// Users the caller follows come first, then the most followed ones.
+// Returns:
+// - 400 Bad Request if prefix is missing or count is invalid.
+// - 500 Internal Server Error if the caller's follows cannot be fetched.
+// On success, responds with a 200 OK status and the suggestions in JSON format.
+func (s *Server) GetUserSuggestions(context *gin.Context) {
+	s.respondWithSuggestions(context, s.Autocomplete.CompleteUsers)
+}
+
+// GetProjectSuggestions handles GET requests to autocomplete project names,
+// such as for project pickers. It expects the `prefix` URL parameter and an
+// optional `count`. Projects the caller follows come first, then the most
+// followed ones.
+// Returns:
+// - 400 Bad Request if prefix is missing or count is invalid.
+// - 500 Internal Server Error if the caller's follows cannot be fetched.
+// On success, responds with a 200 OK status and the suggestions in JSON format.
+func (s *Server) GetProjectSuggestions(context *gin.Context) {
+	s.respondWithSuggestions(context, s.Autocomplete.CompleteProjects)
+}
+
+func (s *Server) respondWithSuggestions(context *gin.Context,
+	complete func(prefix string, callerId int64, limit int) ([]types.Suggestion, int, error)) {
+	prefix := context.Query("prefix")
+	if prefix == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing required url query parameter: prefix")
+		return
+	}
+	if context.Query("cursor") != "" {
+		RespondWithError(context, http.StatusBadRequest, "Suggestions are not paged, use count to get more of them")
+		return
+	}
+
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	limit := page.Limit
+	if limit == 0 {
+		limit = suggestionLimit
+	}
+
+	callerId, _ := GetCaller(context)
+	suggestions, httpcode, err := complete(prefix, callerId, limit)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch suggestions: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, suggestions)
+}
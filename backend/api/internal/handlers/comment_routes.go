@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
-	"backend/api/internal/database"
+	"backend/api/internal/auth"
 	"backend/api/internal/types"
 
 	"github.com/gin-gonic/gin"
@@ -18,14 +19,14 @@ import (
 // - 404 Not Found if the post does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the post details in JSON format.
-func GetCommentById(context *gin.Context) {
+func (s *Server) GetCommentById(context *gin.Context) {
 	strId := context.Param("comment_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse comment_id: %v", err))
 		return
 	}
-	comment, err := database.QueryComment(id)
+	comment, err := s.Comments.QueryComment(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch comment: %v", err))
 		return
@@ -46,14 +47,14 @@ func GetCommentById(context *gin.Context) {
 // - 404 Not Found if the user does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the comments” details in JSON format.
-func GetCommentsByUserId(context *gin.Context) {
+func (s *Server) GetCommentsByUserId(context *gin.Context) {
 	strId := context.Param("user_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse user_id: %v", err))
 		return
 	}
-	comments, httpcode, err := database.QueryCommentsByUserId(id)
+	comments, httpcode, err := s.Comments.QueryCommentsByUserId(id)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
 		return
@@ -74,14 +75,14 @@ func GetCommentsByUserId(context *gin.Context) {
 // - 404 Not Found if the project does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the comments details in JSON format.
-func GetCommentsByProjectId(context *gin.Context) {
+func (s *Server) GetCommentsByProjectId(context *gin.Context) {
 	strId := context.Param("project_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project_id: %v", err))
 		return
 	}
-	comments, httpcode, err := database.QueryCommentsByProjectId(id)
+	comments, httpcode, err := s.Comments.QueryCommentsByProjectId(id)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
 		return
@@ -97,32 +98,184 @@ func GetCommentsByProjectId(context *gin.Context) {
 
 // GetCommentsByPostId handles GET requests to retrieve comments information by its owning post.
 // It expects the `post_id` parameter in the URL and does not require a request body.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
 // - 400 Bad Request if the ID is invalid.
 // - 404 Not Found if the post does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the comments details in JSON format.
-func GetCommentsByPostId(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetCommentsByPostId(context *gin.Context) {
 	strId := context.Param("post_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse post_id: %v", err))
 		return
 	}
-	comments, httpcode, err := database.QueryCommentsByPostId(id)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	comments, next, httpcode, err := s.Comments.QueryCommentsByPostId(id, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
 		return
 	}
 
-	if comments == nil {
+	if comments == nil && page.After == nil {
 		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Comments from post with id %v not found", strId))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, comments, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, comments)
 }
 
+// defaults for comment threads when the request leaves them out
+const (
+	defaultThreadDepth = 5
+	defaultThreadCount = 20
+)
+
+// GetCommentTreeByPostId handles GET requests to retrieve the comments on a post
+// as a tree, each with its replies nested under `children`.
+// It expects the `post_id` parameter in the URL and takes the optional `depth`,
+// `sort` (top or new), `count` and `cursor` URL parameters, see parseThreadQuery.
+// Returns:
+// - 400 Bad Request if the ID or the parameters are invalid.
+// - 404 Not Found if the post does not exist.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the tree as `items` along with
+// the `next_cursor` of the next page.
+func (s *Server) GetCommentTreeByPostId(context *gin.Context) {
+	strId := context.Param("post_id")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse post_id: %v", err))
+		return
+	}
+	query, ok := s.parseThreadQuery(context)
+	if !ok {
+		return
+	}
+
+	post, err := s.Posts.QueryPost(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch post: %v", err))
+		return
+	}
+	if post == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Post with id '%v' not found", strId))
+		return
+	}
+
+	nodes, next, httpcode, err := s.Comments.QueryCommentTreeByPostId(id, query)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
+		return
+	}
+	respondWithTree(context, nodes, next)
+}
+
+// GetCommentTreeByProjectId handles GET requests to retrieve the comments on a
+// project as a tree, each with its replies nested under `children`.
+// It expects the `project_id` parameter in the URL and takes the optional `depth`,
+// `sort` (top or new), `count` and `cursor` URL parameters, see parseThreadQuery.
+// Returns:
+// - 400 Bad Request if the ID or the parameters are invalid.
+// - 404 Not Found if the project does not exist.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the tree as `items` along with
+// the `next_cursor` of the next page.
+func (s *Server) GetCommentTreeByProjectId(context *gin.Context) {
+	strId := context.Param("project_id")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project_id: %v", err))
+		return
+	}
+	query, ok := s.parseThreadQuery(context)
+	if !ok {
+		return
+	}
+
+	project, err := s.Projects.QueryProject(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch project: %v", err))
+		return
+	}
+	if project == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Project with id '%v' not found", strId))
+		return
+	}
+
+	nodes, next, httpcode, err := s.Comments.QueryCommentTreeByProjectId(id, query)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
+		return
+	}
+	respondWithTree(context, nodes, next)
+}
+
+// parseThreadQuery reads how much of a comment thread a request asks for.
+// `depth` is how many levels of comments to include and `count` how many
+// comments to include on each level. A `cursor`, sent along with the count,
+// is either the next_cursor of the previous page or the replies_cursor of a
+// comment, which lists the replies to that comment that were left out.
+// If the parameters are invalid, it responds with a 400 Bad Request and returns false.
+func (s *Server) parseThreadQuery(context *gin.Context) (types.ThreadQuery, bool) {
+	query := types.ThreadQuery{Depth: defaultThreadDepth, Sort: context.DefaultQuery("sort", "top")}
+
+	if strDepth := context.Query("depth"); strDepth != "" {
+		depth, err := strconv.Atoi(strDepth)
+		if err != nil {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse depth int: %v", err))
+			return query, false
+		}
+		if depth < 1 || depth > s.Limits.MaxThreadDepth {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Depth must be between 1 and %v, got %v", s.Limits.MaxThreadDepth, depth))
+			return query, false
+		}
+		query.Depth = depth
+	}
+
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return query, false
+	}
+	if page.Limit == 0 {
+		page.Limit = defaultThreadCount
+	}
+
+	// a replies_cursor only names the comment, its replies start at the top
+	if page.After != nil {
+		query.Parent = page.After.Parent
+		if page.After.ID == 0 {
+			page.After = nil
+		}
+	}
+	query.Page = page
+	return query, true
+}
+
+// respondWithTree sends a page of a comment thread, with the cursors of
+// the replies that were left out encoded the same way as next_cursor.
+func respondWithTree(context *gin.Context, nodes []types.CommentNode, next *types.Cursor) {
+	encodeReplyCursors(nodes)
+	respondWithPage(context, nodes, next)
+}
+
+func encodeReplyCursors(nodes []types.CommentNode) {
+	for i := range nodes {
+		nodes[i].RepliesCursor = encodeCursor(nodes[i].Replies)
+		encodeReplyCursors(nodes[i].Children)
+	}
+}
+
 // GetCommentsByCommentId handles GET requests to retrieve comments information by its owning comment.
 // It expects the `comment_id` parameter in the URL and does not require a request body.
 // Returns:
@@ -130,14 +283,14 @@ func GetCommentsByPostId(context *gin.Context) {
 // - 404 Not Found if the comment does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the comments details in JSON format.
-func GetCommentsByCommentId(context *gin.Context) {
+func (s *Server) GetCommentsByCommentId(context *gin.Context) {
 	strId := context.Param("comment_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse comment_id: %v", err))
 		return
 	}
-	comments, httpcode, err := database.QueryCommentsByUserId(id)
+	comments, httpcode, err := s.Comments.QueryCommentsByUserId(id)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch comments: %v", err))
 		return
@@ -155,10 +308,12 @@ func GetCommentsByCommentId(context *gin.Context) {
 // It expects a JSON payload that can be bound to a `types.Comment` object.
 // Validates the provided owner's ID, verifies the post, and ensures the user exists.
 // Returns:
-// - 400 Bad Request if the JSON payload is invalid or the user/post cannot be verified.
+// - 400 Bad Request if the JSON payload is invalid, the content is too long or the user/post cannot be verified.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the author is not the caller.
 // - 500 Internal Server Error if there is a database error.
 // On success, responds with a 201 Created status and the new comment ID in JSON format.
-func CreateCommentOnPost(context *gin.Context) {
+func (s *Server) CreateCommentOnPost(context *gin.Context) {
 	var newComment types.Comment
 	err := context.BindJSON(&newComment)
 
@@ -166,6 +321,9 @@ func CreateCommentOnPost(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
 		return
 	}
+	if !RequireContentLength(context, newComment.Content, s.Limits.MaxCommentLength, "Comment") {
+		return
+	}
 
 	strId := context.Param("post_id")
 	postId, err := strconv.Atoi(strId)
@@ -175,7 +333,7 @@ func CreateCommentOnPost(context *gin.Context) {
 	}
 
 	// Verify the owner
-	username, err := database.GetUsernameById(newComment.User)
+	username, err := s.Users.GetUsernameById(newComment.User)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify comment ownership: %v", err))
 		return
@@ -186,8 +344,12 @@ func CreateCommentOnPost(context *gin.Context) {
 		return
 	}
 
+	if !RequireCallerIsAuthor(context, newComment.User, "comment") {
+		return
+	}
+
 	// Verify the post
-	post, err := database.QueryPost(postId)
+	post, err := s.Posts.QueryPost(postId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify post: %v", err))
 		return
@@ -199,7 +361,7 @@ func CreateCommentOnPost(context *gin.Context) {
 	}
 
 	// Create the comment
-	id, err := database.QueryCreateCommentOnPost(newComment, postId)
+	id, err := s.Comments.QueryCreateCommentOnPost(newComment, postId)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create comment on post: %v", err))
 		return
@@ -212,10 +374,12 @@ func CreateCommentOnPost(context *gin.Context) {
 // It expects a JSON payload that can be bound to a `types.Comment` object.
 // Validates the provided owner's ID, verifies the project, and ensures the user exists.
 // Returns:
-// - 400 Bad Request if the JSON payload is invalid or the user/project cannot be verified.
+// - 400 Bad Request if the JSON payload is invalid, the content is too long or the user/project cannot be verified.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the author is not the caller.
 // - 500 Internal Server Error if there is a database error.
 // On success, responds with a 201 Created status and the new comment ID in JSON format.
-func CreateCommentOnProject(context *gin.Context) {
+func (s *Server) CreateCommentOnProject(context *gin.Context) {
 	var newComment types.Comment
 	err := context.BindJSON(&newComment)
 
@@ -223,6 +387,9 @@ func CreateCommentOnProject(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
 		return
 	}
+	if !RequireContentLength(context, newComment.Content, s.Limits.MaxCommentLength, "Comment") {
+		return
+	}
 
 	strId := context.Param("project_id")
 	projId, err := strconv.Atoi(strId)
@@ -232,7 +399,7 @@ func CreateCommentOnProject(context *gin.Context) {
 	}
 
 	// Verify the owner
-	username, err := database.GetUsernameById(newComment.User)
+	username, err := s.Users.GetUsernameById(newComment.User)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify comment ownership: %v", err))
 		return
@@ -243,8 +410,12 @@ func CreateCommentOnProject(context *gin.Context) {
 		return
 	}
 
+	if !RequireCallerIsAuthor(context, newComment.User, "comment") {
+		return
+	}
+
 	// Verify the project
-	project, err := database.QueryProject(projId)
+	project, err := s.Projects.QueryProject(projId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify project: %v", err))
 		return
@@ -256,7 +427,7 @@ func CreateCommentOnProject(context *gin.Context) {
 	}
 
 	// Create the comment
-	id, err := database.QueryCreateCommentOnProject(newComment, projId)
+	id, err := s.Comments.QueryCreateCommentOnProject(newComment, projId)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create comment on project: %v", err))
 		return
@@ -269,10 +440,12 @@ func CreateCommentOnProject(context *gin.Context) {
 // It expects a JSON payload that can be bound to a `types.Comment` object.
 // Validates the provided owner's ID, verifies the parent comment, and ensures the user exists.
 // Returns:
-// - 400 Bad Request if the JSON payload is invalid or the user/parent comment cannot be verified.
+// - 400 Bad Request if the JSON payload is invalid, the content is too long or the user/parent comment cannot be verified.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the author is not the caller.
 // - 500 Internal Server Error if there is a database error.
 // On success, responds with a 201 Created status and the new reply (comment) ID in JSON format.
-func CreateCommentOnComment(context *gin.Context) {
+func (s *Server) CreateCommentOnComment(context *gin.Context) {
 	var newComment types.Comment
 	err := context.BindJSON(&newComment)
 
@@ -280,6 +453,9 @@ func CreateCommentOnComment(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
 		return
 	}
+	if !RequireContentLength(context, newComment.Content, s.Limits.MaxCommentLength, "Comment") {
+		return
+	}
 
 	strId := context.Param("comment_id")
 	commId, err := strconv.Atoi(strId)
@@ -289,7 +465,7 @@ func CreateCommentOnComment(context *gin.Context) {
 	}
 
 	// Verify the owner
-	username, err := database.GetUsernameById(newComment.User)
+	username, err := s.Users.GetUsernameById(newComment.User)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify comment ownership: %v", err))
 		return
@@ -300,8 +476,12 @@ func CreateCommentOnComment(context *gin.Context) {
 		return
 	}
 
+	if !RequireCallerIsAuthor(context, newComment.User, "comment") {
+		return
+	}
+
 	// Verify the parent comment
-	parentComment, err := database.QueryComment(commId)
+	parentComment, err := s.Comments.QueryComment(commId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify parent comment: %v", err))
 		return
@@ -313,7 +493,7 @@ func CreateCommentOnComment(context *gin.Context) {
 	}
 
 	// Create the reply (comment)
-	id, err := database.QueryCreateCommentOnComment(newComment, commId)
+	id, err := s.Comments.QueryCreateCommentOnComment(newComment, commId)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create reply to comment: %v", err))
 		return
@@ -326,10 +506,12 @@ func CreateCommentOnComment(context *gin.Context) {
 // It expects the `comment_id` parameter in the URL.
 // Returns:
 // - 400 Bad Request if the post_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither owns the comment nor is a moderator.
 // - 404 Not Found if no post is found with the given id.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the post deletion.
-func DeleteComment(context *gin.Context) {
+func (s *Server) DeleteComment(context *gin.Context) {
 	strId := context.Param("comment_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
@@ -337,7 +519,22 @@ func DeleteComment(context *gin.Context) {
 		return
 	}
 
-	httpCode, err := database.QueryDeleteComment(id)
+	existingComment, err := s.Comments.QueryComment(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve comment: %v", err))
+		return
+	}
+	if existingComment == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Comment with id %v not found", id))
+		return
+	}
+
+	if !CallerCan(context, auth.PermissionRemoveContent) && !RequireCallerIsOwner(context, existingComment.User, "comment") {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	httpCode, err := s.Comments.QueryDeleteComment(id, callerId)
 	if err != nil {
 		RespondWithError(context, int(httpCode), fmt.Sprintf("Failed to delete comment: %v", err))
 		return
@@ -350,18 +547,20 @@ func DeleteComment(context *gin.Context) {
 // UpdateCommentContent handles PUT requests to delete a post.
 // It expects the `comment_id` parameter in the URL.
 // Returns:
-// - 400 Bad Request if the post_id is invalid.
+// - 400 Bad Request if the comment_id is invalid or the content is empty or too long.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the comment.
 // - 404 Not Found if no post is found with the given id.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the post deletion.
-func UpdateCommentContent(context *gin.Context) {
+func (s *Server) UpdateCommentContent(context *gin.Context) {
 	id, err := strconv.Atoi(context.Param("comment_id"))
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse comment id: %v", err))
 		return
 	}
 
-	existingComment, err := database.QueryComment(id)
+	existingComment, err := s.Comments.QueryComment(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve comment: %v", err))
 		return
@@ -371,6 +570,10 @@ func UpdateCommentContent(context *gin.Context) {
 		return
 	}
 
+	if !RequireCallerIsOwner(context, existingComment.User, "comment") {
+		return
+	}
+
 	var requestData struct {
 		Content string `json:"content"`
 	}
@@ -384,14 +587,17 @@ func UpdateCommentContent(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, "Content cannot be empty")
 		return
 	}
+	if !RequireContentLength(context, requestData.Content, s.Limits.MaxCommentLength, "Comment") {
+		return
+	}
 
-	httpcode, err := database.QueryUpdateCommentContent(id, requestData.Content)
+	httpcode, err := s.Comments.QueryUpdateCommentContent(id, requestData.Content, s.commentEditWindow(context))
 	if err != nil {
 		RespondWithError(context, int(httpcode), fmt.Sprintf("Error updating comment: %v", err))
 		return
 	}
 
-	updatedComment, err := database.QueryComment(id)
+	updatedComment, err := s.Comments.QueryComment(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error validating updated comment: %v", err))
 		return
@@ -412,13 +618,18 @@ func UpdateCommentContent(context *gin.Context) {
 // LikeComment handles POST requests to like a comment.
 // It expects the `username` and `comment_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func LikeComment(context *gin.Context) {
+func (s *Server) LikeComment(context *gin.Context) {
 	username := context.Param("username")
 	commentId := context.Param("comment_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.CreateCommentLike(username, commentId)
+	httpcode, err := s.Comments.CreateCommentLike(username, commentId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to like comment: %v", err))
 		return
@@ -429,13 +640,18 @@ func LikeComment(context *gin.Context) {
 // UnlikeComment handles POST requests to unlike a comment.
 // It expects the `username` and `comment_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func UnlikeComment(context *gin.Context) {
+func (s *Server) UnlikeComment(context *gin.Context) {
 	username := context.Param("username")
 	commentId := context.Param("comment_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.RemoveCommentLike(username, commentId)
+	httpcode, err := s.Comments.RemoveCommentLike(username, commentId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to unlike comment: %v", err))
 		return
@@ -448,11 +664,11 @@ func UnlikeComment(context *gin.Context) {
 // Returns:
 // - Appropriate error code for database failures or invalid input.
 // On success, responds with a 200 OK status and a status message.
-func IsCommentLiked(context *gin.Context) {
+func (s *Server) IsCommentLiked(context *gin.Context) {
 	username := context.Param("username")
 	commentId := context.Param("comment_id")
 
-	httpcode, exists, err := database.QueryCommentLike(username, commentId)
+	httpcode, exists, err := s.Comments.QueryCommentLike(username, commentId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to query for comment like: %v", err))
 		return
@@ -465,13 +681,54 @@ func IsCommentLiked(context *gin.Context) {
 // Returns:
 // - Appropriate error code for database failures or invalid input.
 // On success, responds with a 200 OK status and a status message.
-func IsCommentEditable(context *gin.Context) {
+func (s *Server) IsCommentEditable(context *gin.Context) {
 	commentId := context.Param("comment_id")
 
-	httpcode, exists, err := database.QueryIsCommentEditable(commentId)
+	httpcode, exists, err := s.Comments.QueryIsCommentEditable(commentId, s.commentEditWindow(context))
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to query for comment: %v", err))
 		return
 	}
 	context.JSON(httpcode, gin.H{"status": exists})
 }
+
+// commentEditWindow is how long after posting the caller can edit their
+// comments, zero for callers allowed to edit them at any time.
+func (s *Server) commentEditWindow(context *gin.Context) time.Duration {
+	if CallerCan(context, auth.PermissionEditAnytime) {
+		return 0
+	}
+	return s.Limits.CommentEditWindow.Duration
+}
+
+// GetCommentHistory handles GET requests for what a comment said before each of its edits.
+// It expects the `comment_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the comment_id is invalid.
+// - 404 Not Found if no comment is found with the given id.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the comment's revisions, newest first.
+func (s *Server) GetCommentHistory(context *gin.Context) {
+	id, err := strconv.Atoi(context.Param("comment_id"))
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse comment id: %v", err))
+		return
+	}
+
+	comment, err := s.Comments.QueryComment(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve comment: %v", err))
+		return
+	}
+	if comment == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Comment with id %v not found", id))
+		return
+	}
+
+	revisions, httpCode, err := s.Comments.QueryCommentRevisions(id)
+	if err != nil {
+		RespondWithError(context, httpCode, fmt.Sprintf("Failed to fetch comment history: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, revisions)
+}
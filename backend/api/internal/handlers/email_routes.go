@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/mail"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEmailPreferences handles GET requests to retrieve where a user is
+// emailed, whether they confirmed the address and how often they get a
+// digest of their notifications.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the preferences in JSON
+// format, with digests off for users who never set them.
+func (s *Server) GetEmailPreferences(context *gin.Context) {
+	if !RequireCallerIsUser(context, context.Param("username")) {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	preferences, code, err := s.Digests.QueryEmailPreferences(callerId)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch email preferences: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, preferences)
+}
+
+// UpdateEmailPreferences handles PUT requests to set where a user is
+// emailed and how often they get a digest of their notifications. The
+// first digest after turning them on comes a day or week later, and only
+// once the address is confirmed through the link emailed to it.
+// It expects the `username` parameter in the URL and a JSON payload with
+// the `email` address and the `digest` frequency, off, daily or weekly.
+// Returns:
+// - 400 Bad Request if the email address or frequency is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 429 Too Many Requests if a confirmation link was sent to another address too recently.
+// - 500 Internal Server Error if a database query or sending the confirmation link fails.
+// On success, responds with a 200 OK status and the saved preferences in JSON format.
+func (s *Server) UpdateEmailPreferences(context *gin.Context) {
+	if !RequireCallerIsUser(context, context.Param("username")) {
+		return
+	}
+
+	var preferences types.EmailPreferences
+	if err := context.BindJSON(&preferences); err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+	// a bare address, display names would end up in the To header
+	if address, err := netmail.ParseAddress(preferences.Email); err != nil || address.Address != preferences.Email {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid email address '%v'", preferences.Email))
+		return
+	}
+	if _, ok := types.DigestFrequencies[preferences.Digest]; !ok && preferences.Digest != types.DigestOff {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Unknown digest frequency '%v', must be off, daily or weekly", preferences.Digest))
+		return
+	}
+
+	token, err := auth.NewRandomToken()
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to save email preferences: %v", err))
+		return
+	}
+	callerId, username := GetCaller(context)
+	sendLink, code, err := s.Digests.QuerySetEmailPreferences(callerId, preferences, auth.HashToken(token), time.Now().UTC())
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to save email preferences: %v", err))
+		return
+	}
+	if sendLink {
+		message := mail.RenderConfirmation(preferences.Email, username, mail.ConfirmationLink(s.MailLinkURL, token))
+		if err := s.Mailer.Send(message); err != nil {
+			RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to send confirmation email: %v", err))
+			return
+		}
+	}
+
+	saved, code, err := s.Digests.QueryEmailPreferences(callerId)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch email preferences: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, saved)
+}
+
+// ConfirmEmail handles GET requests from the link emailed to confirm an
+// address, after which digests are sent to it.
+// It expects the `token` query parameter from the link.
+// Returns:
+// - 400 Bad Request if the token is missing.
+// - 404 Not Found if the token is invalid or was already used.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the address.
+func (s *Server) ConfirmEmail(context *gin.Context) {
+	token := context.Query("token")
+	if token == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing the 'token' query parameter")
+		return
+	}
+
+	code, err := s.Digests.QueryConfirmEmail(auth.HashToken(token), time.Now().UTC())
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to confirm email address: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Email address confirmed."})
+}
+
+// Unsubscribe handles GET requests from the unsubscribe link at the bottom
+// of every digest, turning the user's digests off.
+// It expects the `token` query parameter from the link.
+// Returns:
+// - 400 Bad Request if the token is missing.
+// - 404 Not Found if the token is invalid, or for an address the user no longer uses.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming digests are off.
+func (s *Server) Unsubscribe(context *gin.Context) {
+	token := context.Query("token")
+	if token == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing the 'token' query parameter")
+		return
+	}
+
+	userId, ok := auth.UnsubscribeTokenUser(token)
+	if !ok {
+		RespondWithError(context, http.StatusNotFound, "The unsubscribe link is invalid")
+		return
+	}
+	preferences, code, err := s.Digests.QueryEmailPreferences(userId)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch email preferences: %v", err))
+		return
+	}
+	if preferences.Email == "" || !auth.CheckUnsubscribeToken(token, userId, preferences.Email) {
+		RespondWithError(context, http.StatusNotFound, "The unsubscribe link is invalid")
+		return
+	}
+
+	// digests only ever went to a confirmed address, which keeps it
+	// confirmed without sending another link
+	if preferences.Confirmed && preferences.Digest != types.DigestOff {
+		preferences.Digest = types.DigestOff
+		_, code, err = s.Digests.QuerySetEmailPreferences(userId, preferences, "", time.Now().UTC())
+		if err != nil {
+			RespondWithError(context, code, fmt.Sprintf("Failed to turn off digests: %v", err))
+			return
+		}
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Unsubscribed, no more digests will be sent."})
+}
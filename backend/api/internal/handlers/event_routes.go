@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEvents handles GET requests to open a Server-Sent Events stream of
+// what happens around the caller: their new notifications, new posts by
+// the users and on the projects they follow, and the like counts of the
+// posts, projects and comments they are viewing.
+// It accepts the optional URL parameters `posts`, `projects` and `comments`,
+// comma separated ids of the items whose like counts to watch, and the
+// Last-Event-ID header to resume after the last event received. When the
+// missed events are gone, the stream starts with a `reset` event instead.
+// Follows made while the stream is open take effect once it reconnects.
+// Returns:
+// - 400 Bad Request if an id is invalid or more items are watched than a feed page holds.
+// - 401 Unauthorized if the caller is not logged in.
+// - 500 Internal Server Error if a database query fails.
+// - 503 Service Unavailable if the server does not publish events.
+// On success, responds with a 200 OK status and keeps the stream open
+// until the client goes away.
+func (s *Server) GetEvents(context *gin.Context) {
+	if s.Events == nil {
+		RespondWithError(context, http.StatusServiceUnavailable, "Event streams are not available on this server")
+		return
+	}
+
+	callerId, username := GetCaller(context)
+	topics := []string{events.UserTopic(callerId)}
+
+	watched := 0
+	for _, watch := range []struct {
+		param string
+		topic func(int64) string
+	}{
+		{"posts", events.PostTopic},
+		{"projects", events.ProjectTopic},
+		{"comments", events.CommentTopic},
+	} {
+		ids, err := parseIdList(context.Query(watch.param))
+		if err != nil {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse %v: %v", watch.param, err))
+			return
+		}
+		for _, id := range ids {
+			topics = append(topics, watch.topic(id))
+		}
+		watched += len(ids)
+	}
+	if watched > s.Limits.MaxFeedCount {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Cannot watch more than %v items at once", s.Limits.MaxFeedCount))
+		return
+	}
+
+	users, _, code, err := s.Users.QueryGetUsersFollowing(username, types.Page{})
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch followed users: %v", err))
+		return
+	}
+	for _, id := range users {
+		topics = append(topics, events.PostsByUserTopic(int64(id)))
+	}
+	projects, code, err := s.Projects.QueryGetProjectFollowing(username)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch followed projects: %v", err))
+		return
+	}
+	for _, id := range projects {
+		topics = append(topics, events.PostsOnProjectTopic(int64(id)))
+	}
+
+	subscription, resumed := s.Events.Subscribe(topics, context.GetHeader("Last-Event-ID"))
+	defer subscription.Close()
+
+	header := context.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// stops nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+	if !resumed {
+		fmt.Fprintf(context.Writer, "event: %v\ndata: {}\n\n", events.TypeReset)
+	}
+	context.Writer.Flush()
+
+	keepAlive := time.NewTicker(s.Events.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-context.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(context.Writer, ": keepalive\n\n")
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// dropped for falling behind, the client reconnects and resumes
+				return
+			}
+			fmt.Fprintf(context.Writer, "id: %v\nevent: %v\ndata: %s\n\n", s.Events.Id(event), event.Type, event.Data)
+		}
+		context.Writer.Flush()
+	}
+}
+
+// parseIdList parses a comma separated list of ids, empty for none.
+func parseIdList(list string) ([]int64, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, strId := range strings.Split(list, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(strId), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
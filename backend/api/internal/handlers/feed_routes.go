@@ -3,99 +3,123 @@ package handlers
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 
-	"backend/api/internal/database"
 	"backend/api/internal/types"
 	"github.com/gin-gonic/gin"
 )
 
-// GetPostsFeed handles GET requests to retrieve a set of posts for the feed
-// It expects the URL parameters of `type`, `start`, and `count`
+// GetPostsFeed handles GET requests to retrieve a page of posts for the feed
+// It expects the URL parameters of `type` and `count`, and the `cursor` of
+// the previous page's next_cursor to continue where it left off.
+// The `trending` type favours posts liked and commented on lately and the
+// `following` type is the caller's home feed, see GetHomeFeed.
 // Returns:
-// - 400 Bad Request if the inputs are invalid.
+// - 400 Bad Request if the inputs are invalid or count is over the feed limit.
+// - 401 Unauthorized if the `following` feed is requested without logging in.
 // - 404 Not Found if the post does not exist.
 // - 500 Internal Server Error if the database query fails.
-// On success, responds with a 200 OK status and the post feed in JSON format.
-func GetPostsFeed(context *gin.Context) {
+// On success, responds with a 200 OK status and the page of the post feed in JSON format.
+func (s *Server) GetPostsFeed(context *gin.Context) {
 	feedType := context.Query("type")
-	strStart := context.Query("start")
-	strCount := context.Query("count")
-
-	if feedType == "" || strStart == "" || strCount == "" {
-		RespondWithError(context, http.StatusBadRequest, "Missing one or more required url query parameters: type, start, or count")
-		return
-	}
-
-	start, err := strconv.Atoi(strStart)
-	if err != nil {
-		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse starting int: %v", err))
+	if feedType == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing one or more required url query parameters: type or count")
 		return
 	}
 
-	count, err := strconv.Atoi(strCount)
-	if err != nil {
-		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse count int: %v", err))
+	page, ok := s.parsePage(context, true)
+	if !ok {
 		return
 	}
-	var posts []types.Post = []types.Post{}
+	var posts []types.Post
+	var next *types.Cursor
 	var code int
+	var err error
 	switch feedType {
 	case "time":
-		posts, code, err = database.GetPostByTimeFeed(start, count)
+		posts, next, code, err = s.Feed.GetPostByTimeFeed(page)
 	case "likes":
-		posts, code, err = database.GetPostByLikesFeed(start, count)
+		posts, next, code, err = s.Feed.GetPostByLikesFeed(page)
+	case "trending":
+		posts, next, code, err = s.Feed.GetPostByTrendingFeed(page)
+	case "following":
+		callerId, _ := GetCaller(context)
+		if callerId == 0 {
+			RespondWithError(context, http.StatusUnauthorized, "Log in to see the following feed")
+			return
+		}
+		posts, next, code, err = s.Feed.GetPostByFollowingFeed(callerId, page)
 	default:
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid feed type passed: %v", feedType))
 		return
 	}
 	if err != nil {
 		RespondWithError(context, code, fmt.Sprintf("An error occurred getting feed: %v", err))
+		return
 	}
-	context.JSON(http.StatusOK, posts)
+	respondWithPage(context, posts, next)
 }
 
-// GetProjectsFeed handles GET requests to retrieve a set of projects for the feed
-// It expects the URL parameters of `type`, `start`, and `count`
+// GetHomeFeed handles GET requests to retrieve the caller's home feed, the
+// most recent posts by the users they follow or on the projects they follow.
+// It expects the URL parameter of `count`, and the `cursor` of the previous
+// page's next_cursor to continue where it left off.
 // Returns:
-// - 400 Bad Request if the inputs are invalid.
-// - 404 Not Found if the post does not exist.
+// - 400 Bad Request if the inputs are invalid or count is over the feed limit.
 // - 500 Internal Server Error if the database query fails.
-// On success, responds with a 200 OK status and the post feed in JSON format.
-func GetProjectsFeed(context *gin.Context) {
-	feedType := context.Query("type")
-	strStart := context.Query("start")
-	strCount := context.Query("count")
-
-	if feedType == "" || strStart == "" || strCount == "" {
-		RespondWithError(context, http.StatusBadRequest, "Missing one or more required url query parameters: type, start, or count")
+// On success, responds with a 200 OK status and the page of the post feed in JSON format.
+func (s *Server) GetHomeFeed(context *gin.Context) {
+	page, ok := s.parsePage(context, true)
+	if !ok {
 		return
 	}
 
-	start, err := strconv.Atoi(strStart)
+	callerId, _ := GetCaller(context)
+	posts, next, code, err := s.Feed.GetPostByFollowingFeed(callerId, page)
 	if err != nil {
-		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse starting int: %v", err))
+		RespondWithError(context, code, fmt.Sprintf("An error occurred getting feed: %v", err))
 		return
 	}
+	respondWithPage(context, posts, next)
+}
 
-	count, err := strconv.Atoi(strCount)
-	if err != nil {
-		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse count int: %v", err))
+// GetProjectsFeed handles GET requests to retrieve a page of projects for the feed
+// It expects the URL parameters of `type` and `count`, and the `cursor` of
+// the previous page's next_cursor to continue where it left off.
+// The `trending` type favours projects liked, commented on and followed lately.
+// Returns:
+// - 400 Bad Request if the inputs are invalid or count is over the feed limit.
+// - 404 Not Found if the post does not exist.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the page of the project feed in JSON format.
+func (s *Server) GetProjectsFeed(context *gin.Context) {
+	feedType := context.Query("type")
+	if feedType == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing one or more required url query parameters: type or count")
 		return
 	}
-	var projects []types.Project = []types.Project{}
+
+	page, ok := s.parsePage(context, true)
+	if !ok {
+		return
+	}
+	var projects []types.Project
+	var next *types.Cursor
 	var code int
+	var err error
 	switch feedType {
 	case "time":
-		projects, code, err = database.GetProjectByTimeFeed(start, count)
+		projects, next, code, err = s.Feed.GetProjectByTimeFeed(page)
 	case "likes":
-		projects, code, err = database.GetProjectByLikesFeed(start, count)
+		projects, next, code, err = s.Feed.GetProjectByLikesFeed(page)
+	case "trending":
+		projects, next, code, err = s.Feed.GetProjectByTrendingFeed(page)
 	default:
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid feed type passed: %v", feedType))
 		return
 	}
 	if err != nil {
 		RespondWithError(context, code, fmt.Sprintf("An error occurred getting feed: %v", err))
+		return
 	}
-	context.JSON(http.StatusOK, projects)
+	respondWithPage(context, projects, next)
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireContentLength checks that user written text fits within `max` characters,
+// where `resource` is used to build the error message.
+// If not, it responds with a 400 Bad Request and returns false.
+func RequireContentLength(context *gin.Context, content string, max int, resource string) bool {
+	if length := utf8.RuneCountInString(content); length > max {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("%v content is %v characters long, the limit is %v", resource, length, max))
+		return false
+	}
+	return true
+}
+
+// RequireFeedCount checks that a feed request does not ask for more items
+// than the configured limit. If it does, it responds with a 400 Bad Request and returns false.
+func (s *Server) RequireFeedCount(context *gin.Context, count int) bool {
+	if count > s.Limits.MaxFeedCount {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Feed count %v is over the limit of %v", count, s.Limits.MaxFeedCount))
+		return false
+	}
+	return true
+}
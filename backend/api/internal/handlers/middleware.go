@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keys used to store the authenticated caller on the gin context
+const (
+	CallerIdKey       = "caller_id"
+	CallerUsernameKey = "caller_username"
+	CallerSessionKey  = "caller_session"
+	CallerRoleKey     = "caller_role"
+)
+
+// RequireAuth builds middleware that resolves the caller from the
+// `Authorization: Bearer <token>` header and stores their id, username
+// and session on the gin context for the handlers further down the chain.
+// The token can either be an access token handed out on login, whose session
+// is checked so revoked sessions are rejected before the token expires, or a
+// personal access token. Personal access tokens are only accepted on routes
+// that list the scopes they need, and only if they were granted all of them.
+// Returns:
+// - 401 Unauthorized if the header is missing, the token is invalid or the session was revoked.
+// - 403 Forbidden if the account is suspended or a personal access token is used without the required scopes.
+// - 500 Internal Server Error if the token could not be looked up.
+func (s *Server) RequireAuth(scopes ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		header := context.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		token = strings.TrimSpace(token)
+		if !found || token == "" {
+			RespondWithError(context, http.StatusUnauthorized, "Missing or malformed Authorization header")
+			context.Abort()
+			return
+		}
+
+		if auth.IsPersonalToken(token) {
+			s.authenticatePersonalToken(context, token, scopes)
+			return
+		}
+
+		claims, err := auth.ParseAccessToken(token)
+		if err != nil {
+			RespondWithError(context, http.StatusUnauthorized, fmt.Sprintf("Failed to authenticate: %v", err))
+			context.Abort()
+			return
+		}
+
+		caller, httpcode, err := s.Auth.QuerySessionUser(claims.SessionId)
+		if err == nil && caller.ID != claims.Subject {
+			httpcode, err = http.StatusUnauthorized, fmt.Errorf("Access token does not match its session")
+		}
+		if err != nil {
+			RespondWithError(context, httpcode, fmt.Sprintf("Failed to authenticate: %v", err))
+			context.Abort()
+			return
+		}
+		if !allowCaller(context, caller) {
+			return
+		}
+
+		context.Set(CallerIdKey, caller.ID)
+		context.Set(CallerUsernameKey, caller.Username)
+		context.Set(CallerRoleKey, caller.Role)
+		context.Set(CallerSessionKey, claims.SessionId)
+		context.Next()
+	}
+}
+
+// OptionalAuth builds middleware for public routes that show more to a
+// logged in caller. Requests without an Authorization header go through
+// anonymously, any other request has to pass RequireAuth with `scopes`.
+func (s *Server) OptionalAuth(scopes ...string) gin.HandlerFunc {
+	requireAuth := s.RequireAuth(scopes...)
+	return func(context *gin.Context) {
+		if context.GetHeader("Authorization") == "" {
+			context.Next()
+			return
+		}
+		requireAuth(context)
+	}
+}
+
+// authenticatePersonalToken is the part of RequireAuth that handles
+// personal access tokens, which have no session and are limited by scope.
+func (s *Server) authenticatePersonalToken(context *gin.Context, token string, scopes []string) {
+	caller, granted, httpcode, err := s.Auth.QueryPersonalTokenUser(auth.HashToken(token))
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to authenticate: %v", err))
+		context.Abort()
+		return
+	}
+	if !allowCaller(context, caller) {
+		return
+	}
+
+	if len(scopes) == 0 {
+		RespondWithError(context, http.StatusForbidden, "Personal access tokens cannot be used on this route")
+		context.Abort()
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			RespondWithError(context, http.StatusForbidden, fmt.Sprintf("Personal access token is missing the '%v' scope", scope))
+			context.Abort()
+			return
+		}
+	}
+
+	// tokens only ever act with a regular user's permissions, so a leaked
+	// token belonging to staff cannot be used to moderate or administrate
+	context.Set(CallerIdKey, caller.ID)
+	context.Set(CallerUsernameKey, caller.Username)
+	context.Set(CallerRoleKey, auth.RoleUser)
+	context.Next()
+}
+
+// allowCaller rejects callers whose account has been suspended.
+// If the caller is suspended, it responds with a 403 Forbidden and returns false.
+func allowCaller(context *gin.Context, caller *types.Caller) bool {
+	if caller.Suspended {
+		RespondWithError(context, http.StatusForbidden, fmt.Sprintf("Account '%v' is suspended", caller.Username))
+		context.Abort()
+		return false
+	}
+	return true
+}
+
+// RequirePermission builds middleware that only lets callers through
+// whose role grants `permission`. It has to run after RequireAuth.
+// Returns:
+// - 403 Forbidden if the caller's role does not grant the permission.
+func RequirePermission(permission auth.Permission) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !CallerCan(context, permission) {
+			_, callerUsername := GetCaller(context)
+			RespondWithError(context, http.StatusForbidden, fmt.Sprintf("User '%v' does not have the '%v' permission", callerUsername, permission))
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
+
+// AliasParam makes the URL parameter `name` readable as `alias` as well.
+// gin needs wildcards in the same place of routes with the same method to
+// share a name, so routes that would conflict take their parameter under
+// the existing name and alias it to the one their handler reads.
+func AliasParam(name string, alias string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Params = append(context.Params, gin.Param{Key: alias, Value: context.Param(name)})
+		context.Next()
+	}
+}
+
+// CallerCan reports whether the caller's role grants a permission, for
+// handlers that let privileged users past the usual ownership checks.
+func CallerCan(context *gin.Context, permission auth.Permission) bool {
+	return auth.HasPermission(context.GetString(CallerRoleKey), permission)
+}
+
+// GetCaller returns the id and username of the authenticated caller.
+// It only returns useful data on routes guarded by RequireAuth.
+func GetCaller(context *gin.Context) (int64, string) {
+	return context.GetInt64(CallerIdKey), context.GetString(CallerUsernameKey)
+}
+
+// GetCallerSession returns the id of the session the caller authenticated with,
+// which is empty for callers using a personal access token.
+func GetCallerSession(context *gin.Context) string {
+	return context.GetString(CallerSessionKey)
+}
+
+// RequireCallerIsUser checks that the caller is acting as themselves,
+// for routes that take the acting user's name from the URL.
+// If not, it responds with a 403 Forbidden and returns false.
+func RequireCallerIsUser(context *gin.Context, username string) bool {
+	_, callerUsername := GetCaller(context)
+	if callerUsername != username {
+		RespondWithError(context, http.StatusForbidden, fmt.Sprintf("User '%v' cannot act on behalf of '%v'", callerUsername, username))
+		return false
+	}
+	return true
+}
+
+// RequireCallerIsOwner checks that the caller owns the resource being
+// written, where `owner` is the owning user id and `resource` is used to
+// build the error message. If not, it responds with a 403 Forbidden and returns false.
+func RequireCallerIsOwner(context *gin.Context, owner int64, resource string) bool {
+	callerId, callerUsername := GetCaller(context)
+	if callerId != owner {
+		RespondWithError(context, http.StatusForbidden, fmt.Sprintf("User '%v' does not own this %v", callerUsername, resource))
+		return false
+	}
+	return true
+}
+
+// RequireCallerIsAuthor checks that a new resource is being created on
+// behalf of the caller, where `author` is the user id given in the request
+// body. If not, it responds with a 403 Forbidden and returns false.
+func RequireCallerIsAuthor(context *gin.Context, author int64, resource string) bool {
+	callerId, callerUsername := GetCaller(context)
+	if callerId != author {
+		RespondWithError(context, http.StatusForbidden, fmt.Sprintf("User '%v' cannot create a %v for another user", callerUsername, resource))
+		return false
+	}
+	return true
+}
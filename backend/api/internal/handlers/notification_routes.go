@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications handles GET requests to retrieve the caller's notifications,
+// latest first, with the ones about the same thing grouped together.
+// It expects the URL parameter of `count`, and the `cursor` of the previous
+// page's next_cursor to continue where it left off.
+// Returns:
+// - 400 Bad Request if the inputs are invalid or count is over the feed limit.
+// - 401 Unauthorized if the caller is not logged in.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status, the page of notifications and
+// how many groups of notifications are unread in JSON format.
+func (s *Server) GetNotifications(context *gin.Context) {
+	page, ok := s.parsePage(context, true)
+	if !ok {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	notifications, next, code, err := s.Notifications.QueryNotifications(callerId, page)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch notifications: %v", err))
+		return
+	}
+	unread, code, err := s.Notifications.QueryUnreadNotificationCount(callerId)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to count unread notifications: %v", err))
+		return
+	}
+
+	context.JSON(http.StatusOK, types.NotificationPage{
+		Items:      notifications,
+		NextCursor: encodeCursor(next),
+		Unread:     unread,
+	})
+}
+
+// MarkNotificationRead handles POST requests to mark a group of the caller's
+// notifications read, up to the notification given. Anything newer in the
+// group stays unread.
+// It expects the `notification_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the notification_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 404 Not Found if the caller has no notification with the given id.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) MarkNotificationRead(context *gin.Context) {
+	id, err := strconv.ParseInt(context.Param("notification_id"), 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse notification id: %v", err))
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	code, err := s.Notifications.QueryMarkNotificationRead(callerId, id)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to mark notification read: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Notification %v marked read.", id)})
+}
+
+// MarkAllNotificationsRead handles POST requests to mark every one of the
+// caller's notifications read.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) MarkAllNotificationsRead(context *gin.Context) {
+	callerId, _ := GetCaller(context)
+	code, err := s.Notifications.QueryMarkAllNotificationsRead(callerId)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to mark notifications read: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "All notifications marked read."})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// encodeCursor turns a cursor into the opaque string clients send back
+// as `cursor` to get the next page. It is empty when there is no next page.
+func encodeCursor(cursor *types.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads back a cursor made by encodeCursor.
+func decodeCursor(encoded string) (*types.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cursor types.Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// parsePage reads the page a request asks for from the `count` and optional
+// `cursor` URL parameters. Feeds are always paged and set `required`, other
+// lists are only paged when a count is given and otherwise return every item,
+// which parsePage reports as a page with a Limit of 0.
+// If the parameters are invalid, it responds with a 400 Bad Request and returns false.
+func (s *Server) parsePage(context *gin.Context, required bool) (types.Page, bool) {
+	strCount := context.Query("count")
+	strCursor := context.Query("cursor")
+
+	if strCount == "" {
+		if required || strCursor != "" {
+			RespondWithError(context, http.StatusBadRequest, "Missing required url query parameter: count")
+			return types.Page{}, false
+		}
+		return types.Page{}, true
+	}
+
+	count, err := strconv.Atoi(strCount)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse count int: %v", err))
+		return types.Page{}, false
+	}
+	if count < 1 {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Count must be at least 1, got %v", count))
+		return types.Page{}, false
+	}
+	if !s.RequireFeedCount(context, count) {
+		return types.Page{}, false
+	}
+
+	page := types.Page{Limit: count}
+	if strCursor != "" {
+		page.After, err = decodeCursor(strCursor)
+		if err != nil {
+			RespondWithError(context, http.StatusBadRequest, "Invalid cursor, pass back the next_cursor of the previous page")
+			return types.Page{}, false
+		}
+	}
+	return page, true
+}
+
+// respondWithPage sends a page of a list along with the cursor of the next page.
+func respondWithPage[T any](context *gin.Context, items []T, next *types.Cursor) {
+	if items == nil {
+		items = []T{}
+	}
+	context.JSON(http.StatusOK, types.PageResponse[T]{Items: items, NextCursor: encodeCursor(next)})
+}
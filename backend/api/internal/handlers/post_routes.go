@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
-	"backend/api/internal/database"
+	"backend/api/internal/auth"
 	"backend/api/internal/types"
 
 	"github.com/gin-gonic/gin"
@@ -18,14 +18,14 @@ import (
 // - 404 Not Found if the post does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the post details in JSON format.
-func GetPostById(context *gin.Context) {
+func (s *Server) GetPostById(context *gin.Context) {
 	strId := context.Param("post_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse post_id: %v", err))
 		return
 	}
-	post, err := database.QueryPost(id)
+	post, err := s.Posts.QueryPost(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch post: %v", err))
 		return
@@ -39,31 +39,74 @@ func GetPostById(context *gin.Context) {
 	context.JSON(http.StatusOK, post)
 }
 
+// GetPostHistory handles GET requests for what a post said before each of its edits.
+// It expects the `post_id` parameter in the URL and does not require a request body.
+// Returns:
+// - 400 Bad Request if the ID is invalid.
+// - 404 Not Found if the post does not exist.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the post's revisions, newest first.
+func (s *Server) GetPostHistory(context *gin.Context) {
+	strId := context.Param("post_id")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse post_id: %v", err))
+		return
+	}
+	post, err := s.Posts.QueryPost(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch post: %v", err))
+		return
+	}
+	if post == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Post with id '%v' not found", strId))
+		return
+	}
+
+	revisions, httpCode, err := s.Posts.QueryPostRevisions(id)
+	if err != nil {
+		RespondWithError(context, httpCode, fmt.Sprintf("Failed to fetch post history: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, revisions)
+}
+
 // GetPostByUserId handles GET requests to retrieve project information by its owning user.
 // It expects the `user_id` parameter in the URL and does not require a request body.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
 // - 400 Bad Request if the ID is invalid.
 // - 404 Not Found if the user does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the posts' details in JSON format.
-func GetPostsByUserId(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetPostsByUserId(context *gin.Context) {
 	strId := context.Param("user_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse user_id: %v", err))
 		return
 	}
-	posts, httpcode, err := database.QueryPostsByUserId(id)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	posts, next, httpcode, err := s.Posts.QueryPostsByUserId(id, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch posts: %v", err))
 		return
 	}
 
-	if posts == nil {
+	if posts == nil && page.After == nil {
 		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Posts from user with id %v not found", strId))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, posts, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, posts)
 }
 
@@ -74,14 +117,14 @@ func GetPostsByUserId(context *gin.Context) {
 // - 404 Not Found if the project does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the posts' details in JSON format.
-func GetPostsByProjectId(context *gin.Context) {
+func (s *Server) GetPostsByProjectId(context *gin.Context) {
 	strId := context.Param("project_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project_id: %v", err))
 		return
 	}
-	posts, httpcode, err := database.QueryPostsByProjectId(id)
+	posts, httpcode, err := s.Posts.QueryPostsByProjectId(id)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch posts: %v", err))
 		return
@@ -99,10 +142,12 @@ func GetPostsByProjectId(context *gin.Context) {
 // It expects a JSON payload that can be bound to a `types.Post` object.
 // Validates the provided owner's ID and ensures the user and project exist.
 // Returns:
-// - 400 Bad Request if the JSON payload is invalid or the owner/project cannot be verified.
+// - 400 Bad Request if the JSON payload is invalid, the content is too long or the owner/project cannot be verified.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the author is not the caller.
 // - 500 Internal Server Error if there is a database error.
 // On success, responds with a 201 Created status and the new post ID in JSON format.
-func CreatePost(context *gin.Context) {
+func (s *Server) CreatePost(context *gin.Context) {
 	var newPost types.Post
 	err := context.BindJSON(&newPost)
 
@@ -110,9 +155,12 @@ func CreatePost(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
 		return
 	}
+	if !RequireContentLength(context, newPost.Content, s.Limits.MaxPostLength, "Post") {
+		return
+	}
 
 	// verify the owner
-	username, err := database.GetUsernameById(newPost.User)
+	username, err := s.Users.GetUsernameById(newPost.User)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify post ownership: %v", err))
 		return
@@ -123,8 +171,12 @@ func CreatePost(context *gin.Context) {
 		return
 	}
 
+	if !RequireCallerIsAuthor(context, newPost.User, "post") {
+		return
+	}
+
 	// verify the project
-	project, err := database.QueryPost(int(newPost.User))
+	project, err := s.Projects.QueryProject(int(newPost.Project))
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify post ownership: %v", err))
 		return
@@ -135,7 +187,7 @@ func CreatePost(context *gin.Context) {
 		return
 	}
 
-	id, err := database.QueryCreatePost(&newPost)
+	id, err := s.Posts.QueryCreatePost(&newPost)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create project: %v", err))
 		return
@@ -147,10 +199,12 @@ func CreatePost(context *gin.Context) {
 // It expects the `post_id` parameter in the URL.
 // Returns:
 // - 400 Bad Request if the post_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither owns the post nor is a moderator.
 // - 404 Not Found if no post is found with the given id.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the post deletion.
-func DeletePost(context *gin.Context) {
+func (s *Server) DeletePost(context *gin.Context) {
 	strId := context.Param("post_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
@@ -158,7 +212,22 @@ func DeletePost(context *gin.Context) {
 		return
 	}
 
-	httpCode, err := database.QueryDeletePost(id)
+	existingPost, err := s.Posts.QueryPost(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve post: %v", err))
+		return
+	}
+	if existingPost == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Post with id '%v' not found", id))
+		return
+	}
+
+	if !CallerCan(context, auth.PermissionRemoveContent) && !RequireCallerIsOwner(context, existingPost.User, "post") {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	httpCode, err := s.Posts.QueryDeletePost(id, callerId)
 	// delete posts can return different errors...
 	if err != nil {
 		RespondWithError(context, int(httpCode), fmt.Sprintf("Failed to delete post: %v", err))
@@ -173,11 +242,13 @@ func DeletePost(context *gin.Context) {
 // It expects the `post_id` parameter in the URL and a JSON payload with update fields.
 // Validates the post ID, checks for the existence of the post, and ensures the fields being updated are allowed.
 // Returns:
-// - 400 Bad Request for invalid input or disallowed fields.
+// - 400 Bad Request for invalid input, disallowed fields or content that is too long.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the post.
 // - 404 Not Found if the post does not exist.
 // - 500 Internal Server Error for database errors.
 // On success, responds with a 200 OK status and the updated post details in JSON format.
-func UpdatePostInfo(context *gin.Context) {
+func (s *Server) UpdatePostInfo(context *gin.Context) {
 	var updateData map[string]interface{}
 
 	// Parse post ID from the URL
@@ -192,8 +263,11 @@ func UpdatePostInfo(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse update data: %v", err))
 		return
 	}
+	if content, ok := updateData["content"].(string); ok && !RequireContentLength(context, content, s.Limits.MaxPostLength, "Post") {
+		return
+	}
 
-	existingPost, err := database.QueryPost(id)
+	existingPost, err := s.Posts.QueryPost(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve post: %v", err))
 		return
@@ -203,28 +277,18 @@ func UpdatePostInfo(context *gin.Context) {
 		return
 	}
 
-	// validate new owner if provided in update data
-	if newOwner, ok := updateData["user"]; ok {
-		ownerID, ok := newOwner.(float64) // Assuming JSON numbers are decoded as float64
-		if !ok {
-			RespondWithError(context, http.StatusBadRequest, "Invalid owner id format")
-			return
-		}
-		username, err := database.GetUsernameById(int64(ownerID))
-		if err != nil || username == "" {
-			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid owner id: %v", ownerID))
-			return
-		}
+	if !RequireCallerIsOwner(context, existingPost.User, "post") {
+		return
 	}
 
-	// validate new owner if provided in update data
+	// validate new project if provided in update data
 	if newProject, ok := updateData["project"]; ok {
 		projectID, ok := newProject.(float64) // Assuming JSON numbers are decoded as float64
 		if !ok {
 			RespondWithError(context, http.StatusBadRequest, "Invalid project id format")
 			return
 		}
-		existingProject, err := database.QueryProject(int(projectID))
+		existingProject, err := s.Projects.QueryProject(int(projectID))
 		if err != nil || existingProject == nil {
 			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid project id: %v", projectID))
 			return
@@ -241,13 +305,13 @@ func UpdatePostInfo(context *gin.Context) {
 		}
 	}
 
-	err = database.QueryUpdatePost(id, updatedData)
+	err = s.Posts.QueryUpdatePost(id, updatedData)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error updating post: %v", err))
 		return
 	}
 
-	updatedPost, err := database.QueryPost(id)
+	updatedPost, err := s.Posts.QueryPost(id)
 
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error validating updated post: %v", err))
@@ -263,13 +327,18 @@ func UpdatePostInfo(context *gin.Context) {
 // LikePost handles POST requests to like a post.
 // It expects the `username` and `post_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func LikePost(context *gin.Context) {
+func (s *Server) LikePost(context *gin.Context) {
 	username := context.Param("username")
 	postId := context.Param("post_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.CreatePostLike(username, postId)
+	httpcode, err := s.Posts.CreatePostLike(username, postId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to like post: %v", err))
 		return
@@ -280,13 +349,18 @@ func LikePost(context *gin.Context) {
 // UnlikePost handles POST requests to unlike a post.
 // It expects the `username` and `post_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func UnlikePost(context *gin.Context) {
+func (s *Server) UnlikePost(context *gin.Context) {
 	username := context.Param("username")
 	postId := context.Param("post_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.RemovePostLike(username, postId)
+	httpcode, err := s.Posts.RemovePostLike(username, postId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to unlike post: %v", err))
 		return
@@ -299,11 +373,11 @@ func UnlikePost(context *gin.Context) {
 // Returns:
 // - Appropriate error code for database failures or invalid input.
 // On success, responds with a 200 OK status and a status message.
-func IsPostLiked(context *gin.Context) {
+func (s *Server) IsPostLiked(context *gin.Context) {
 	username := context.Param("username")
 	postId := context.Param("post_id")
 
-	httpcode, exists, err := database.QueryPostLike(username, postId)
+	httpcode, exists, err := s.Posts.QueryPostLike(username, postId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to query for post like: %v", err))
 		return
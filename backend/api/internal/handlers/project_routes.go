@@ -1,16 +1,97 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
-	"backend/api/internal/database"
+	"backend/api/internal/auth"
 	"backend/api/internal/types"
 
 	"github.com/gin-gonic/gin"
 )
 
+// GetProjects handles GET requests to discover projects. Every URL parameter
+// is optional: `tag` can be repeated and matches projects with every one of
+// the tags, or any of them with `match=any`. `status` is a status name or
+// number, `owner` a username and `sort` one of time (the default), likes or
+// trending. Pages are sized by `count` and continued with `cursor`.
+// Returns:
+// - 400 Bad Request if a parameter is invalid or count is over the feed limit.
+// - 404 Not Found if the owner does not exist.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the page of projects in JSON format.
+func (s *Server) GetProjects(context *gin.Context) {
+	filter := types.ProjectFilter{
+		Tags:         context.QueryArray("tag"),
+		MatchAllTags: true,
+		Sort:         context.Query("sort"),
+	}
+
+	switch match := context.Query("match"); match {
+	case "", "all":
+	case "any":
+		filter.MatchAllTags = false
+	default:
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid match '%v', expected all or any", match))
+		return
+	}
+
+	if strStatus := context.Query("status"); strStatus != "" {
+		status, ok := types.ProjectStatuses[strStatus]
+		if !ok {
+			parsed, err := strconv.ParseInt(strStatus, 10, 16)
+			if err != nil {
+				RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid status '%v', expected planned, active, archived or a number", strStatus))
+				return
+			}
+			status = int16(parsed)
+		}
+		filter.Status = &status
+	}
+
+	if owner := context.Query("owner"); owner != "" {
+		ownerId, err := s.Users.GetUserIdByUsername(owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			RespondWithError(context, http.StatusNotFound, fmt.Sprintf("User '%v' not found", owner))
+			return
+		}
+		if err != nil {
+			RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch owner '%v': %v", owner, err))
+			return
+		}
+		filter.Owner = int64(ownerId)
+	}
+
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+
+	projects, next, httpcode, err := s.Projects.QueryProjects(filter, page)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch projects: %v", err))
+		return
+	}
+	respondWithPage(context, projects, next)
+}
+
+// GetTags handles GET requests for every tag in use and how many projects
+// use it, most used first, such as for a tag cloud.
+// Returns:
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the tag counts in JSON format.
+func (s *Server) GetTags(context *gin.Context) {
+	counts, httpcode, err := s.Projects.QueryTagCounts()
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch tags: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, counts)
+}
+
 // GetProjectById handles GET requests to retrieve project information by its ID.
 // It expects the `project_id` parameter in the URL and does not require a request body.
 // Returns:
@@ -18,13 +99,13 @@ import (
 // - 404 Not Found if the project does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the project details in JSON format.
-func GetProjectById(context *gin.Context) {
+func (s *Server) GetProjectById(context *gin.Context) {
 	strId := context.Param("project_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		return
 	}
-	project, err := database.QueryProject(id)
+	project, err := s.Projects.QueryProject(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch project: %v", err))
 		return
@@ -45,13 +126,13 @@ func GetProjectById(context *gin.Context) {
 // - 404 Not Found if the user id does not exist.
 // - 500 Internal Server Error if the database query fails.
 // On success, responds with a 200 OK status and the projects' details in JSON format.
-func GetProjectsByUserId(context *gin.Context) {
+func (s *Server) GetProjectsByUserId(context *gin.Context) {
 	strId := context.Param("user_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
 		return
 	}
-	project, httpcode, err := database.QueryProjectsByUserId(id)
+	project, httpcode, err := s.Projects.QueryProjectsByUserId(id)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch projects: %v", err))
 		return
@@ -70,9 +151,11 @@ func GetProjectsByUserId(context *gin.Context) {
 // Validates the provided owner's ID and ensures the user exists.
 // Returns:
 // - 400 Bad Request if the JSON payload is invalid or the owner cannot be verified.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the owner is not the caller.
 // - 500 Internal Server Error if there is a database error.
 // On success, responds with a 201 Created status and the new project ID in JSON format.
-func CreateProject(context *gin.Context) {
+func (s *Server) CreateProject(context *gin.Context) {
 	var newProj types.Project
 	err := context.BindJSON(&newProj)
 
@@ -82,7 +165,7 @@ func CreateProject(context *gin.Context) {
 	}
 
 	// verify the owner
-	username, err := database.GetUsernameById(newProj.Owner)
+	username, err := s.Users.GetUsernameById(newProj.Owner)
 	if err != nil {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to verify project ownership: %v", err))
 		return
@@ -93,7 +176,11 @@ func CreateProject(context *gin.Context) {
 		return
 	}
 
-	id, err := database.QueryCreateProject(&newProj)
+	if !RequireCallerIsAuthor(context, newProj.Owner, "project") {
+		return
+	}
+
+	id, err := s.Projects.QueryCreateProject(&newProj)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create project: %v", err))
 		return
@@ -105,10 +192,12 @@ func CreateProject(context *gin.Context) {
 // It expects the `project_id` parameter in the URL.
 // Returns:
 // - 400 Bad Request if the project_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither owns the project nor is a moderator.
 // - 404 Not Found if no project is found with the given id.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the project deletion.
-func DeleteProject(context *gin.Context) {
+func (s *Server) DeleteProject(context *gin.Context) {
 	strId := context.Param("project_id")
 	id, err := strconv.Atoi(strId)
 	if err != nil {
@@ -116,7 +205,22 @@ func DeleteProject(context *gin.Context) {
 		return
 	}
 
-	httpCode, err := database.QueryDeleteProject(id)
+	existingProj, err := s.Projects.QueryProject(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve project: %v", err))
+		return
+	}
+	if existingProj == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Project with id '%v' not found", id))
+		return
+	}
+
+	if !CallerCan(context, auth.PermissionRemoveContent) && !RequireCallerIsOwner(context, existingProj.Owner, "project") {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	httpCode, err := s.Projects.QueryDeleteProject(id, callerId)
 	// delete projects can return different errors...
 	if err != nil {
 		RespondWithError(context, int(httpCode), fmt.Sprintf("Failed to delete project: %v", err))
@@ -131,11 +235,13 @@ func DeleteProject(context *gin.Context) {
 // It expects the `project_id` parameter in the URL and a JSON payload with update fields.
 // Validates the project ID, checks for the existence of the project, and ensures the fields being updated are allowed.
 // Returns:
-// - 400 Bad Request for invalid input or disallowed fields.
+// - 400 Bad Request for invalid input or disallowed fields, including `owner`.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
 // - 404 Not Found if the project does not exist.
 // - 500 Internal Server Error for database errors.
 // On success, responds with a 200 OK status and the updated project details in JSON format.
-func UpdateProjectInfo(context *gin.Context) {
+func (s *Server) UpdateProjectInfo(context *gin.Context) {
 	var updateData map[string]interface{}
 
 	// Parse project ID from the URL
@@ -152,8 +258,14 @@ func UpdateProjectInfo(context *gin.Context) {
 		return
 	}
 
+	// projects only change hands through the admin owner route
+	if _, ok := updateData["owner"]; ok {
+		RespondWithError(context, http.StatusBadRequest, "Field 'owner' is not allowed for updates, an admin has to reassign the project")
+		return
+	}
+
 	// Check if the project exists
-	existingProj, err := database.QueryProject(id)
+	existingProj, err := s.Projects.QueryProject(id)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve project: %v", err))
 		return
@@ -163,18 +275,8 @@ func UpdateProjectInfo(context *gin.Context) {
 		return
 	}
 
-	// Validate new owner if provided in update data
-	if newOwner, ok := updateData["owner"]; ok {
-		ownerID, ok := newOwner.(float64) // Assuming JSON numbers are decoded as float64
-		if !ok {
-			RespondWithError(context, http.StatusBadRequest, "Invalid owner id format")
-			return
-		}
-		username, err := database.GetUsernameById(int64(ownerID))
-		if err != nil || username == "" {
-			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid owner id: %v", ownerID))
-			return
-		}
+	if !RequireCallerIsOwner(context, existingProj.Owner, "project") {
+		return
 	}
 
 	// Filter and validate update fields
@@ -189,13 +291,13 @@ func UpdateProjectInfo(context *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.QueryUpdateProject(id, updatedData)
+	err = s.Projects.QueryUpdateProject(id, updatedData)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error updating project: %v", err))
 		return
 	}
 
-	updatedProj, err := database.QueryProject(id)
+	updatedProj, err := s.Projects.QueryProject(id)
 
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error validating updated project: %v", err))
@@ -210,11 +312,13 @@ func UpdateProjectInfo(context *gin.Context) {
 
 // GetProjectFollowers handles GET requests to fetch a list of users following a project.
 // It expects the `project_id` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
 // - 400 Bad Request if the project ID is invalid.
 // - Appropriate error code (404 if missing data, 500 if error) for database query failures.
 // On success, responds with a 200 OK status and a list of followers in JSON format.
-func GetProjectFollowers(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetProjectFollowers(context *gin.Context) {
 	projectId := context.Param("project_id")
 	intProjectId, err := strconv.Atoi(projectId)
 
@@ -222,12 +326,21 @@ func GetProjectFollowers(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project id: %V", err))
 	}
 
-	followers, httpcode, err := database.QueryGetProjectFollowers(intProjectId)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	followers, next, httpcode, err := s.Projects.QueryGetProjectFollowers(intProjectId, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch followers: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, followers, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, followers)
 }
 
@@ -236,10 +349,10 @@ func GetProjectFollowers(context *gin.Context) {
 // Returns:
 // - Appropriate error code (404 if missing data, 500 if error) for database query failures.
 // On success, responds with a 200 OK status and a list of followed projects in JSON format.
-func GetProjectFollowing(context *gin.Context) {
+func (s *Server) GetProjectFollowing(context *gin.Context) {
 	username := context.Param("username")
 
-	following, httpcode, err := database.QueryGetProjectFollowing(username)
+	following, httpcode, err := s.Projects.QueryGetProjectFollowing(username)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch following: %v", err))
 		return
@@ -250,11 +363,13 @@ func GetProjectFollowing(context *gin.Context) {
 
 // GetProjectFollowersUsernames handles GET requests to fetch the usernames of users following a project.
 // It expects the `project_id` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
 // - 400 Bad Request if the project ID is invalid.
 // - Appropriate error code (404 if missing data, 500 if error) for database query failures.
 // On success, responds with a 200 OK status and a list of usernames in JSON format.
-func GetProjectFollowersUsernames(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetProjectFollowersUsernames(context *gin.Context) {
 	projectId := context.Param("project_id")
 	intProjectId, err := strconv.Atoi(projectId)
 
@@ -262,12 +377,21 @@ func GetProjectFollowersUsernames(context *gin.Context) {
 		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project id: %V", err))
 	}
 
-	followers, httpcode, err := database.QueryGetProjectFollowersUsernames(intProjectId)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	followers, next, httpcode, err := s.Projects.QueryGetProjectFollowersUsernames(intProjectId, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch followers: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, followers, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, followers)
 }
 
@@ -276,10 +400,10 @@ func GetProjectFollowersUsernames(context *gin.Context) {
 // Returns:
 // - Appropriate error code (404 if missing data, 500 if error) for database query failures.
 // On success, responds with a 200 OK status and a list of project names in JSON format.
-func GetProjectFollowingNames(context *gin.Context) {
+func (s *Server) GetProjectFollowingNames(context *gin.Context) {
 	username := context.Param("username")
 
-	following, httpcode, err := database.QueryGetProjectFollowingNames(username)
+	following, httpcode, err := s.Projects.QueryGetProjectFollowingNames(username)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch following: %v", err))
 		return
@@ -291,13 +415,18 @@ func GetProjectFollowingNames(context *gin.Context) {
 // FollowProject handles POST requests to follow a project.
 // It expects the `username` and `project_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func FollowProject(context *gin.Context) {
+func (s *Server) FollowProject(context *gin.Context) {
 	username := context.Param("username")
 	projectId := context.Param("project_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.CreateNewProjectFollow(username, projectId)
+	httpcode, err := s.Projects.CreateNewProjectFollow(username, projectId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to add follower: %v", err))
 		return
@@ -308,13 +437,18 @@ func FollowProject(context *gin.Context) {
 // UnfollowProject handles DELETE requests to unfollow a project.
 // It expects the `username` and `project_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func UnfollowProject(context *gin.Context) {
+func (s *Server) UnfollowProject(context *gin.Context) {
 	username := context.Param("username")
 	projectId := context.Param("project_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.RemoveProjectFollow(username, projectId)
+	httpcode, err := s.Projects.RemoveProjectFollow(username, projectId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to remove follower: %v", err))
 		return
@@ -325,13 +459,18 @@ func UnfollowProject(context *gin.Context) {
 // LikeProject handles POST requests to like a project.
 // It expects the `username` and `project_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func LikeProject(context *gin.Context) {
+func (s *Server) LikeProject(context *gin.Context) {
 	username := context.Param("username")
 	projectId := context.Param("project_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.CreateProjectLike(username, projectId)
+	httpcode, err := s.Projects.CreateProjectLike(username, projectId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to like project: %v", err))
 		return
@@ -342,13 +481,18 @@ func LikeProject(context *gin.Context) {
 // UnlikeProject handles POST requests to unlike a project.
 // It expects the `username` and `project_id` parameters in the URL.
 // Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - Appropriate error code (404 if missing data, 500 if error) for database failures or invalid input.
 // On success, responds with a 200 OK status and a confirmation message.
-func UnlikeProject(context *gin.Context) {
+func (s *Server) UnlikeProject(context *gin.Context) {
 	username := context.Param("username")
 	projectId := context.Param("project_id")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.RemoveProjectLike(username, projectId)
+	httpcode, err := s.Projects.RemoveProjectLike(username, projectId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to unlike project: %v", err))
 		return
@@ -361,11 +505,11 @@ func UnlikeProject(context *gin.Context) {
 // Returns:
 // - Appropriate error code for database failures or invalid input.
 // On success, responds with a 200 OK status and a status message.
-func IsProjectLiked(context *gin.Context) {
+func (s *Server) IsProjectLiked(context *gin.Context) {
 	username := context.Param("username")
 	projectId := context.Param("project_id")
 
-	httpcode, exists, err := database.QueryProjectLike(username, projectId)
+	httpcode, exists, err := s.Projects.QueryProjectLike(username, projectId)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to query for project like: %v", err))
 		return
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/blobs"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck lets load balancers and scripts check that the api is up.
+func HealthCheck(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"message": "API is running!"})
+}
+
+// NewRouter registers every route of the api on a new gin engine, with
+// requests served by `s` and CORS allowed from `allowedOrigins`. main
+// serves it on the configured address, and the tests serve it with
+// httptest so they do not need a running api.
+func NewRouter(s *Server, allowedOrigins []string) *gin.Engine {
+	router := gin.Default()
+	router.HandleMethodNotAllowed = true
+
+	// Apply CORS middleware to the router
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     allowedOrigins, // the frontend URLs (React Native or Web app)
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowCredentials: true, // Allow cookies or authentication headers
+	}))
+
+	router.GET("/health", HealthCheck)
+
+	router.POST("/auth/register", s.Register)
+	router.POST("/auth/login", s.Login)
+	router.POST("/auth/refresh", s.RefreshTokens)
+	router.POST("/auth/logout", s.RequireAuth(), s.Logout)
+	router.POST("/auth/logout-all", s.RequireAuth(), s.LogoutEverywhere)
+	router.PUT("/auth/password", s.RequireAuth(), s.ChangePassword)
+
+	// every route that writes data, other than registering and logging in
+	// above and the links emailed to users, runs through RequireAuth, which resolves the caller so the
+	// handlers can check what they are allowed to touch. Users are only
+	// created through /auth/register, which gives them credentials.
+	// routes that list scopes also accept personal access tokens granted those scopes
+	router.GET("/users/:username", s.GetUserByUsername)
+	router.PUT("/users/:username", s.RequireAuth(), s.UpdateUserInfo)
+	router.DELETE("/users/:username", s.RequireAuth(), s.DeleteUser)
+
+	router.POST("/users/:username/tokens", s.RequireAuth(), s.CreatePersonalToken)
+	router.GET("/users/:username/tokens", s.RequireAuth(auth.ScopeRead), s.GetPersonalTokens)
+	router.DELETE("/users/:username/tokens/:token_id", s.RequireAuth(), s.DeletePersonalToken)
+
+	router.GET("/users/:username/email-preferences", s.RequireAuth(auth.ScopeRead), s.GetEmailPreferences)
+	router.PUT("/users/:username/email-preferences", s.RequireAuth(), s.UpdateEmailPreferences)
+	// followed from emails, so the token in the link is all they need
+	router.GET("/email/confirm", s.ConfirmEmail)
+	router.GET("/email/unsubscribe", s.Unsubscribe)
+
+	router.PUT("/users/:username/picture", s.RequireAuth(), s.UpdateUserPicture)
+
+	router.GET("/users/:username/followers", s.GetUsersFollowers)
+	router.GET("/users/:username/follows", s.GetUsersFollowing)
+	router.GET("/users/:username/followers/usernames", s.GetUsersFollowersUsernames)
+	router.GET("/users/:username/follows/usernames", s.GetUsersFollowingUsernames)
+
+	router.POST("/users/:username/follow/:new_follow", s.RequireAuth(), s.FollowUser)
+	router.POST("/users/:username/unfollow/:unfollow", s.RequireAuth(), s.UnfollowUser)
+
+	router.GET("/projects", s.GetProjects)
+	router.GET("/projects/:project_id", s.GetProjectById)
+	router.POST("/projects", s.RequireAuth(auth.ScopeProjectsWrite), s.CreateProject)
+	router.PUT("/projects/:project_id", s.RequireAuth(auth.ScopeProjectsWrite), s.UpdateProjectInfo)
+	router.DELETE("/projects/:project_id", s.RequireAuth(auth.ScopeProjectsWrite), s.DeleteProject)
+	router.POST("/projects/restore/:project_id", s.RequireAuth(auth.ScopeProjectsWrite), s.RestoreProject)
+	router.GET("/projects/by-user/:user_id", s.GetProjectsByUserId)
+	router.GET("/tags", s.GetTags)
+
+	router.GET("/projects/:project_id/followers", s.GetProjectFollowers)
+	router.GET("/projects/follows/:username", s.GetProjectFollowing)
+	router.GET("/projects/:project_id/followers/usernames", s.GetProjectFollowersUsernames)
+	router.GET("/projects/follows/:username/names", s.GetProjectFollowingNames)
+
+	router.POST("/projects/:username/follow/:project_id", s.RequireAuth(), s.FollowProject)
+	router.POST("/projects/:username/unfollow/:project_id", s.RequireAuth(), s.UnfollowProject)
+
+	router.POST("/projects/:username/likes/:project_id", s.RequireAuth(), s.LikeProject)
+	router.POST("/projects/:username/unlikes/:project_id", s.RequireAuth(), s.UnlikeProject)
+	router.GET("/projects/does-like/:username/:project_id", s.IsProjectLiked)
+
+	// the POST routes under /projects/ name their first wildcard :username
+	router.POST("/projects/:username/webhooks", AliasParam("username", "project_id"), s.RequireAuth(auth.ScopeProjectsWrite), s.CreateWebhook)
+	router.GET("/projects/:project_id/webhooks", s.RequireAuth(auth.ScopeProjectsWrite), s.GetWebhooks)
+	router.DELETE("/projects/:project_id/webhooks/:webhook_id", s.RequireAuth(auth.ScopeProjectsWrite), s.DeleteWebhook)
+	router.GET("/projects/:project_id/webhooks/:webhook_id/deliveries", s.RequireAuth(auth.ScopeProjectsWrite), s.GetWebhookDeliveries)
+	router.POST("/projects/:username/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", AliasParam("username", "project_id"), s.RequireAuth(auth.ScopeProjectsWrite), s.RedeliverWebhook)
+
+	router.GET("/projects/:project_id/images", s.GetProjectImages)
+	router.POST("/projects/:username/images", AliasParam("username", "project_id"), s.RequireAuth(auth.ScopeProjectsWrite), s.UploadProjectImage)
+	router.DELETE("/projects/:project_id/images/:image_id", s.RequireAuth(auth.ScopeProjectsWrite), s.DeleteProjectImage)
+
+	router.GET("/posts/:post_id", s.GetPostById)
+	router.GET("/posts/:post_id/history", s.GetPostHistory)
+	router.POST("/posts", s.RequireAuth(auth.ScopePostsWrite), s.CreatePost)
+	router.PUT("/posts/:post_id", s.RequireAuth(auth.ScopePostsWrite), s.UpdatePostInfo)
+	router.DELETE("/posts/:post_id", s.RequireAuth(auth.ScopePostsWrite), s.DeletePost)
+	router.POST("/posts/restore/:post_id", s.RequireAuth(auth.ScopePostsWrite), s.RestorePost)
+
+	router.GET("/posts/by-user/:user_id", s.GetPostsByUserId)
+	router.GET("/posts/by-project/:project_id", s.GetPostsByProjectId)
+
+	router.POST("/posts/:username/likes/:post_id", s.RequireAuth(), s.LikePost)
+	router.POST("/posts/:username/unlikes/:post_id", s.RequireAuth(), s.UnlikePost)
+	router.GET("/posts/does-like/:username/:post_id", s.IsPostLiked)
+
+	router.POST("/comments/for-post/:post_id", s.RequireAuth(), s.CreateCommentOnPost)
+	router.POST("/comments/for-project/:project_id", s.RequireAuth(), s.CreateCommentOnProject)
+	router.POST("/comments/for-comment/:comment_id", s.RequireAuth(), s.CreateCommentOnComment)
+	router.GET("/comments/:comment_id", s.GetCommentById)
+	router.GET("/comments/:comment_id/history", s.GetCommentHistory)
+	router.PUT("/comments/:comment_id", s.RequireAuth(), s.UpdateCommentContent)
+	router.DELETE("/comments/:comment_id", s.RequireAuth(), s.DeleteComment)
+	router.POST("/comments/restore/:comment_id", s.RequireAuth(), s.RestoreComment)
+
+	router.GET("/comments/by-user/:user_id", s.GetCommentsByUserId)
+	router.GET("/comments/by-post/:post_id", s.GetCommentsByPostId)
+	router.GET("/comments/by-post/:post_id/tree", s.GetCommentTreeByPostId)
+	router.GET("/comments/by-project/:project_id", s.GetCommentsByProjectId)
+	router.GET("/comments/by-project/:project_id/tree", s.GetCommentTreeByProjectId)
+	router.GET("/comments/by-comment/:comment_id", s.GetCommentsByCommentId)
+
+	router.POST("/comments/:username/likes/:comment_id", s.RequireAuth(), s.LikeComment)
+	router.POST("/comments/:username/unlikes/:comment_id", s.RequireAuth(), s.UnlikeComment)
+	router.GET("/comments/does-like/:username/:comment_id", s.IsCommentLiked)
+	router.GET("/comments/can-edit/:comment_id", s.OptionalAuth(auth.ScopeRead), s.IsCommentEditable)
+
+	router.GET("/feed/posts", s.OptionalAuth(auth.ScopeRead), s.GetPostsFeed)
+	router.GET("/feed/home", s.RequireAuth(auth.ScopeRead), s.GetHomeFeed)
+	router.GET("/feed/projects", s.GetProjectsFeed)
+
+	router.GET("/notifications", s.RequireAuth(auth.ScopeRead), s.GetNotifications)
+	router.POST("/notifications/read", s.RequireAuth(), s.MarkAllNotificationsRead)
+	router.POST("/notifications/:notification_id/read", s.RequireAuth(), s.MarkNotificationRead)
+
+	router.GET("/events", s.RequireAuth(auth.ScopeRead), s.GetEvents)
+	router.GET("/ws/threads/:kind/:id", s.OptionalAuth(auth.ScopeRead), s.GetThreadSocket)
+
+	router.GET("/search", s.GetSearch)
+	router.GET("/autocomplete/users", s.OptionalAuth(auth.ScopeRead), s.GetUserSuggestions)
+	router.GET("/autocomplete/projects", s.OptionalAuth(auth.ScopeRead), s.GetProjectSuggestions)
+
+	// staff routes, each one checks the permission its role has to grant
+	admin := router.Group("/admin", s.RequireAuth())
+	admin.DELETE("/posts/:post_id", RequirePermission(auth.PermissionRemoveContent), s.DeletePost)
+	admin.DELETE("/projects/:project_id", RequirePermission(auth.PermissionRemoveContent), s.DeleteProject)
+	admin.DELETE("/comments/:comment_id", RequirePermission(auth.PermissionRemoveContent), s.DeleteComment)
+	admin.POST("/posts/:post_id/restore", RequirePermission(auth.PermissionRemoveContent), s.RestorePost)
+	admin.POST("/projects/:project_id/restore", RequirePermission(auth.PermissionRemoveContent), s.RestoreProject)
+	admin.POST("/comments/:comment_id/restore", RequirePermission(auth.PermissionRemoveContent), s.RestoreComment)
+
+	admin.GET("/users/:username", RequirePermission(auth.PermissionManageUsers), s.GetUserStatus)
+	admin.DELETE("/users/:username", RequirePermission(auth.PermissionManageUsers), s.DeleteUser)
+	admin.POST("/users/:username/restore", RequirePermission(auth.PermissionManageUsers), s.RestoreUser)
+	admin.PUT("/users/:username/role", RequirePermission(auth.PermissionManageUsers), s.SetUserRole)
+	admin.POST("/users/:username/suspend", RequirePermission(auth.PermissionManageUsers), s.SuspendUser)
+	admin.POST("/users/:username/unsuspend", RequirePermission(auth.PermissionManageUsers), s.UnsuspendUser)
+
+	admin.PUT("/projects/:project_id/owner", RequirePermission(auth.PermissionReassignProjects), s.ReassignProjectOwner)
+
+	// uploads kept on the api's own disk are served by it, unless they
+	// are served from an absolute URL by a proxy or CDN in front of it.
+	// Browsers must not guess another type than the one sniffed on upload
+	if local, ok := s.Blobs.(*blobs.Local); ok && local.ServePath() != "" {
+		media := router.Group(local.ServePath(), func(context *gin.Context) {
+			context.Header("X-Content-Type-Options", "nosniff")
+		})
+		media.Static("/", local.Directory())
+	}
+
+	return router
+}
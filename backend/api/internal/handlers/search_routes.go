@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchLimit is how many results a search returns when no count is given.
+const searchLimit = 20
+
+// GetSearch handles GET requests to find users, projects, posts and comments
+// by the words in them. It expects the `q` URL parameter, whose words all have
+// to match, each one as the start of a word, so `scal` finds ScaleDB.
+// The optional `kind` parameter limits the results to one kind of item and
+// `count` caps how many come back, best matches first.
+// On SQLite, search uses FTS4 rather than FTS5, since FTS5 is only built
+// into the driver with the sqlite_fts5 build tag. FTS4 cannot rank matches
+// itself, so only the 1000 newest matches of each kind are ranked, and an
+// older match that would rank higher can be left out. Postgres ranks
+// every match.
+// Returns:
+// - 400 Bad Request if q is missing or has no words, or the kind or count is invalid.
+// - 500 Internal Server Error if the database query fails.
+// On success, responds with a 200 OK status and the results in JSON format,
+// each with a snippet marking the matched words with <mark>.
+func (s *Server) GetSearch(context *gin.Context) {
+	text := context.Query("q")
+	if text == "" {
+		RespondWithError(context, http.StatusBadRequest, "Missing required url query parameter: q")
+		return
+	}
+	if context.Query("cursor") != "" {
+		RespondWithError(context, http.StatusBadRequest, "Search results are not paged, use count to get more of them")
+		return
+	}
+
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	limit := page.Limit
+	if limit == 0 {
+		limit = searchLimit
+	}
+
+	results, httpcode, err := s.Search.QuerySearch(text, context.Query("kind"), limit)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to search: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"backend/api/internal/blobs"
+	"backend/api/internal/config"
+	"backend/api/internal/mail"
+	"backend/api/internal/store"
+)
+
+// Server holds everything the handlers depend on. Routes are registered
+// with its methods, so tests can build a server on top of any store
+// implementation instead of a live database.
+type Server struct {
+	store.Stores
+	// the limits every handler checks requests against
+	Limits config.Limits
+	// where uploaded pictures and images are kept
+	Blobs blobs.Storage
+	// sends the emails confirming addresses, with links to the api at MailLinkURL
+	Mailer      mail.Mailer
+	MailLinkURL string
+}
+
+// NewServer returns a server that reads and writes through `stores`,
+// keeps uploads in `storage`, emails through `mailer` with links to the
+// api at `linkURL` and enforces `limits`.
+func NewServer(stores store.Stores, storage blobs.Storage, mailer mail.Mailer, linkURL string, limits config.Limits) *Server {
+	return &Server{Stores: stores, Blobs: storage, Mailer: mailer, MailLinkURL: linkURL, Limits: limits}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// GetThreadSocket handles GET requests to follow the comment thread of a
+// post or project live over a WebSocket.
+// It expects the `kind` (post or project) and `id` parameters in the URL.
+// Once connected, every message is a JSON types.ThreadEvent whose `type` is
+// one of:
+// - comment: a new comment on the thread, `data` is the comment.
+// - comment_edited: a comment's new content, `data` is the comment.
+// - comment_deleted: `data` is the comment with its author and content hidden.
+// - likes: the new like count of a comment, `data` is the count.
+// - typing: `data` names the user writing a comment, including the client's own user.
+// - keepalive: sent when nothing else happened for a while, without `data`.
+// Logged in clients send {"type":"typing"}, with the optional `parent` comment
+// they are replying to, while their user writes a comment. It is passed on
+// at most once every few seconds per socket. Anonymous clients can follow a
+// thread but their typing is ignored.
+// Returns:
+// - 400 Bad Request if the kind or ID is invalid, or the request is not a WebSocket handshake.
+// - 401 Unauthorized if the Authorization header is invalid.
+// - 404 Not Found if the post or project does not exist.
+// - 500 Internal Server Error if the database query fails.
+// - 503 Service Unavailable if the server does not publish events.
+// On success, switches protocols and keeps the socket open until the client
+// goes away or falls too far behind.
+func (s *Server) GetThreadSocket(context *gin.Context) {
+	if s.Events == nil {
+		RespondWithError(context, http.StatusServiceUnavailable, "Live threads are not available on this server")
+		return
+	}
+
+	kind := context.Param("kind")
+	strId := context.Param("id")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse id: %v", err))
+		return
+	}
+
+	switch kind {
+	case "post":
+		post, err := s.Posts.QueryPost(id)
+		if err != nil {
+			RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch post: %v", err))
+			return
+		}
+		if post == nil {
+			RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Post with id '%v' not found", strId))
+			return
+		}
+	case "project":
+		project, err := s.Projects.QueryProject(id)
+		if err != nil {
+			RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch project: %v", err))
+			return
+		}
+		if project == nil {
+			RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Project with id '%v' not found", strId))
+			return
+		}
+	default:
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Invalid thread kind '%v', expected post or project", kind))
+		return
+	}
+
+	_, username := GetCaller(context)
+	topic := events.ThreadTopic(kind, int64(id))
+	subscription, _ := s.Events.Subscribe([]string{topic}, "")
+	defer subscription.Close()
+
+	socket := websocket.Server{
+		// callers authenticate with the Authorization header rather than
+		// cookies, so other sites cannot act as them and any origin is let in
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			s.serveThread(conn, subscription, topic, username)
+		},
+	}
+	socket.ServeHTTP(context.Writer, context.Request)
+}
+
+// how often a socket passes on that its user is typing, any typing in
+// between is dropped so one client cannot flood everyone in the thread
+const typingInterval = 3 * time.Second
+
+// how long sending a message down a socket may take before the client is
+// given up on, so a stalled client does not hold its handler forever
+const threadWriteTimeout = 10 * time.Second
+
+// serveThread sends the events of a thread down a socket while reading
+// what its client is typing, until either side gives up.
+func (s *Server) serveThread(conn *websocket.Conn, subscription *events.Subscription, topic string, username string) {
+	defer conn.Close()
+
+	// reading also notices the client closing the socket
+	left := make(chan struct{})
+	go func() {
+		defer close(left)
+		var lastTyping time.Time
+		for {
+			var message types.ThreadMessage
+			if err := websocket.JSON.Receive(conn, &message); err != nil {
+				return
+			}
+			if message.Type != events.TypeTyping || username == "" || time.Since(lastTyping) < typingInterval {
+				continue
+			}
+			lastTyping = time.Now()
+			s.Events.Broadcast(events.TypeTyping, types.Typing{User: username, Parent: message.Parent}, topic)
+		}
+	}()
+
+	send := func(event types.ThreadEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(threadWriteTimeout))
+		return websocket.JSON.Send(conn, event)
+	}
+
+	keepAlive := time.NewTicker(s.Events.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-left:
+			return
+		case <-keepAlive.C:
+			err = send(types.ThreadEvent{Type: "keepalive"})
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// dropped for falling behind, the client reconnects and reloads the thread
+				return
+			}
+			err = send(types.ThreadEvent{Type: event.Type, Data: event.Data})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePersonalToken handles POST requests to create a personal access token.
+// It expects the `username` parameter in the URL and a JSON body with a `name`
+// and the `scopes` the token is allowed to use.
+// Returns:
+// - 400 Bad Request if the JSON is invalid or a scope is unknown.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 500 Internal Server Error if the token could not be created.
+// On success, responds with a 201 Created status and the raw token, which is not shown again.
+func (s *Server) CreatePersonalToken(context *gin.Context) {
+	username := context.Param("username")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
+
+	var newToken types.NewPersonalToken
+	err := context.BindJSON(&newToken)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+
+	err = auth.ValidateScopes(newToken.Scopes)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to create token: %v", err))
+		return
+	}
+
+	rawToken, err := auth.NewPersonalToken()
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create token: %v", err))
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	token, err := s.Auth.QueryCreatePersonalToken(callerId, &newToken, auth.HashToken(rawToken))
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create token: %v", err))
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":    fmt.Sprintf("Created token '%s'", token.Name),
+		"token":      rawToken,
+		"token_info": token,
+	})
+}
+
+// GetPersonalTokens handles GET requests to list a user's personal access tokens.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 500 Internal Server Error if the tokens could not be fetched.
+// On success, responds with a 200 OK status and the token metadata in JSON format.
+func (s *Server) GetPersonalTokens(context *gin.Context) {
+	username := context.Param("username")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	tokens, httpcode, err := s.Auth.QueryPersonalTokens(callerId)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch tokens: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, tokens)
+}
+
+// DeletePersonalToken handles DELETE requests to revoke a personal access token.
+// It expects the `username` and `token_id` parameters in the URL.
+// Returns:
+// - 400 Bad Request if the token id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 404 Not Found if the user has no token with the given id.
+// - 500 Internal Server Error if the token could not be deleted.
+// On success, responds with a 200 OK status and a message confirming the revocation.
+func (s *Server) DeletePersonalToken(context *gin.Context) {
+	username := context.Param("username")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
+
+	strId := context.Param("token_id")
+	tokenId, err := strconv.ParseInt(strId, 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse token_id: %v", err))
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	httpcode, err := s.Auth.QueryDeletePersonalToken(callerId, tokenId)
+	if err != nil {
+		RespondWithError(context, httpcode, fmt.Sprintf("Failed to revoke token: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Token %v revoked.", tokenId)})
+}
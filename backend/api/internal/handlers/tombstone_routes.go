@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestorePost handles POST requests to bring back a deleted post along with
+// the comments deleted with it.
+// It expects the `post_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the post_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither owns the post nor is a moderator, or a moderator deleted it.
+// - 404 Not Found if no deleted post is found with the given id.
+// - 410 Gone if the post was deleted too long ago to be restored by its owner.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the restore.
+func (s *Server) RestorePost(context *gin.Context) {
+	s.restore(context, "post", context.Param("post_id"))
+}
+
+// RestoreProject handles POST requests to bring back a deleted project along
+// with the posts and comments deleted with it.
+// It expects the `project_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the project_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither owns the project nor is a moderator, or a moderator deleted it.
+// - 404 Not Found if no deleted project is found with the given id.
+// - 410 Gone if the project was deleted too long ago to be restored by its owner.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the restore.
+func (s *Server) RestoreProject(context *gin.Context) {
+	s.restore(context, "project", context.Param("project_id"))
+}
+
+// RestoreComment handles POST requests to bring back a deleted comment.
+// It expects the `comment_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the comment_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller neither wrote the comment nor is a moderator, or a moderator deleted it.
+// - 404 Not Found if no deleted comment is found with the given id.
+// - 410 Gone if the comment was deleted too long ago to be restored by its author.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the restore.
+func (s *Server) RestoreComment(context *gin.Context) {
+	s.restore(context, "comment", context.Param("comment_id"))
+}
+
+// RestoreUser handles POST requests to bring back a deleted user along with
+// everything deleted with them. Deleted users cannot log in, so only
+// admins can restore them.
+// It expects the `username` parameter in the URL.
+// Returns:
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not allowed to manage users.
+// - 404 Not Found if no deleted user is found with the given username.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the restore.
+func (s *Server) RestoreUser(context *gin.Context) {
+	username := context.Param("username")
+
+	tombstone, err := s.Tombstones.QueryUserTombstone(username)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve user: %v", err))
+		return
+	}
+	if tombstone == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Deleted user '%v' not found", username))
+		return
+	}
+
+	httpCode, err := s.Tombstones.QueryRestore("user", tombstone.ID)
+	if err != nil {
+		RespondWithError(context, httpCode, fmt.Sprintf("Failed to restore user: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("User '%v' restored.", username)})
+}
+
+// restore brings back a deleted item of `kind` for its owner, as long as
+// they deleted it themselves within the restore window. What anyone else
+// took down, like a moderator, stays down. Moderators can restore
+// anything that has not been purged yet.
+func (s *Server) restore(context *gin.Context, kind string, strId string) {
+	id, err := strconv.ParseInt(strId, 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse %v id: %v", kind, err))
+		return
+	}
+
+	tombstone, err := s.Tombstones.QueryTombstone(kind, id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve %v: %v", kind, err))
+		return
+	}
+	if tombstone == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Deleted %v with id %v not found", kind, id))
+		return
+	}
+
+	if !CallerCan(context, auth.PermissionRemoveContent) {
+		if !RequireCallerIsOwner(context, tombstone.Owner, kind) {
+			return
+		}
+		if callerId, _ := GetCaller(context); tombstone.DeletedBy != callerId {
+			RespondWithError(context, http.StatusForbidden, fmt.Sprintf("The %v with id %v was not deleted by its owner and can only be restored by a moderator", kind, id))
+			return
+		}
+		if !s.restorable(tombstone) {
+			RespondWithError(context, http.StatusGone, fmt.Sprintf("The %v with id %v was deleted too long ago to be restored", kind, id))
+			return
+		}
+	}
+
+	httpCode, err := s.Tombstones.QueryRestore(kind, id)
+	if err != nil {
+		RespondWithError(context, httpCode, fmt.Sprintf("Failed to restore %v: %v", kind, err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Restored %v %v.", kind, id)})
+}
+
+// restorable reports whether a deleted item is still within the restore window.
+func (s *Server) restorable(tombstone *types.Tombstone) bool {
+	return time.Since(tombstone.DeletedAt) <= s.Limits.RestoreWindow.Duration
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/blobs"
+	"backend/api/internal/logger"
+	"backend/api/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the multipart form field uploads are sent in
+const uploadField = "image"
+
+// how much of an upload can be taken up by the form around the image
+const uploadFormOverhead = 64 << 10
+
+// an image read from an upload, with the type sniffed from its content
+type upload struct {
+	data        []byte
+	contentType string
+	extension   string
+}
+
+// readUpload reads the image in the `image` field of a multipart upload,
+// checking its size and sniffing its type from its content.
+// If it is not a valid upload, it responds with an error and returns false.
+func (s *Server) readUpload(context *gin.Context) (*upload, bool) {
+	limit := int64(s.Limits.MaxUploadSize)
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, limit+uploadFormOverhead)
+	tooLarge := func(err error) bool {
+		var maxBytesError *http.MaxBytesError
+		return errors.As(err, &maxBytesError)
+	}
+
+	reader, err := context.Request.MultipartReader()
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Expected a multipart/form-data upload: %v", err))
+		return nil, false
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Missing the '%v' field with the file to upload", uploadField))
+			return nil, false
+		}
+		if tooLarge(err) {
+			RespondWithError(context, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads can be at most %v bytes", limit))
+			return nil, false
+		}
+		if err != nil {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+			return nil, false
+		}
+		if part.FormName() != uploadField {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		if tooLarge(err) || int64(len(data)) > limit {
+			RespondWithError(context, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads can be at most %v bytes", limit))
+			return nil, false
+		}
+		if err != nil {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+			return nil, false
+		}
+		if len(data) == 0 {
+			RespondWithError(context, http.StatusBadRequest, "The uploaded file is empty")
+			return nil, false
+		}
+		// whatever type the client claimed, the content decides
+		contentType, extension, ok := blobs.SniffImage(data)
+		if !ok {
+			RespondWithError(context, http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported file type '%v', must be a PNG, JPEG, GIF or WebP image", contentType))
+			return nil, false
+		}
+		return &upload{data: data, contentType: contentType, extension: extension}, true
+	}
+}
+
+// storeUpload puts an upload into blob storage under `prefix`, with a
+// random name so a replaced image is never served from a stale cache.
+func (s *Server) storeUpload(prefix string, image *upload) (string, string, error) {
+	name, err := auth.NewRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	key := prefix + name[:16] + image.extension
+	url, err := s.Blobs.Put(key, image.contentType, image.data)
+	if err != nil {
+		return "", "", err
+	}
+	return key, url, nil
+}
+
+// deleteBlob removes what is served at `url`, if it was uploaded to the
+// blob storage under `prefix`. Anything else, like another user's upload
+// an old picture was pointed at, is left alone. Failing to delete only
+// leaves a file behind, so it is logged instead of failing the request.
+func (s *Server) deleteBlob(prefix string, url string) {
+	key, ok := s.Blobs.Key(url)
+	if !ok || !strings.HasPrefix(key, prefix) {
+		return
+	}
+	if err := s.Blobs.Delete(key); err != nil {
+		logger.Log.Errorf("Failed to delete blob '%v': %v", key, err)
+	}
+}
+
+// UpdateUserPicture handles PUT requests to upload a new profile picture.
+// It expects the `username` parameter in the URL and a multipart form
+// with the picture in the `image` field. The picture it replaces is
+// deleted if it was uploaded too.
+// Returns:
+// - 400 Bad Request if the upload is malformed or has no image.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
+// - 404 Not Found if no user is found with the given username.
+// - 413 Request Entity Too Large if the picture is over the upload limit.
+// - 415 Unsupported Media Type if the picture is not a PNG, JPEG, GIF or WebP image.
+// - 500 Internal Server Error if the picture cannot be stored.
+// On success, responds with a 200 OK status and the updated user in JSON format.
+func (s *Server) UpdateUserPicture(context *gin.Context) {
+	username := context.Param("username")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
+	image, ok := s.readUpload(context)
+	if !ok {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	prefix := fmt.Sprintf("users/%v/", callerId)
+	key, url, err := s.storeUpload(prefix+"picture-", image)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to store picture: %v", err))
+		return
+	}
+	previous, code, err := s.Images.QuerySetUserPicture(username, url)
+	if err != nil {
+		s.Blobs.Delete(key)
+		RespondWithError(context, code, fmt.Sprintf("Failed to update picture: %v", err))
+		return
+	}
+	s.deleteBlob(prefix, previous)
+
+	user, err := s.Users.QueryUsername(username)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Picture updated successfully.", "user": user})
+}
+
+// GetProjectImages handles GET requests to list the images of a project.
+// It expects the `project_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the project id is invalid.
+// - 404 Not Found if the project does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the images, oldest first, in JSON format.
+func (s *Server) GetProjectImages(context *gin.Context) {
+	id, err := strconv.Atoi(context.Param("project_id"))
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project id: %v", err))
+		return
+	}
+	project, err := s.Projects.QueryProject(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve project: %v", err))
+		return
+	}
+	if project == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Project with id '%v' not found", id))
+		return
+	}
+
+	images, code, err := s.Images.QueryProjectImages(project.ID)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch project images: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, images)
+}
+
+// UploadProjectImage handles POST requests to add an image to a project.
+// It expects the `project_id` parameter in the URL and a multipart form
+// with the image in the `image` field.
+// Returns:
+// - 400 Bad Request if the upload is malformed or the project has too many images.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project does not exist.
+// - 413 Request Entity Too Large if the image is over the upload limit.
+// - 415 Unsupported Media Type if the image is not a PNG, JPEG, GIF or WebP image.
+// - 500 Internal Server Error if the image cannot be stored.
+// On success, responds with a 201 Created status and the image in JSON format.
+func (s *Server) UploadProjectImage(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+
+	// the limit is checked again when the image is recorded, this only
+	// saves reading and storing an upload that could not be kept
+	existing, code, err := s.Images.QueryProjectImages(project.ID)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch project images: %v", err))
+		return
+	}
+	if len(existing) >= s.Limits.MaxProjectImages {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Projects can have at most %v images", s.Limits.MaxProjectImages))
+		return
+	}
+	image, ok := s.readUpload(context)
+	if !ok {
+		return
+	}
+
+	key, url, err := s.storeUpload(fmt.Sprintf("projects/%v/", project.ID), image)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to store image: %v", err))
+		return
+	}
+	projectImage := types.ProjectImage{Project: project.ID, URL: url, ContentType: image.contentType, Size: int64(len(image.data))}
+	id, code, err := s.Images.QueryCreateProjectImage(&projectImage, s.Limits.MaxProjectImages)
+	if err != nil {
+		s.Blobs.Delete(key)
+		RespondWithError(context, code, fmt.Sprintf("Failed to create project image: %v", err))
+		return
+	}
+
+	created, err := s.Images.QueryProjectImage(id)
+	if err != nil || created == nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch created project image: %v", err))
+		return
+	}
+	context.JSON(http.StatusCreated, created)
+}
+
+// DeleteProjectImage handles DELETE requests to remove an image from a project.
+// It expects the `project_id` and `image_id` parameters in the URL.
+// Returns:
+// - 400 Bad Request if either id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project or image does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a message confirming the deletion.
+func (s *Server) DeleteProjectImage(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(context.Param("image_id"), 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse image id: %v", err))
+		return
+	}
+
+	image, err := s.Images.QueryProjectImage(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve project image: %v", err))
+		return
+	}
+	// images of other projects are reported as not found
+	if image == nil || image.Project != project.ID {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Image with id '%v' not found", id))
+		return
+	}
+
+	code, err := s.Images.QueryDeleteProjectImage(id)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to delete project image: %v", err))
+		return
+	}
+	s.deleteBlob(fmt.Sprintf("projects/%v/", project.ID), image.URL)
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Image %v deleted.", id)})
+}
@@ -3,8 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
-	"backend/api/internal/database"
+	"backend/api/internal/auth"
 	"backend/api/internal/types"
 
 	"github.com/gin-gonic/gin"
@@ -17,10 +18,10 @@ import (
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and the user data in JSON format.
-func GetUsernameById(context *gin.Context) {
+func (s *Server) GetUsernameById(context *gin.Context) {
 	username := context.Param("username")
 
-	user, err := database.QueryUsername(username)
+	user, err := s.Users.QueryUsername(username)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, "Failed to fetch user")
 		return
@@ -41,10 +42,10 @@ func GetUsernameById(context *gin.Context) {
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and the user data in JSON format.
-func GetUserByUsername(context *gin.Context) {
+func (s *Server) GetUserByUsername(context *gin.Context) {
 	username := context.Param("username")
 
-	user, err := database.QueryUsername(username)
+	user, err := s.Users.QueryUsername(username)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to get user: %v", err))
 		return
@@ -58,38 +59,23 @@ func GetUserByUsername(context *gin.Context) {
 	context.JSON(http.StatusOK, user)
 }
 
-// CreateUser handles POST requests to create a new user.
-// It expects a JSON body with the user details.
-// Returns:
-// - 400 Bad Request if the JSON is invalid or the user details are incomplete.
-// - 500 Internal Server Error if an error occurs while creating the user.
-// On success, responds with a 201 Created status and a message confirming the user creation.
-func CreateUser(context *gin.Context) {
-	var newUser types.User
-	err := context.BindJSON(&newUser)
-
-	if err != nil {
-		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
-		return
-	}
-	err = database.QueryCreateUser(&newUser)
-	if err != nil {
-		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create user: %v", err))
-		return
-	}
-	context.JSON(http.StatusCreated, gin.H{"message": fmt.Sprintf("Created new user: '%s'", newUser.Username)})
-}
-
 // DeleteUser handles DELETE requests to delete a user.
 // It expects the `username` parameter in the URL.
 // Returns:
 // - 400 Bad Request if the username is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is neither the user being deleted nor an admin.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the user deletion.
-func DeleteUser(context *gin.Context) {
+func (s *Server) DeleteUser(context *gin.Context) {
 	username := context.Param("username")
-	httpCode, err := database.QueryDeleteUser(username)
+	if !CallerCan(context, auth.PermissionManageUsers) && !RequireCallerIsUser(context, username) {
+		return
+	}
+
+	callerId, _ := GetCaller(context)
+	httpCode, err := s.Users.QueryDeleteUser(username, callerId)
 	if err != nil {
 		RespondWithError(context, int(httpCode), fmt.Sprintf("Failed to delete user: %v", err))
 		return
@@ -101,16 +87,21 @@ func DeleteUser(context *gin.Context) {
 // It expects the `username` parameter in the URL and a JSON body with the updated data.
 // Returns:
 // - 400 Bad Request if the data is invalid or contains unallowed fields.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user being updated.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if an error occurs while updating the user data.
 // On success, responds with a 200 OK status and a message confirming the update.
-func UpdateUserInfo(context *gin.Context) {
+func (s *Server) UpdateUserInfo(context *gin.Context) {
 	// we dont want to create a whole new user, that is
 	// why we dont use a user type here...
 	// maybe could change later, so we can use
 	// an empty mapped interface
 	var updateData map[string]interface{}
 	username := context.Param("username")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
 	// Bind the incoming JSON data to a map
 	err := context.BindJSON(&updateData)
@@ -119,7 +110,7 @@ func UpdateUserInfo(context *gin.Context) {
 		return
 	}
 
-	existingUser, err := database.QueryUsername(username)
+	existingUser, err := s.Users.QueryUsername(username)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error fetching user: %v", err))
 		return
@@ -134,6 +125,12 @@ func UpdateUserInfo(context *gin.Context) {
 
 	// Iterate through the fields of the existing user and map the request data to those fields
 	for key, value := range updateData {
+		// the picture is only set by uploading one, so it always
+		// points at a blob this user owns
+		if strings.EqualFold(key, "picture") {
+			RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Field '%s' is not allowed to be updated, upload a new picture instead", key))
+			return
+		}
 		// use helper to check if the field exists in existingUser
 		if IsFieldAllowed(existingUser, key) {
 			updatedData[key] = value
@@ -142,7 +139,7 @@ func UpdateUserInfo(context *gin.Context) {
 			return
 		}
 	}
-	err = database.QueryUpdateUser(username, updatedData)
+	err = s.Users.QueryUpdateUser(username, updatedData)
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error updating user: %v", err))
 		return
@@ -154,9 +151,9 @@ func UpdateUserInfo(context *gin.Context) {
 
 	// if there is a new username provided, ensure it is not empty
 	if usernameExists && parseOk && usernameStr != "" {
-		validUser, err = database.QueryUsername(usernameStr)
+		validUser, err = s.Users.QueryUsername(usernameStr)
 	} else {
-		validUser, err = database.QueryUsername(username)
+		validUser, err = s.Users.QueryUsername(username)
 	}
 	if err != nil {
 		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Error validating updated data: %v", err))
@@ -166,73 +163,121 @@ func UpdateUserInfo(context *gin.Context) {
 
 // GetUsersFollowers handles GET requests to fetch the list of user IDs who follow the specified user.
 // It expects the `username` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
+// - 400 Bad Request if the page parameters are invalid.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a list of follower IDs in JSON format.
-func GetUsersFollowers(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetUsersFollowers(context *gin.Context) {
 	username := context.Param("username")
 
-	followers, httpcode, err := database.QueryGetUsersFollowers(username)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	followers, next, httpcode, err := s.Users.QueryGetUsersFollowers(username, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch followers: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, followers, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, followers)
 }
 
 // GetUsersFollowing handles GET requests to fetch the list of user IDs that the specified user follows.
 // It expects the `username` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
+// - 400 Bad Request if the page parameters are invalid.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a list of following user IDs in JSON format.
-func GetUsersFollowing(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetUsersFollowing(context *gin.Context) {
 	username := context.Param("username")
 
-	following, httpcode, err := database.QueryGetUsersFollowing(username)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	following, next, httpcode, err := s.Users.QueryGetUsersFollowing(username, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch following: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, following, next)
+		return
+	}
+
 	context.JSON(http.StatusOK, following)
 }
 
 // GetUsersFollowersUsernames handles GET requests to fetch the usernames of users who follow the specified user.
 // It expects the `username` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
+// - 400 Bad Request if the page parameters are invalid.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a list of follower usernames in JSON format.
-func GetUsersFollowersUsernames(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetUsersFollowersUsernames(context *gin.Context) {
 	username := context.Param("username")
 
-	followers, httpcode, err := database.QueryGetUsersFollowersUsernames(username)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	followers, next, httpcode, err := s.Users.QueryGetUsersFollowersUsernames(username, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch followers: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, followers, next)
+		return
+	}
+
     context.JSON(http.StatusOK, gin.H{"message": "Successfully got followers", "followers":followers})
 }
 
 // GetUsersFollowingUsernames handles GET requests to fetch the usernames of users whom the specified user follows.
 // It expects the `username` parameter in the URL.
+// It also takes the optional `count` and `cursor` URL parameters to page through the list.
 // Returns:
+// - 400 Bad Request if the page parameters are invalid.
 // - 404 Not Found if no user is found with the given username.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a list of following usernames in JSON format.
-func GetUsersFollowingUsernames(context *gin.Context) {
+// When paged, the list is sent as `items` along with the `next_cursor` of the next page.
+func (s *Server) GetUsersFollowingUsernames(context *gin.Context) {
 	username := context.Param("username")
 
-	following, httpcode, err := database.QueryGetUsersFollowingUsernames(username)
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+	following, next, httpcode, err := s.Users.QueryGetUsersFollowingUsernames(username, page)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to fetch following: %v", err))
 		return
 	}
 
+	if page.Limit > 0 {
+		respondWithPage(context, following, next)
+		return
+	}
+
     context.JSON(http.StatusOK, gin.H{"message": "Successfully got following", "following": following})
 }
 
@@ -240,13 +285,18 @@ func GetUsersFollowingUsernames(context *gin.Context) {
 // It expects the `username` and `new_follow` parameters in the URL.
 // Returns:
 // - 400 Bad Request if the follow operation fails or the user is already following the other user.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the follow operation.
-func FollowUser(context *gin.Context) {
+func (s *Server) FollowUser(context *gin.Context) {
 	username := context.Param("username")
 	newFollow := context.Param("new_follow")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.CreateNewUserFollow(username, newFollow)
+	httpcode, err := s.Users.CreateNewUserFollow(username, newFollow)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to add follower: %v", err))
 		return
@@ -258,13 +308,18 @@ func FollowUser(context *gin.Context) {
 // It expects the `username` and `unfollow` parameters in the URL.
 // Returns:
 // - 400 Bad Request if the unfollow operation fails or the user is not following the other user.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller is not the user in the URL.
 // - 500 Internal Server Error if a database query fails.
 // On success, responds with a 200 OK status and a message confirming the unfollow operation.
-func UnfollowUser(context *gin.Context) {
+func (s *Server) UnfollowUser(context *gin.Context) {
 	username := context.Param("username")
 	unFollow := context.Param("unfollow")
+	if !RequireCallerIsUser(context, username) {
+		return
+	}
 
-	httpcode, err := database.RemoveUserFollow(username, unFollow)
+	httpcode, err := s.Users.RemoveUserFollow(username, unFollow)
 	if err != nil {
 		RespondWithError(context, httpcode, fmt.Sprintf("Failed to remove follower: %v", err))
 		return
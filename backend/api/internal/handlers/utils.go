@@ -18,7 +18,20 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// fields that are kept by the server rather than set by callers,
+// likes are counted from the likes tables and owners only change
+// through the admin routes
+var fixedFields = map[string]bool{
+	"user":  true,
+	"owner": true,
+	"likes": true,
+}
+
 func IsFieldAllowed(existingData interface{}, fieldName string) bool {
+	if fixedFields[strings.ToLower(fieldName)] {
+		return false
+	}
+
 	// existingUser should be a pointer to the struct, so get the type of the struct
 	val := reflect.ValueOf(existingData)
 
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/types"
+	"backend/api/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireOwnedProject resolves the `project_id` URL parameter to a project
+// the caller owns, which is required to see or change its webhooks and
+// to change its images.
+// If it is not, it responds with an error and returns false.
+func (s *Server) requireOwnedProject(context *gin.Context) (*types.Project, bool) {
+	id, err := strconv.Atoi(context.Param("project_id"))
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse project id: %v", err))
+		return nil, false
+	}
+
+	project, err := s.Projects.QueryProject(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve project: %v", err))
+		return nil, false
+	}
+	if project == nil {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Project with id '%v' not found", id))
+		return nil, false
+	}
+	if !RequireCallerIsOwner(context, project.Owner, "project") {
+		return nil, false
+	}
+	return project, true
+}
+
+// requireProjectWebhook resolves the `webhook_id` URL parameter to a
+// webhook of `project`. Webhooks of other projects are reported as not
+// found, so their ids cannot be probed.
+// If it is not, it responds with an error and returns false.
+func (s *Server) requireProjectWebhook(context *gin.Context, project *types.Project) (*types.Webhook, bool) {
+	id, err := strconv.ParseInt(context.Param("webhook_id"), 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse webhook id: %v", err))
+		return nil, false
+	}
+
+	webhook, err := s.Webhooks.QueryWebhook(id)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve webhook: %v", err))
+		return nil, false
+	}
+	if webhook == nil || webhook.Project != project.ID {
+		RespondWithError(context, http.StatusNotFound, fmt.Sprintf("Webhook with id '%v' not found", id))
+		return nil, false
+	}
+	return webhook, true
+}
+
+// validateWebhook checks that a new webhook delivers to an absolute http
+// or https URL and subscribes to known events, each listed once.
+func validateWebhook(webhook types.NewWebhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil {
+		return fmt.Errorf("Invalid url: %v", err)
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("Invalid url '%v', must be an absolute http or https url", webhook.URL)
+	}
+	if target.User != nil {
+		return fmt.Errorf("Invalid url '%v', must not contain credentials", webhook.URL)
+	}
+
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("Webhooks must subscribe to at least one event")
+	}
+	seen := map[string]bool{}
+	for _, event := range webhook.Events {
+		if !webhooks.IsEvent(event) {
+			return fmt.Errorf("Unknown event '%v', must be one of %v", event, strings.Join(webhooks.Events, ", "))
+		}
+		if seen[event] {
+			return fmt.Errorf("Event '%v' is listed more than once", event)
+		}
+		seen[event] = true
+	}
+	return nil
+}
+
+// CreateWebhook handles POST requests to register a webhook on a project.
+// It expects the `project_id` parameter in the URL and a JSON payload with
+// the `url` to deliver to and the `events` to deliver.
+// Returns:
+// - 400 Bad Request if the payload is invalid or the project has too many webhooks.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 201 Created status and the webhook in JSON
+// format, along with the secret its deliveries are signed with. The secret
+// is only ever sent back here.
+func (s *Server) CreateWebhook(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+
+	var newWebhook types.NewWebhook
+	if err := context.BindJSON(&newWebhook); err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to bind to JSON: %v", err))
+		return
+	}
+	if err := validateWebhook(newWebhook); err != nil {
+		RespondWithError(context, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, code, err := s.Webhooks.QueryWebhooks(project.ID)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch webhooks: %v", err))
+		return
+	}
+	if len(existing) >= s.Limits.MaxWebhooks {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Projects can have at most %v webhooks", s.Limits.MaxWebhooks))
+		return
+	}
+
+	secret, err := auth.NewRandomToken()
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create webhook secret: %v", err))
+		return
+	}
+	webhook := types.Webhook{Project: project.ID, URL: newWebhook.URL, Events: newWebhook.Events, Secret: secret}
+	id, err := s.Webhooks.QueryCreateWebhook(&webhook)
+	if err != nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to create webhook: %v", err))
+		return
+	}
+
+	created, err := s.Webhooks.QueryWebhook(id)
+	if err != nil || created == nil {
+		RespondWithError(context, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve created webhook: %v", err))
+		return
+	}
+	context.JSON(http.StatusCreated, created)
+}
+
+// GetWebhooks handles GET requests to list the webhooks of a project.
+// It expects the `project_id` parameter in the URL.
+// Returns:
+// - 400 Bad Request if the project_id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the webhooks, without
+// their secrets, in JSON format.
+func (s *Server) GetWebhooks(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+
+	webhooks, code, err := s.Webhooks.QueryWebhooks(project.ID)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch webhooks: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, webhooks)
+}
+
+// DeleteWebhook handles DELETE requests to remove a webhook from a project,
+// along with its delivery log. Deliveries still waiting are not sent.
+// It expects the `project_id` and `webhook_id` parameters in the URL.
+// Returns:
+// - 400 Bad Request if an id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project or webhook does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and a confirmation message.
+func (s *Server) DeleteWebhook(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+	webhook, ok := s.requireProjectWebhook(context, project)
+	if !ok {
+		return
+	}
+
+	code, err := s.Webhooks.QueryDeleteWebhook(webhook.ID)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to delete webhook: %v", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Webhook %v deleted.", webhook.ID)})
+}
+
+// GetWebhookDeliveries handles GET requests to retrieve the delivery log of
+// a webhook, latest first, with how every delivery went.
+// It expects the `project_id` and `webhook_id` parameters in the URL, and
+// optionally `count` and the `cursor` of the previous page to page through it.
+// Returns:
+// - 400 Bad Request if an id or the paging parameters are invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project or webhook does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 200 OK status and the page of deliveries in JSON format.
+func (s *Server) GetWebhookDeliveries(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+	webhook, ok := s.requireProjectWebhook(context, project)
+	if !ok {
+		return
+	}
+	page, ok := s.parsePage(context, false)
+	if !ok {
+		return
+	}
+
+	deliveries, next, code, err := s.Webhooks.QueryDeliveries(webhook.ID, page)
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to fetch deliveries: %v", err))
+		return
+	}
+	respondWithPage(context, deliveries, next)
+}
+
+// RedeliverWebhook handles POST requests to send a past delivery of a
+// webhook again, as a new delivery with the same payload that is logged
+// separately and retried like any other.
+// It expects the `project_id`, `webhook_id` and `delivery_id` parameters in the URL.
+// Returns:
+// - 400 Bad Request if an id is invalid.
+// - 401 Unauthorized if the caller is not logged in.
+// - 403 Forbidden if the caller does not own the project.
+// - 404 Not Found if the project, webhook or delivery does not exist.
+// - 500 Internal Server Error if a database query fails.
+// On success, responds with a 202 Accepted status and the id of the new delivery.
+func (s *Server) RedeliverWebhook(context *gin.Context) {
+	project, ok := s.requireOwnedProject(context)
+	if !ok {
+		return
+	}
+	webhook, ok := s.requireProjectWebhook(context, project)
+	if !ok {
+		return
+	}
+	deliveryId, err := strconv.ParseInt(context.Param("delivery_id"), 10, 64)
+	if err != nil {
+		RespondWithError(context, http.StatusBadRequest, fmt.Sprintf("Failed to parse delivery id: %v", err))
+		return
+	}
+
+	id, code, err := s.Webhooks.QueryRedeliver(webhook.ID, deliveryId, time.Now().UTC())
+	if err != nil {
+		RespondWithError(context, code, fmt.Sprintf("Failed to redeliver: %v", err))
+		return
+	}
+	context.JSON(http.StatusAccepted, gin.H{
+		"message":  fmt.Sprintf("Delivery %v queued again.", deliveryId),
+		"delivery": id,
+	})
+}
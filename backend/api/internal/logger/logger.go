@@ -18,3 +18,14 @@ func InitLogger() {
 	Log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true}) // Human-readable
 	Log.SetLevel(logrus.InfoLevel)
 }
+
+// SetLevel changes how verbose the logger is, e.g. "debug" or "warn".
+// Unknown levels are ignored, the config is validated before this is called.
+func SetLevel(level string) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		Log.Warnf("Ignoring unknown log level '%v'", level)
+		return
+	}
+	Log.SetLevel(parsed)
+}
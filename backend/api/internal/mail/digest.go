@@ -0,0 +1,177 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/logger"
+	"backend/api/internal/types"
+)
+
+// how many digests are collected per round
+const digestBatchSize = 100
+
+// the order digests are sent in, so every round goes the same way
+var digestFrequencies = []string{types.DigestDaily, types.DigestWeekly}
+
+// DigestStore is what the digester needs from the digest store.
+type DigestStore interface {
+	QueryDueDigests(frequency string, sentBefore time.Time, limit int) ([]types.Digest, int, error)
+	QueryMarkDigestSent(userId int64, through int64, now time.Time) (int, error)
+}
+
+// Digester emails users who asked for it a digest of their notifications.
+// Only one should run per database, or digests may be sent twice.
+type Digester struct {
+	store   DigestStore
+	mailer  Mailer
+	linkURL string
+}
+
+// NewDigester returns a digester collecting digests from `store` and
+// sending them through `mailer`, with unsubscribe links to the api at `linkURL`.
+func NewDigester(store DigestStore, mailer Mailer, linkURL string) *Digester {
+	return &Digester{store: store, mailer: mailer, linkURL: linkURL}
+}
+
+// Run sends the due digests every `interval` until `ctx` is done. A digest
+// is due once a day or week passed since the last one, so it goes out up
+// to `interval` late.
+func (d *Digester) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.SendDue(time.Now().UTC())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends every digest due at `now`. Digests with nothing in them
+// are skipped, and a digest that fails to send is carried over to the next
+// one, but both count as done until the next one is due.
+//
+// Returns:
+//   - int: How many digests were sent.
+func (d *Digester) SendDue(now time.Time) int {
+	sent := 0
+	for _, frequency := range digestFrequencies {
+		for {
+			due, _, err := d.store.QueryDueDigests(frequency, now.Add(-types.DigestFrequencies[frequency]), digestBatchSize)
+			if err != nil {
+				logger.Log.Errorf("Failed to collect %v digests: %v", frequency, err)
+				break
+			}
+			marked := 0
+			for _, digest := range due {
+				through := digest.Through
+				if digest.Through > digest.Since {
+					if err := d.mailer.Send(RenderDigest(digest, frequency, UnsubscribeLink(d.linkURL, digest.User, digest.Email))); err != nil {
+						logger.Log.Errorf("Failed to email digest to user %v: %v", digest.User, err)
+						through = digest.Since
+					} else {
+						sent++
+					}
+				}
+				if _, err := d.store.QueryMarkDigestSent(digest.User, through, now); err != nil {
+					logger.Log.Errorf("Failed to mark digest of user %v sent: %v", digest.User, err)
+					continue
+				}
+				marked++
+			}
+			// marked digests are no longer due, so a full round may have
+			// left more behind. Unless marking failed, which would repeat it
+			if len(due) < digestBatchSize || marked < len(due) {
+				break
+			}
+		}
+	}
+	return sent
+}
+
+// counted describes how many of something there are, like "1 new like" or "3 new likes".
+func counted(count int64, singular string, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 new %v", singular)
+	}
+	return fmt.Sprintf("%v new %v", count, plural)
+}
+
+// ConfirmationLink returns the link that confirms an address, on the api at `linkURL`.
+func ConfirmationLink(linkURL string, token string) string {
+	return strings.TrimSuffix(linkURL, "/") + "/email/confirm?token=" + url.QueryEscape(token)
+}
+
+// UnsubscribeLink returns the link that turns off the digests a user gets
+// at `email`, on the api at `linkURL`.
+func UnsubscribeLink(linkURL string, userId int64, email string) string {
+	return strings.TrimSuffix(linkURL, "/") + "/email/unsubscribe?token=" + url.QueryEscape(auth.UnsubscribeToken(userId, email))
+}
+
+// RenderConfirmation writes out the email asking a user to confirm the
+// address they want digests at. Nothing else is sent to it until they do.
+//
+// Parameters:
+//   - to: The address to confirm.
+//   - username: The user who set it.
+//   - link: The confirmation link.
+//
+// Returns:
+//   - Message: The email to send to the address.
+func RenderConfirmation(to string, username string, link string) Message {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %v,\n\n", username)
+	fmt.Fprintf(&body, "Please confirm that you want DevBits digests sent to this address by opening this link:\n\n  %v\n\n", link)
+	fmt.Fprintf(&body, "If you did not ask for them, ignore this email and nothing more will be sent to you.\n")
+
+	return Message{
+		To:      to,
+		Subject: "Confirm your email address for DevBits",
+		Body:    body.String(),
+	}
+}
+
+// RenderDigest writes out the email of a digest.
+//
+// Parameters:
+//   - digest: The digest to send.
+//   - frequency: How often the user gets digests, daily or weekly.
+//   - unsubscribeLink: The link that turns the digests off.
+//
+// Returns:
+//   - Message: The email to send to the user.
+func RenderDigest(digest types.Digest, frequency string, unsubscribeLink string) Message {
+	counts := []string{}
+	if digest.Followers > 0 {
+		counts = append(counts, counted(digest.Followers, "follower", "followers"))
+	}
+	if digest.Likes > 0 {
+		counts = append(counts, counted(digest.Likes, "like", "likes"))
+	}
+	if digest.Replies > 0 {
+		counts = append(counts, counted(digest.Replies, "reply", "replies"))
+	}
+	summary := strings.Join(counts, ", ")
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %v,\n\n", digest.Username)
+	fmt.Fprintf(&body, "Since your last digest you got %v.\n\n", summary)
+	for _, highlight := range digest.Highlights {
+		fmt.Fprintf(&body, "  - %v\n", highlight.Message)
+	}
+	fmt.Fprintf(&body, "\nYou get this digest %v. You can change how often, or turn it off, in your email preferences.\n", frequency)
+	fmt.Fprintf(&body, "To stop getting digests, unsubscribe here: %v\n", unsubscribeLink)
+
+	return Message{
+		To:      digest.Email,
+		Subject: fmt.Sprintf("Your %v DevBits digest: %v", frequency, summary),
+		Body:    body.String(),
+	}
+}
@@ -0,0 +1,46 @@
+// The mail package sends email to users. Mailers deliver a message one
+// way or another, through an SMTP server or only to the log, and the
+// Digester regularly emails users who asked for it a digest of what they
+// were notified of.
+package mail
+
+import (
+	"fmt"
+	"strings"
+
+	"backend/api/internal/logger"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages. Send returns once the message was handed off,
+// which does not mean it reached the recipient yet.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// checkHeaders refuses messages whose recipient or subject would break out
+// of their header line and add headers of their own.
+func checkHeaders(message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return fmt.Errorf("Invalid message, the recipient and subject must be a single line")
+	}
+	return nil
+}
+
+// LogMailer only logs the messages it is given, for development and for
+// deployments that do not send email.
+type LogMailer struct{}
+
+func (LogMailer) Send(message Message) error {
+	if err := checkHeaders(message); err != nil {
+		return err
+	}
+	logger.Log.Infof("Email to %v: %v\n%v", message.To, message.Subject, message.Body)
+	return nil
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP server. The connection is
+// upgraded with STARTTLS whenever the server offers it, and credentials are
+// only sent over TLS or to a server on localhost.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	// the From header, and the bare address in it that the server is given
+	from    string
+	sender  string
+	timeout time.Duration
+}
+
+// NewSMTPMailer returns a mailer sending as `from` through the server at
+// `host` and `port`, logging in when a username is given. `from` can
+// carry a display name, like "DevBits <no-reply@example.com>". Every
+// message has to be sent within `timeout`.
+func NewSMTPMailer(host string, port int, username string, password string, from string, timeout time.Duration) *SMTPMailer {
+	sender := from
+	if address, err := netmail.ParseAddress(from); err == nil {
+		sender = address.Address
+	}
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from, sender: sender, timeout: timeout}
+}
+
+// Send delivers a message to the server, failing if the server does not
+// accept it within the mailer's timeout.
+func (m *SMTPMailer) Send(message Message) error {
+	if err := checkHeaders(message); err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.host, strconv.Itoa(m.port)), m.timeout)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to SMTP server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(m.timeout))
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("Failed to greet SMTP server: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("Failed to start TLS: %v", err)
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("Failed to log in to SMTP server: %v", err)
+		}
+	}
+
+	if err := client.Mail(m.sender); err != nil {
+		return fmt.Errorf("SMTP server refused sender: %v", err)
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return fmt.Errorf("SMTP server refused recipient: %v", err)
+	}
+	data, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP server refused message: %v", err)
+	}
+	if _, err := data.Write(m.compose(message)); err != nil {
+		return fmt.Errorf("Failed to send message: %v", err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("SMTP server refused message: %v", err)
+	}
+	return client.Quit()
+}
+
+// compose writes out a message with its headers, with CRLF line endings
+// as SMTP expects.
+func (m *SMTPMailer) compose(message Message) []byte {
+	var composed bytes.Buffer
+	fmt.Fprintf(&composed, "From: %v\r\n", m.from)
+	fmt.Fprintf(&composed, "To: %v\r\n", message.To)
+	fmt.Fprintf(&composed, "Subject: %v\r\n", message.Subject)
+	fmt.Fprintf(&composed, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	composed.WriteString("MIME-Version: 1.0\r\n")
+	composed.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	composed.WriteString("\r\n")
+	composed.Write(bytes.ReplaceAll(bytes.ReplaceAll([]byte(message.Body), []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n")))
+	return composed.Bytes()
+}
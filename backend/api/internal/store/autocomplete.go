@@ -0,0 +1,225 @@
+package store
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/api/internal/autocomplete"
+	"backend/api/internal/types"
+)
+
+// autocompleter answers autocomplete queries from in-memory prefix
+// indexes, which the stores wrapped by WithAutocomplete keep up to date
+// as users and projects are created, renamed, deleted and followed.
+type autocompleter struct {
+	stores   Stores
+	users    *autocomplete.Index
+	projects *autocomplete.Index
+}
+
+// WithAutocomplete builds the autocomplete indexes from stores and returns
+// them with Autocomplete set. Users, Auth, Projects and Tombstones are
+// wrapped so every change made through them also reaches the indexes,
+// which means the indexes only stay accurate while this server is the
+// only one writing.
+func WithAutocomplete(stores Stores) (Stores, error) {
+	ac := &autocompleter{
+		stores:   stores,
+		users:    autocomplete.New(nil),
+		projects: autocomplete.New(nil),
+	}
+	if err := ac.reload(); err != nil {
+		return Stores{}, err
+	}
+
+	stores.Users = autocompleteUsers{stores.Users, ac}
+	stores.Auth = autocompleteAuth{stores.Auth, ac}
+	stores.Projects = autocompleteProjects{stores.Projects, ac}
+	stores.Tombstones = autocompleteTombstones{stores.Tombstones, ac}
+	stores.Autocomplete = ac
+	return stores, nil
+}
+
+// reload rebuilds both indexes from scratch, for changes that cascade
+// further than is worth tracking one by one.
+func (ac *autocompleter) reload() error {
+	users, _, err := ac.stores.Users.QueryUserFollowerCounts()
+	if err != nil {
+		return err
+	}
+	projects, _, err := ac.stores.Projects.QueryProjectFollowerCounts()
+	if err != nil {
+		return err
+	}
+	ac.users.Reset(users)
+	ac.projects.Reset(projects)
+	return nil
+}
+
+func (ac *autocompleter) CompleteUsers(prefix string, callerId int64, limit int) ([]types.Suggestion, int, error) {
+	following := map[int64]bool{}
+	if callerId != 0 {
+		username, err := ac.stores.Users.GetUsernameById(callerId)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		ids, _, httpcode, err := ac.stores.Users.QueryGetUsersFollowing(username, types.Page{})
+		if err != nil {
+			return nil, httpcode, err
+		}
+		for _, id := range ids {
+			following[int64(id)] = true
+		}
+	}
+	return ac.users.Complete(prefix, limit, following), http.StatusOK, nil
+}
+
+func (ac *autocompleter) CompleteProjects(prefix string, callerId int64, limit int) ([]types.Suggestion, int, error) {
+	following := map[int64]bool{}
+	if callerId != 0 {
+		username, err := ac.stores.Users.GetUsernameById(callerId)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		ids, httpcode, err := ac.stores.Projects.QueryGetProjectFollowing(username)
+		if err != nil {
+			return nil, httpcode, err
+		}
+		for _, id := range ids {
+			following[int64(id)] = true
+		}
+	}
+	return ac.projects.Complete(prefix, limit, following), http.StatusOK, nil
+}
+
+// putUser indexes a user under their current username.
+func (ac *autocompleter) putUser(username string) {
+	if id, err := ac.stores.Users.GetUserIdByUsername(username); err == nil {
+		ac.users.Put(int64(id), username)
+	}
+}
+
+type autocompleteUsers struct {
+	UserStore
+	ac *autocompleter
+}
+
+func (users autocompleteUsers) QueryCreateUser(user *types.User) error {
+	err := users.UserStore.QueryCreateUser(user)
+	if err == nil {
+		users.ac.putUser(user.Username)
+	}
+	return err
+}
+
+func (users autocompleteUsers) QueryUpdateUser(username string, updatedData map[string]interface{}) error {
+	err := users.UserStore.QueryUpdateUser(username, updatedData)
+	if newUsername, ok := updatedData["username"].(string); ok && err == nil {
+		users.ac.putUser(newUsername)
+	}
+	return err
+}
+
+func (users autocompleteUsers) QueryDeleteUser(username string, deletedBy int64) (int16, error) {
+	httpcode, err := users.UserStore.QueryDeleteUser(username, deletedBy)
+	if err == nil {
+		// the user's projects and follows go with them, if rebuilding
+		// fails the index is only stale until the next restart
+		users.ac.reload()
+	}
+	return httpcode, err
+}
+
+func (users autocompleteUsers) CreateNewUserFollow(user string, newFollow string) (int, error) {
+	httpcode, err := users.UserStore.CreateNewUserFollow(user, newFollow)
+	if err == nil {
+		users.ac.addUserFollowers(newFollow, 1)
+	}
+	return httpcode, err
+}
+
+func (users autocompleteUsers) RemoveUserFollow(user string, unfollow string) (int, error) {
+	httpcode, err := users.UserStore.RemoveUserFollow(user, unfollow)
+	if err == nil {
+		users.ac.addUserFollowers(unfollow, -1)
+	}
+	return httpcode, err
+}
+
+func (ac *autocompleter) addUserFollowers(username string, delta int64) {
+	if id, err := ac.stores.Users.GetUserIdByUsername(username); err == nil {
+		ac.users.AddFollowers(int64(id), delta)
+	}
+}
+
+type autocompleteAuth struct {
+	AuthStore
+	ac *autocompleter
+}
+
+func (auth autocompleteAuth) QueryRegisterUser(registration *types.UserRegistration) (int, error) {
+	httpcode, err := auth.AuthStore.QueryRegisterUser(registration)
+	if err == nil {
+		auth.ac.putUser(registration.Username)
+	}
+	return httpcode, err
+}
+
+type autocompleteProjects struct {
+	ProjectStore
+	ac *autocompleter
+}
+
+func (projects autocompleteProjects) QueryCreateProject(proj *types.Project) (int64, error) {
+	id, err := projects.ProjectStore.QueryCreateProject(proj)
+	if err == nil {
+		projects.ac.projects.Put(id, proj.Name)
+	}
+	return id, err
+}
+
+func (projects autocompleteProjects) QueryUpdateProject(id int, updatedData map[string]interface{}) error {
+	err := projects.ProjectStore.QueryUpdateProject(id, updatedData)
+	if name, ok := updatedData["name"].(string); ok && err == nil {
+		projects.ac.projects.Put(int64(id), name)
+	}
+	return err
+}
+
+func (projects autocompleteProjects) QueryDeleteProject(id int, deletedBy int64) (int16, error) {
+	httpcode, err := projects.ProjectStore.QueryDeleteProject(id, deletedBy)
+	if err == nil {
+		projects.ac.projects.Remove(int64(id))
+	}
+	return httpcode, err
+}
+
+func (projects autocompleteProjects) CreateNewProjectFollow(username string, projectID string) (int, error) {
+	httpcode, err := projects.ProjectStore.CreateNewProjectFollow(username, projectID)
+	if id, parseErr := strconv.ParseInt(projectID, 10, 64); err == nil && parseErr == nil {
+		projects.ac.projects.AddFollowers(id, 1)
+	}
+	return httpcode, err
+}
+
+func (projects autocompleteProjects) RemoveProjectFollow(username string, projectID string) (int, error) {
+	httpcode, err := projects.ProjectStore.RemoveProjectFollow(username, projectID)
+	if id, parseErr := strconv.ParseInt(projectID, 10, 64); err == nil && parseErr == nil {
+		projects.ac.projects.AddFollowers(id, -1)
+	}
+	return httpcode, err
+}
+
+type autocompleteTombstones struct {
+	TombstoneStore
+	ac *autocompleter
+}
+
+func (tombstones autocompleteTombstones) QueryRestore(kind string, id int64) (int, error) {
+	httpcode, err := tombstones.TombstoneStore.QueryRestore(kind, id)
+	if err == nil && (kind == "user" || kind == "project") {
+		// a restored user brings back their projects too
+		tombstones.ac.reload()
+	}
+	return httpcode, err
+}
@@ -0,0 +1,266 @@
+package store
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+)
+
+// publisher publishes what happens through the stores wrapped by
+// WithEvents to a bus, once the write behind it has succeeded.
+type publisher struct {
+	stores Stores
+	bus    *events.Bus
+
+	// notifications are written along with the likes, follows and comments
+	// that cause them, so after each of those the new ones are read back
+	// and published. The lock keeps two writers from publishing one twice.
+	mu               sync.Mutex
+	lastNotification int64
+}
+
+// WithEvents returns the stores with Events set to `bus`. Users, Projects,
+// Posts and Comments are wrapped so new notifications, new posts, changed
+// like counts and new, edited and deleted comments are published to it,
+// which means only the events of writes made by this server reach its
+// streams.
+func WithEvents(stores Stores, bus *events.Bus) (Stores, error) {
+	last, _, err := stores.Notifications.QueryLastNotificationId()
+	if err != nil {
+		return Stores{}, err
+	}
+	p := &publisher{stores: stores, bus: bus, lastNotification: last}
+
+	stores.Users = eventUsers{stores.Users, p}
+	stores.Projects = eventProjects{stores.Projects, p}
+	stores.Posts = eventPosts{stores.Posts, p}
+	stores.Comments = eventComments{stores.Comments, p}
+	stores.Events = bus
+	return stores, nil
+}
+
+// publishNotifications publishes every notification sent since the last
+// call to the user it is for. Events are best effort, if reading them
+// back fails they are picked up after the next write instead.
+func (p *publisher) publishNotifications() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sent, _, err := p.stores.Notifications.QueryNotificationsAfter(p.lastNotification)
+	if err != nil {
+		return
+	}
+	for _, notification := range sent {
+		p.bus.Publish(events.TypeNotification, notification.Notification, events.UserTopic(notification.Recipient))
+		p.lastNotification = notification.ID
+	}
+}
+
+// publishLikes publishes the like count of an item after it was liked or
+// unliked. `likes` looks the count up, false if the item is gone, and
+// `topics` lists where the item's likes go.
+func (p *publisher) publishLikes(kind string, strId string, topics func(int64) []string, likes func(id int) (int64, bool)) {
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		return
+	}
+	if count, ok := likes(id); ok {
+		p.bus.Publish(events.TypeLikes, types.LikeCount{Kind: kind, ID: int64(id), Likes: count}, topics(int64(id))...)
+	}
+}
+
+// publishComment publishes a comment as it is now to the thread it is
+// part of, hidden if it was deleted.
+func (p *publisher) publishComment(eventType string, id int64) {
+	comment, err := p.stores.Comments.QueryComment(int(id))
+	if err != nil || comment == nil {
+		return
+	}
+	kind, threadId, _, err := p.stores.Comments.QueryCommentThread(int(id))
+	if err != nil {
+		return
+	}
+	p.bus.Publish(eventType, comment, events.ThreadTopic(kind, threadId))
+}
+
+func postTopics(id int64) []string { return []string{events.PostTopic(id)} }
+
+func projectTopics(id int64) []string { return []string{events.ProjectTopic(id)} }
+
+// commentTopics sends the likes of a comment to its own topic as well
+// as to its thread.
+func (p *publisher) commentTopics(id int64) []string {
+	topics := []string{events.CommentTopic(id)}
+	if kind, threadId, _, err := p.stores.Comments.QueryCommentThread(int(id)); err == nil {
+		topics = append(topics, events.ThreadTopic(kind, threadId))
+	}
+	return topics
+}
+
+func (p *publisher) postLikes(id int) (int64, bool) {
+	post, err := p.stores.Posts.QueryPost(id)
+	if err != nil || post == nil {
+		return 0, false
+	}
+	return post.Likes, true
+}
+
+func (p *publisher) projectLikes(id int) (int64, bool) {
+	project, err := p.stores.Projects.QueryProject(id)
+	if err != nil || project == nil {
+		return 0, false
+	}
+	return project.Likes, true
+}
+
+func (p *publisher) commentLikes(id int) (int64, bool) {
+	comment, err := p.stores.Comments.QueryComment(id)
+	if err != nil || comment == nil {
+		return 0, false
+	}
+	return comment.Likes, true
+}
+
+type eventUsers struct {
+	UserStore
+	p *publisher
+}
+
+func (users eventUsers) CreateNewUserFollow(user string, newFollow string) (int, error) {
+	httpcode, err := users.UserStore.CreateNewUserFollow(user, newFollow)
+	if err == nil {
+		users.p.publishNotifications()
+	}
+	return httpcode, err
+}
+
+type eventProjects struct {
+	ProjectStore
+	p *publisher
+}
+
+func (projects eventProjects) CreateNewProjectFollow(username string, projectID string) (int, error) {
+	httpcode, err := projects.ProjectStore.CreateNewProjectFollow(username, projectID)
+	if err == nil {
+		projects.p.publishNotifications()
+	}
+	return httpcode, err
+}
+
+func (projects eventProjects) CreateProjectLike(username string, strProjId string) (int, error) {
+	httpcode, err := projects.ProjectStore.CreateProjectLike(username, strProjId)
+	if err == nil {
+		projects.p.publishLikes("project", strProjId, projectTopics, projects.p.projectLikes)
+		projects.p.publishNotifications()
+	}
+	return httpcode, err
+}
+
+func (projects eventProjects) RemoveProjectLike(username string, strProjId string) (int, error) {
+	httpcode, err := projects.ProjectStore.RemoveProjectLike(username, strProjId)
+	if err == nil {
+		projects.p.publishLikes("project", strProjId, projectTopics, projects.p.projectLikes)
+	}
+	return httpcode, err
+}
+
+type eventPosts struct {
+	PostStore
+	p *publisher
+}
+
+func (posts eventPosts) QueryCreatePost(post *types.Post) (int64, error) {
+	id, err := posts.PostStore.QueryCreatePost(post)
+	if err != nil {
+		return id, err
+	}
+	if created, err := posts.PostStore.QueryPost(int(id)); err == nil && created != nil {
+		posts.p.bus.Publish(events.TypePost, created, events.PostsByUserTopic(created.User), events.PostsOnProjectTopic(created.Project))
+	}
+	return id, nil
+}
+
+func (posts eventPosts) CreatePostLike(username string, strPostId string) (int, error) {
+	httpcode, err := posts.PostStore.CreatePostLike(username, strPostId)
+	if err == nil {
+		posts.p.publishLikes("post", strPostId, postTopics, posts.p.postLikes)
+		posts.p.publishNotifications()
+	}
+	return httpcode, err
+}
+
+func (posts eventPosts) RemovePostLike(username string, strPostId string) (int, error) {
+	httpcode, err := posts.PostStore.RemovePostLike(username, strPostId)
+	if err == nil {
+		posts.p.publishLikes("post", strPostId, postTopics, posts.p.postLikes)
+	}
+	return httpcode, err
+}
+
+type eventComments struct {
+	CommentStore
+	p *publisher
+}
+
+func (comments eventComments) QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnPost(comment, postId)
+	if err == nil {
+		comments.p.publishComment(events.TypeComment, id)
+		comments.p.publishNotifications()
+	}
+	return id, err
+}
+
+func (comments eventComments) QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnProject(comment, projectId)
+	if err == nil {
+		comments.p.publishComment(events.TypeComment, id)
+		comments.p.publishNotifications()
+	}
+	return id, err
+}
+
+func (comments eventComments) QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnComment(comment, commentId)
+	if err == nil {
+		comments.p.publishComment(events.TypeComment, id)
+		comments.p.publishNotifications()
+	}
+	return id, err
+}
+
+func (comments eventComments) QueryDeleteComment(id int, deletedBy int64) (int16, error) {
+	httpcode, err := comments.CommentStore.QueryDeleteComment(id, deletedBy)
+	if err == nil {
+		comments.p.publishComment(events.TypeCommentDeleted, int64(id))
+	}
+	return httpcode, err
+}
+
+func (comments eventComments) QueryUpdateCommentContent(id int, newContent string, editWindow time.Duration) (int16, error) {
+	httpcode, err := comments.CommentStore.QueryUpdateCommentContent(id, newContent, editWindow)
+	if err == nil {
+		comments.p.publishComment(events.TypeCommentEdited, int64(id))
+	}
+	return httpcode, err
+}
+
+func (comments eventComments) CreateCommentLike(username string, strCommentId string) (int, error) {
+	httpcode, err := comments.CommentStore.CreateCommentLike(username, strCommentId)
+	if err == nil {
+		comments.p.publishLikes("comment", strCommentId, comments.p.commentTopics, comments.p.commentLikes)
+		comments.p.publishNotifications()
+	}
+	return httpcode, err
+}
+
+func (comments eventComments) RemoveCommentLike(username string, strCommentId string) (int, error) {
+	httpcode, err := comments.CommentStore.RemoveCommentLike(username, strCommentId)
+	if err == nil {
+		comments.p.publishLikes("comment", strCommentId, comments.p.commentTopics, comments.p.commentLikes)
+	}
+	return httpcode, err
+}
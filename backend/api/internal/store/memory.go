@@ -0,0 +1,221 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// the rows of the tables that have more to them than the public types
+type memoryUser struct {
+	id             int64
+	user           types.User
+	role           string
+	suspensionDate *time.Time
+}
+
+type memoryFamily struct {
+	userId         int64
+	revocationDate *time.Time
+}
+
+type memoryRefreshToken struct {
+	familyId       string
+	expirationDate time.Time
+	usedDate       *time.Time
+}
+
+type memoryPersonalToken struct {
+	userId    int64
+	tokenHash string
+	token     types.PersonalToken
+}
+
+// a (user id, resource id) pair, used for follows and likes
+type pair [2]int64
+
+// memory is a store that keeps every table in a map guarded by a single
+// mutex. It follows the same rules as the SQL queries, including their
+// status codes and error messages, so handlers behave the same on both.
+type memory struct {
+	mu sync.Mutex
+
+	// the last id handed out per table, like AUTOINCREMENT
+	lastIds map[string]int64
+
+	users          map[int64]*memoryUser
+	passwords      map[string]string
+	families       map[string]*memoryFamily
+	refreshTokens  map[string]*memoryRefreshToken
+	personalTokens map[int64]*memoryPersonalToken
+	userFollows    map[pair]bool
+
+	// the likes and follows that count towards trending scores, by when
+	// they were made
+	projects       map[int64]*types.Project
+	projectFollows map[pair]time.Time
+	projectLikes   map[pair]time.Time
+
+	posts     map[int64]*types.Post
+	postLikes map[pair]time.Time
+
+	comments        map[int64]*types.Comment
+	postComments    map[int64]int64
+	projectComments map[int64]int64
+	commentLikes    map[pair]bool
+
+	// what posts and comments said before each edit, oldest first
+	postRevisions    map[int64][]types.Revision
+	commentRevisions map[int64][]types.Revision
+
+	// deleted users, projects and posts, moved out of their tables until
+	// they are restored or purged. Deleted comments stay where they are.
+	tombstones map[tombstoneKey]*memoryTombstone
+	// who deleted each deleted comment
+	commentDeleters map[int64]int64
+
+	notifications map[int64]*memoryNotification
+
+	webhooks   map[int64]*types.Webhook
+	deliveries map[int64]*types.WebhookDelivery
+
+	emailPreferences map[int64]*memoryEmailPreferences
+
+	projectImages map[int64]*types.ProjectImage
+}
+
+// NewMemory returns empty stores that live in memory, for tests that
+// need handlers without a database. Every store shares the same data.
+func NewMemory() Stores {
+	m := &memory{
+		lastIds:          map[string]int64{},
+		users:            map[int64]*memoryUser{},
+		passwords:        map[string]string{},
+		families:         map[string]*memoryFamily{},
+		refreshTokens:    map[string]*memoryRefreshToken{},
+		personalTokens:   map[int64]*memoryPersonalToken{},
+		userFollows:      map[pair]bool{},
+		projects:         map[int64]*types.Project{},
+		projectFollows:   map[pair]time.Time{},
+		projectLikes:     map[pair]time.Time{},
+		posts:            map[int64]*types.Post{},
+		postLikes:        map[pair]time.Time{},
+		comments:         map[int64]*types.Comment{},
+		postComments:     map[int64]int64{},
+		projectComments:  map[int64]int64{},
+		commentLikes:     map[pair]bool{},
+		postRevisions:    map[int64][]types.Revision{},
+		commentRevisions: map[int64][]types.Revision{},
+		tombstones:       map[tombstoneKey]*memoryTombstone{},
+		commentDeleters:  map[int64]int64{},
+		notifications:    map[int64]*memoryNotification{},
+		webhooks:         map[int64]*types.Webhook{},
+		deliveries:       map[int64]*types.WebhookDelivery{},
+		emailPreferences: map[int64]*memoryEmailPreferences{},
+		projectImages:    map[int64]*types.ProjectImage{},
+	}
+	return Stores{
+		Users:         m,
+		Auth:          m,
+		Projects:      m,
+		Posts:         m,
+		Comments:      m,
+		Feed:          m,
+		Search:        m,
+		Tombstones:    m,
+		Notifications: m,
+		Webhooks:      m,
+		Digests:       m,
+		Images:        m,
+	}
+}
+
+// nextId hands out the next id for a table.
+func (m *memory) nextId(table string) int64 {
+	m.lastIds[table]++
+	return m.lastIds[table]
+}
+
+// userByName looks up a user by username, nil if there is none.
+func (m *memory) userByName(username string) *memoryUser {
+	for _, user := range m.users {
+		if user.user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// userId mirrors GetUserIdByUsername, including its error message.
+func (m *memory) userId(username string) (int, error) {
+	user := m.userByName(username)
+	if user == nil {
+		return -1, fmt.Errorf("Error fetching user ID for username '%v' (this usually means username does not exist) : %w", username, sql.ErrNoRows)
+	}
+	return int(user.id), nil
+}
+
+// sortedIds returns the keys of a table in ascending order, which is the
+// order the SQL queries return rows in.
+func sortedIds[T any](table map[int64]T) []int64 {
+	ids := make([]int64, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// applyUpdate copies the fields in updatedData onto target, matching them
+// by their json name the same way BuildUpdateQuery matches columns.
+func applyUpdate(target interface{}, updatedData map[string]interface{}) error {
+	current, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("Error building query: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(current, &fields); err != nil {
+		return fmt.Errorf("Error building query: %v", err)
+	}
+
+	for key, value := range updatedData {
+		key = strings.ToLower(key)
+		if _, ok := fields[key]; !ok {
+			return fmt.Errorf("Error executing update query: no such column: %v", key)
+		}
+		fields[key] = value
+	}
+
+	updated, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("Error building query: %v", err)
+	}
+	if err := json.Unmarshal(updated, target); err != nil {
+		return fmt.Errorf("Error executing update query: %v", err)
+	}
+	return nil
+}
+
+// revokeFamilies revokes every open session a user has.
+func (m *memory) revokeFamilies(userId int64, now time.Time) {
+	for _, family := range m.families {
+		if family.userId == userId && family.revocationDate == nil {
+			family.revocationDate = &now
+		}
+	}
+}
+
+func (m *memory) caller(user *memoryUser) *types.Caller {
+	return &types.Caller{
+		ID:        user.id,
+		Username:  user.user.Username,
+		Role:      user.role,
+		Suspended: user.suspensionDate != nil,
+	}
+}
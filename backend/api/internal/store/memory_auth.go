@@ -0,0 +1,233 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (m *memory) QueryRegisterUser(registration *types.UserRegistration) (int, error) {
+	if err := database.ValidatePassword(registration.Password); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// hashed before taking the lock, bcrypt is slow on purpose
+	passwordHash, err := database.HashPassword(registration.Password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.usernameTaken(registration.Username) {
+		return http.StatusConflict, fmt.Errorf("Username '%v' is already taken", registration.Username)
+	}
+	if err := m.createUser(&registration.User); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	m.passwords[registration.Username] = passwordHash
+	return http.StatusCreated, nil
+}
+
+func (m *memory) QueryVerifyLogin(username string, password string) (int64, int, error) {
+	m.mu.Lock()
+	user := m.userByName(username)
+	passwordHash, ok := m.passwords[username]
+	m.mu.Unlock()
+
+	if user == nil || !ok {
+		return -1, http.StatusUnauthorized, fmt.Errorf("Invalid username or password")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return -1, http.StatusUnauthorized, fmt.Errorf("Invalid username or password")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user.suspensionDate != nil {
+		return -1, http.StatusForbidden, fmt.Errorf("Account '%v' is suspended", username)
+	}
+	return user.id, http.StatusOK, nil
+}
+
+func (m *memory) QueryChangePassword(userId int64, username string, password string) (int, error) {
+	if err := database.ValidatePassword(password); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	passwordHash, err := database.HashPassword(password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.passwords[username]; ok {
+		m.passwords[username] = passwordHash
+	}
+	m.revokeFamilies(userId, time.Now().UTC())
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryCreateTokenFamily(userId int64, familyId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.families[familyId]; ok {
+		return fmt.Errorf("Failed to create session: UNIQUE constraint failed: TokenFamilies.id")
+	}
+	m.families[familyId] = &memoryFamily{userId: userId}
+	return nil
+}
+
+func (m *memory) QueryStoreRefreshToken(familyId string, tokenHash string, expirationDate time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.refreshTokens[tokenHash]; ok {
+		return fmt.Errorf("Failed to store refresh token: UNIQUE constraint failed: RefreshTokens.token_hash")
+	}
+	m.refreshTokens[tokenHash] = &memoryRefreshToken{familyId: familyId, expirationDate: expirationDate}
+	return nil
+}
+
+func (m *memory) QueryUseRefreshToken(tokenHash string) (int64, string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[tokenHash]
+	if !ok {
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Invalid refresh token")
+	}
+	family, ok := m.families[token.familyId]
+	if !ok {
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Invalid refresh token")
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case family.revocationDate != nil:
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Session has been revoked")
+	case token.usedDate != nil:
+		family.revocationDate = &now
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Refresh token has already been used, the session has been revoked")
+	case now.After(token.expirationDate):
+		return -1, "", http.StatusUnauthorized, fmt.Errorf("Refresh token has expired")
+	}
+
+	token.usedDate = &now
+	return family.userId, token.familyId, http.StatusOK, nil
+}
+
+func (m *memory) QueryRevokeTokenFamily(familyId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if family, ok := m.families[familyId]; ok && family.revocationDate == nil {
+		now := time.Now().UTC()
+		family.revocationDate = &now
+	}
+	return nil
+}
+
+func (m *memory) QueryRevokeAllUserTokens(userId int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeFamilies(userId, time.Now().UTC())
+	return nil
+}
+
+func (m *memory) QuerySessionUser(familyId string) (*types.Caller, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.families[familyId]
+	if !ok || family.revocationDate != nil {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Session has been revoked")
+	}
+	user, ok := m.users[family.userId]
+	if !ok {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Session has been revoked")
+	}
+	return m.caller(user), http.StatusOK, nil
+}
+
+func (m *memory) QueryCreatePersonalToken(userId int64, newToken *types.NewPersonalToken, tokenHash string) (*types.PersonalToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.personalTokens {
+		if token.tokenHash == tokenHash {
+			return nil, fmt.Errorf("Failed to create token '%v': UNIQUE constraint failed: PersonalAccessTokens.token_hash", newToken.Name)
+		}
+	}
+
+	token := types.PersonalToken{
+		ID:           m.nextId("PersonalAccessTokens"),
+		Name:         newToken.Name,
+		Scopes:       slices.Clone(newToken.Scopes),
+		CreationDate: time.Now().UTC(),
+	}
+	m.personalTokens[token.ID] = &memoryPersonalToken{userId: userId, tokenHash: tokenHash, token: token}
+
+	created := token
+	created.Scopes = newToken.Scopes
+	return &created, nil
+}
+
+func (m *memory) QueryPersonalTokens(userId int64) ([]types.PersonalToken, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []types.PersonalToken{}
+	for _, id := range sortedIds(m.personalTokens) {
+		stored := m.personalTokens[id]
+		if stored.userId != userId {
+			continue
+		}
+		token := stored.token
+		token.Scopes = slices.Clone(token.Scopes)
+		tokens = append(tokens, token)
+	}
+	return tokens, http.StatusOK, nil
+}
+
+func (m *memory) QueryDeletePersonalToken(userId int64, tokenId int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.personalTokens[tokenId]
+	if !ok || token.userId != userId {
+		return http.StatusNotFound, fmt.Errorf("Token with id '%v' not found", tokenId)
+	}
+	delete(m.personalTokens, tokenId)
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryPersonalTokenUser(tokenHash string) (*types.Caller, []string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.personalTokens {
+		if token.tokenHash != tokenHash {
+			continue
+		}
+		user, ok := m.users[token.userId]
+		if !ok {
+			break
+		}
+		now := time.Now().UTC()
+		token.token.LastUsed = &now
+		return m.caller(user), slices.Clone(token.token.Scopes), http.StatusOK, nil
+	}
+	return nil, nil, http.StatusUnauthorized, fmt.Errorf("Invalid personal access token")
+}
@@ -0,0 +1,316 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+func (m *memory) QueryComment(id int) (*types.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[int64(id)]
+	if !ok {
+		return nil, nil
+	}
+	found := *comment
+	database.HideDeletedComment(&found)
+	return &found, nil
+}
+
+// commentsWhere returns copies of the comments matching `match`, oldest
+// first, with the deleted ones hidden.
+func (m *memory) commentsWhere(match func(*types.Comment) bool) []types.Comment {
+	var comments []types.Comment
+	for _, id := range sortedIds(m.comments) {
+		if comment := m.comments[id]; match(comment) {
+			found := *comment
+			database.HideDeletedComment(&found)
+			comments = append(comments, found)
+		}
+	}
+	return comments
+}
+
+// linkedComments returns the comments linked to `target` in a link table.
+func (m *memory) linkedComments(links map[int64]int64, target int64) []types.Comment {
+	return m.commentsWhere(func(comment *types.Comment) bool {
+		linked, ok := links[comment.ID]
+		return ok && linked == target
+	})
+}
+
+func (m *memory) QueryCommentsByUserId(userId int) ([]types.Comment, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the SQL query matches project comments on the project id rather
+	// than the author, and lists them before the user's post comments
+	comments := m.commentsWhere(func(comment *types.Comment) bool {
+		linked, ok := m.projectComments[comment.ID]
+		return ok && linked == int64(userId) && comment.DeletedAt == nil
+	})
+	for _, comment := range m.commentsWhere(func(comment *types.Comment) bool {
+		_, onPost := m.postComments[comment.ID]
+		return onPost && comment.User == int64(userId) && comment.DeletedAt == nil
+	}) {
+		comments = append(comments, comment)
+	}
+	return comments, http.StatusOK, nil
+}
+
+func (m *memory) QueryCommentsByProjectId(id int) ([]types.Comment, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.linkedComments(m.projectComments, int64(id)), http.StatusOK, nil
+}
+
+func (m *memory) QueryCommentsByPostId(id int, page types.Page) ([]types.Comment, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comments, next := pageOf(m.linkedComments(m.postComments, int64(id)), page, commentCursor, byId)
+	return comments, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryCommentsByCommentId(id int) ([]types.Comment, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.commentsWhere(func(comment *types.Comment) bool {
+		return comment.ParentComment.Valid && comment.ParentComment.Int64 == int64(id)
+	}), http.StatusOK, nil
+}
+
+// createComment stores a new comment with no likes and returns its id.
+func (m *memory) createComment(comment types.Comment) int64 {
+	comment.ID = m.nextId("Comments")
+	comment.Likes = 0
+	comment.CreationDate = time.Now().UTC()
+	m.comments[comment.ID] = &comment
+	return comment.ID
+}
+
+func (m *memory) QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.createComment(comment)
+	m.postComments[id] = int64(postId)
+	m.notifyComment(database.NotifyPostComment, m.comments[id], int64(postId))
+	return id, nil
+}
+
+func (m *memory) QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.createComment(comment)
+	m.projectComments[id] = int64(projectId)
+	m.notifyComment(database.NotifyProjectComment, m.comments[id], int64(projectId))
+	return id, nil
+}
+
+func (m *memory) QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment.ParentComment = types.NullableInt64{}
+	comment.ParentComment.Int64, comment.ParentComment.Valid = int64(commentId), true
+	id := m.createComment(comment)
+	m.notifyComment(database.NotifyCommentReply, m.comments[id], int64(commentId))
+	return id, nil
+}
+
+func (m *memory) QueryDeleteComment(id int, deletedBy int64) (int16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.bury("comment", int64(id), time.Now().UTC(), deletedBy) {
+		return http.StatusNotFound, fmt.Errorf("Comment not found or already marked as deleted")
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUpdateCommentContent(id int, newContent string, editWindow time.Duration) (int16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[int64(id)]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("Comment not found")
+	}
+	now := time.Now().UTC()
+	if !database.EditWindowOpen(comment.CreationDate, now, editWindow) {
+		return http.StatusBadRequest, database.EditWindowClosedError(editWindow)
+	}
+
+	if m.saveRevision(m.commentRevisions, "CommentRevisions", int64(id), comment.Content, newContent, now) {
+		comment.Content = newContent
+		comment.EditedAt = &now
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryIsCommentEditable(strCommId string, editWindow time.Duration) (int, bool, error) {
+	commId, err := strconv.Atoi(strCommId)
+	if err != nil {
+		return http.StatusInternalServerError, false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[int64(commId)]
+	if !ok {
+		return http.StatusNotFound, false, fmt.Errorf("Comment not found")
+	}
+	return http.StatusOK, database.EditWindowOpen(comment.CreationDate, time.Now().UTC(), editWindow), nil
+}
+
+// commentLike resolves the user and comment of a like, where `parsing`
+// names the id in the error message the same way each SQL query does.
+func (m *memory) commentLike(username string, strCommentId string, parsing string) (pair, int, error) {
+	userId, err := m.userId(username)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
+	}
+	commentId, err := strconv.Atoi(strCommentId)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred parsing %v: %v", parsing, err)
+	}
+	return pair{int64(userId), int64(commentId)}, http.StatusOK, nil
+}
+
+func (m *memory) CreateCommentLike(username string, strCommentId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.commentLike(username, strCommentId, "user id")
+	if err != nil {
+		return httpCode, err
+	}
+	if m.commentLikes[like] {
+		return http.StatusOK, nil
+	}
+	m.commentLikes[like] = true
+	if comment, ok := m.comments[like[1]]; ok {
+		comment.Likes++
+	}
+	m.notify(database.NotifyCommentLike, like[0], like[1], nil, time.Now().UTC())
+	return http.StatusCreated, nil
+}
+
+func (m *memory) RemoveCommentLike(username string, strCommentId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.commentLike(username, strCommentId, "username id")
+	if err != nil {
+		return httpCode, err
+	}
+	if !m.commentLikes[like] {
+		return http.StatusNoContent, nil
+	}
+	delete(m.commentLikes, like)
+	if comment, ok := m.comments[like[1]]; ok {
+		comment.Likes--
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryCommentLike(username string, strCommId string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.commentLike(username, strCommId, "comment_id")
+	if err != nil {
+		return httpCode, false, err
+	}
+	return http.StatusOK, m.commentLikes[like], nil
+}
+
+func (m *memory) QueryCommentTreeByPostId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.commentTree(m.postComments, m.projectComments, int64(id), query)
+}
+
+func (m *memory) QueryCommentTreeByProjectId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.commentTree(m.projectComments, m.postComments, int64(id), query)
+}
+
+func (m *memory) QueryCommentThread(id int) (string, int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// walks up the replies until one is linked, like the SQL query
+	for commentId, ok := int64(id), true; ok; {
+		comment, found := m.comments[commentId]
+		if !found {
+			break
+		}
+		if postId, linked := m.postComments[commentId]; linked {
+			return "post", postId, http.StatusOK, nil
+		}
+		if projectId, linked := m.projectComments[commentId]; linked {
+			return "project", projectId, http.StatusOK, nil
+		}
+		commentId, ok = comment.ParentComment.Int64, comment.ParentComment.Valid
+	}
+	return "", 0, http.StatusNotFound, fmt.Errorf("Comment with id %v is not part of a thread", id)
+}
+
+// commentTree walks a thread level by level, the way the SQL query's
+// recursive CTE does, and leaves nesting and paging to BuildCommentTree.
+func (m *memory) commentTree(links, others map[int64]int64, target int64, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error) {
+	inThread := func(comment *types.Comment) bool {
+		if _, ok := others[comment.ID]; ok {
+			return false
+		}
+		linked, ok := links[comment.ID]
+		return !ok || linked == target
+	}
+
+	level := m.commentsWhere(func(comment *types.Comment) bool {
+		if query.Parent != 0 {
+			return comment.ParentComment.Valid && comment.ParentComment.Int64 == query.Parent && inThread(comment)
+		}
+		if linked, ok := links[comment.ID]; !ok || linked != target {
+			return false
+		}
+		linkedParent, ok := links[comment.ParentComment.Int64]
+		return !comment.ParentComment.Valid || !ok || linkedParent != target
+	})
+
+	var thread []database.ThreadComment
+	for depth := 0; len(level) > 0; depth++ {
+		parents := map[int64]bool{}
+		for _, comment := range level {
+			thread = append(thread, database.ThreadComment{Comment: comment, Depth: depth})
+			parents[comment.ID] = true
+		}
+		if depth == query.Depth {
+			break
+		}
+		level = m.commentsWhere(func(comment *types.Comment) bool {
+			return comment.ParentComment.Valid && parents[comment.ParentComment.Int64] && inThread(comment)
+		})
+	}
+
+	nodes, next, err := database.BuildCommentTree(thread, query)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	return nodes, next, http.StatusOK, nil
+}
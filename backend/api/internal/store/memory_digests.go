@@ -0,0 +1,139 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+type memoryEmailPreferences struct {
+	preferences      types.EmailPreferences
+	confirmationHash string
+	linkSent         time.Time
+	lastDigest       time.Time
+	lastNotification int64
+}
+
+// latestNotification returns the id of the latest notification a user got.
+func (m *memory) latestNotification(userId int64) int64 {
+	var latest int64
+	for id, notification := range m.notifications {
+		if notification.userId == userId {
+			latest = max(latest, id)
+		}
+	}
+	return latest
+}
+
+func (m *memory) QueryEmailPreferences(userId int64) (types.EmailPreferences, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.emailPreferences[userId]; ok {
+		return stored.preferences, http.StatusOK, nil
+	}
+	return types.EmailPreferences{Digest: types.DigestOff}, http.StatusOK, nil
+}
+
+func (m *memory) QuerySetEmailPreferences(userId int64, preferences types.EmailPreferences, confirmationHash string, now time.Time) (bool, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.emailPreferences[userId]
+	sameEmail := ok && stored.preferences.Email == preferences.Email
+	sendLink := !(sameEmail && stored.preferences.Confirmed)
+	if sendLink && ok && stored.linkSent.After(now.Add(-database.ConfirmationResendInterval)) {
+		if !sameEmail {
+			return false, http.StatusTooManyRequests, fmt.Errorf("A confirmation link was sent less than %v ago, try again later", database.ConfirmationResendInterval)
+		}
+		sendLink = false
+	}
+
+	if !ok {
+		stored = &memoryEmailPreferences{preferences: types.EmailPreferences{Digest: types.DigestOff}}
+		m.emailPreferences[userId] = stored
+	}
+	if !ok || (stored.preferences.Digest == types.DigestOff && preferences.Digest != types.DigestOff) {
+		stored.lastDigest = now
+		stored.lastNotification = m.latestNotification(userId)
+	}
+	stored.preferences.Email = preferences.Email
+	stored.preferences.Digest = preferences.Digest
+	if sendLink {
+		stored.confirmationHash = confirmationHash
+		stored.linkSent = now
+		stored.preferences.Confirmed = false
+	}
+	return sendLink, http.StatusOK, nil
+}
+
+func (m *memory) QueryConfirmEmail(confirmationHash string, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.emailPreferences {
+		if stored.confirmationHash != "" && stored.confirmationHash == confirmationHash {
+			stored.confirmationHash = ""
+			stored.preferences.Confirmed = true
+			return http.StatusOK, nil
+		}
+	}
+	return http.StatusNotFound, fmt.Errorf("The confirmation link is invalid or was already used")
+}
+
+func (m *memory) QueryDueDigests(frequency string, sentBefore time.Time, limit int) ([]types.Digest, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	digests := []types.Digest{}
+	for _, userId := range sortedIds(m.emailPreferences) {
+		stored := m.emailPreferences[userId]
+		user, ok := m.users[userId]
+		if !ok || stored.preferences.Digest != frequency || !stored.preferences.Confirmed || stored.lastDigest.After(sentBefore) {
+			continue
+		}
+		if len(digests) == limit {
+			break
+		}
+
+		digest := types.Digest{
+			User:     userId,
+			Username: user.user.Username,
+			Email:    stored.preferences.Email,
+			Since:    stored.lastNotification,
+			Through:  stored.lastNotification,
+		}
+		covered := func(n *memoryNotification) bool {
+			_, active := m.users[n.actorId]
+			return n.userId == userId && n.id > digest.Since && n.readDate == nil && active
+		}
+		for id, notification := range m.notifications {
+			if covered(notification) {
+				database.AddToDigest(&digest, notification.kind, 1)
+				digest.Through = max(digest.Through, id)
+			}
+		}
+		digest.Highlights = m.groupNotifications(covered)
+		slices.SortFunc(digest.Highlights, func(a, b types.Notification) int { return int(b.ID - a.ID) })
+		if len(digest.Highlights) > database.DigestHighlights {
+			digest.Highlights = digest.Highlights[:database.DigestHighlights]
+		}
+		digests = append(digests, digest)
+	}
+	return digests, http.StatusOK, nil
+}
+
+func (m *memory) QueryMarkDigestSent(userId int64, through int64, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.emailPreferences[userId]; ok {
+		stored.lastDigest = now
+		stored.lastNotification = through
+	}
+	return http.StatusOK, nil
+}
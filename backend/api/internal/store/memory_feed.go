@@ -0,0 +1,200 @@
+package store
+
+import (
+	"net/http"
+	"sort"
+
+	"backend/api/internal/database"
+	"backend/api/internal/trending"
+	"backend/api/internal/types"
+)
+
+// newestFirst, mostLikedFirst and byId are the orders the lists are sorted
+// in, comparing the cursors of two items the way the SQL queries do.
+func newestFirst(a, b types.Cursor) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.After(b.Time)
+	}
+	return a.ID > b.ID
+}
+
+func mostLikedFirst(a, b types.Cursor) bool {
+	if a.Likes != b.Likes {
+		return a.Likes > b.Likes
+	}
+	return a.ID > b.ID
+}
+
+func trendingFirst(a, b types.Cursor) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID > b.ID
+}
+
+func byId(a, b types.Cursor) bool { return a.ID < b.ID }
+
+func postCursor(post types.Post) types.Cursor {
+	return types.Cursor{Time: post.CreationDate, Likes: post.Likes, ID: post.ID}
+}
+
+func projectCursor(project types.Project) types.Cursor {
+	return types.Cursor{Time: project.CreationDate, Likes: project.Likes, ID: project.ID}
+}
+
+func commentCursor(comment types.Comment) types.Cursor {
+	return types.Cursor{Time: comment.CreationDate, Likes: comment.Likes, ID: comment.ID}
+}
+
+func idCursor(id int) types.Cursor { return types.Cursor{ID: int64(id)} }
+
+// pageOf sorts `items` in the order `less` gives their cursors and returns
+// the page of them that follows the page's cursor, along with the next cursor.
+func pageOf[T any](items []T, page types.Page, cursorOf func(T) types.Cursor, less func(a, b types.Cursor) bool) ([]T, *types.Cursor) {
+	sort.SliceStable(items, func(i, j int) bool { return less(cursorOf(items[i]), cursorOf(items[j])) })
+
+	start := 0
+	if page.After != nil {
+		start = sort.Search(len(items), func(i int) bool { return less(*page.After, cursorOf(items[i])) })
+	}
+	items = items[start:]
+	if page.Limit > 0 && len(items) > page.Limit+1 {
+		items = items[:page.Limit+1]
+	}
+	return database.CutPage(items, page, cursorOf)
+}
+
+func allPosts(*types.Post) bool { return true }
+
+func (m *memory) GetPostByTimeFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posts, next := pageOf(m.postsWhere(allPosts), page, postCursor, newestFirst)
+	return posts, next, http.StatusOK, nil
+}
+
+func (m *memory) GetPostByLikesFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posts, next := pageOf(m.postsWhere(allPosts), page, postCursor, mostLikedFirst)
+	return posts, next, http.StatusOK, nil
+}
+
+// postScore computes the trending score of a post from its likes and
+// comments. The SQL store keeps it up to date as they come in instead.
+func (m *memory) postScore(post types.Post) float64 {
+	var events []trending.Event
+	for like, date := range m.postLikes {
+		if like[1] == post.ID {
+			events = append(events, trending.Event{Weight: trending.LikeWeight, Time: date})
+		}
+	}
+	for commentId, postId := range m.postComments {
+		if comment, ok := m.comments[commentId]; ok && postId == post.ID {
+			events = append(events, trending.Event{Weight: trending.CommentWeight, Time: comment.CreationDate})
+		}
+	}
+	return trending.Score(post.CreationDate, events)
+}
+
+func (m *memory) GetPostByTrendingFeed(page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scores := map[int64]float64{}
+	posts := m.postsWhere(allPosts)
+	for _, post := range posts {
+		scores[post.ID] = m.postScore(post)
+	}
+	cursorOf := func(post types.Post) types.Cursor {
+		cursor := postCursor(post)
+		cursor.Score = scores[post.ID]
+		return cursor
+	}
+
+	posts, next := pageOf(posts, page, cursorOf, trendingFirst)
+	return posts, next, http.StatusOK, nil
+}
+
+func (m *memory) GetPostByFollowingFeed(userId int64, page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followed := func(post *types.Post) bool {
+		_, followsProject := m.projectFollows[pair{userId, post.Project}]
+		return m.userFollows[pair{userId, post.User}] || followsProject
+	}
+	posts, next := pageOf(m.postsWhere(followed), page, postCursor, newestFirst)
+	return posts, next, http.StatusOK, nil
+}
+
+func (m *memory) allProjects() []types.Project {
+	var projects []types.Project
+	for _, id := range sortedIds(m.projects) {
+		projects = append(projects, copyProject(m.projects[id]))
+	}
+	return projects
+}
+
+func (m *memory) GetProjectByTimeFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	projects, next := pageOf(m.allProjects(), page, projectCursor, newestFirst)
+	return projects, next, http.StatusOK, nil
+}
+
+func (m *memory) GetProjectByLikesFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	projects, next := pageOf(m.allProjects(), page, projectCursor, mostLikedFirst)
+	return projects, next, http.StatusOK, nil
+}
+
+// projectScore computes the trending score of a project from its likes,
+// comments and follows.
+func (m *memory) projectScore(project types.Project) float64 {
+	var events []trending.Event
+	for like, date := range m.projectLikes {
+		if like[1] == project.ID {
+			events = append(events, trending.Event{Weight: trending.LikeWeight, Time: date})
+		}
+	}
+	for commentId, projectId := range m.projectComments {
+		if comment, ok := m.comments[commentId]; ok && projectId == project.ID {
+			events = append(events, trending.Event{Weight: trending.CommentWeight, Time: comment.CreationDate})
+		}
+	}
+	for follow, date := range m.projectFollows {
+		if follow[1] == project.ID {
+			events = append(events, trending.Event{Weight: trending.FollowWeight, Time: date})
+		}
+	}
+	return trending.Score(project.CreationDate, events)
+}
+
+// scoredProjectCursor returns the cursor func of the trending order,
+// with the scores of `projects` computed up front.
+func (m *memory) scoredProjectCursor(projects []types.Project) func(types.Project) types.Cursor {
+	scores := map[int64]float64{}
+	for _, project := range projects {
+		scores[project.ID] = m.projectScore(project)
+	}
+	return func(project types.Project) types.Cursor {
+		cursor := projectCursor(project)
+		cursor.Score = scores[project.ID]
+		return cursor
+	}
+}
+
+func (m *memory) GetProjectByTrendingFeed(page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	projects := m.allProjects()
+	projects, next := pageOf(projects, page, m.scoredProjectCursor(projects), trendingFirst)
+	return projects, next, http.StatusOK, nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+func (m *memory) QuerySetUserPicture(username string, picture string) (string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.userByName(username)
+	if user == nil {
+		return "", http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", username)
+	}
+	previous := user.user.Picture
+	user.user.Picture = picture
+	return previous, http.StatusOK, nil
+}
+
+func (m *memory) QueryCreateProjectImage(image *types.ProjectImage, maxImages int) (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projects[image.Project]; !ok {
+		return -1, http.StatusNotFound, fmt.Errorf("Project with id '%v' not found", image.Project)
+	}
+	count := 0
+	for _, existing := range m.projectImages {
+		if existing.Project == image.Project {
+			count++
+		}
+	}
+	if count >= maxImages {
+		return -1, http.StatusBadRequest, fmt.Errorf("Projects can have at most %v images", maxImages)
+	}
+
+	id := m.nextId("ProjectImages")
+	m.projectImages[id] = &types.ProjectImage{
+		ID:           id,
+		Project:      image.Project,
+		URL:          image.URL,
+		ContentType:  image.ContentType,
+		Size:         image.Size,
+		CreationDate: time.Now().UTC(),
+	}
+	return id, http.StatusCreated, nil
+}
+
+func (m *memory) QueryProjectImages(projectId int64) ([]types.ProjectImage, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	images := []types.ProjectImage{}
+	for _, id := range sortedIds(m.projectImages) {
+		if image := m.projectImages[id]; image.Project == projectId {
+			images = append(images, *image)
+		}
+	}
+	return images, http.StatusOK, nil
+}
+
+func (m *memory) QueryProjectImage(id int64) (*types.ProjectImage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	image, ok := m.projectImages[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *image
+	return &found, nil
+}
+
+func (m *memory) QueryDeleteProjectImage(id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projectImages[id]; !ok {
+		return http.StatusNotFound, fmt.Errorf("Project image with id %v not found", id)
+	}
+	delete(m.projectImages, id)
+	return http.StatusOK, nil
+}
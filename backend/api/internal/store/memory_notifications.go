@@ -0,0 +1,223 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+type memoryNotification struct {
+	id       int64
+	userId   int64
+	actorId  int64
+	kind     string
+	target   int64
+	comment  *int64
+	creation time.Time
+	readDate *time.Time
+}
+
+// recipient mirrors the recipient queries of the SQL notification kinds,
+// returning who hears about `kind` being done to `target`.
+func (m *memory) recipient(kind string, target int64) (int64, bool) {
+	switch kind {
+	case database.NotifyPostLike, database.NotifyPostComment:
+		if post, ok := m.posts[target]; ok {
+			return post.User, true
+		}
+	case database.NotifyProjectLike, database.NotifyProjectFollow, database.NotifyProjectComment:
+		if project, ok := m.projects[target]; ok {
+			return project.Owner, true
+		}
+	case database.NotifyCommentLike, database.NotifyCommentReply:
+		if comment, ok := m.comments[target]; ok && comment.DeletedAt == nil {
+			return comment.User, true
+		}
+	case database.NotifyUserFollow:
+		if _, ok := m.users[target]; ok {
+			return target, true
+		}
+	}
+	return 0, false
+}
+
+// notify lets the owner of `target` know that `actor` acted on it, unless
+// they are the same user.
+func (m *memory) notify(kind string, actor int64, target int64, comment *int64, now time.Time) {
+	recipient, ok := m.recipient(kind, target)
+	if !ok || recipient == actor {
+		return
+	}
+	id := m.nextId("Notifications")
+	m.notifications[id] = &memoryNotification{
+		id:       id,
+		userId:   recipient,
+		actorId:  actor,
+		kind:     kind,
+		target:   target,
+		comment:  comment,
+		creation: now,
+	}
+}
+
+// notifyComment notifies about a new comment, which for replies goes to
+// the author of the comment replied to instead of the owner of `target`.
+func (m *memory) notifyComment(kind string, comment *types.Comment, target int64) {
+	id := comment.ID
+	if comment.ParentComment.Valid {
+		m.notify(database.NotifyCommentReply, comment.User, comment.ParentComment.Int64, &id, comment.CreationDate)
+		return
+	}
+	m.notify(kind, comment.User, target, &id, comment.CreationDate)
+}
+
+// dropNotifications deletes the notifications `about` picks out.
+func (m *memory) dropNotifications(about func(*memoryNotification) bool) {
+	for id, notification := range m.notifications {
+		if about(notification) {
+			delete(m.notifications, id)
+		}
+	}
+}
+
+type notificationGroup struct {
+	kind   string
+	target int64
+}
+
+func notificationCursor(notification types.Notification) types.Cursor {
+	return types.Cursor{ID: notification.ID}
+}
+
+func latestFirst(a, b types.Cursor) bool { return a.ID > b.ID }
+
+func (m *memory) QueryNotifications(userId int64, page types.Page) ([]types.Notification, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notifications := m.groupNotifications(func(n *memoryNotification) bool { return n.userId == userId })
+	notifications, next := pageOf(notifications, page, notificationCursor, latestFirst)
+	return notifications, next, http.StatusOK, nil
+}
+
+// groupNotifications groups the notifications `which` picks out by what
+// they are about, like the SQL notification queries, leaving out those of
+// deleted users. The groups are in no particular order.
+func (m *memory) groupNotifications(which func(*memoryNotification) bool) []types.Notification {
+	groups := map[notificationGroup]*types.Notification{}
+	actors := map[notificationGroup]map[int64]bool{}
+	for _, id := range sortedIds(m.notifications) {
+		notification := m.notifications[id]
+		actor, ok := m.users[notification.actorId]
+		if !which(notification) || !ok {
+			continue
+		}
+		key := notificationGroup{notification.kind, notification.target}
+		group, ok := groups[key]
+		if !ok {
+			group = &types.Notification{Kind: notification.kind, Target: notification.target, Read: true}
+			groups[key] = group
+			actors[key] = map[int64]bool{}
+		}
+		// ids ascend, so the last notification seen describes the group
+		group.ID = notification.id
+		group.Comment = notification.comment
+		group.Actor = actor.user.Username
+		group.CreationDate = notification.creation
+		group.Read = group.Read && notification.readDate != nil
+		actors[key][notification.actorId] = true
+	}
+
+	notifications := make([]types.Notification, 0, len(groups))
+	for key, group := range groups {
+		group.Actors = int64(len(actors[key]))
+		group.Message = database.NotificationMessage(group.Kind, group.Actor, group.Actors)
+		notifications = append(notifications, *group)
+	}
+	return notifications
+}
+
+func (m *memory) QueryUnreadNotificationCount(userId int64) (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unread := map[notificationGroup]bool{}
+	for _, notification := range m.notifications {
+		if _, ok := m.users[notification.actorId]; !ok {
+			continue
+		}
+		if notification.userId == userId && notification.readDate == nil {
+			unread[notificationGroup{notification.kind, notification.target}] = true
+		}
+	}
+	return int64(len(unread)), http.StatusOK, nil
+}
+
+func (m *memory) QueryMarkNotificationRead(userId int64, id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	marked, ok := m.notifications[id]
+	if !ok || marked.userId != userId {
+		return http.StatusNotFound, fmt.Errorf("Notification with id %v not found", id)
+	}
+	now := time.Now().UTC()
+	for _, notification := range m.notifications {
+		if notification.userId == userId && notification.kind == marked.kind && notification.target == marked.target &&
+			notification.id <= id && notification.readDate == nil {
+			notification.readDate = &now
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryMarkAllNotificationsRead(userId int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, notification := range m.notifications {
+		if notification.userId == userId && notification.readDate == nil {
+			notification.readDate = &now
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryLastNotificationId() (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastIds["Notifications"], http.StatusOK, nil
+}
+
+func (m *memory) QueryNotificationsAfter(after int64) ([]types.SentNotification, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := []types.SentNotification{}
+	for _, id := range sortedIds(m.notifications) {
+		notification := m.notifications[id]
+		actor, ok := m.users[notification.actorId]
+		if id <= after || !ok {
+			continue
+		}
+		sent = append(sent, types.SentNotification{
+			Recipient: notification.userId,
+			Notification: types.Notification{
+				ID:           id,
+				Kind:         notification.kind,
+				Target:       notification.target,
+				Comment:      notification.comment,
+				Actor:        actor.user.Username,
+				Actors:       1,
+				Message:      database.NotificationMessage(notification.kind, actor.user.Username, 1),
+				CreationDate: notification.creation,
+			},
+		})
+	}
+	return sent, http.StatusOK, nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+func (m *memory) QueryPost(id int) (*types.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[int64(id)]
+	if !ok {
+		return nil, nil
+	}
+	found := *post
+	return &found, nil
+}
+
+func (m *memory) QueryCreatePost(post *types.Post) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := *post
+	created.ID = m.nextId("Posts")
+	created.CreationDate = time.Now().UTC()
+	m.posts[created.ID] = &created
+	return created.ID, nil
+}
+
+func (m *memory) QueryDeletePost(id int, deletedBy int64) (int16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.bury("post", int64(id), time.Now().UTC(), deletedBy) {
+		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUpdatePost(id int, updatedData map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[int64(id)]
+	if !ok {
+		return fmt.Errorf("No post found with id `%d` to update", id)
+	}
+
+	updated := *post
+	if err := applyUpdate(&updated, updatedData); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if m.saveRevision(m.postRevisions, "PostRevisions", int64(id), post.Content, updated.Content, now) {
+		updated.EditedAt = &now
+	}
+	m.posts[int64(id)] = &updated
+	return nil
+}
+
+// postsWhere returns copies of the posts matching `match`, oldest first.
+func (m *memory) postsWhere(match func(*types.Post) bool) []types.Post {
+	var posts []types.Post
+	for _, id := range sortedIds(m.posts) {
+		if post := m.posts[id]; match(post) {
+			posts = append(posts, *post)
+		}
+	}
+	return posts
+}
+
+func (m *memory) QueryPostsByUserId(userId int, page types.Page) ([]types.Post, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posts, next := pageOf(m.postsWhere(func(post *types.Post) bool { return post.User == int64(userId) }), page, postCursor, byId)
+	return posts, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryPostsByProjectId(projId int) ([]types.Post, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posts := m.postsWhere(func(post *types.Post) bool { return post.Project == int64(projId) })
+	if posts == nil {
+		posts = []types.Post{}
+	}
+	return posts, http.StatusOK, nil
+}
+
+// postLike resolves the user and post of a like, where `parsing` names
+// the id in the error message the same way each SQL query does.
+func (m *memory) postLike(username string, strPostId string, parsing string) (pair, int, error) {
+	userId, err := m.userId(username)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
+	}
+	postId, err := strconv.Atoi(strPostId)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred parsing %v: %v", parsing, err)
+	}
+	return pair{int64(userId), int64(postId)}, http.StatusOK, nil
+}
+
+func (m *memory) CreatePostLike(username string, strPostId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.postLike(username, strPostId, "post_id id")
+	if err != nil {
+		return httpCode, err
+	}
+	post, ok := m.posts[like[1]]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("Post ID %d does not exist", like[1])
+	}
+	if _, ok := m.postLikes[like]; ok {
+		return http.StatusOK, nil
+	}
+	m.postLikes[like] = time.Now().UTC()
+	post.Likes++
+	m.notify(database.NotifyPostLike, like[0], like[1], nil, m.postLikes[like])
+	return http.StatusCreated, nil
+}
+
+func (m *memory) RemovePostLike(username string, strPostId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.postLike(username, strPostId, "username id")
+	if err != nil {
+		return httpCode, err
+	}
+	if _, ok := m.postLikes[like]; !ok {
+		return http.StatusNoContent, nil
+	}
+	delete(m.postLikes, like)
+	if post, ok := m.posts[like[1]]; ok {
+		post.Likes--
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryPostLike(username string, strPostId string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.postLike(username, strPostId, "post_id")
+	if err != nil {
+		return httpCode, false, err
+	}
+	_, liked := m.postLikes[like]
+	return http.StatusOK, liked, nil
+}
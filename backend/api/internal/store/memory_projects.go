@@ -0,0 +1,349 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+func copyProject(project *types.Project) types.Project {
+	copied := *project
+	copied.Links = slices.Clone(project.Links)
+	copied.Tags = slices.Clone(project.Tags)
+	return copied
+}
+
+func (m *memory) QueryProject(id int) (*types.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	project, ok := m.projects[int64(id)]
+	if !ok {
+		return nil, nil
+	}
+	found := copyProject(project)
+	return &found, nil
+}
+
+func (m *memory) QueryProjectsByUserId(userId int) ([]types.Project, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var projects []types.Project
+	for _, id := range sortedIds(m.projects) {
+		if project := m.projects[id]; project.Owner == int64(userId) {
+			projects = append(projects, copyProject(project))
+		}
+	}
+	return projects, http.StatusOK, nil
+}
+
+// matchesTags mirrors the tag conditions of QueryProjects.
+func matchesTags(project types.Project, filter types.ProjectFilter) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+	has := map[string]bool{}
+	for _, tag := range project.Tags {
+		has[strings.ToLower(tag)] = true
+	}
+	for _, tag := range filter.Tags {
+		found := has[strings.ToLower(tag)]
+		if found && !filter.MatchAllTags {
+			return true
+		} else if !found && filter.MatchAllTags {
+			return false
+		}
+	}
+	return filter.MatchAllTags
+}
+
+func (m *memory) QueryProjects(filter types.ProjectFilter, page types.Page) ([]types.Project, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var projects []types.Project
+	for _, project := range m.allProjects() {
+		if matchesTags(project, filter) &&
+			(filter.Status == nil || project.Status == *filter.Status) &&
+			(filter.Owner == 0 || project.Owner == filter.Owner) {
+			projects = append(projects, project)
+		}
+	}
+
+	var next *types.Cursor
+	switch filter.Sort {
+	case "", "time":
+		projects, next = pageOf(projects, page, projectCursor, newestFirst)
+	case "likes":
+		projects, next = pageOf(projects, page, projectCursor, mostLikedFirst)
+	case "trending":
+		projects, next = pageOf(projects, page, m.scoredProjectCursor(projects), trendingFirst)
+	default:
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("Invalid sort '%v', expected time, likes or trending", filter.Sort)
+	}
+	return projects, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryTagCounts() ([]types.TagCount, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage := map[string]int64{}
+	for _, project := range m.projects {
+		for _, tag := range slices.Compact(slices.Sorted(slices.Values(project.Tags))) {
+			usage[tag]++
+		}
+	}
+
+	counts := []types.TagCount{}
+	for tag, count := range usage {
+		counts = append(counts, types.TagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b types.TagCount) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return counts, http.StatusOK, nil
+}
+
+func (m *memory) QueryCreateProject(proj *types.Project) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := copyProject(proj)
+	created.ID = m.nextId("Projects")
+	created.Likes = 0
+	created.CreationDate = time.Now().UTC()
+	m.projects[created.ID] = &created
+	return created.ID, nil
+}
+
+func (m *memory) QueryDeleteProject(id int, deletedBy int64) (int16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.bury("project", int64(id), time.Now().UTC(), deletedBy) {
+		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUpdateProject(id int, updatedData map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	project, ok := m.projects[int64(id)]
+	if !ok {
+		return fmt.Errorf("No project found with id `%d` to update", id)
+	}
+
+	updated := copyProject(project)
+	if err := applyUpdate(&updated, updatedData); err != nil {
+		return err
+	}
+	m.projects[int64(id)] = &updated
+	return nil
+}
+
+func (m *memory) QueryReassignProject(projectId int64, newOwner int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[newOwner]; !ok {
+		return http.StatusBadRequest, fmt.Errorf("User with id '%v' not found", newOwner)
+	}
+	project, ok := m.projects[projectId]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("Project with id '%v' not found", projectId)
+	}
+	project.Owner = newOwner
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryGetProjectFollowers(projectID int, page types.Page) ([]int, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids, next := pageOf(m.existingUsers(followIds(m.projectFollows, int64(projectID), 1)), page, idCursor, byId)
+	return ids, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryGetProjectFollowersUsernames(projectID int, page types.Page) ([]string, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids, next := pageOf(m.existingUsers(followIds(m.projectFollows, int64(projectID), 1)), page, idCursor, byId)
+	return m.usernames(ids), next, http.StatusOK, nil
+}
+
+// followedProjects returns the ids of the existing projects a user follows.
+func (m *memory) followedProjects(userId int) []int {
+	var ids []int
+	for _, id := range followIds(m.projectFollows, int64(userId), 0) {
+		if _, ok := m.projects[int64(id)]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *memory) QueryGetProjectFollowing(username string) ([]int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error fetching user id from username: %v", err)
+	}
+	return m.followedProjects(userId), http.StatusOK, nil
+}
+
+func (m *memory) QueryGetProjectFollowingNames(username string) ([]string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error fetching user id from username: %v", err)
+	}
+
+	var names []string
+	for _, id := range m.followedProjects(userId) {
+		names = append(names, m.projects[int64(id)].Name)
+	}
+	return names, http.StatusOK, nil
+}
+
+// projectFollow resolves the user and project of a follow or unfollow,
+// with the same errors CreateNewProjectFollow and RemoveProjectFollow return.
+func (m *memory) projectFollow(username string, projectID string) (pair, int, error) {
+	userId, err := m.userId(username)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", username)
+	}
+	intProjectID, err := strconv.Atoi(projectID)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred parsing project id: %v", projectID)
+	}
+	if _, ok := m.projects[int64(intProjectID)]; !ok {
+		return pair{}, http.StatusNotFound, fmt.Errorf("Project with id %v does not exist", intProjectID)
+	}
+	return pair{int64(userId), int64(intProjectID)}, http.StatusOK, nil
+}
+
+func (m *memory) CreateNewProjectFollow(username string, projectID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follow, httpCode, err := m.projectFollow(username, projectID)
+	if err != nil {
+		return httpCode, err
+	}
+	if _, ok := m.projectFollows[follow]; ok {
+		return http.StatusConflict, fmt.Errorf("User is already following this project")
+	}
+	m.projectFollows[follow] = time.Now().UTC()
+	m.notify(database.NotifyProjectFollow, follow[0], follow[1], nil, m.projectFollows[follow])
+	return http.StatusOK, nil
+}
+
+func (m *memory) RemoveProjectFollow(username string, projectID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follow, httpCode, err := m.projectFollow(username, projectID)
+	if err != nil {
+		return httpCode, err
+	}
+	if _, ok := m.projectFollows[follow]; !ok {
+		return http.StatusConflict, fmt.Errorf("User is not following this project")
+	}
+	delete(m.projectFollows, follow)
+	return http.StatusOK, nil
+}
+
+// projectLike resolves the user and project of a like, where `parsing`
+// names the id in the error message the same way each SQL query does.
+func (m *memory) projectLike(username string, strProjId string, parsing string) (pair, int, error) {
+	userId, err := m.userId(username)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred getting id for username: %v", err)
+	}
+	projId, err := strconv.Atoi(strProjId)
+	if err != nil {
+		return pair{}, http.StatusInternalServerError, fmt.Errorf("An error occurred parsing %v: %v", parsing, err)
+	}
+	if _, ok := m.projects[int64(projId)]; !ok {
+		return pair{}, http.StatusNotFound, fmt.Errorf("Project with id %v does not exist", projId)
+	}
+	return pair{int64(userId), int64(projId)}, http.StatusOK, nil
+}
+
+func (m *memory) CreateProjectLike(username string, strProjId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.projectLike(username, strProjId, "proj_id")
+	if err != nil {
+		return httpCode, err
+	}
+	if _, ok := m.projectLikes[like]; ok {
+		return http.StatusOK, nil
+	}
+	m.projectLikes[like] = time.Now().UTC()
+	m.projects[like[1]].Likes++
+	m.notify(database.NotifyProjectLike, like[0], like[1], nil, m.projectLikes[like])
+	return http.StatusCreated, nil
+}
+
+func (m *memory) RemoveProjectLike(username string, strProjId string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.projectLike(username, strProjId, "username id")
+	if err != nil {
+		return httpCode, err
+	}
+	if _, ok := m.projectLikes[like]; !ok {
+		return http.StatusNoContent, nil
+	}
+	delete(m.projectLikes, like)
+	m.projects[like[1]].Likes--
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryProjectLike(username string, strProjId string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	like, httpCode, err := m.projectLike(username, strProjId, "proj_id")
+	if err != nil {
+		return httpCode, false, err
+	}
+	_, liked := m.projectLikes[like]
+	return http.StatusOK, liked, nil
+}
+
+func (m *memory) QueryProjectFollowerCounts() ([]types.Suggestion, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followers := map[int64]int64{}
+	for follow := range m.projectFollows {
+		followers[follow[1]]++
+	}
+	suggestions := []types.Suggestion{}
+	for _, id := range sortedIds(m.projects) {
+		suggestions = append(suggestions, types.Suggestion{ID: id, Name: m.projects[id].Name, Followers: followers[id]})
+	}
+	return suggestions, http.StatusOK, nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/types"
+)
+
+// saveRevision keeps what `id` said before its content is replaced with
+// `content`, unless the new content is the same, and reports whether it was.
+func (m *memory) saveRevision(revisions map[int64][]types.Revision, table string, id int64, previous string, content string, now time.Time) bool {
+	if previous == content {
+		return false
+	}
+	revisions[id] = append(revisions[id], types.Revision{ID: m.nextId(table), Content: previous, EditedAt: now})
+	return true
+}
+
+func (m *memory) QueryPostRevisions(id int) ([]types.Revision, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.posts[int64(id)]; !ok {
+		return []types.Revision{}, http.StatusOK, nil
+	}
+	return latestRevisionsFirst(m.postRevisions[int64(id)]), http.StatusOK, nil
+}
+
+func (m *memory) QueryCommentRevisions(id int) ([]types.Revision, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if comment, ok := m.comments[int64(id)]; !ok || comment.DeletedAt != nil {
+		return []types.Revision{}, http.StatusOK, nil
+	}
+	return latestRevisionsFirst(m.commentRevisions[int64(id)]), http.StatusOK, nil
+}
+
+func latestRevisionsFirst(revisions []types.Revision) []types.Revision {
+	copied := append([]types.Revision{}, revisions...)
+	slices.Reverse(copied)
+	return copied
+}
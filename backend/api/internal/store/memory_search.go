@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+// searchable is an item as the full text indexes see it.
+type searchable struct {
+	kind  string
+	id    int64
+	title string
+	body  string
+}
+
+func (m *memory) searchables() []searchable {
+	var items []searchable
+	for _, id := range sortedIds(m.users) {
+		items = append(items, searchable{"user", id, m.users[id].user.Username, m.users[id].user.Bio})
+	}
+	for _, id := range sortedIds(m.projects) {
+		project := m.projects[id]
+		items = append(items, searchable{"project", id, project.Name, project.Description + " " + strings.Join(project.Tags, " ")})
+	}
+	for _, id := range sortedIds(m.posts) {
+		items = append(items, searchable{"post", id, "", m.posts[id].Content})
+	}
+	for _, id := range sortedIds(m.comments) {
+		if comment := m.comments[id]; comment.User != -1 {
+			items = append(items, searchable{"comment", id, "", comment.Content})
+		}
+	}
+	return items
+}
+
+// QuerySearch matches every term as a prefix of a word, like the SQL
+// indexes, but ranks by counting matched words rather than by bm25.
+func (m *memory) QuerySearch(text string, kind string, limit int) ([]types.SearchResult, int, error) {
+	terms, err := database.SearchTerms(text)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if kind != "" && !slices.Contains(database.SearchKinds(), kind) {
+		return nil, http.StatusBadRequest, fmt.Errorf("Invalid kind '%v', expected %v", kind, strings.Join(database.SearchKinds(), ", "))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prefixes []string
+	for _, term := range terms {
+		prefixes = append(prefixes, regexp.QuoteMeta(term))
+	}
+	word := regexp.MustCompile(`(?i)\b(` + strings.Join(prefixes, "|") + `)\w*`)
+
+	results := []types.SearchResult{}
+	for _, item := range m.searchables() {
+		if kind != "" && item.kind != kind {
+			continue
+		}
+		text := strings.ToLower(item.title + " " + item.body)
+		matchesAll := true
+		for _, term := range terms {
+			if !regexp.MustCompile(`\b` + regexp.QuoteMeta(term)).MatchString(text) {
+				matchesAll = false
+				break
+			}
+		}
+		if !matchesAll {
+			continue
+		}
+
+		rank := 4*float64(len(word.FindAllString(item.title, -1))) + float64(len(word.FindAllString(item.body, -1)))
+		snippet := item.body
+		if !word.MatchString(snippet) {
+			snippet = item.title
+		}
+		results = append(results, types.SearchResult{
+			Kind:    item.kind,
+			ID:      item.id,
+			Title:   item.title,
+			Snippet: word.ReplaceAllString(snippet, "<mark>$0</mark>"),
+			Rank:    rank,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, http.StatusOK, nil
+}
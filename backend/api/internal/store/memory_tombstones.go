@@ -0,0 +1,366 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+type tombstoneKey struct {
+	kind string
+	id   int64
+}
+
+// a deleted user, project or post along with when and by whom it was deleted
+type memoryTombstone struct {
+	deletedAt time.Time
+	deletedBy int64
+	owner     int64
+	// a *memoryUser, *types.Project or *types.Post
+	row interface{}
+}
+
+// projectOf looks up a project whether it is deleted or not.
+func (m *memory) projectOf(id int64) *types.Project {
+	if project, ok := m.projects[id]; ok {
+		return project
+	}
+	if tomb, ok := m.tombstones[tombstoneKey{"project", id}]; ok {
+		return tomb.row.(*types.Project)
+	}
+	return nil
+}
+
+// postOf looks up a post whether it is deleted or not.
+func (m *memory) postOf(id int64) *types.Post {
+	if post, ok := m.posts[id]; ok {
+		return post
+	}
+	if tomb, ok := m.tombstones[tombstoneKey{"post", id}]; ok {
+		return tomb.row.(*types.Post)
+	}
+	return nil
+}
+
+// the rules below mirror the cascades of the SQL tombstones, deciding
+// what is deleted and restored along with item `id` of `kind`
+
+func (m *memory) projectGoesWith(kind string, id int64, project *types.Project) bool {
+	switch kind {
+	case "user":
+		return project.Owner == id
+	case "project":
+		return project.ID == id
+	}
+	return false
+}
+
+func (m *memory) postGoesWith(kind string, id int64, post *types.Post) bool {
+	switch kind {
+	case "user":
+		if post.User == id {
+			return true
+		}
+		project := m.projectOf(post.Project)
+		return project != nil && project.Owner == id
+	case "project":
+		return post.Project == id
+	case "post":
+		return post.ID == id
+	}
+	return false
+}
+
+func (m *memory) commentGoesWith(kind string, id int64, comment *types.Comment) bool {
+	switch kind {
+	case "comment":
+		return comment.ID == id
+	case "user":
+		if comment.User == id {
+			return true
+		}
+	}
+	if postId, ok := m.postComments[comment.ID]; ok {
+		if post := m.postOf(postId); post != nil && m.postGoesWith(kind, id, post) {
+			return true
+		}
+	}
+	if projectId, ok := m.projectComments[comment.ID]; ok {
+		if project := m.projectOf(projectId); project != nil && m.projectGoesWith(kind, id, project) {
+			return true
+		}
+	}
+	return false
+}
+
+// bury deletes an item along with everything that goes with it, dating
+// them all to `now` and recording `deletedBy` as who deleted them. It
+// returns false if the item does not exist or was already deleted.
+func (m *memory) bury(kind string, id int64, now time.Time, deletedBy int64) bool {
+	switch kind {
+	case "user":
+		user, ok := m.users[id]
+		if !ok {
+			return false
+		}
+		delete(m.users, id)
+		m.tombstones[tombstoneKey{kind, id}] = &memoryTombstone{deletedAt: now, deletedBy: deletedBy, owner: id, row: user}
+	case "project":
+		if _, ok := m.projects[id]; !ok {
+			return false
+		}
+	case "post":
+		if _, ok := m.posts[id]; !ok {
+			return false
+		}
+	case "comment":
+		if comment, ok := m.comments[id]; !ok || comment.DeletedAt != nil {
+			return false
+		}
+	}
+
+	for _, comment := range m.comments {
+		if comment.DeletedAt == nil && m.commentGoesWith(kind, id, comment) {
+			deletedAt := now
+			comment.DeletedAt = &deletedAt
+			m.commentDeleters[comment.ID] = deletedBy
+		}
+	}
+	for postId, post := range m.posts {
+		if m.postGoesWith(kind, id, post) {
+			delete(m.posts, postId)
+			m.tombstones[tombstoneKey{"post", postId}] = &memoryTombstone{deletedAt: now, deletedBy: deletedBy, owner: post.User, row: post}
+		}
+	}
+	for projectId, project := range m.projects {
+		if m.projectGoesWith(kind, id, project) {
+			delete(m.projects, projectId)
+			m.tombstones[tombstoneKey{"project", projectId}] = &memoryTombstone{deletedAt: now, deletedBy: deletedBy, owner: project.Owner, row: project}
+		}
+	}
+	return true
+}
+
+// usernameTaken reports whether a username belongs to anyone, including
+// deleted users who have not been purged yet.
+func (m *memory) usernameTaken(username string) bool {
+	if m.userByName(username) != nil {
+		return true
+	}
+	return m.buriedUser(username) != nil
+}
+
+func (m *memory) buriedUser(username string) *memoryUser {
+	for key, tomb := range m.tombstones {
+		if key.kind == "user" && tomb.row.(*memoryUser).user.Username == username {
+			return tomb.row.(*memoryUser)
+		}
+	}
+	return nil
+}
+
+func (m *memory) QueryTombstone(kind string, id int64) (*types.Tombstone, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !database.KnownTombstoneKind(kind) {
+		return nil, fmt.Errorf("Unknown kind '%v'", kind)
+	}
+	return m.tombstone(kind, id), nil
+}
+
+func (m *memory) tombstone(kind string, id int64) *types.Tombstone {
+	if kind == "comment" {
+		comment, ok := m.comments[id]
+		if !ok || comment.DeletedAt == nil {
+			return nil
+		}
+		return &types.Tombstone{Kind: kind, ID: id, Owner: comment.User, DeletedAt: *comment.DeletedAt, DeletedBy: m.commentDeleters[id]}
+	}
+	tomb, ok := m.tombstones[tombstoneKey{kind, id}]
+	if !ok {
+		return nil
+	}
+	return &types.Tombstone{Kind: kind, ID: id, Owner: tomb.owner, DeletedAt: tomb.deletedAt, DeletedBy: tomb.deletedBy}
+}
+
+func (m *memory) QueryUserTombstone(username string) (*types.Tombstone, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.buriedUser(username)
+	if user == nil {
+		return nil, nil
+	}
+	return m.tombstone("user", user.id), nil
+}
+
+func (m *memory) QueryRestore(kind string, id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !database.KnownTombstoneKind(kind) {
+		return http.StatusBadRequest, fmt.Errorf("Unknown kind '%v'", kind)
+	}
+	tomb := m.tombstone(kind, id)
+	if tomb == nil {
+		return http.StatusNotFound, fmt.Errorf("Deleted %v with id %v not found", kind, id)
+	}
+
+	for _, comment := range m.comments {
+		if comment.DeletedAt != nil && comment.DeletedAt.Equal(tomb.DeletedAt) && m.commentGoesWith(kind, id, comment) {
+			comment.DeletedAt = nil
+			delete(m.commentDeleters, comment.ID)
+		}
+	}
+	for key, buried := range m.tombstones {
+		if !buried.deletedAt.Equal(tomb.DeletedAt) {
+			continue
+		}
+		switch row := buried.row.(type) {
+		case *memoryUser:
+			if kind != "user" || key.id != id {
+				continue
+			}
+			m.users[key.id] = row
+		case *types.Project:
+			if !m.projectGoesWith(kind, id, row) {
+				continue
+			}
+			m.projects[key.id] = row
+		case *types.Post:
+			if !m.postGoesWith(kind, id, row) {
+				continue
+			}
+			m.posts[key.id] = row
+		}
+		delete(m.tombstones, key)
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryPurge(before time.Time) (int64, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	var uploads []string
+	replied := map[int64]bool{}
+	for _, comment := range m.comments {
+		if comment.ParentComment.Valid {
+			replied[comment.ParentComment.Int64] = true
+		}
+	}
+	for id, comment := range m.comments {
+		if comment.DeletedAt == nil || !comment.DeletedAt.Before(before) {
+			continue
+		}
+		delete(m.commentRevisions, id)
+		m.dropNotifications(func(n *memoryNotification) bool {
+			if n.comment != nil && *n.comment == id {
+				return true
+			}
+			return (n.kind == database.NotifyCommentLike || n.kind == database.NotifyCommentReply) && n.target == id
+		})
+		if replied[id] {
+			// kept to hold its thread together, but what it said is gone
+			comment.Content = ""
+			comment.EditedAt = nil
+			continue
+		}
+		delete(m.comments, id)
+		delete(m.commentDeleters, id)
+		delete(m.postComments, id)
+		delete(m.projectComments, id)
+		deletePairs(m.commentLikes, id, 1)
+		purged++
+	}
+
+	for key, tomb := range m.tombstones {
+		if !tomb.deletedAt.Before(before) {
+			continue
+		}
+		delete(m.tombstones, key)
+		purged++
+		switch row := tomb.row.(type) {
+		case *types.Post:
+			delete(m.postRevisions, key.id)
+			deletePairs(m.postLikes, key.id, 1)
+			deleteLinks(m.postComments, key.id)
+			m.dropNotifications(func(n *memoryNotification) bool {
+				return (n.kind == database.NotifyPostLike || n.kind == database.NotifyPostComment) && n.target == key.id
+			})
+		case *types.Project:
+			deletePairs(m.projectLikes, key.id, 1)
+			deletePairs(m.projectFollows, key.id, 1)
+			deleteLinks(m.projectComments, key.id)
+			m.dropNotifications(func(n *memoryNotification) bool {
+				switch n.kind {
+				case database.NotifyProjectLike, database.NotifyProjectFollow, database.NotifyProjectComment:
+					return n.target == key.id
+				}
+				return false
+			})
+			m.dropWebhooks(func(webhook *types.Webhook) bool { return webhook.Project == key.id })
+			for id, image := range m.projectImages {
+				if image.Project == key.id {
+					uploads = append(uploads, image.URL)
+					delete(m.projectImages, id)
+				}
+			}
+		case *memoryUser:
+			if row.user.Picture != "" {
+				uploads = append(uploads, row.user.Picture)
+			}
+			delete(m.passwords, row.user.Username)
+			for tokenId, token := range m.personalTokens {
+				if token.userId == key.id {
+					delete(m.personalTokens, tokenId)
+				}
+			}
+			for familyId, family := range m.families {
+				if family.userId != key.id {
+					continue
+				}
+				for tokenHash, token := range m.refreshTokens {
+					if token.familyId == familyId {
+						delete(m.refreshTokens, tokenHash)
+					}
+				}
+				delete(m.families, familyId)
+			}
+			delete(m.emailPreferences, key.id)
+			deletePairs(m.userFollows, key.id, 0)
+			deletePairs(m.userFollows, key.id, 1)
+			deletePairs(m.projectFollows, key.id, 0)
+			deletePairs(m.projectLikes, key.id, 0)
+			deletePairs(m.postLikes, key.id, 0)
+			deletePairs(m.commentLikes, key.id, 0)
+			m.dropNotifications(func(n *memoryNotification) bool {
+				return n.userId == key.id || n.actorId == key.id
+			})
+		}
+	}
+	return purged, uploads, nil
+}
+
+// deletePairs deletes the follows or likes whose side `side` is `id`.
+func deletePairs[T any](pairs map[pair]T, id int64, side int) {
+	for p := range pairs {
+		if p[side] == id {
+			delete(pairs, p)
+		}
+	}
+}
+
+// deleteLinks deletes the comment links pointing at `target`.
+func deleteLinks(links map[int64]int64, target int64) {
+	for comment, linked := range links {
+		if linked == target {
+			delete(links, comment)
+		}
+	}
+}
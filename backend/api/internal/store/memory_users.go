@@ -0,0 +1,301 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/auth"
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+func (m *memory) GetUsernameById(id int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[id]; ok {
+		return user.user.Username, nil
+	}
+	return "", nil
+}
+
+func (m *memory) GetUserIdByUsername(username string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.userId(username)
+}
+
+func (m *memory) QueryUsername(username string) (*types.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.queryUsername(username), nil
+}
+
+func (m *memory) queryUsername(username string) *types.User {
+	user := m.userByName(username)
+	if user == nil {
+		return nil
+	}
+	found := user.user
+	found.Links = slices.Clone(found.Links)
+	return &found
+}
+
+func (m *memory) QueryCreateUser(user *types.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createUser(user)
+}
+
+func (m *memory) createUser(user *types.User) error {
+	if m.usernameTaken(user.Username) {
+		return fmt.Errorf("Failed to create user '%v': UNIQUE constraint failed: Users.username", user.Username)
+	}
+
+	created := *user
+	created.Links = slices.Clone(user.Links)
+	created.CreationDate = time.Now().UTC()
+	id := m.nextId("Users")
+	m.users[id] = &memoryUser{id: id, user: created, role: auth.RoleUser}
+	return nil
+}
+
+func (m *memory) QueryDeleteUser(username string, deletedBy int64) (int16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.userByName(username)
+	if user == nil {
+		return http.StatusNotFound, fmt.Errorf("Deletion did not affect any records")
+	}
+
+	now := time.Now().UTC()
+	m.revokeFamilies(user.id, now)
+	m.bury("user", user.id, now, deletedBy)
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUpdateUser(username string, updatedData map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newUsername, usernameExists := updatedData["username"]
+	usernameStr, parseOk := newUsername.(string)
+
+	if usernameExists && parseOk && usernameStr == "" {
+		return fmt.Errorf("Updated username cannot be empty!")
+	}
+
+	user := m.userByName(username)
+	if user == nil {
+		return fmt.Errorf("No user found with username '%s' to update", username)
+	}
+	if usernameExists && parseOk && usernameStr != username && m.usernameTaken(usernameStr) {
+		return fmt.Errorf("Error checking rows affected: UNIQUE constraint failed: Users.username")
+	}
+
+	updated := user.user
+	if err := applyUpdate(&updated, updatedData); err != nil {
+		return err
+	}
+	user.user = updated
+
+	if usernameExists && parseOk && usernameStr != username {
+		if password, ok := m.passwords[username]; ok {
+			delete(m.passwords, username)
+			m.passwords[usernameStr] = password
+		}
+	}
+	return nil
+}
+
+// followIds returns the ids on one side of a follow table, where `from`
+// picks which side is matched against id.
+func followIds[T any](follows map[pair]T, id int64, from int) []int {
+	var ids []int
+	for follow := range follows {
+		if follow[from] == id {
+			ids = append(ids, int(follow[1-from]))
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (m *memory) usernames(ids []int) []string {
+	var usernames []string
+	for _, id := range ids {
+		if user, ok := m.users[int64(id)]; ok {
+			usernames = append(usernames, user.user.Username)
+		}
+	}
+	return usernames
+}
+
+// existingUsers drops the ids of users that have been deleted, the same
+// way joining against Users does.
+func (m *memory) existingUsers(ids []int) []int {
+	var existing []int
+	for _, id := range ids {
+		if _, ok := m.users[int64(id)]; ok {
+			existing = append(existing, id)
+		}
+	}
+	return existing
+}
+
+func (m *memory) QueryGetUsersFollowers(username string, page types.Page) ([]int, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	ids, next := pageOf(m.existingUsers(followIds(m.userFollows, int64(userId), 1)), page, idCursor, byId)
+	return ids, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryGetUsersFollowersUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	ids, next := pageOf(m.existingUsers(followIds(m.userFollows, int64(userId), 1)), page, idCursor, byId)
+	return m.usernames(ids), next, http.StatusOK, nil
+}
+
+func (m *memory) QueryGetUsersFollowing(username string, page types.Page) ([]int, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, err
+	}
+	ids, next := pageOf(m.existingUsers(followIds(m.userFollows, int64(userId), 0)), page, idCursor, byId)
+	return ids, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryGetUsersFollowingUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(username)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, err
+	}
+	ids, next := pageOf(m.existingUsers(followIds(m.userFollows, int64(userId), 0)), page, idCursor, byId)
+	return m.usernames(ids), next, http.StatusOK, nil
+}
+
+func (m *memory) CreateNewUserFollow(user string, newFollow string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(user)
+	if err != nil {
+		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", user)
+	}
+	newFollowId, err := m.userId(newFollow)
+	if err != nil {
+		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", newFollow)
+	}
+
+	follow := pair{int64(userId), int64(newFollowId)}
+	if m.userFollows[follow] {
+		return http.StatusConflict, fmt.Errorf("User '%v' is already being followed", newFollow)
+	}
+	m.userFollows[follow] = true
+	m.notify(database.NotifyUserFollow, follow[0], follow[1], nil, time.Now().UTC())
+	return http.StatusOK, nil
+}
+
+func (m *memory) RemoveUserFollow(user string, unfollow string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, err := m.userId(user)
+	if err != nil {
+		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", user)
+	}
+	unfollowId, err := m.userId(unfollow)
+	if err != nil {
+		return http.StatusNotFound, fmt.Errorf("Cannot find user with username '%v'", unfollow)
+	}
+
+	follow := pair{int64(userId), int64(unfollowId)}
+	if !m.userFollows[follow] {
+		return http.StatusConflict, fmt.Errorf("User '%v' is not being followed", unfollow)
+	}
+	delete(m.userFollows, follow)
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUserStatus(username string) (*types.UserStatus, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.userByName(username)
+	if user == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+	}
+	return &types.UserStatus{
+		Username:       user.user.Username,
+		Role:           user.role,
+		SuspensionDate: user.suspensionDate,
+	}, http.StatusOK, nil
+}
+
+func (m *memory) QuerySetUserRole(username string, role string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.userByName(username)
+	if user == nil {
+		return http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+	}
+	user.role = role
+	return http.StatusOK, nil
+}
+
+func (m *memory) QuerySuspendUser(username string, suspend bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.userByName(username)
+	if user == nil {
+		return http.StatusNotFound, fmt.Errorf("User with username '%v' not found", username)
+	}
+
+	user.suspensionDate = nil
+	if suspend {
+		now := time.Now().UTC()
+		user.suspensionDate = &now
+		m.revokeFamilies(user.id, now)
+	}
+	return http.StatusOK, nil
+}
+
+func (m *memory) QueryUserFollowerCounts() ([]types.Suggestion, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followers := map[int64]int64{}
+	for follow := range m.userFollows {
+		followers[follow[1]]++
+	}
+	suggestions := []types.Suggestion{}
+	for _, id := range sortedIds(m.users) {
+		suggestions = append(suggestions, types.Suggestion{ID: id, Name: m.users[id].user.Username, Followers: followers[id]})
+	}
+	return suggestions, http.StatusOK, nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/types"
+)
+
+func (m *memory) QueryCreateWebhook(webhook *types.Webhook) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextId("Webhooks")
+	m.webhooks[id] = &types.Webhook{
+		ID:           id,
+		Project:      webhook.Project,
+		URL:          webhook.URL,
+		Events:       slices.Clone(webhook.Events),
+		Secret:       webhook.Secret,
+		CreationDate: time.Now().UTC(),
+	}
+	return id, nil
+}
+
+func (m *memory) QueryWebhooks(projectId int64) ([]types.Webhook, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := []types.Webhook{}
+	for _, id := range sortedIds(m.webhooks) {
+		if webhook := *m.webhooks[id]; webhook.Project == projectId {
+			webhook.Secret = ""
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, http.StatusOK, nil
+}
+
+func (m *memory) QueryWebhook(id int64) (*types.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *webhook
+	return &found, nil
+}
+
+func (m *memory) QueryDeleteWebhook(id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return http.StatusNotFound, fmt.Errorf("Webhook with id %v not found", id)
+	}
+	m.dropWebhooks(func(webhook *types.Webhook) bool { return webhook.ID == id })
+	return http.StatusOK, nil
+}
+
+// dropWebhooks deletes the webhooks `which` picks out along with their deliveries.
+func (m *memory) dropWebhooks(which func(*types.Webhook) bool) {
+	for id, webhook := range m.webhooks {
+		if !which(webhook) {
+			continue
+		}
+		delete(m.webhooks, id)
+		for deliveryId, delivery := range m.deliveries {
+			if delivery.Webhook == id {
+				delete(m.deliveries, deliveryId)
+			}
+		}
+	}
+}
+
+// queueDelivery adds a pending delivery, due right away.
+func (m *memory) queueDelivery(webhookId int64, event string, payload []byte, redeliveryOf *int64, now time.Time) int64 {
+	id := m.nextId("WebhookDeliveries")
+	m.deliveries[id] = &types.WebhookDelivery{
+		ID:           id,
+		Webhook:      webhookId,
+		Event:        event,
+		Payload:      slices.Clone(payload),
+		Status:       database.DeliveryPending,
+		RedeliveryOf: redeliveryOf,
+		CreationDate: now,
+		NextAttempt:  &now,
+	}
+	return id
+}
+
+func (m *memory) QueryQueueDeliveries(projectId int64, event string, payload []byte, now time.Time) (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var queued int64
+	for _, id := range sortedIds(m.webhooks) {
+		if webhook := m.webhooks[id]; webhook.Project == projectId && slices.Contains(webhook.Events, event) {
+			m.queueDelivery(id, event, payload, nil, now)
+			queued++
+		}
+	}
+	return queued, http.StatusOK, nil
+}
+
+func (m *memory) QueryDueDeliveries(now time.Time, limit int) ([]types.PendingDelivery, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	due := []types.PendingDelivery{}
+	for _, id := range sortedIds(m.deliveries) {
+		delivery := m.deliveries[id]
+		if delivery.Status != database.DeliveryPending || delivery.NextAttempt.After(now) {
+			continue
+		}
+		webhook := m.webhooks[delivery.Webhook]
+		due = append(due, types.PendingDelivery{WebhookDelivery: *delivery, URL: webhook.URL, Secret: webhook.Secret})
+	}
+	slices.SortStableFunc(due, func(a, b types.PendingDelivery) int { return a.NextAttempt.Compare(*b.NextAttempt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, http.StatusOK, nil
+}
+
+func (m *memory) QueryRecordAttempt(attempt types.DeliveryAttempt) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[attempt.Delivery]
+	if !ok {
+		return http.StatusOK, nil
+	}
+	delivery.Status = attempt.Status
+	delivery.Attempts++
+	delivery.ResponseCode = attempt.ResponseCode
+	delivery.Error = attempt.Error
+	delivery.NextAttempt = attempt.NextAttempt
+	if attempt.Status == database.DeliveryDelivered {
+		delivered := attempt.Time
+		delivery.DeliveredDate = &delivered
+	}
+	return http.StatusOK, nil
+}
+
+func deliveryCursor(delivery types.WebhookDelivery) types.Cursor {
+	return types.Cursor{ID: delivery.ID}
+}
+
+func (m *memory) QueryDeliveries(webhookId int64, page types.Page) ([]types.WebhookDelivery, *types.Cursor, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := []types.WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.Webhook == webhookId {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	deliveries, next := pageOf(deliveries, page, deliveryCursor, latestFirst)
+	return deliveries, next, http.StatusOK, nil
+}
+
+func (m *memory) QueryRedeliver(webhookId int64, deliveryId int64, now time.Time) (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[deliveryId]
+	if !ok || delivery.Webhook != webhookId {
+		return -1, http.StatusNotFound, fmt.Errorf("Delivery with id %v not found", deliveryId)
+	}
+	return m.queueDelivery(webhookId, delivery.Event, delivery.Payload, &deliveryId, now), http.StatusOK, nil
+}
@@ -0,0 +1,272 @@
+// The store package describes everything the handlers need from storage
+// as a set of interfaces, one per resource. The SQL implementation is
+// database.Database, and Memory keeps everything in maps so handlers can
+// be tested without a database.
+//
+// Methods keep the names, arguments and HTTP-like status codes of the
+// query functions they were extracted from, so every implementation has
+// to report errors the same way the SQL one does.
+package store
+
+import (
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+)
+
+// UserStore reads and writes user profiles, follows and account state.
+type UserStore interface {
+	GetUsernameById(id int64) (string, error)
+	GetUserIdByUsername(username string) (int, error)
+	QueryUsername(username string) (*types.User, error)
+	QueryCreateUser(user *types.User) error
+	QueryDeleteUser(username string, deletedBy int64) (int16, error)
+	QueryUpdateUser(username string, updatedData map[string]interface{}) error
+
+	QueryGetUsersFollowers(username string, page types.Page) ([]int, *types.Cursor, int, error)
+	QueryGetUsersFollowersUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error)
+	QueryGetUsersFollowing(username string, page types.Page) ([]int, *types.Cursor, int, error)
+	QueryGetUsersFollowingUsernames(username string, page types.Page) ([]string, *types.Cursor, int, error)
+	CreateNewUserFollow(user string, newFollow string) (int, error)
+	RemoveUserFollow(user string, unfollow string) (int, error)
+	QueryUserFollowerCounts() ([]types.Suggestion, int, error)
+
+	QueryUserStatus(username string) (*types.UserStatus, int, error)
+	QuerySetUserRole(username string, role string) (int, error)
+	QuerySuspendUser(username string, suspend bool) (int, error)
+}
+
+// AuthStore keeps credentials, sessions and personal access tokens.
+type AuthStore interface {
+	QueryRegisterUser(registration *types.UserRegistration) (int, error)
+	QueryVerifyLogin(username string, password string) (int64, int, error)
+	QueryChangePassword(userId int64, username string, password string) (int, error)
+
+	QueryCreateTokenFamily(userId int64, familyId string) error
+	QueryStoreRefreshToken(familyId string, tokenHash string, expirationDate time.Time) error
+	QueryUseRefreshToken(tokenHash string) (int64, string, int, error)
+	QueryRevokeTokenFamily(familyId string) error
+	QueryRevokeAllUserTokens(userId int64) error
+	QuerySessionUser(familyId string) (*types.Caller, int, error)
+
+	QueryCreatePersonalToken(userId int64, newToken *types.NewPersonalToken, tokenHash string) (*types.PersonalToken, error)
+	QueryPersonalTokens(userId int64) ([]types.PersonalToken, int, error)
+	QueryDeletePersonalToken(userId int64, tokenId int64) (int, error)
+	QueryPersonalTokenUser(tokenHash string) (*types.Caller, []string, int, error)
+}
+
+// ProjectStore reads and writes projects and who follows and likes them.
+type ProjectStore interface {
+	QueryProject(id int) (*types.Project, error)
+	QueryProjectsByUserId(userId int) ([]types.Project, int, error)
+	QueryCreateProject(proj *types.Project) (int64, error)
+	QueryDeleteProject(id int, deletedBy int64) (int16, error)
+	QueryUpdateProject(id int, updatedData map[string]interface{}) error
+	QueryReassignProject(projectId int64, newOwner int64) (int, error)
+	QueryProjects(filter types.ProjectFilter, page types.Page) ([]types.Project, *types.Cursor, int, error)
+	QueryTagCounts() ([]types.TagCount, int, error)
+
+	QueryGetProjectFollowers(projectID int, page types.Page) ([]int, *types.Cursor, int, error)
+	QueryGetProjectFollowersUsernames(projectID int, page types.Page) ([]string, *types.Cursor, int, error)
+	QueryGetProjectFollowing(username string) ([]int, int, error)
+	QueryGetProjectFollowingNames(username string) ([]string, int, error)
+	CreateNewProjectFollow(username string, projectID string) (int, error)
+	RemoveProjectFollow(username string, projectID string) (int, error)
+	QueryProjectFollowerCounts() ([]types.Suggestion, int, error)
+
+	CreateProjectLike(username string, strProjId string) (int, error)
+	RemoveProjectLike(username string, strProjId string) (int, error)
+	QueryProjectLike(username string, strProjId string) (int, bool, error)
+}
+
+// PostStore reads and writes posts and their likes.
+type PostStore interface {
+	QueryPost(id int) (*types.Post, error)
+	QueryCreatePost(post *types.Post) (int64, error)
+	QueryDeletePost(id int, deletedBy int64) (int16, error)
+	QueryUpdatePost(id int, updatedData map[string]interface{}) error
+	QueryPostsByUserId(userId int, page types.Page) ([]types.Post, *types.Cursor, int, error)
+	QueryPostsByProjectId(projId int) ([]types.Post, int, error)
+	QueryPostRevisions(id int) ([]types.Revision, int, error)
+
+	CreatePostLike(username string, strPostId string) (int, error)
+	RemovePostLike(username string, strPostId string) (int, error)
+	QueryPostLike(username string, strPostId string) (int, bool, error)
+}
+
+// CommentStore reads and writes comments on posts, projects and other comments.
+type CommentStore interface {
+	QueryComment(id int) (*types.Comment, error)
+	QueryCommentsByUserId(userId int) ([]types.Comment, int, error)
+	QueryCommentsByProjectId(id int) ([]types.Comment, int, error)
+	QueryCommentsByPostId(id int, page types.Page) ([]types.Comment, *types.Cursor, int, error)
+	QueryCommentsByCommentId(id int) ([]types.Comment, int, error)
+	QueryCommentTreeByPostId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error)
+	QueryCommentTreeByProjectId(id int, query types.ThreadQuery) ([]types.CommentNode, *types.Cursor, int, error)
+	QueryCommentThread(id int) (string, int64, int, error)
+
+	QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error)
+	QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error)
+	QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error)
+	QueryDeleteComment(id int, deletedBy int64) (int16, error)
+	QueryUpdateCommentContent(id int, newContent string, editWindow time.Duration) (int16, error)
+	QueryIsCommentEditable(strCommId string, editWindow time.Duration) (int, bool, error)
+	QueryCommentRevisions(id int) ([]types.Revision, int, error)
+
+	CreateCommentLike(username string, strCommentId string) (int, error)
+	RemoveCommentLike(username string, strCommentId string) (int, error)
+	QueryCommentLike(username string, strCommId string) (int, bool, error)
+}
+
+// FeedStore pages through posts and projects for the feeds, returning
+// the cursor of the page after the one asked for.
+type FeedStore interface {
+	GetPostByTimeFeed(page types.Page) ([]types.Post, *types.Cursor, int, error)
+	GetPostByLikesFeed(page types.Page) ([]types.Post, *types.Cursor, int, error)
+	GetPostByTrendingFeed(page types.Page) ([]types.Post, *types.Cursor, int, error)
+	GetPostByFollowingFeed(userId int64, page types.Page) ([]types.Post, *types.Cursor, int, error)
+	GetProjectByTimeFeed(page types.Page) ([]types.Project, *types.Cursor, int, error)
+	GetProjectByLikesFeed(page types.Page) ([]types.Project, *types.Cursor, int, error)
+	GetProjectByTrendingFeed(page types.Page) ([]types.Project, *types.Cursor, int, error)
+}
+
+// SearchStore finds users, projects, posts and comments by the words in them.
+type SearchStore interface {
+	QuerySearch(text string, kind string, limit int) ([]types.SearchResult, int, error)
+}
+
+// AutocompleteStore suggests users and projects by the start of their
+// names, putting the ones the caller follows first.
+type AutocompleteStore interface {
+	CompleteUsers(prefix string, callerId int64, limit int) ([]types.Suggestion, int, error)
+	CompleteProjects(prefix string, callerId int64, limit int) ([]types.Suggestion, int, error)
+}
+
+// TombstoneStore restores deleted users, projects, posts and comments,
+// and purges them for good once they are too old to restore. Purging
+// hands back the URLs of the uploads it forgot, for their files to be
+// deleted too.
+type TombstoneStore interface {
+	QueryTombstone(kind string, id int64) (*types.Tombstone, error)
+	QueryUserTombstone(username string) (*types.Tombstone, error)
+	QueryRestore(kind string, id int64) (int, error)
+	QueryPurge(before time.Time) (int64, []string, error)
+}
+
+// NotificationStore lists the notifications users get when others like,
+// follow or comment on their things, and keeps track of which were read.
+type NotificationStore interface {
+	QueryNotifications(userId int64, page types.Page) ([]types.Notification, *types.Cursor, int, error)
+	QueryUnreadNotificationCount(userId int64) (int64, int, error)
+	QueryMarkNotificationRead(userId int64, id int64) (int, error)
+	QueryMarkAllNotificationsRead(userId int64) (int, error)
+
+	QueryLastNotificationId() (int64, int, error)
+	QueryNotificationsAfter(after int64) ([]types.SentNotification, int, error)
+}
+
+// WebhookStore keeps the webhooks of projects and the log of what was
+// delivered to them.
+type WebhookStore interface {
+	QueryCreateWebhook(webhook *types.Webhook) (int64, error)
+	QueryWebhooks(projectId int64) ([]types.Webhook, int, error)
+	QueryWebhook(id int64) (*types.Webhook, error)
+	QueryDeleteWebhook(id int64) (int, error)
+
+	QueryQueueDeliveries(projectId int64, event string, payload []byte, now time.Time) (int64, int, error)
+	QueryDueDeliveries(now time.Time, limit int) ([]types.PendingDelivery, int, error)
+	QueryRecordAttempt(attempt types.DeliveryAttempt) (int, error)
+	QueryDeliveries(webhookId int64, page types.Page) ([]types.WebhookDelivery, *types.Cursor, int, error)
+	QueryRedeliver(webhookId int64, deliveryId int64, now time.Time) (int64, int, error)
+}
+
+// DigestStore keeps the email preferences of users and collects what
+// their digests tell them about.
+type DigestStore interface {
+	QueryEmailPreferences(userId int64) (types.EmailPreferences, int, error)
+	QuerySetEmailPreferences(userId int64, preferences types.EmailPreferences, confirmationHash string, now time.Time) (bool, int, error)
+	QueryConfirmEmail(confirmationHash string, now time.Time) (int, error)
+
+	QueryDueDigests(frequency string, sentBefore time.Time, limit int) ([]types.Digest, int, error)
+	QueryMarkDigestSent(userId int64, through int64, now time.Time) (int, error)
+}
+
+// ImageStore keeps track of the pictures of users and the images of
+// projects that were uploaded to blob storage.
+type ImageStore interface {
+	QuerySetUserPicture(username string, picture string) (string, int, error)
+
+	QueryCreateProjectImage(image *types.ProjectImage, maxImages int) (int64, int, error)
+	QueryProjectImages(projectId int64) ([]types.ProjectImage, int, error)
+	QueryProjectImage(id int64) (*types.ProjectImage, error)
+	QueryDeleteProjectImage(id int64) (int, error)
+}
+
+// Stores is the full set of stores a server needs.
+type Stores struct {
+	Users         UserStore
+	Auth          AuthStore
+	Projects      ProjectStore
+	Posts         PostStore
+	Comments      CommentStore
+	Feed          FeedStore
+	Search        SearchStore
+	Tombstones    TombstoneStore
+	Notifications NotificationStore
+	Webhooks      WebhookStore
+	Digests       DigestStore
+	Images        ImageStore
+	// set by WithAutocomplete, which keeps its index up to date
+	Autocomplete AutocompleteStore
+	// set by WithEvents, which publishes the writes made through the stores
+	Events *events.Bus
+}
+
+// NewSQL returns stores backed by an open database.
+func NewSQL(db *database.Database) Stores {
+	return Stores{
+		Users:         db,
+		Auth:          db,
+		Projects:      db,
+		Posts:         db,
+		Comments:      db,
+		Feed:          db,
+		Search:        db,
+		Tombstones:    db,
+		Notifications: db,
+		Webhooks:      db,
+		Digests:       db,
+		Images:        db,
+	}
+}
+
+// both implementations have to provide every store
+var (
+	_ UserStore         = (*database.Database)(nil)
+	_ AuthStore         = (*database.Database)(nil)
+	_ ProjectStore      = (*database.Database)(nil)
+	_ PostStore         = (*database.Database)(nil)
+	_ CommentStore      = (*database.Database)(nil)
+	_ FeedStore         = (*database.Database)(nil)
+	_ SearchStore       = (*database.Database)(nil)
+	_ TombstoneStore    = (*database.Database)(nil)
+	_ NotificationStore = (*database.Database)(nil)
+	_ WebhookStore      = (*database.Database)(nil)
+	_ DigestStore       = (*database.Database)(nil)
+	_ ImageStore        = (*database.Database)(nil)
+
+	_ UserStore         = (*memory)(nil)
+	_ AuthStore         = (*memory)(nil)
+	_ ProjectStore      = (*memory)(nil)
+	_ PostStore         = (*memory)(nil)
+	_ CommentStore      = (*memory)(nil)
+	_ FeedStore         = (*memory)(nil)
+	_ SearchStore       = (*memory)(nil)
+	_ TombstoneStore    = (*memory)(nil)
+	_ NotificationStore = (*memory)(nil)
+	_ WebhookStore      = (*memory)(nil)
+	_ DigestStore       = (*memory)(nil)
+	_ ImageStore        = (*memory)(nil)
+)
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/api/internal/logger"
+	"backend/api/internal/types"
+	"backend/api/internal/webhooks"
+)
+
+// hooker queues webhook deliveries for what happens through the stores
+// wrapped by WithWebhooks, once the write behind it has succeeded.
+type hooker struct {
+	stores     Stores
+	dispatcher *webhooks.Dispatcher
+}
+
+// WithWebhooks returns the stores with Projects, Posts and Comments
+// wrapped so new posts and comments on a project, and likes and follows
+// of it, are queued for the project's webhooks and handed to `dispatcher`.
+// Webhooks is wrapped too, so redeliveries are sent right away.
+func WithWebhooks(stores Stores, dispatcher *webhooks.Dispatcher) Stores {
+	h := &hooker{stores: stores, dispatcher: dispatcher}
+
+	stores.Projects = hookedProjects{stores.Projects, h}
+	stores.Posts = hookedPosts{stores.Posts, h}
+	stores.Comments = hookedComments{stores.Comments, h}
+	stores.Webhooks = hookedWebhooks{stores.Webhooks, h}
+	return stores
+}
+
+// queue queues `data` for the webhooks of a project that subscribed to
+// `event`. Like events, webhooks are best effort: failing to queue them
+// does not fail the write they are about.
+func (h *hooker) queue(event string, projectId int64, actor string, data interface{}) {
+	now := time.Now().UTC()
+	payload, err := json.Marshal(types.WebhookPayload{Event: event, Project: projectId, Actor: actor, CreationDate: now, Data: data})
+	if err != nil {
+		logger.Log.Errorf("Failed to encode %v webhook payload: %v", event, err)
+		return
+	}
+	queued, _, err := h.stores.Webhooks.QueryQueueDeliveries(projectId, event, payload, now)
+	if err != nil {
+		logger.Log.Errorf("Failed to queue %v webhooks of project %v: %v", event, projectId, err)
+		return
+	}
+	if queued > 0 {
+		h.dispatcher.Wake()
+	}
+}
+
+// queueProject queues an event about a project along with the project as
+// it is now, after `username` liked or followed it.
+func (h *hooker) queueProject(event string, username string, strProjId string) {
+	id, err := strconv.Atoi(strProjId)
+	if err != nil {
+		return
+	}
+	project, err := h.stores.Projects.QueryProject(id)
+	if err != nil || project == nil {
+		return
+	}
+	h.queue(event, project.ID, username, project)
+}
+
+// a new comment along with the thread it was written on
+type commentCreated struct {
+	Comment types.Comment `json:"comment"`
+	// post or project, and the id of that post or project
+	Thread   string `json:"thread"`
+	ThreadId int64  `json:"thread_id"`
+}
+
+// queueComment queues a new comment for the webhooks of the project it
+// was written on, directly or on one of the project's posts.
+func (h *hooker) queueComment(id int64) {
+	comment, err := h.stores.Comments.QueryComment(int(id))
+	if err != nil || comment == nil {
+		return
+	}
+	kind, threadId, _, err := h.stores.Comments.QueryCommentThread(int(id))
+	if err != nil {
+		return
+	}
+	projectId := threadId
+	if kind == "post" {
+		post, err := h.stores.Posts.QueryPost(int(threadId))
+		if err != nil || post == nil {
+			return
+		}
+		projectId = post.Project
+	}
+	actor, err := h.stores.Users.GetUsernameById(comment.User)
+	if err != nil {
+		return
+	}
+	h.queue(webhooks.EventCommentCreated, projectId, actor, commentCreated{Comment: *comment, Thread: kind, ThreadId: threadId})
+}
+
+type hookedProjects struct {
+	ProjectStore
+	h *hooker
+}
+
+func (projects hookedProjects) CreateNewProjectFollow(username string, projectID string) (int, error) {
+	httpcode, err := projects.ProjectStore.CreateNewProjectFollow(username, projectID)
+	if err == nil {
+		projects.h.queueProject(webhooks.EventProjectFollowed, username, projectID)
+	}
+	return httpcode, err
+}
+
+func (projects hookedProjects) CreateProjectLike(username string, strProjId string) (int, error) {
+	httpcode, err := projects.ProjectStore.CreateProjectLike(username, strProjId)
+	// liking again succeeds with a 200 without liking anything
+	if err == nil && httpcode == http.StatusCreated {
+		projects.h.queueProject(webhooks.EventProjectLiked, username, strProjId)
+	}
+	return httpcode, err
+}
+
+type hookedPosts struct {
+	PostStore
+	h *hooker
+}
+
+func (posts hookedPosts) QueryCreatePost(post *types.Post) (int64, error) {
+	id, err := posts.PostStore.QueryCreatePost(post)
+	if err != nil {
+		return id, err
+	}
+	created, err := posts.PostStore.QueryPost(int(id))
+	if err == nil && created != nil {
+		if actor, err := posts.h.stores.Users.GetUsernameById(created.User); err == nil {
+			posts.h.queue(webhooks.EventPostCreated, created.Project, actor, created)
+		}
+	}
+	return id, nil
+}
+
+type hookedComments struct {
+	CommentStore
+	h *hooker
+}
+
+func (comments hookedComments) QueryCreateCommentOnPost(comment types.Comment, postId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnPost(comment, postId)
+	if err == nil {
+		comments.h.queueComment(id)
+	}
+	return id, err
+}
+
+func (comments hookedComments) QueryCreateCommentOnProject(comment types.Comment, projectId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnProject(comment, projectId)
+	if err == nil {
+		comments.h.queueComment(id)
+	}
+	return id, err
+}
+
+func (comments hookedComments) QueryCreateCommentOnComment(comment types.Comment, commentId int) (int64, error) {
+	id, err := comments.CommentStore.QueryCreateCommentOnComment(comment, commentId)
+	if err == nil {
+		comments.h.queueComment(id)
+	}
+	return id, err
+}
+
+type hookedWebhooks struct {
+	WebhookStore
+	h *hooker
+}
+
+func (webhooks hookedWebhooks) QueryRedeliver(webhookId int64, deliveryId int64, now time.Time) (int64, int, error) {
+	id, httpcode, err := webhooks.WebhookStore.QueryRedeliver(webhookId, deliveryId, now)
+	if err == nil {
+		webhooks.h.dispatcher.Wake()
+	}
+	return id, httpcode, err
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"net/http"
+)
+
+var admin_tests []TestCase = []TestCase{
+	// regular users cannot use the admin routes
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/admin/posts/3",
+		Input:          "",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/admin/posts/3",
+		Input:          "",
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'dev_user1' does not have the 'content:remove' permission"}`,
+	},
+	// roles cannot be changed through the regular user update
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/data_scientist3",
+		Input:          `{"role":"admin"}`,
+		Username:       "data_scientist3",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'role' is not allowed to be updated"}`,
+	},
+	// moderators can remove anyone's content
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/admin/posts/3",
+		Input:          "",
+		Username:       "moderator7",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"Post 3 deleted."}`,
+	},
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/posts/3",
+		Input:          "",
+		Username:       "moderator7",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Post with id '3' not found"}`,
+	},
+	// but not manage users
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/admin/users/dev_user1",
+		Input:          "",
+		Username:       "moderator7",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'moderator7' does not have the 'users:manage' permission"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/projects/3/owner",
+		Input:          `{"owner":4}`,
+		Username:       "moderator7",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'moderator7' does not have the 'projects:reassign' permission"}`,
+	},
+	// admins can see and change roles
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/admin/users/moderator7",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"username":"moderator7","role":"moderator","suspended_on":null}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/admin/users/not_a_user",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Failed to fetch user status: User with username 'not_a_user' not found"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/users/moderator7/role",
+		Input:          `{"role":"owner"}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to update role: Unknown role 'owner', expected one of user, moderator, admin"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/users/not_a_user/role",
+		Input:          `{"role":"moderator"}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Failed to update role: User with username 'not_a_user' not found"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/users/site_admin6/role",
+		Input:          `{"role":"user"}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"User 'site_admin6' cannot change their own role"}`,
+	},
+	// suspending a user logs them out and keeps them out
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/register",
+		Input:          `{"username":"suspended_user","password":"password123"}`,
+		ExpectedStatus: http.StatusCreated,
+		ExpectedBody:   `{"message":"Registered new user: 'suspended_user'"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/suspended_user",
+		Input:          `{"bio":"About to be suspended"}`,
+		Username:       "suspended_user",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"User updated successfully."}`,
+		IgnoredFields:  []string{"user"},
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/admin/users/suspended_user/suspend",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"User 'suspended_user' suspended."}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/suspended_user",
+		Input:          `{"bio":"Still here?"}`,
+		Username:       "suspended_user",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Failed to authenticate: Session has been revoked"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"suspended_user","password":"password123"}`,
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"Failed to log in: Account 'suspended_user' is suspended"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/admin/users/suspended_user",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"username":"suspended_user","role":"user"}`,
+		IgnoredFields:  []string{"suspended_on"},
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/admin/users/suspended_user/unsuspend",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"User 'suspended_user' unsuspended."}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"suspended_user","password":"password123"}`,
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"Logged in as 'suspended_user'"}`,
+		IgnoredFields:  []string{"tokens"},
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/admin/users/site_admin6/suspend",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"User 'site_admin6' cannot suspend themselves"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/admin/users/not_a_user/suspend",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Failed to suspend user: User with username 'not_a_user' not found"}`,
+	},
+	// and delete other accounts
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/users/suspended_user",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"User 'suspended_user' deleted."}`,
+	},
+	// admins can hand projects over to someone else
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/projects/3/owner",
+		Input:          `{"owner":4}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"Project 3 now belongs to user 4."}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/projects/3/owner",
+		Input:          `{"owner":9999}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to reassign project: User with id '9999' not found"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/admin/projects/9999/owner",
+		Input:          `{"owner":4}`,
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Failed to reassign project: Project with id '9999' not found"}`,
+	},
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var auth_tests []TestCase = []TestCase{
+	// register a new user with a password
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/register",
+		Input:          `{"username":"auth_user","password":"correct-horse","bio":"Registered through the auth api.","links":[],"picture":""}`,
+		ExpectedStatus: http.StatusCreated,
+		ExpectedBody:   `{"message":"Registered new user: 'auth_user'"}`,
+	},
+	// registering the same name again
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/register",
+		Input:          `{"username":"auth_user","password":"correct-horse"}`,
+		ExpectedStatus: http.StatusConflict,
+		ExpectedBody:   `{"error":"Conflict","message":"Failed to register user: Username 'auth_user' is already taken"}`,
+	},
+	// registering with a short password
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/register",
+		Input:          `{"username":"short_password_user","password":"short"}`,
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to register user: Password must be at least 8 characters long"}`,
+	},
+	// registering without a password
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/register",
+		Input:          `{"username":"no_password_user"}`,
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'UserRegistration.Password' Error:Field validation for 'Password' failed on the 'required' tag"}`,
+	},
+	// the registered user is a regular user
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/auth_user",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"username":"auth_user","bio":"Registered through the auth api.","links":[],"picture":""}`,
+		IgnoredFields:  []string{"created_on"},
+	},
+	// log in as the new user
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"auth_user","password":"correct-horse"}`,
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"Logged in as 'auth_user'"}`,
+		IgnoredFields:  []string{"tokens"},
+	},
+	// log in as a seeded user
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"data_scientist3","password":"password123"}`,
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"Logged in as 'data_scientist3'"}`,
+		IgnoredFields:  []string{"tokens"},
+	},
+	// wrong password
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"auth_user","password":"wrong-password"}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Failed to log in: Invalid username or password"}`,
+	},
+	// unknown user looks the same as a wrong password
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/login",
+		Input:          `{"username":"not_a_user","password":"correct-horse"}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Failed to log in: Invalid username or password"}`,
+	},
+	// refresh tokens have to be ones the server handed out
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/refresh",
+		Input:          `{"refresh_token":"not-a-refresh-token"}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Failed to refresh tokens: Invalid refresh token"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/refresh",
+		Input:          `{}`,
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'RefreshRequest.RefreshToken' Error:Field validation for 'RefreshToken' failed on the 'required' tag"}`,
+	},
+	// logging out needs a session to end
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/auth/logout",
+		Input:          "",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	// and so does changing a password
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/auth/password",
+		Input:          `{"current_password":"password123","new_password":"new-password"}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+}
+
+// tokenPair pulls the access and refresh token out of a login or refresh response
+func tokenPair(t *testing.T, body map[string]interface{}) (string, string) {
+	t.Helper()
+
+	tokens, ok := body["tokens"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Response is missing its tokens: %v", body)
+	}
+	return tokens["access_token"].(string), tokens["refresh_token"].(string)
+}
+
+// the token flow depends on values handed out by earlier requests,
+// so it cannot be written as a table of test cases
+func testTokenLifecycle(t *testing.T, server *TestServer) {
+	// a fresh user so revoking their sessions cannot affect other tests
+	username := fmt.Sprintf("token_user_%d", time.Now().UnixNano())
+	credentials := fmt.Sprintf(`{"username":%q,"password":"first-password"}`, username)
+
+	status, _ := server.authRequest(t, http.MethodPost, "/auth/register", "", credentials)
+	assert.Equal(t, http.StatusCreated, status)
+
+	status, body := server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	firstAccess, firstRefresh := tokenPair(t, body)
+
+	// rotating hands out a new pair, and both access tokens still work
+	status, body = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, firstRefresh))
+	assert.Equal(t, http.StatusOK, status)
+	secondAccess, secondRefresh := tokenPair(t, body)
+	assert.NotEqual(t, firstRefresh, secondRefresh)
+
+	status, _ = server.authRequest(t, http.MethodPut, "/users/"+username, firstAccess, `{"bio":"still logged in"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// reusing a rotated refresh token revokes the whole family
+	status, body = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, firstRefresh))
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Failed to refresh tokens: Refresh token has already been used, the session has been revoked", body["message"])
+
+	status, body = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, secondRefresh))
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Failed to refresh tokens: Session has been revoked", body["message"])
+
+	status, body = server.authRequest(t, http.MethodPut, "/users/"+username, secondAccess, `{"bio":"revoked"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Failed to authenticate: Session has been revoked", body["message"])
+
+	// logging out only ends the current session
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	loggedOutAccess, _ := tokenPair(t, body)
+
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	otherAccess, otherRefresh := tokenPair(t, body)
+
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/logout", loggedOutAccess, "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/logout", loggedOutAccess, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = server.authRequest(t, http.MethodPut, "/users/"+username, otherAccess, `{"bio":"other session"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// logging out everywhere ends every session
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	everywhereAccess, everywhereRefresh := tokenPair(t, body)
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	lostAccess, _ := tokenPair(t, body)
+
+	status, body = server.authRequest(t, http.MethodPost, "/auth/logout-all", everywhereAccess, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, fmt.Sprintf("Logged out '%s' everywhere", username), body["message"])
+	status, _ = server.authRequest(t, http.MethodPut, "/users/"+username, lostAccess, `{"bio":"lost device"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, everywhereRefresh))
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	otherAccess, otherRefresh = tokenPair(t, body)
+
+	// changing the password needs the current one
+	status, body = server.authRequest(t, http.MethodPut, "/auth/password", otherAccess, `{"current_password":"wrong-password","new_password":"second-password"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Failed to change password: Invalid username or password", body["message"])
+
+	// and revokes every session the user has
+	status, _ = server.authRequest(t, http.MethodPut, "/auth/password", otherAccess, `{"current_password":"first-password","new_password":"second-password"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, otherRefresh))
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// deleting the user revokes the sessions they had left
+	status, body = server.authRequest(t, http.MethodPost, "/auth/login", "", fmt.Sprintf(`{"username":%q,"password":"second-password"}`, username))
+	assert.Equal(t, http.StatusOK, status)
+	finalAccess, finalRefresh := tokenPair(t, body)
+
+	status, _ = server.authRequest(t, http.MethodDelete, "/users/"+username, finalAccess, "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = server.authRequest(t, http.MethodPost, "/auth/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, finalRefresh))
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
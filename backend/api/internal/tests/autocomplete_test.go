@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var autocomplete_tests []TestCase = []TestCase{
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/autocomplete/users",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Missing required url query parameter: prefix"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/autocomplete/projects?prefix=o&cursor=abc",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Suggestions are not paged, use count to get more of them"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/autocomplete/projects?prefix=Sca",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[{"id":4,"name":"ScaleDB","followers":0,"following":false}]`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/autocomplete/users?prefix=nobody",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[]`,
+	},
+}
+
+// suggest gets the names autocomplete suggests, in order.
+func (server *TestServer) suggest(t *testing.T, endpoint string, token string) []string {
+	t.Helper()
+
+	var suggestions []types.Suggestion
+	server.getJSON(t, endpoint, nil, token, &suggestions)
+	names := []string{}
+	for _, suggestion := range suggestions {
+		names = append(names, suggestion.Name)
+	}
+	return names
+}
+
+func testAutocomplete(t *testing.T, server *TestServer) {
+	status, body := server.authRequest(t, http.MethodPost, "/auth/register", "", `{"username":"dev_ops8","password":"password123"}`)
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+
+	// the most followed come first, then the shortest names
+	assert.Equal(t, []string{"dev_user1", "data_scientist3", "dev_ops8"}, server.suggest(t, "/autocomplete/users?prefix=D", ""))
+	assert.Equal(t, []string{"dev_user1"}, server.suggest(t, "/autocomplete/users?prefix=d&count=1", ""))
+	assert.Equal(t, []string{"dev_user1", "dev_ops8"}, server.suggest(t, "/autocomplete/users?prefix=dev", ""))
+
+	// then the users the caller follows move up front
+	dev := server.loginAs(t, "dev_user1")
+	assert.Equal(t, []string{"data_scientist3", "dev_user1", "dev_ops8"}, server.suggest(t, "/autocomplete/users?prefix=d", dev))
+
+	for _, follower := range []string{"site_admin6", "moderator7"} {
+		status, body = server.authRequest(t, http.MethodPost, "/users/"+follower+"/follow/dev_ops8", server.loginAs(t, follower), "")
+		assert.Equal(t, http.StatusOK, status, "%v", body)
+	}
+	assert.Equal(t, []string{"dev_ops8", "dev_user1"}, server.suggest(t, "/autocomplete/users?prefix=dev", ""))
+
+	// renamed users keep their followers under the new name
+	ops := server.loginAs(t, "dev_ops8")
+	status, body = server.authRequest(t, http.MethodPut, "/users/dev_ops8", ops, `{"username":"ops_dev8"}`)
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	assert.Equal(t, []string{"dev_user1"}, server.suggest(t, "/autocomplete/users?prefix=dev", ""))
+	assert.Equal(t, []string{"ops_dev8"}, server.suggest(t, "/autocomplete/users?prefix=OPS", ""))
+
+	// new projects can be picked right away
+	status, body = server.authRequest(t, http.MethodPost, "/projects", dev, `{"name":"OpenAPI Lint","description":"Lints OpenAPI specs","owner":1,"tags":[]}`)
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+	assert.Equal(t, []string{"OpenAPI Toolkit", "OpenAPI Lint"}, server.suggest(t, "/autocomplete/projects?prefix=openapi", ""))
+
+	status, body = server.authRequest(t, http.MethodPost, "/projects/dev_user1/follow/5", dev, "")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	writer := server.loginAs(t, "tech_writer2")
+	assert.Equal(t, []string{"OpenAPI Toolkit", "OpenAPI Lint"}, server.suggest(t, "/autocomplete/projects?prefix=openapi", writer), "tech_writer2 follows the toolkit")
+	assert.Equal(t, []string{"OpenAPI Lint", "OpenAPI Toolkit"}, server.suggest(t, "/autocomplete/projects?prefix=openapi", dev))
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes a config file into a temp dir and returns its path
+func writeConfig(t *testing.T, name string, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := config.Load("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, config.Default(), cfg)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	yamlPath := writeConfig(t, "config.yml", `
+listen_address: "0.0.0.0:9000"
+log_level: debug
+cors:
+  allowed_origins: ["https://devbits.example.com"]
+auth:
+  access_token_ttl: 5m
+limits:
+  max_feed_count: 20
+`)
+	tomlPath := writeConfig(t, "config.toml", `
+listen_address = "0.0.0.0:9000"
+log_level = "debug"
+
+[cors]
+allowed_origins = ["https://devbits.example.com"]
+
+[auth]
+access_token_ttl = "5m"
+
+[limits]
+max_feed_count = 20
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg, err := config.Load(path)
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		assert.Equal(t, "0.0.0.0:9000", cfg.ListenAddress, path)
+		assert.Equal(t, "debug", cfg.LogLevel, path)
+		assert.Equal(t, []string{"https://devbits.example.com"}, cfg.CORS.AllowedOrigins, path)
+		assert.Equal(t, 5*time.Minute, cfg.Auth.AccessTokenTTL.Duration, path)
+		assert.Equal(t, 20, cfg.Limits.MaxFeedCount, path)
+
+		// settings missing from the file keep their defaults
+		assert.Equal(t, config.Default().Database, cfg.Database, path)
+		assert.Equal(t, config.Default().Limits.MaxPostLength, cfg.Limits.MaxPostLength, path)
+	}
+}
+
+func TestConfigEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "config.yml", "listen_address: \"0.0.0.0:9000\"\n")
+
+	t.Setenv("DEVBITS_LISTEN_ADDRESS", "127.0.0.1:7000")
+	t.Setenv("DEVBITS_CORS_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("DEVBITS_MAX_POST_LENGTH", "280")
+	t.Setenv("DEVBITS_DEBUG", "false")
+	t.Setenv("DEVBITS_AUTO_MIGRATE", "true")
+	t.Setenv("DEVBITS_TOKEN_SECRET", "0123456789abcdef0123456789abcdef")
+
+	cfg, err := config.Load(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "127.0.0.1:7000", cfg.ListenAddress)
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORS.AllowedOrigins)
+		assert.Equal(t, 280, cfg.Limits.MaxPostLength)
+		assert.False(t, cfg.Debug)
+		assert.True(t, cfg.Database.AutoMigrate)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		file  string
+		env   map[string]string
+		error string
+	}{
+		"missing secret outside of debug": {
+			file:  "debug: false\n",
+			error: "Invalid config: auth.token_secret must be at least 32 characters outside of debug mode",
+		},
+		"unknown driver": {
+			file:  "database:\n  driver: mysql\n",
+			error: "Invalid config: database.driver 'mysql' is not supported, expected sqlite3 or postgres",
+		},
+		"bad origin": {
+			file:  "cors:\n  allowed_origins: [\"localhost\"]\n",
+			error: "Invalid config: cors.allowed_origins entry 'localhost' is not an origin like http://host:port",
+		},
+		"bad log level": {
+			file:  "log_level: loud\n",
+			error: "Invalid config: log_level: not a valid logrus Level: \"loud\"",
+		},
+		"access outlives refresh": {
+			file:  "auth:\n  access_token_ttl: 48h\n  refresh_token_ttl: 24h\n",
+			error: "Invalid config: auth.access_token_ttl must be shorter than auth.refresh_token_ttl",
+		},
+		"negative edit window": {
+			file:  "limits:\n  comment_edit_window: -1m\n",
+			error: "Invalid config: limits.comment_edit_window cannot be negative",
+		},
+		"purged before restorable": {
+			file:  "database:\n  purge_after: 24h\nlimits:\n  restore_window: 48h\n",
+			error: "Invalid config: database.purge_after cannot be shorter than limits.restore_window",
+		},
+		"no event history": {
+			file:  "events:\n  history: 0\n",
+			error: "Invalid config: events.history and events.keepalive must be positive",
+		},
+		"endless webhook retries": {
+			file:  "webhooks:\n  max_attempts: 50\n",
+			error: "Invalid config: webhooks.max_attempts must be between 1 and 20",
+		},
+		"no webhook timeout": {
+			file:  "",
+			env:   map[string]string{"DEVBITS_WEBHOOK_TIMEOUT": "0s"},
+			error: "Invalid config: webhooks.retry_backoff, webhooks.timeout and webhooks.poll_interval must be positive",
+		},
+		"smtp without a server": {
+			file:  "mail:\n  driver: smtp\n",
+			error: "Invalid config: mail.smtp_host is required to send mail over smtp",
+		},
+		"unknown mail driver": {
+			file:  "",
+			env:   map[string]string{"DEVBITS_MAIL_DRIVER": "carrier-pigeon"},
+			error: "Invalid config: mail.driver 'carrier-pigeon' is not supported, expected log or smtp",
+		},
+		"relative mail links": {
+			file:  "mail:\n  link_url: /api\n",
+			error: "Invalid config: mail.link_url '/api' must be an absolute url",
+		},
+		"s3 without a bucket": {
+			file:  "storage:\n  driver: s3\n  s3_endpoint: https://s3.example.com\n  s3_access_key: key\n  s3_secret_key: secret\n",
+			error: "Invalid config: storage.s3_region, storage.s3_bucket, storage.s3_access_key and storage.s3_secret_key are required to store uploads in s3",
+		},
+		"s3 served from a path": {
+			file:  "storage:\n  driver: s3\n  s3_endpoint: https://s3.example.com\n  s3_bucket: uploads\n  s3_access_key: key\n  s3_secret_key: secret\n",
+			env:   map[string]string{"DEVBITS_STORAGE_PUBLIC_URL": "/media"},
+			error: "Invalid config: storage.public_url '/media' must be an absolute url to store uploads in s3",
+		},
+		"malformed override": {
+			file:  "",
+			env:   map[string]string{"DEVBITS_MAX_FEED_COUNT": "lots"},
+			error: "Invalid DEVBITS_MAX_FEED_COUNT: strconv.Atoi: parsing \"lots\": invalid syntax",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			_, err := config.Load(writeConfig(t, "config.yml", test.file))
+			assert.EqualError(t, err, test.error)
+		})
+	}
+
+	_, err := config.Load(writeConfig(t, "config.json", "{}"))
+	assert.EqualError(t, err, "Unsupported config file type '.json', expected .yml, .yaml or .toml")
+}
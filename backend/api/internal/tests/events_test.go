@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	bus := events.NewBus(3, time.Minute)
+	first, resumed := bus.Subscribe([]string{events.UserTopic(1)}, "")
+	assert.True(t, resumed)
+	defer first.Close()
+
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, bus.Publish(events.TypeLikes, i, events.UserTopic(int64(i%2))))
+	}
+	one := <-first.Events()
+	three := <-first.Events()
+	assert.Equal(t, json.RawMessage("1"), one.Data)
+	assert.Equal(t, json.RawMessage("3"), three.Data)
+
+	// only the events of its topics after the given one are replayed
+	resumedAfter, resumed := bus.Subscribe([]string{events.UserTopic(0)}, bus.Id(one))
+	assert.True(t, resumed)
+	assert.Equal(t, json.RawMessage("2"), (<-resumedAfter.Events()).Data)
+	assert.Equal(t, json.RawMessage("4"), (<-resumedAfter.Events()).Data)
+	resumedAfter.Close()
+	resumedAfter.Close()
+
+	// the first event fell out of the history, so the second was missed
+	missed := events.Event{Seq: 0}
+	_, resumed = bus.Subscribe([]string{events.UserTopic(0)}, bus.Id(missed))
+	assert.False(t, resumed)
+	for _, id := range []string{"elsewhere-3", "garbage", bus.Id(events.Event{Seq: 9})} {
+		_, resumed = bus.Subscribe([]string{events.UserTopic(0)}, id)
+		assert.False(t, resumed, id)
+	}
+
+	// a subscriber that stops reading is dropped instead of holding up the rest
+	slow, _ := bus.Subscribe([]string{events.UserTopic(0)}, "")
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, bus.Publish(events.TypeLikes, i, events.UserTopic(0)))
+	}
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Less(t, received, 100)
+	slow.Close()
+}
+
+// an event as read off an event stream
+type streamEvent struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// openEvents opens the caller's event stream, which stays open until the test ends.
+func (server *TestServer) openEvents(t *testing.T, token string, query string, lastEventId string) <-chan streamEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events"+query, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	stream := make(chan streamEvent, 16)
+	go func() {
+		defer close(stream)
+		scanner := bufio.NewScanner(resp.Body)
+		var event streamEvent
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				event.id = value
+			case "event":
+				event.eventType = value
+			case "data":
+				event.data = value
+			case "":
+				if event.eventType != "" {
+					stream <- event
+				}
+				event = streamEvent{}
+			}
+		}
+	}()
+	return stream
+}
+
+// nextEvent waits for the next event of a stream, failing the test if none comes.
+func nextEvent(t *testing.T, stream <-chan streamEvent, eventType string) streamEvent {
+	t.Helper()
+	select {
+	case event, ok := <-stream:
+		if !ok {
+			t.Fatalf("Event stream closed while waiting for a %v event", eventType)
+		}
+		assert.Equal(t, eventType, event.eventType, event.data)
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a %v event", eventType)
+	}
+	return streamEvent{}
+}
+
+func testEvents(t *testing.T, server *TestServer) {
+	owner := server.loginAs(t, "dev_user1")
+	act := func(username string, endpoint string, body string) {
+		t.Helper()
+		status, response := server.authRequest(t, http.MethodPost, endpoint, server.loginAs(t, username), body)
+		assert.Less(t, status, 300, "%v: %v", endpoint, response)
+	}
+
+	status, _ := server.authRequest(t, http.MethodGet, "/events?posts=2,abc", owner, "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = server.authRequest(t, http.MethodGet, "/events", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	stream := server.openEvents(t, owner, "?posts=2", "")
+
+	// a notification for dev_user1, the new like count of the watched post
+	// and a post by a user dev_user1 follows
+	act("backend_guru4", "/posts/backend_guru4/likes/1", "")
+	act("ui_designer5", "/posts/ui_designer5/likes/2", "")
+	act("data_scientist3", "/posts", `{"user":3,"project":3,"content":"Streaming now"}`)
+
+	notified := nextEvent(t, stream, events.TypeNotification)
+	var notification types.Notification
+	assert.NoError(t, json.Unmarshal([]byte(notified.data), &notification))
+	assert.Equal(t, "backend_guru4 liked your post", notification.Message)
+	assert.Equal(t, int64(1), notification.Target)
+
+	var likes types.LikeCount
+	assert.NoError(t, json.Unmarshal([]byte(nextEvent(t, stream, events.TypeLikes).data), &likes))
+	assert.Equal(t, types.LikeCount{Kind: "post", ID: 2, Likes: 26}, likes)
+
+	var post types.Post
+	assert.NoError(t, json.Unmarshal([]byte(nextEvent(t, stream, events.TypePost).data), &post))
+	assert.Equal(t, "Streaming now", post.Content)
+	assert.Equal(t, int64(3), post.User)
+
+	// a stream resuming after the notification gets the events it missed
+	resumed := server.openEvents(t, owner, "?posts=2", notified.id)
+	nextEvent(t, resumed, events.TypeLikes)
+	nextEvent(t, resumed, events.TypePost)
+
+	// one that cannot resume is told to reload instead
+	reset := server.openEvents(t, owner, "", "from-before-a-restart-1")
+	nextEvent(t, reset, events.TypeReset)
+}
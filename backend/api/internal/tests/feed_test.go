@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	firstPost  = `{"id":1,"user":1,"project":1,"likes":40,"content":"Excited to release the first version of OpenAPI Toolkit!","created_on":"2024-09-13T00:00:00Z"}`
+	secondPost = `{"id":2,"user":2,"project":2,"likes":25,"content":"We've archived DocuHelper, but feel free to explore the code.","created_on":"2024-06-13T00:00:00Z"}`
+	thirdPost  = `{"id":3,"user":3,"project":3,"likes":15,"content":"Updated ML Research repo with new algorithms for data analysis.","created_on":"2024-11-13T00:00:00Z"}`
+)
+
+var feed_tests []TestCase = []TestCase{
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=time&count=10",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + thirdPost + `,` + firstPost + `,` + secondPost + `]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=likes&count=1",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + firstPost + `]}`,
+		IgnoredFields:  []string{"next_cursor"},
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=time",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Missing required url query parameter: count"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=time&count=0",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Count must be at least 1, got 0"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=time&count=10&cursor=not-a-cursor",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Invalid cursor, pass back the next_cursor of the previous page"}`,
+	},
+
+	// the following feed has the posts of followed users and projects,
+	// dev_user1 follows data_scientist3 and the DocuHelper project
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=following&count=10",
+		Input:          "",
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + thirdPost + `,` + secondPost + `]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=following&count=10",
+		Input:          "",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Log in to see the following feed"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home?count=10",
+		Input:          "",
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + thirdPost + `,` + secondPost + `]}`,
+	},
+	// tech_writer2 follows backend_guru4, who has not posted, and OpenAPI Toolkit
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home?count=10",
+		Input:          "",
+		Username:       "tech_writer2",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + firstPost + `]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home?count=10",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home?count=10",
+		Input:          "",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home",
+		Input:          "",
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Missing required url query parameter: count"}`,
+	},
+
+	// following someone new brings their posts into the feed
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/site_admin6/follow/dev_user1",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"message":"site_admin6 now follows dev_user1"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/home?count=10",
+		Input:          "",
+		Username:       "site_admin6",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[` + firstPost + `]}`,
+	},
+
+	// lists only page when asked to, and keep their plain arrays otherwise
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/dev_user1/followers?count=5",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[4,6]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/dev_user1/followers/usernames?count=1",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":["backend_guru4"]}`,
+		IgnoredFields:  []string{"next_cursor"},
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/posts/by-user/2?cursor=abc",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Missing required url query parameter: count"}`,
+	},
+}
+
+// feedPage fetches a page of a paged list and returns the ids of its items
+// along with the cursor of the next page
+func (server *TestServer) feedPage(t *testing.T, endpoint string, cursor string, token string) ([]int, string) {
+	t.Helper()
+
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var page types.PageResponse[interface{}]
+	server.getJSON(t, endpoint, query, token, &page)
+
+	var ids []int
+	for _, item := range page.Items {
+		switch item := item.(type) {
+		case float64:
+			ids = append(ids, int(item))
+		case map[string]interface{}:
+			ids = append(ids, int(item["id"].(float64)))
+		}
+	}
+	return ids, page.NextCursor
+}
+
+// cursors keep their place while new posts come in, so scrolling on
+// neither skips nor repeats anything, which the table tests cannot show
+func testFeedPaging(t *testing.T, server *TestServer) {
+	token := server.loginAs(t, "dev_user1")
+
+	ids, cursor := server.feedPage(t, "/feed/posts?type=time&count=2", "", "")
+	assert.Equal(t, []int{3, 1}, ids)
+	assert.NotEmpty(t, cursor)
+
+	// a post arriving while the user scrolls lands on top of the feed
+	status, body := server.authRequest(t, http.MethodPost, "/posts", token, `{"user":1,"project":1,"content":"Posted mid scroll"}`)
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+
+	ids, cursor = server.feedPage(t, "/feed/posts?type=time&count=2", cursor, "")
+	assert.Equal(t, []int{2}, ids)
+	assert.Empty(t, cursor)
+
+	ids, _ = server.feedPage(t, "/feed/posts?type=time&count=10", "", "")
+	assert.Equal(t, []int{4, 3, 1, 2}, ids)
+
+	// walking the likes feed one post at a time visits every post once
+	var walked []int
+	for page := 0; page < 10; page++ {
+		ids, cursor = server.feedPage(t, "/feed/posts?type=likes&count=1", cursor, "")
+		walked = append(walked, ids...)
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, walked)
+
+	for _, endpoint := range []string{"/feed/projects?type=time&count=2", "/feed/projects?type=likes&count=2"} {
+		walked = nil
+		for page := 0; page < 10; page++ {
+			ids, cursor = server.feedPage(t, endpoint, cursor, "")
+			walked = append(walked, ids...)
+			if cursor == "" {
+				break
+			}
+		}
+		assert.ElementsMatch(t, []int{1, 2, 3, 4}, walked, endpoint)
+	}
+
+	// the comments on the first post are 7 to 12
+	ids, cursor = server.feedPage(t, "/comments/by-post/1?count=4", "", "")
+	assert.Equal(t, []int{7, 8, 9, 10}, ids)
+	ids, cursor = server.feedPage(t, "/comments/by-post/1?count=4", cursor, "")
+	assert.Equal(t, []int{11, 12}, ids)
+	assert.Empty(t, cursor)
+
+	ids, cursor = server.feedPage(t, "/posts/by-user/1?count=1", "", "")
+	assert.Equal(t, []int{1}, ids)
+	ids, _ = server.feedPage(t, "/posts/by-user/1?count=1", cursor, "")
+	assert.Equal(t, []int{4}, ids)
+
+	ids, cursor = server.feedPage(t, "/feed/home?count=1", "", token)
+	assert.Equal(t, []int{3}, ids)
+	ids, cursor = server.feedPage(t, "/feed/home?count=1", cursor, token)
+	assert.Equal(t, []int{2}, ids)
+	assert.Empty(t, cursor, fmt.Sprintf("dev_user1 only follows two posts, got cursor %q", cursor))
+}
+
+// the trending feeds rank by recent activity, so an old project or post
+// climbs back to the top as soon as people interact with it again
+func testTrendingFeed(t *testing.T, server *TestServer) {
+	token := server.loginAs(t, "ui_designer5")
+
+	// the first post and project had comments the most recently
+	ids, _ := server.feedPage(t, "/feed/posts?type=trending&count=10", "", "")
+	assert.Equal(t, []int{1, 3, 2}, ids)
+	ids, _ = server.feedPage(t, "/feed/projects?type=trending&count=10", "", "")
+	assert.Equal(t, []int{1, 3, 4, 2}, ids)
+
+	// DocuHelper is the oldest project, until someone likes it today
+	status, body := server.authRequest(t, http.MethodPost, "/projects/ui_designer5/likes/2", token, "")
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+	ids, _ = server.feedPage(t, "/feed/projects?type=trending&count=10", "", "")
+	assert.Equal(t, []int{2, 1, 3, 4}, ids)
+
+	// taking the like back puts it back where it was
+	status, body = server.authRequest(t, http.MethodPost, "/projects/ui_designer5/unlikes/2", token, "")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	ids, _ = server.feedPage(t, "/feed/projects?type=trending&count=10", "", "")
+	assert.Equal(t, []int{1, 3, 4, 2}, ids)
+
+	// follows and comments count too
+	status, body = server.authRequest(t, http.MethodPost, "/projects/ui_designer5/follow/4", token, "")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	ids, _ = server.feedPage(t, "/feed/projects?type=trending&count=1", "", "")
+	assert.Equal(t, []int{4}, ids)
+
+	// a new post starts out on top, and a post liked after it passes it
+	status, body = server.authRequest(t, http.MethodPost, "/posts", token, `{"user":5,"project":3,"content":"Trending soon"}`)
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+	ids, _ = server.feedPage(t, "/feed/posts?type=trending&count=1", "", "")
+	assert.Equal(t, []int{4}, ids)
+
+	status, body = server.authRequest(t, http.MethodPost, "/posts/ui_designer5/likes/2", token, "")
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+
+	// walking the feed one post at a time visits every post once
+	var walked []int
+	var cursor string
+	for page := 0; page < 10; page++ {
+		ids, cursor = server.feedPage(t, "/feed/posts?type=trending&count=1", cursor, "")
+		walked = append(walked, ids...)
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []int{2, 4, 1, 3}, walked)
+}
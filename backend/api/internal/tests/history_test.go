@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var history_tests []TestCase = []TestCase{
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/posts/2/history",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[]`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/posts/999/history",
+		Input:          "",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Post with id '999' not found"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/999/history",
+		Input:          "",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Comment with id 999 not found"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/can-edit/1",
+		Input:          "",
+		Username:       "moderator7",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"status":true}`,
+	},
+}
+
+// backdateComment moves a comment's creation back by `age`, past the edit window.
+func (server *TestServer) backdateComment(t *testing.T, id int64, age time.Duration) {
+	t.Helper()
+	db := server.database(t)
+	if _, err := db.Exec(`UPDATE Comments SET creation_date = ? WHERE id = ?`, time.Now().UTC().Add(-age), id); err != nil {
+		t.Fatalf("Failed to backdate comment %v: %v", id, err)
+	}
+}
+
+func testEditHistory(t *testing.T, server *TestServer) {
+	comment := func(token string, user int64, content string) int64 {
+		t.Helper()
+		status, body := server.authRequest(t, http.MethodPost, "/comments/for-post/1", token,
+			fmt.Sprintf(`{"user":%v,"content":%q,"parent_comment":null}`, user, content))
+		assert.Equal(t, http.StatusCreated, status, "%v", body)
+		var id int64
+		fmt.Sscanf(body["message"].(string), "Comment created successfully with id %d", &id)
+		return id
+	}
+	edit := func(token string, endpoint string, content string) (int, map[string]interface{}) {
+		t.Helper()
+		return server.authRequest(t, http.MethodPut, endpoint, token, fmt.Sprintf(`{"content":%q}`, content))
+	}
+
+	// every edit keeps what the comment said before it
+	token := server.loginAs(t, "dev_user1")
+	id := comment(token, 1, "frist")
+	endpoint := fmt.Sprintf("/comments/%v", id)
+	status, body := edit(token, endpoint, "first")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	status, body = edit(token, endpoint, "first!")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+
+	var edited types.Comment
+	server.getJSON(t, endpoint, nil, "", &edited)
+	assert.Equal(t, "first!", edited.Content)
+	var revisions []types.Revision
+	server.getJSON(t, endpoint+"/history", nil, "", &revisions)
+	if assert.Len(t, revisions, 2) && assert.NotNil(t, edited.EditedAt) {
+		assert.Equal(t, "first", revisions[0].Content)
+		assert.Equal(t, "frist", revisions[1].Content)
+		assert.True(t, edited.EditedAt.Equal(revisions[0].EditedAt), "the comment was last edited when its latest revision was made")
+	}
+
+	// and so does every edit of a post
+	status, body = edit(token, "/posts/1", "Version one of OpenAPI Toolkit is out!")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+	var post types.Post
+	server.getJSON(t, "/posts/1", nil, "", &post)
+	assert.NotNil(t, post.EditedAt)
+	server.getJSON(t, "/posts/1/history", nil, "", &revisions)
+	if assert.Len(t, revisions, 1) {
+		assert.Equal(t, "Excited to release the first version of OpenAPI Toolkit!", revisions[0].Content)
+	}
+
+	// comments cannot be edited once the window has closed
+	server.backdateComment(t, id, time.Hour)
+	status, body = edit(token, endpoint, "first?")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "Error updating comment: Cannot update comment. More than 2 minutes have passed since posting.", body["message"])
+
+	// unless they were written by a moderator
+	moderator := server.loginAs(t, "moderator7")
+	id = comment(moderator, 7, "Please keep it civil")
+	server.backdateComment(t, id, time.Hour)
+	status, body = edit(moderator, fmt.Sprintf("/comments/%v", id), "Please keep it civil, everyone")
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+}
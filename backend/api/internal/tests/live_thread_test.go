@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/internal/events"
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// openThread connects to the live thread of a post or project, anonymously
+// if `token` is empty. The socket is closed once the test ends.
+func (server *TestServer) openThread(t *testing.T, endpoint string, token string) *websocket.Conn {
+	t.Helper()
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+endpoint, server.URL)
+	if err != nil {
+		t.Fatalf("Failed to configure socket: %v", err)
+	}
+	if token != "" {
+		config.Header.Set("Authorization", "Bearer "+token)
+	}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to connect to %v: %v", endpoint, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nextThreadEvent waits for the next event of a live thread, failing the
+// test if none comes, and decodes its data into `data`.
+func nextThreadEvent(t *testing.T, conn *websocket.Conn, eventType string, data interface{}) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event types.ThreadEvent
+	if err := websocket.JSON.Receive(conn, &event); err != nil {
+		t.Fatalf("Failed to receive a %v event: %v", eventType, err)
+	}
+	assert.Equal(t, eventType, event.Type, string(event.Data))
+	assert.NoError(t, json.Unmarshal(event.Data, data))
+}
+
+func testLiveThreads(t *testing.T, server *TestServer) {
+	writer := server.loginAs(t, "tech_writer2")
+	owner := server.loginAs(t, "dev_user1")
+	act := func(method string, endpoint string, token string, body string) {
+		t.Helper()
+		status, response := server.authRequest(t, method, endpoint, token, body)
+		assert.Less(t, status, 300, "%v: %v", endpoint, response)
+	}
+
+	for endpoint, expected := range map[string]int{
+		"/ws/threads/post/9999": http.StatusNotFound,
+		"/ws/threads/user/1":    http.StatusBadRequest,
+		"/ws/threads/post/abc":  http.StatusBadRequest,
+	} {
+		status, _ := server.authRequest(t, http.MethodGet, endpoint, "", "")
+		assert.Equal(t, expected, status, endpoint)
+	}
+
+	watcher := server.openThread(t, "/ws/threads/post/1", "")
+	author := server.openThread(t, "/ws/threads/post/1", writer)
+
+	// comments on other threads are not sent
+	act(http.MethodPost, "/comments/for-post/2", owner, `{"user":1,"content":"Elsewhere","parent_comment":null}`)
+	act(http.MethodPost, "/comments/for-post/1", writer, `{"user":2,"content":"Live!","parent_comment":null}`)
+	var comment types.Comment
+	nextThreadEvent(t, watcher, events.TypeComment, &comment)
+	assert.Equal(t, "Live!", comment.Content)
+	assert.Equal(t, int64(2), comment.User)
+
+	// replies are part of the thread of the comment they reply to
+	act(http.MethodPost, fmt.Sprintf("/comments/for-comment/%v", comment.ID), owner, fmt.Sprintf(`{"user":1,"content":"Welcome","parent_comment":%v}`, comment.ID))
+	var reply types.Comment
+	nextThreadEvent(t, watcher, events.TypeComment, &reply)
+	assert.Equal(t, "Welcome", reply.Content)
+	assert.Equal(t, comment.ID, reply.ParentComment.Int64)
+
+	act(http.MethodPut, fmt.Sprintf("/comments/%v", comment.ID), writer, `{"content":"Live, edited"}`)
+	var edited types.Comment
+	nextThreadEvent(t, watcher, events.TypeCommentEdited, &edited)
+	assert.Equal(t, "Live, edited", edited.Content)
+	assert.NotNil(t, edited.EditedAt)
+
+	act(http.MethodPost, fmt.Sprintf("/comments/dev_user1/likes/%v", comment.ID), owner, "")
+	var likes types.LikeCount
+	nextThreadEvent(t, watcher, events.TypeLikes, &likes)
+	assert.Equal(t, types.LikeCount{Kind: "comment", ID: comment.ID, Likes: 1}, likes)
+
+	act(http.MethodDelete, fmt.Sprintf("/comments/%v", reply.ID), owner, "")
+	var deleted types.Comment
+	nextThreadEvent(t, watcher, events.TypeCommentDeleted, &deleted)
+	assert.Equal(t, reply.ID, deleted.ID)
+	assert.NotNil(t, deleted.DeletedAt)
+	assert.NotEqual(t, reply.Content, deleted.Content)
+
+	// typing is only passed on for logged in users
+	assert.NoError(t, websocket.JSON.Send(watcher, types.ThreadMessage{Type: events.TypeTyping}))
+	assert.NoError(t, websocket.JSON.Send(author, types.ThreadMessage{Type: events.TypeTyping, Parent: &comment.ID}))
+	var typing types.Typing
+	nextThreadEvent(t, watcher, events.TypeTyping, &typing)
+	assert.Equal(t, types.Typing{User: "tech_writer2", Parent: &comment.ID}, typing)
+
+	// and only every few seconds, however often the client sends it
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, websocket.JSON.Send(author, types.ThreadMessage{Type: events.TypeTyping}))
+	}
+	// give the server time to read them before anything else happens
+	time.Sleep(100 * time.Millisecond)
+	act(http.MethodPost, "/comments/for-post/1", writer, `{"user":2,"content":"Typed slowly","parent_comment":null}`)
+	nextThreadEvent(t, watcher, events.TypeComment, &comment)
+	assert.Equal(t, "Typed slowly", comment.Content)
+}
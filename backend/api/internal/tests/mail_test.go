@@ -0,0 +1,426 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/api/internal/config"
+	"backend/api/internal/mail"
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a message as the fake SMTP server got it
+type smtpMessage struct {
+	// the credentials the client logged in with, empty if it did not
+	login string
+	from  string
+	to    []string
+	data  string
+}
+
+// fakeSMTP is a local SMTP server that accepts every message, speaking
+// just enough of the protocol for net/smtp.
+type fakeSMTP struct {
+	listener net.Listener
+	messages chan smtpMessage
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fakeSMTP{listener: listener, messages: make(chan smtpMessage, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (server *fakeSMTP) port() int {
+	return server.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (server *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%v\r\n", line) }
+
+	var message smtpMessage
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, "AUTH PLAIN "))
+			message.login = strings.TrimPrefix(string(credentials), "\x00")
+			reply("235 Authenticated")
+		case "MAIL":
+			message.from = strings.Trim(strings.TrimPrefix(command, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			message.to = append(message.to, strings.Trim(strings.TrimPrefix(command, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			message.data = data.String()
+			server.messages <- message
+			message = smtpMessage{login: message.login}
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// next waits for the next message the server got.
+func (server *fakeSMTP) next(t *testing.T) smtpMessage {
+	t.Helper()
+	select {
+	case message := <-server.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for an email")
+		return smtpMessage{}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newFakeSMTP(t)
+	mailer := mail.NewSMTPMailer("127.0.0.1", server.port(), "devbits", "hunter2", "DevBits <no-reply@devbits.test>", time.Second)
+
+	err := mailer.Send(mail.Message{To: "alice@example.com", Subject: "Hello", Body: "first line\nsecond line\n"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	message := server.next(t)
+	assert.Equal(t, "devbits\x00hunter2", message.login)
+	assert.Equal(t, "no-reply@devbits.test", message.from)
+	assert.Equal(t, []string{"alice@example.com"}, message.to)
+	assert.Contains(t, message.data, "From: DevBits <no-reply@devbits.test>\r\n")
+	assert.Contains(t, message.data, "To: alice@example.com\r\n")
+	assert.Contains(t, message.data, "Subject: Hello\r\n")
+	assert.Contains(t, message.data, "\r\n\r\nfirst line\r\nsecond line\r\n")
+
+	// headers cannot be smuggled in through the subject
+	err = mailer.Send(mail.Message{To: "alice@example.com", Subject: "Hi\r\nBcc: eve@example.com", Body: ""})
+	assert.Error(t, err)
+
+	// and a server that is not there fails the send instead of hanging
+	server.listener.Close()
+	assert.Error(t, mailer.Send(mail.Message{To: "alice@example.com", Subject: "Hello", Body: ""}))
+}
+
+// recordingMailer keeps the messages it is given, failing while `fail` is set.
+type recordingMailer struct {
+	mu       sync.Mutex
+	fail     bool
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(message mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("mail server is down")
+	}
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// take returns the messages sent since it was last called.
+func (m *recordingMailer) take() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := m.messages
+	m.messages = nil
+	return messages
+}
+
+func testDigests(t *testing.T, server *TestServer) {
+	token := server.loginAs(t, "dev_user1")
+	endpoint := "/users/dev_user1/email-preferences"
+
+	status, preferences := server.authRequest(t, http.MethodGet, endpoint, token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, types.DigestOff, preferences["digest"])
+
+	for _, invalid := range []string{
+		`{"email":"not an address","digest":"daily"}`,
+		`{"email":"Dev <dev@example.com>","digest":"daily"}`,
+		`{"email":"dev@example.com","digest":"hourly"}`,
+		`{"email":"dev@example.com"}`,
+	} {
+		status, response := server.authRequest(t, http.MethodPut, endpoint, token, invalid)
+		assert.Equal(t, http.StatusBadRequest, status, "%v: %v", invalid, response)
+	}
+	status, _ = server.authRequest(t, http.MethodPut, endpoint, server.loginAs(t, "tech_writer2"), `{"email":"dev@example.com","digest":"daily"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// what happened before digests were turned on is left out of them
+	act := func(username string, endpoint string, body string) {
+		t.Helper()
+		status, response := server.authRequest(t, http.MethodPost, endpoint, server.loginAs(t, username), body)
+		assert.Less(t, status, 300, "%v: %v", endpoint, response)
+	}
+	act("backend_guru4", "/posts/backend_guru4/likes/1", "")
+
+	// the address has to be confirmed through the link emailed to it
+	status, response := server.authRequest(t, http.MethodPut, endpoint, token, `{"email":"dev@example.com","digest":"daily","confirmed":true}`)
+	assert.Equal(t, http.StatusOK, status, "%v", response)
+	assert.Equal(t, false, response["confirmed"])
+	status, preferences = server.authRequest(t, http.MethodGet, endpoint, token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "dev@example.com", preferences["email"])
+	assert.Equal(t, types.DigestDaily, preferences["digest"])
+	assert.Equal(t, false, preferences["confirmed"])
+	emailed := server.Mail.take()
+	if !assert.Len(t, emailed, 1) {
+		return
+	}
+	assert.Equal(t, "dev@example.com", emailed[0].To)
+	assert.Equal(t, "Confirm your email address for DevBits", emailed[0].Subject)
+	confirm := emailedLink(t, emailed[0].Body, "/email/confirm")
+
+	// the link already sent stays valid, and no other address is sent one for a while
+	status, _ = server.authRequest(t, http.MethodPut, endpoint, token, `{"email":"dev@example.com","digest":"daily"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = server.authRequest(t, http.MethodPut, endpoint, token, `{"email":"victim@example.com","digest":"daily"}`)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Empty(t, server.Mail.take())
+
+	act("data_scientist3", "/posts/data_scientist3/likes/1", "")
+	act("ui_designer5", "/posts/ui_designer5/likes/1", "")
+	act("moderator7", "/users/moderator7/follow/dev_user1", "")
+	act("tech_writer2", "/comments/for-post/1", `{"user":2,"content":"Nice work"}`)
+
+	mailer := &recordingMailer{}
+	digester := mail.NewDigester(server.Stores.Digests, mailer, config.Default().Mail.LinkURL)
+	now := time.Now().UTC()
+
+	// nothing is sent until the address is confirmed, with a link that only works once
+	assert.Equal(t, 0, digester.SendDue(now.Add(25*time.Hour)))
+	status, response = server.authRequest(t, http.MethodGet, confirm, "", "")
+	assert.Equal(t, http.StatusOK, status, "%v", response)
+	status, _ = server.authRequest(t, http.MethodGet, confirm, "", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, preferences = server.authRequest(t, http.MethodGet, endpoint, token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, preferences["confirmed"])
+
+	// and then not until a day has passed
+	assert.Equal(t, 0, digester.SendDue(now))
+	assert.Equal(t, 1, digester.SendDue(now.Add(25*time.Hour)))
+	sent := mailer.take()
+	if !assert.Len(t, sent, 1) {
+		return
+	}
+	assert.Equal(t, "dev@example.com", sent[0].To)
+	assert.Equal(t, "Your daily DevBits digest: 1 new follower, 2 new likes, 1 new reply", sent[0].Subject)
+	assert.Contains(t, sent[0].Body, "Hi dev_user1,")
+	assert.Contains(t, sent[0].Body, "  - tech_writer2 commented on your post\n")
+	assert.Contains(t, sent[0].Body, "  - moderator7 followed you\n")
+	assert.Contains(t, sent[0].Body, "  - ui_designer5 and 1 other liked your post\n")
+	unsubscribe := emailedLink(t, sent[0].Body, "/email/unsubscribe")
+
+	// a digest is only sent once a day, and only with something in it
+	assert.Equal(t, 0, digester.SendDue(now.Add(26*time.Hour)))
+	assert.Equal(t, 0, digester.SendDue(now.Add(50*time.Hour)))
+
+	// a digest that fails to send is carried over to the next one
+	act("moderator7", "/posts/moderator7/likes/1", "")
+	mailer.fail = true
+	assert.Equal(t, 0, digester.SendDue(now.Add(75*time.Hour)))
+	mailer.fail = false
+	act("site_admin6", "/users/site_admin6/follow/dev_user1", "")
+	assert.Equal(t, 1, digester.SendDue(now.Add(100*time.Hour)))
+	sent = mailer.take()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Your daily DevBits digest: 1 new follower, 1 new like", sent[0].Subject)
+	}
+
+	// notifications already read in the app are left out
+	act("ui_designer5", "/users/ui_designer5/follow/dev_user1", "")
+	status, _ = server.authRequest(t, http.MethodPost, "/notifications/read", token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, digester.SendDue(now.Add(125*time.Hour)))
+
+	// the unsubscribe link in the digest turns them off
+	status, _ = server.authRequest(t, http.MethodGet, unsubscribe+"x", "", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, response = server.authRequest(t, http.MethodGet, unsubscribe, "", "")
+	assert.Equal(t, http.StatusOK, status, "%v", response)
+	status, preferences = server.authRequest(t, http.MethodGet, endpoint, token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, types.DigestOff, preferences["digest"])
+
+	// turning them back on keeps the address confirmed
+	status, preferences = server.authRequest(t, http.MethodPut, endpoint, token, `{"email":"dev@example.com","digest":"daily"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, preferences["confirmed"])
+	assert.Empty(t, server.Mail.take())
+
+	// and nothing is sent once digests are turned off
+	status, _ = server.authRequest(t, http.MethodPut, endpoint, token, `{"email":"dev@example.com","digest":"off"}`)
+	assert.Equal(t, http.StatusOK, status)
+	act("data_scientist3", "/users/data_scientist3/follow/dev_user1", "")
+	assert.Equal(t, 0, digester.SendDue(now.Add(30*24*time.Hour)))
+	assert.Empty(t, mailer.take())
+}
+
+// emailedLink finds the link to `path` in the body of an email, and returns
+// it relative to the api so it can be followed on the test server.
+func emailedLink(t *testing.T, body string, path string) string {
+	t.Helper()
+
+	linkURL := config.Default().Mail.LinkURL
+	start := strings.Index(body, linkURL+path+"?")
+	if start < 0 {
+		t.Fatalf("Expected a link to %v in the email: %v", path, body)
+	}
+	return strings.TrimPrefix(strings.Fields(body[start:])[0], linkURL)
+}
+
+func TestStoreDigests(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			registerUser(t, stores, "bob")
+			carol := registerUser(t, stores, "carol")
+
+			preferences, _, err := stores.Digests.QueryEmailPreferences(alice)
+			assert.NoError(t, err)
+			assert.Equal(t, types.EmailPreferences{Digest: types.DigestOff}, preferences)
+
+			now := time.Now().UTC()
+			sendLink, _, err := stores.Digests.QuerySetEmailPreferences(alice, types.EmailPreferences{Email: "alice@example.com", Digest: types.DigestWeekly}, "alice-hash", now)
+			assert.NoError(t, err)
+			assert.True(t, sendLink)
+			_, _, err = stores.Digests.QuerySetEmailPreferences(carol, types.EmailPreferences{Email: "carol@example.com", Digest: types.DigestDaily}, "carol-hash", now)
+			assert.NoError(t, err)
+
+			// unconfirmed addresses get no digests
+			due, _, err := stores.Digests.QueryDueDigests(types.DigestWeekly, now.Add(time.Hour), 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+
+			// links are only sent again once the last one is old enough, and
+			// changing address in between has to wait
+			sendLink, _, err = stores.Digests.QuerySetEmailPreferences(alice, types.EmailPreferences{Email: "alice@example.com", Digest: types.DigestWeekly}, "other-hash", now.Add(time.Minute))
+			assert.NoError(t, err)
+			assert.False(t, sendLink)
+			_, code, err := stores.Digests.QuerySetEmailPreferences(alice, types.EmailPreferences{Email: "mallory@example.com", Digest: types.DigestWeekly}, "other-hash", now.Add(time.Minute))
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, code)
+
+			code, err = stores.Digests.QueryConfirmEmail("other-hash", now)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, code)
+			for _, hash := range []string{"alice-hash", "carol-hash"} {
+				_, err = stores.Digests.QueryConfirmEmail(hash, now)
+				assert.NoError(t, err)
+			}
+			code, _ = stores.Digests.QueryConfirmEmail("alice-hash", now)
+			assert.Equal(t, http.StatusNotFound, code)
+			preferences, _, err = stores.Digests.QueryEmailPreferences(alice)
+			assert.NoError(t, err)
+			assert.Equal(t, types.EmailPreferences{Email: "alice@example.com", Digest: types.DigestWeekly, Confirmed: true}, preferences)
+
+			_, err = stores.Users.CreateNewUserFollow("bob", "alice")
+			assert.NoError(t, err)
+			_, err = stores.Users.CreateNewUserFollow("carol", "alice")
+			assert.NoError(t, err)
+
+			// digests are due once their frequency passed since the last one
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestWeekly, now.Add(-time.Hour), 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestWeekly, now, 10)
+			assert.NoError(t, err)
+			if !assert.Len(t, due, 1) {
+				return
+			}
+			digest := due[0]
+			assert.Equal(t, alice, digest.User)
+			assert.Equal(t, "alice", digest.Username)
+			assert.Equal(t, "alice@example.com", digest.Email)
+			assert.Equal(t, int64(2), digest.Followers)
+			assert.Equal(t, int64(0), digest.Likes)
+			assert.Greater(t, digest.Through, digest.Since)
+			if assert.Len(t, digest.Highlights, 1) {
+				assert.Equal(t, "carol and 1 other followed you", digest.Highlights[0].Message)
+			}
+
+			_, err = stores.Digests.QueryMarkDigestSent(alice, digest.Through, now.Add(time.Minute))
+			assert.NoError(t, err)
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestWeekly, now, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestWeekly, now.Add(time.Hour), 10)
+			assert.NoError(t, err)
+			if assert.Len(t, due, 1) {
+				assert.Equal(t, digest.Through, due[0].Since)
+				assert.Equal(t, int64(0), due[0].Followers)
+				assert.Empty(t, due[0].Highlights)
+			}
+
+			// a new address has to be confirmed again
+			sendLink, _, err = stores.Digests.QuerySetEmailPreferences(alice, types.EmailPreferences{Email: "alice@example.org", Digest: types.DigestWeekly}, "new-hash", now.Add(time.Hour))
+			assert.NoError(t, err)
+			assert.True(t, sendLink)
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestWeekly, now.Add(time.Hour), 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+
+			// deleted users get no digests
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestDaily, now, 10)
+			assert.NoError(t, err)
+			assert.Len(t, due, 1)
+			_, err = stores.Users.QueryDeleteUser("carol", carol)
+			assert.NoError(t, err)
+			due, _, err = stores.Digests.QueryDueDigests(types.DigestDaily, now, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+		})
+	}
+}
@@ -8,15 +8,35 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
     "fmt"
     "os"
-    "database/sql"
 
+	"backend/api/internal/auth"
+	"backend/api/internal/blobs"
+	"backend/api/internal/config"
+	"backend/api/internal/database"
+	"backend/api/internal/events"
+	"backend/api/internal/handlers"
+	"backend/api/internal/logger"
+	"backend/api/internal/store"
+	"backend/api/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -26,6 +46,194 @@ type TestCase struct {
 	Input          string
 	ExpectedStatus int
 	ExpectedBody   string
+	// fields of a JSON response that change between runs, such as
+	// generated tokens, and are left out of the comparison. Nested fields
+	// are written as paths like `post.edited_at`, and a path into a list
+	// applies to each of its items, or only to one if it starts with an
+	// index like `6.created_on`
+	IgnoredFields []string
+	// the user the request is sent as, leave empty for anonymous requests
+	Username string
+}
+
+// every user created by the tests or the test data uses this password
+const testPassword = "password123"
+
+var seededUsers = []string{"dev_user1", "tech_writer2", "data_scientist3", "backend_guru4", "ui_designer5", "site_admin6", "moderator7"}
+
+// TestServer is the api served in-process by httptest, on top of stores
+// of its own, so tests neither need a running api nor share any data
+type TestServer struct {
+	*httptest.Server
+	Stores store.Stores
+	// the emails the api sent, like the links confirming addresses
+	Mail *recordingMailer
+
+	// session tokens of the users the tests have logged in as
+	sessionTokens     map[string]string
+	sessionTokensLock sync.Mutex
+}
+
+// the process wide settings every test server relies on
+var setupOnce sync.Once
+
+// newTestServerWith serves the full router on top of `stores`
+func newTestServerWith(t *testing.T, stores store.Stores) *TestServer {
+	t.Helper()
+
+	setupOnce.Do(func() {
+		gin.SetMode(gin.TestMode)
+		logger.InitLogger()
+		logger.SetLevel("warn")
+		auth.SetSigningKey([]byte("test-signing-key"))
+	})
+
+	stores, err := store.WithAutocomplete(stores)
+	if err != nil {
+		t.Fatalf("Failed to build the autocomplete index: %v", err)
+	}
+	defaults := config.Default()
+	stores, err = store.WithEvents(stores, events.NewBus(defaults.Events.History, defaults.Events.KeepAlive.Duration))
+	if err != nil {
+		t.Fatalf("Failed to start publishing events: %v", err)
+	}
+	// the receivers of the tests listen on loopback
+	dispatcher := webhooks.NewDispatcher(stores.Webhooks, defaults.Webhooks.MaxAttempts, defaults.Webhooks.RetryBackoff.Duration,
+		defaults.Webhooks.Timeout.Duration, true)
+	stores = store.WithWebhooks(stores, dispatcher)
+	// stopped before the database is closed under it
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx, defaults.Webhooks.PollInterval.Duration)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		stop()
+		<-stopped
+	})
+
+	// uploads are served by the api itself, from a directory of the test
+	storage, err := blobs.NewLocal(t.TempDir(), "", defaults.Mail.LinkURL)
+	if err != nil {
+		t.Fatalf("Failed to set up upload storage: %v", err)
+	}
+	mailer := &recordingMailer{}
+	server := handlers.NewServer(stores, storage, mailer, defaults.Mail.LinkURL, defaults.Limits)
+	httpServer := httptest.NewServer(handlers.NewRouter(server, defaults.CORS.AllowedOrigins))
+	t.Cleanup(httpServer.Close)
+
+	return &TestServer{Server: httpServer, Stores: stores, Mail: mailer, sessionTokens: map[string]string{}}
+}
+
+// NewTestServer starts an api on a fresh database loaded with the test data,
+// which is thrown away once the test finishes
+func NewTestServer(t *testing.T) *TestServer {
+	t.Helper()
+
+	db := openTestDatabase(t)
+	if err := LoadTestData(db); err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+	return newTestServerWith(t, store.NewSQL(db))
+}
+
+// database returns the database behind the server's stores. Feed is
+// never wrapped, unlike the stores that autocomplete or publish events
+func (server *TestServer) database(t *testing.T) *database.Database {
+	t.Helper()
+	db, ok := server.Stores.Feed.(*database.Database)
+	if !ok {
+		t.Fatalf("The stores are not backed by a database")
+	}
+	return db
+}
+
+// loginAs logs in as the given user, reusing the session if the
+// test already logged in as them on this server
+func (server *TestServer) loginAs(t *testing.T, username string) string {
+	t.Helper()
+
+	server.sessionTokensLock.Lock()
+	defer server.sessionTokensLock.Unlock()
+	if token, ok := server.sessionTokens[username]; ok {
+		return token
+	}
+
+	var login struct {
+		Tokens struct {
+			AccessToken string `json:"access_token"`
+		} `json:"tokens"`
+	}
+	body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, testPassword)
+	if status := server.request(t, http.MethodPost, "/auth/login", nil, "", body, &login); status != http.StatusOK {
+		t.Fatalf("Failed to log in as %v: status %v", username, status)
+	}
+
+	server.sessionTokens[username] = login.Tokens.AccessToken
+	return login.Tokens.AccessToken
+}
+
+// authRequest sends a JSON request with an optional access token and
+// returns the status along with the decoded response body
+func (server *TestServer) authRequest(t *testing.T, method string, endpoint string, token string, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	var decoded map[string]interface{}
+	status := server.request(t, method, endpoint, nil, token, body, &decoded)
+	return status, decoded
+}
+
+// request sends a JSON request like authRequest, with `query` added to
+// any parameters already in the endpoint, and decodes the response body
+// into `target`, which can be of any type. It returns the status
+func (server *TestServer) request(t *testing.T, method string, endpoint string, query url.Values, token string, body string, target interface{}) int {
+	t.Helper()
+
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint += separator + query.Encode()
+	}
+	req, err := http.NewRequest(method, server.URL+endpoint, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// decoded in two steps, so an error in place of the expected body is reported as such
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatalf("Expected a JSON response from %s %s: %v", method, endpoint, err)
+	}
+	// a reused target starts over, or what the response leaves out would keep its old value
+	reflect.ValueOf(target).Elem().SetZero()
+	if err := json.Unmarshal(raw, target); err != nil {
+		t.Fatalf("Unexpected response from %s %s with status %v: %s", method, endpoint, resp.StatusCode, raw)
+	}
+	return resp.StatusCode
+}
+
+// getJSON gets `endpoint` with `query` and an optional access token, and
+// decodes the response into `target`, failing the test if it is rejected
+func (server *TestServer) getJSON(t *testing.T, endpoint string, query url.Values, token string, target interface{}) {
+	t.Helper()
+
+	status := server.request(t, http.MethodGet, endpoint, query, token, "", target)
+	if !assert.Equal(t, http.StatusOK, status, "GET %v %v", endpoint, query.Encode()) {
+		t.FailNow()
+	}
 }
 
 var main_tests []TestCase = []TestCase{
@@ -45,32 +253,87 @@ var main_tests []TestCase = []TestCase{
 	},
 }
 
-func ResetTestDatabase(db *sql.DB) error {
-	files := []string{
-		"../database/create_tables.sql",
-		"../database/create_test_data.sql",
+// every postgres test database is a schema with a unique name
+var testSchemaCount atomic.Int64
+
+// openTestDatabase opens an empty database for a single test. That is a
+// temporary sqlite file by default, or a schema of its own in the database
+// DEVBITS_DB_DSN points at when DEVBITS_DB_DRIVER is set to postgres.
+func openTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	driver := os.Getenv("DEVBITS_DB_DRIVER")
+	if driver == "" || driver == string(database.SQLite) {
+		return openTempDatabase(t)
 	}
 
-	for _, file := range files {
-		sqlBytes, err := os.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %v", file, err)
-		}
+	dsn := os.Getenv("DEVBITS_DB_DSN")
+	admin, err := database.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d_%d", time.Now().UnixNano(), testSchemaCount.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("Failed to create schema %v: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
 
-		_, err = db.Exec(string(sqlBytes))
+	// lib/pq passes unknown settings on to the server, so every
+	// connection of the test's pool starts out in its own schema
+	if strings.Contains(dsn, "://") {
+		parsed, err := url.Parse(dsn)
 		if err != nil {
-			return fmt.Errorf("failed to execute SQL from file %s: %v", file, err)
+			t.Fatalf("Failed to parse DEVBITS_DB_DSN: %v", err)
 		}
+		query := parsed.Query()
+		query.Set("search_path", schema)
+		parsed.RawQuery = query.Encode()
+		dsn = parsed.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := database.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// LoadTestData brings an empty database up to date and loads the test
+// data, so every test starts from the same rows
+func LoadTestData(db *database.Database) error {
+	if _, err := database.MigrateUp(db); err != nil {
+		return fmt.Errorf("failed to apply migrations: %v", err)
 	}
 
-	fmt.Println("Test database reset successfully.")
+	file := "../database/create_test_data.sql"
+	sqlBytes, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %v", file, err)
+	}
+
+	_, err = db.Exec(string(sqlBytes))
+	if err != nil {
+		return fmt.Errorf("failed to execute SQL from file %s: %v", file, err)
+	}
+
+	// the test data is inserted without trending scores, like the rows
+	// of a database that was just migrated
+	if _, err := db.BackfillTrendingScores(); err != nil {
+		return fmt.Errorf("failed to compute trending scores: %v", err)
+	}
 	return nil
 }
 
-func (tc *TestCase) Run(t *testing.T) {
+func (tc *TestCase) Run(t *testing.T, server *TestServer) {
 	t.Helper() // Mark this function as a test helper
 
-	url := "http://localhost:8080" + tc.Endpoint
+	url := server.URL + tc.Endpoint
 	var req *http.Request
 	var err error
 
@@ -85,6 +348,9 @@ func (tc *TestCase) Run(t *testing.T) {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if tc.Username != "" {
+		req.Header.Set("Authorization", "Bearer "+server.loginAs(t, tc.Username))
+	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -101,15 +367,18 @@ func (tc *TestCase) Run(t *testing.T) {
 	assert.Equal(t, tc.ExpectedStatus, resp.StatusCode, "Status code mismatch for %s %s", tc.Method, tc.Endpoint)
 
 	// check if the response is expected to be JSON
-	if resp.Header.Get("Content-Type") == "application/json" {
+	if resp.Header.Get("Content-Type") == "application/json" || len(tc.IgnoredFields) > 0 {
 		// try to parse response JSON
-		var actualJSON map[string]interface{}
+		var actualJSON interface{}
 		if err := json.Unmarshal(body, &actualJSON); err != nil {
 			t.Fatalf("Expected valid JSON response but got invalid JSON. Body: %q, Error: %v", body, err)
 		}
+		for _, field := range tc.IgnoredFields {
+			ignoreField(actualJSON, strings.Split(field, "."))
+		}
 
 		// parse the expected JSON
-		var expectedJSON map[string]interface{}
+		var expectedJSON interface{}
 		if err := json.Unmarshal([]byte(tc.ExpectedBody), &expectedJSON); err != nil {
 			t.Fatalf("Test has invalid ExpectedBody JSON: %q, Error: %v", tc.ExpectedBody, err)
 		}
@@ -120,35 +389,127 @@ func (tc *TestCase) Run(t *testing.T) {
 	}
 }
 
-func TestAPI(t *testing.T) {
-	tests := map[string][]TestCase{
-		"Main Tests":    main_tests,
-		"User Tests":    user_tests,
-		"Project Tests": project_tests,
-		"Comment Tests": comment_tests,
-		"Post Tests":    post_tests,
-	}
-
-    db, err := sql.Open("sqlite3", "../database/dev.sqlite3")
-	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to database: %v", err))
+// ignoreField removes the field at `path` from a decoded JSON value.
+func ignoreField(value interface{}, path []string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(value, path[0])
+		} else {
+			ignoreField(value[path[0]], path[1:])
+		}
+	case []interface{}:
+		// a leading index picks one item, otherwise every item is matched
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index < len(value) {
+				ignoreField(value[index], path[1:])
+			}
+			return
+		}
+		for _, item := range value {
+			ignoreField(item, path)
+		}
 	}
-	defer db.Close()
+}
 
-	err = ResetTestDatabase(db)
-	if err != nil {
-		panic(fmt.Sprintf("Database reset failed: %v", err))
+func TestAPI(t *testing.T) {
+	tests := map[string][]TestCase{
+		"Main Tests":         main_tests,
+		"Auth Tests":         auth_tests,
+		"Token Tests":        token_tests,
+		"Admin Tests":        admin_tests,
+		"User Tests":         user_tests,
+		"Project Tests":      project_tests,
+		"Comment Tests":      comment_tests,
+		"Post Tests":         post_tests,
+		"Feed Tests":         feed_tests,
+		"Search Tests":       search_tests,
+		"Autocomplete Tests": autocomplete_tests,
+		"Thread Tests":       thread_tests,
+		"History Tests":      history_tests,
 	}
 
-	// iterate through categories and run tests in parallel within each category
+	// every category and flow gets a server and database of its own,
+	// so they can run in parallel without seeing each other's changes
 	for category, testCases := range tests {
 		t.Run(category, func(t *testing.T) {
             t.Parallel() // run the test in parallel
+			server := NewTestServer(t)
+
+			// log in up front, some tests rename these users
+			for _, username := range seededUsers {
+				server.loginAs(t, username)
+			}
 			for _, test := range testCases {
 				t.Run(test.Method+" "+test.Endpoint, func(t *testing.T) {
-					test.Run(t)
+					test.Run(t, server)
 				})
 			}
 		})
 	}
+
+	t.Run("Token Lifecycle", func(t *testing.T) {
+		t.Parallel()
+		testTokenLifecycle(t, NewTestServer(t))
+	})
+	t.Run("Personal Tokens", func(t *testing.T) {
+		t.Parallel()
+		testPersonalTokens(t, NewTestServer(t))
+	})
+	t.Run("Feed Paging", func(t *testing.T) {
+		t.Parallel()
+		testFeedPaging(t, NewTestServer(t))
+	})
+	t.Run("Trending Feed", func(t *testing.T) {
+		t.Parallel()
+		testTrendingFeed(t, NewTestServer(t))
+	})
+	t.Run("Project Discovery", func(t *testing.T) {
+		t.Parallel()
+		testProjectDiscovery(t, NewTestServer(t))
+	})
+	t.Run("Search", func(t *testing.T) {
+		t.Parallel()
+		testSearch(t, NewTestServer(t))
+	})
+	t.Run("Autocomplete", func(t *testing.T) {
+		t.Parallel()
+		testAutocomplete(t, NewTestServer(t))
+	})
+	t.Run("Comment Threads", func(t *testing.T) {
+		t.Parallel()
+		testCommentThreads(t, NewTestServer(t))
+	})
+	t.Run("Edit History", func(t *testing.T) {
+		t.Parallel()
+		testEditHistory(t, NewTestServer(t))
+	})
+	t.Run("Tombstones", func(t *testing.T) {
+		t.Parallel()
+		testTombstones(t, NewTestServer(t))
+	})
+	t.Run("Notifications", func(t *testing.T) {
+		t.Parallel()
+		testNotifications(t, NewTestServer(t))
+	})
+	t.Run("Events", func(t *testing.T) {
+		t.Parallel()
+		testEvents(t, NewTestServer(t))
+	})
+	t.Run("Live Threads", func(t *testing.T) {
+		t.Parallel()
+		testLiveThreads(t, NewTestServer(t))
+	})
+	t.Run("Webhooks", func(t *testing.T) {
+		t.Parallel()
+		testWebhooks(t, NewTestServer(t))
+	})
+	t.Run("Digests", func(t *testing.T) {
+		t.Parallel()
+		testDigests(t, NewTestServer(t))
+	})
+	t.Run("Uploads", func(t *testing.T) {
+		t.Parallel()
+		testUploads(t, NewTestServer(t))
+	})
 }
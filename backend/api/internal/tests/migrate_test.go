@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"backend/api/internal/database"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// openTempDatabase opens an empty sqlite database that is removed after the test
+func openTempDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.sqlite3"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *database.Database, table string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, table).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to look up table %v: %v", table, err)
+	}
+	return count > 0
+}
+
+func TestMigrationsAreNumbered(t *testing.T) {
+	// every dialect has to be able to reach the same schema versions
+	versions := map[database.Dialect][]int{}
+	for _, dialect := range database.Dialects {
+		migrations, err := database.Migrations(dialect)
+		if !assert.NoError(t, err, dialect) || !assert.NotEmpty(t, migrations, dialect) {
+			continue
+		}
+		for i, migration := range migrations {
+			assert.Equal(t, i+1, migration.Version, "%v migrations should be numbered without gaps", dialect)
+			versions[dialect] = append(versions[dialect], migration.Version)
+		}
+	}
+	assert.Equal(t, versions[database.SQLite], versions[database.Postgres], "sqlite3 and postgres migrations should match")
+}
+
+// the api refuses to start on a database with pending migrations, so the
+// checked in development database has to keep up with every new one
+func TestDevDatabaseIsMigrated(t *testing.T) {
+	db, err := database.Open("sqlite3", "file:../database/dev.sqlite3?mode=ro")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	statuses, err := database.QueryMigrationStatus(db)
+	if assert.NoError(t, err) {
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedDate, "%04d_%v is pending in dev.sqlite3, run `go run ./api migrate up`", status.Version, status.Name)
+		}
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db := openTempDatabase(t)
+	migrations, err := database.Migrations(database.SQLite)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	statuses, err := database.QueryMigrationStatus(db)
+	if assert.NoError(t, err) && assert.Len(t, statuses, len(migrations)) {
+		for _, status := range statuses {
+			assert.Nil(t, status.AppliedDate, "%04d_%v should be pending", status.Version, status.Name)
+		}
+	}
+
+	ran, err := database.MigrateUp(db)
+	assert.NoError(t, err)
+	assert.Len(t, ran, len(migrations))
+	assert.True(t, tableExists(t, db, "Users"))
+
+	// running again is a no-op
+	ran, err = database.MigrateUp(db)
+	assert.NoError(t, err)
+	assert.Empty(t, ran)
+
+	statuses, err = database.QueryMigrationStatus(db)
+	if assert.NoError(t, err) {
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedDate, "%04d_%v should be applied", status.Version, status.Name)
+		}
+	}
+
+	ran, err = database.MigrateDown(db, len(migrations))
+	assert.NoError(t, err)
+	assert.Len(t, ran, len(migrations))
+	assert.False(t, tableExists(t, db, "Users"))
+	assert.True(t, tableExists(t, db, "schema_migrations"))
+
+	_, err = database.MigrateDown(db, 0)
+	assert.EqualError(t, err, "Steps must be positive, got 0")
+}
+
+func TestMigrateRefusesUnknownVersions(t *testing.T) {
+	db := openTempDatabase(t)
+
+	_, err := database.MigrateUp(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, name, applied_date) VALUES (9999, 'from_the_future', CURRENT_TIMESTAMP);`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = database.MigrateUp(db)
+	assert.EqualError(t, err, "Database has migration 9999 applied, which this build does not know about")
+	_, err = database.MigrateDown(db, 1)
+	assert.EqualError(t, err, "Database has migration 9999 applied, which this build does not know about")
+}
+
+func TestDialectRebind(t *testing.T) {
+	query := `SELECT id FROM Users WHERE username = ? AND bio != 'why?' AND id > ?;`
+
+	assert.Equal(t, query, database.SQLite.Rebind(query))
+	assert.Equal(t, `SELECT id FROM Users WHERE username = $1 AND bio != 'why?' AND id > $2;`, database.Postgres.Rebind(query))
+}
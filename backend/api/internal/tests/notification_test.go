@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNotifications(t *testing.T, server *TestServer) {
+	owner := server.loginAs(t, "dev_user1")
+	act := func(username string, endpoint string, body string) {
+		t.Helper()
+		status, response := server.authRequest(t, http.MethodPost, endpoint, server.loginAs(t, username), body)
+		assert.Less(t, status, 300, "%v: %v", endpoint, response)
+	}
+
+	// likes on the same post are grouped, and liking your own post says nothing
+	for _, username := range []string{"data_scientist3", "backend_guru4", "moderator7", "site_admin6", "ui_designer5", "dev_user1"} {
+		act(username, fmt.Sprintf("/posts/%v/likes/1", username), "")
+	}
+	act("tech_writer2", "/comments/for-comment/12", `{"user":2,"content":"Good point","parent_comment":12}`)
+	act("data_scientist3", "/users/data_scientist3/follow/dev_user1", "")
+
+	var first types.NotificationPage
+	server.getJSON(t, "/notifications?count=2", nil, owner, &first)
+	assert.Equal(t, int64(3), first.Unread)
+	if assert.Len(t, first.Items, 2) {
+		assert.Equal(t, "data_scientist3 followed you", first.Items[0].Message)
+		assert.Equal(t, "user_follow", first.Items[0].Kind)
+		assert.Equal(t, "tech_writer2 replied to your comment", first.Items[1].Message)
+		assert.Equal(t, int64(12), first.Items[1].Target)
+		assert.NotNil(t, first.Items[1].Comment)
+	}
+	assert.NotEmpty(t, first.NextCursor)
+
+	var second types.NotificationPage
+	server.getJSON(t, "/notifications?count=2", url.Values{"cursor": {first.NextCursor}}, owner, &second)
+	assert.Empty(t, second.NextCursor)
+	if !assert.Len(t, second.Items, 1) {
+		return
+	}
+	likes := second.Items[0]
+	assert.Equal(t, "ui_designer5 and 4 others liked your post", likes.Message)
+	assert.Equal(t, int64(5), likes.Actors)
+	assert.Equal(t, int64(1), likes.Target)
+	assert.False(t, likes.Read)
+
+	// notifications can only be marked read by who they are for
+	endpoint := fmt.Sprintf("/notifications/%v/read", likes.ID)
+	status, _ := server.authRequest(t, http.MethodPost, endpoint, server.loginAs(t, "tech_writer2"), "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = server.authRequest(t, http.MethodPost, endpoint, owner, "")
+	assert.Equal(t, http.StatusOK, status)
+	var page types.NotificationPage
+	server.getJSON(t, "/notifications?count=10", nil, owner, &page)
+	assert.Equal(t, int64(2), page.Unread)
+
+	// a new like brings the group back to the top, unread again
+	act("tech_writer2", "/posts/tech_writer2/unlikes/1", "")
+	act("tech_writer2", "/posts/tech_writer2/likes/1", "")
+	server.getJSON(t, "/notifications?count=10", nil, owner, &page)
+	assert.Equal(t, int64(3), page.Unread)
+	if assert.Len(t, page.Items, 3) {
+		assert.Equal(t, "tech_writer2 and 5 others liked your post", page.Items[0].Message)
+		assert.False(t, page.Items[0].Read)
+	}
+
+	status, _ = server.authRequest(t, http.MethodPost, "/notifications/read", owner, "")
+	assert.Equal(t, http.StatusOK, status)
+	server.getJSON(t, "/notifications?count=10", nil, owner, &page)
+	assert.Equal(t, int64(0), page.Unread)
+	for _, notification := range page.Items {
+		assert.True(t, notification.Read, notification.Message)
+	}
+
+	status, _ = server.authRequest(t, http.MethodGet, "/notifications?count=10", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 var post_tests []TestCase = []TestCase{
@@ -25,6 +27,7 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/posts",
 		Input:          `{"user":1,"project":1,"content":"New feature announcement!"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusCreated,
 		ExpectedBody:   `{"message":"Post created successfully with id '4'"}`,
 	},
@@ -32,6 +35,7 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/posts",
 		Input:          `{"user":1,"project":1,"content":""}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusBadRequest,
 		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'Post.Content' Error:Field validation for 'Content' failed on the 'required' tag"}`,
 	},
@@ -39,6 +43,7 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/posts",
 		Input:          `{"user":-1,"project":1,"content":"Test content"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusBadRequest,
 		ExpectedBody:   `{"error":"Bad Request","message":"Failed to verify post ownership. User could not be found"}`,
 	},
@@ -47,25 +52,37 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodPut,
 		Endpoint:       "/posts/1",
 		Input:          `{"content":"Updated: First version of OpenAPI Toolkit released!"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"Post updated successfully","post":{"id":1,"user":1,"project":1,"likes":40,"content":"Updated: First version of OpenAPI Toolkit released!","created_on":"2024-09-13T00:00:00Z"}}`,
+		IgnoredFields:  []string{"post.edited_at"},
+	},
+	// the author and likes are kept by the server
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/posts/1",
+		Input:          `{"user":2}`,
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'user' is not allowed for updates"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/posts/1",
+		Input:          `{"likes":999}`,
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'likes' is not allowed for updates"}`,
 	},
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/posts/9999",
 		Input:          `{"content":"Non-existent Post"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Post with id '9999' not found"}`,
 	},
 
-	{
-		Method:         http.MethodGet,
-		Endpoint:       "/posts/by-user/1",
-		Input:          "",
-		ExpectedStatus: http.StatusOK,
-		ExpectedBody:   `[{"id":1,"user":1,"project":1,"likes":40,"content":"Updated: First version of OpenAPI Toolkit released!","created_on":"2024-09-13T00:00:00Z"}]`,
-	},
-
 	{
 		Method:         http.MethodGet,
 		Endpoint:       "/posts/by-project/2",
@@ -78,6 +95,7 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/posts/4",
 		Input:          "",
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"Post 4 deleted."}`,
 	},
@@ -85,14 +103,26 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/posts/9999",
 		Input:          "",
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusNotFound,
-		ExpectedBody:   `{"error":"Not Found","message":"Failed to delete post: Deletion did not affect any records"}`,
+		ExpectedBody:   `{"error":"Not Found","message":"Post with id '9999' not found"}`,
+	},
+
+	// the post created above is gone again
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/posts/by-user/1",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[{"id":1,"user":1,"project":1,"likes":40,"content":"Updated: First version of OpenAPI Toolkit released!","created_on":"2024-09-13T00:00:00Z"}]`,
+		IgnoredFields:  []string{"edited_at"},
 	},
 
 	{
 		Method:         http.MethodPost,
 		Endpoint:       "/posts/tech_writer2/likes/1",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusCreated,
 		ExpectedBody:   `{"message":"tech_writer2 likes post 1"}`,
 	},
@@ -100,6 +130,7 @@ var post_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/posts/tech_writer2/unlikes/1",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"tech_writer2 unliked post 1"}`,
 	},
@@ -111,5 +142,36 @@ var post_tests []TestCase = []TestCase{
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"status":false}`,
 	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/posts/2",
+		Input:          `{"content":"Edited by someone else"}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'ui_designer5' does not own this post"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/posts/tech_writer2/likes/2",
+		Input:          "",
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'ui_designer5' cannot act on behalf of 'tech_writer2'"}`,
+	},
+	// posts and feeds are capped by the configured limits
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/posts",
+		Input:          fmt.Sprintf(`{"user":1,"project":1,"content":%q}`, strings.Repeat("a", 5001)),
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Post content is 5001 characters long, the limit is 5000"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/feed/posts?type=time&start=0&count=101",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Feed count 101 is over the limit of 100"}`,
+	},
 }
-
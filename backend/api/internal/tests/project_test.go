@@ -2,6 +2,11 @@ package tests
 
 import (
 	"net/http"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var project_tests []TestCase = []TestCase{
@@ -21,11 +26,51 @@ var project_tests []TestCase = []TestCase{
 		ExpectedBody:   `{"error":"Not Found","message":"Project with id '-1' not found"}`,
 	},
 
+	// Test GET tags, most used first
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/tags",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[{"tag":"Python","count":2},{"tag":"Backend","count":1},{"tag":"Database","count":1},{"tag":"Documentation","count":1},{"tag":"Go","count":1},{"tag":"Machine Learning","count":1},{"tag":"OpenAPI","count":1},{"tag":"Research","count":1},{"tag":"Scalability","count":1},{"tag":"Tooling","count":1}]`,
+	},
+
+	// Test GET projects with invalid filters
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/projects?tag=Go&match=some",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Invalid match 'some', expected all or any"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/projects?status=abandoned",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Invalid status 'abandoned', expected planned, active, archived or a number"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/projects?owner=not_a_user",
+		Input:          "",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"User 'not_a_user' not found"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/projects?sort=name",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to fetch projects: Invalid sort 'name', expected time, likes or trending"}`,
+	},
+
 	// Test POST to create a new project
 	{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects",
 		Input:          `{"name":"New Project","description":"Test project description","owner":1}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusCreated,
 		ExpectedBody:   `{"message":"Project created successfully with id '5'"}`,
 	},
@@ -33,6 +78,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects",
 		Input:          `{"name":"","description":"Test project description","owner":1}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusBadRequest,
 		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'Project.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`,
 	},
@@ -40,6 +86,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects",
 		Input:          `{"name":"Duplicate Project","description":"Test duplicate","owner":-1}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusBadRequest,
 		ExpectedBody:   `{"error":"Bad Request","message":"Failed to verify project ownership. User could not be found"}`,
 	},
@@ -50,29 +97,50 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPut,
 		Endpoint:       "/projects/1",
 		Input:          `{"name":"Completely Updated Project","description":"This project has been fully updated.","owner":2,"status":2,"likes":200,"tags":["UpdatedTag1","UpdatedTag2"],"links":["https://updatedlink1.com","https://updatedlink2.com"]}`,
-		ExpectedStatus: http.StatusOK,
-		ExpectedBody:   `{"message":"Project updated successfully","project":{"id":1,"owner":2,"name":"Completely Updated Project","description":"This project has been fully updated.","status":2,"likes":200,"tags":["UpdatedTag1","UpdatedTag2"],"links":["https://updatedlink1.com","https://updatedlink2.com"],"creation_date":"2023-06-13T00:00:00Z"}}`,
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'owner' is not allowed for updates, an admin has to reassign the project"}`,
 	},
 
-	// update back
+	// the owner can't be handed back either
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/projects/1",
 		Input:          `{"owner":1,"name":"OpenAPI Toolkit","description":"A toolkit for generating and testing OpenAPI specs.","status":1,"likes":120,"tags":["OpenAPI","Go","Tooling"],"links":["https://github.com/dev_user1/openapi-toolkit"]}`,
+		Username:       "tech_writer2",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'owner' is not allowed for updates, an admin has to reassign the project"}`,
+	},
+
+	// and neither request changed the project
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/projects/1",
+		Input:          "",
 		ExpectedStatus: http.StatusOK,
-		ExpectedBody:   `{"message":"Project updated successfully","project":{"id":1,"owner":1,"name":"OpenAPI Toolkit","description":"A toolkit for generating and testing OpenAPI specs.","status":1,"likes":120,"tags":["OpenAPI","Go","Tooling"],"links":["https://github.com/dev_user1/openapi-toolkit"],"creation_date":"2023-06-13T00:00:00Z"}}`,
+		ExpectedBody:   `{"id":1,"owner":1,"name":"OpenAPI Toolkit","description":"A toolkit for generating and testing OpenAPI specs.","status":1,"likes":120,"tags":["OpenAPI","Go","Tooling"],"links":["https://github.com/dev_user1/openapi-toolkit"],"creation_date":"2023-06-13T00:00:00Z"}`,
 	},
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/projects/4",
 		Input:          `{"owner":9999}`,
+		Username:       "backend_guru4",
 		ExpectedStatus: http.StatusBadRequest,
-		ExpectedBody:   `{"error":"Bad Request","message":"Invalid owner id: 9999"}`,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'owner' is not allowed for updates, an admin has to reassign the project"}`,
+	},
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/projects/4",
+		Input:          `{"likes":9999}`,
+		Username:       "backend_guru4",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'likes' is not allowed for updates"}`,
 	},
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/projects/9999",
 		Input:          `{"name":"Non-existent Project"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Project with id '9999' not found"}`,
 	},
@@ -82,6 +150,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/projects/5",
 		Input:          "",
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"Project 5 deleted."}`,
 	},
@@ -89,20 +158,23 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/projects/9999",
 		Input:          "",
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusNotFound,
-		ExpectedBody:   `{"error":"Not Found","message":"Failed to delete project: Deletion did not affect any records"}`,
+		ExpectedBody:   `{"error":"Not Found","message":"Project with id '9999' not found"}`,
 	},
 	{
 		Method:         http.MethodDelete,
 		Endpoint:       "/projects/9999",
 		Input:          "",
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusNotFound,
-		ExpectedBody:   `{"error":"Not Found","message":"Failed to delete project: Deletion did not affect any records"}`,
+		ExpectedBody:   `{"error":"Not Found","message":"Project with id '9999' not found"}`,
 	},
 	{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/follow/2",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"tech_writer2 now follows project 2"}`,
 	},
@@ -110,6 +182,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/unfollow/1",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"tech_writer2 unfollowed project 1"}`,
 	},
@@ -117,6 +190,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/follow/9999",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Failed to add follower: Project with id 9999 does not exist"}`,
 	},
@@ -124,6 +198,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/unfollow/9999",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Failed to remove follower: Project with id 9999 does not exist"}`,
 	},
@@ -173,6 +248,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/likes/4",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusCreated,
 		ExpectedBody:   `{"message":"tech_writer2 likes project 4"}`,
 	},
@@ -180,6 +256,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/likes/9999",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Failed to like project: Project with id 9999 does not exist"}`,
 	},
@@ -194,6 +271,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/unlikes/4",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"tech_writer2 unliked project 4"}`,
 	},
@@ -201,6 +279,7 @@ var project_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/projects/tech_writer2/unlikes/9999",
 		Input:          "",
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
 		ExpectedBody:   `{"error":"Not Found","message":"Failed to unlike project: Project with id 9999 does not exist"}`,
 	},
@@ -211,4 +290,94 @@ var project_tests []TestCase = []TestCase{
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"id":4,"owner":4,"name":"ScaleDB","description":"A scalable database system for modern apps.","status":1,"likes":70,"tags":["Database","Scalability","Backend"],"links":["https://github.com/backend_guru4/scaledb"],"creation_date":"2024-03-15T00:00:00Z"}`,
     },
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/projects",
+		Input:          `{"name":"Anonymous Project","description":"Created without logging in","owner":1}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/projects",
+		Input:          `{"name":"Someone Else's Project","description":"Created for another user","owner":1}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'ui_designer5' cannot create a project for another user"}`,
+	},
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/projects/3",
+		Input:          "",
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'ui_designer5' does not own this project"}`,
+	},
+}
+
+// the filters of the project discovery combine, and tag changes show up
+// in both the filters and the tag counts
+func testProjectDiscovery(t *testing.T, server *TestServer) {
+	filters := map[string][]int{
+		"/projects?count=10":                                   {3, 4, 1, 2},
+		"/projects?count=10&sort=likes":                        {1, 2, 4, 3},
+		"/projects?count=10&tag=python":                        {3, 2},
+		"/projects?count=10&tag=Python&tag=Research":           {3},
+		"/projects?count=10&tag=Python&tag=Research&match=any": {3, 2},
+		"/projects?count=10&tag=Go&tag=Tooling&status=active":  {1},
+		"/projects?count=10&tag=Go&status=archived":            {},
+		"/projects?count=10&status=2":                          {2},
+		"/projects?count=10&owner=dev_user1&sort=likes":        {1},
+		"/projects?count=10&tag=Python&owner=tech_writer2":     {2},
+	}
+	for endpoint, expected := range filters {
+		ids, _ := server.feedPage(t, endpoint, "", "")
+		if len(expected) == 0 {
+			assert.Empty(t, ids, endpoint)
+		} else {
+			assert.Equal(t, expected, ids, endpoint)
+		}
+	}
+
+	// paging through the filtered projects visits each of them once
+	var walked []int
+	var cursor string
+	for page := 0; page < 10; page++ {
+		var ids []int
+		ids, cursor = server.feedPage(t, "/projects?count=1&tag=Python&match=any&sort=likes", cursor, "")
+		walked = append(walked, ids...)
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []int{2, 3}, walked)
+
+	// new and updated tags can be found right away
+	token := server.loginAs(t, "backend_guru4")
+	status, body := server.authRequest(t, http.MethodPost, "/projects", token, `{"name":"GoDB","description":"ScaleDB in Go","owner":4,"tags":["Go","Database","Go"]}`)
+	assert.Equal(t, http.StatusCreated, status, "%v", body)
+	status, body = server.authRequest(t, http.MethodPut, "/projects/4", token, `{"tags":["Database","Go"]}`)
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+
+	ids, _ := server.feedPage(t, "/projects?count=10&tag=go&tag=database", "", "")
+	assert.Equal(t, []int{5, 4}, ids)
+	ids, _ = server.feedPage(t, "/projects?count=10&tag=Scalability", "", "")
+	assert.Empty(t, ids)
+
+	var counts []types.TagCount
+	server.getJSON(t, "/tags", nil, "", &counts)
+	if assert.GreaterOrEqual(t, len(counts), 3) {
+		assert.Equal(t, []types.TagCount{{Tag: "Go", Count: 3}, {Tag: "Database", Count: 2}, {Tag: "Python", Count: 2}}, counts[:3])
+	}
+}
+
+// a failed owner lookup is a server error, only a missing owner is not found
+func TestProjectsOwnerLookupFailure(t *testing.T) {
+	server := NewTestServer(t)
+	if _, err := server.database(t).Exec(`ALTER TABLE Users RENAME TO RenamedUsers`); err != nil {
+		t.Fatalf("Failed to rename the users table: %v", err)
+	}
+
+	status, response := server.authRequest(t, http.MethodGet, "/projects?owner=dev_user1", "", "")
+	assert.Equal(t, http.StatusInternalServerError, status, "%v", response)
 }
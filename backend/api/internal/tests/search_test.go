@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var search_tests []TestCase = []TestCase{
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/search",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Missing required url query parameter: q"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/search?q=%21%3F",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to search: Search must contain at least one letter or digit"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/search?q=go&kind=tag",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to search: Invalid kind 'tag', expected user, project, post, comment"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/search?q=go&cursor=abc",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Search results are not paged, use count to get more of them"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/search?q=nothingmatchesthis",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[]`,
+	},
+}
+
+func testSearch(t *testing.T, server *TestServer) {
+	// words match by their start, whatever their case
+	var results []types.SearchResult
+	server.getJSON(t, "/search", url.Values{"q": {"SCAL"}}, "", &results)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "project", results[0].Kind, "a match in the name ranks first")
+		assert.Equal(t, int64(4), results[0].ID)
+		assert.Equal(t, "ScaleDB", results[0].Title)
+		assert.Contains(t, results[0].Snippet, "<mark>")
+	}
+	kinds := map[string]int64{}
+	for _, result := range results {
+		kinds[result.Kind] = result.ID
+	}
+	assert.Equal(t, int64(4), kinds["user"], "backend_guru4 specializes in scalable systems")
+
+	// every word has to match
+	server.getJSON(t, "/search", url.Values{"q": {"documentation lacking"}, "kind": {"comment"}}, "", &results)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, int64(6), results[0].ID)
+		assert.Contains(t, results[0].Snippet, "<mark>documentation</mark>")
+		assert.Contains(t, results[0].Snippet, "<mark>lacking</mark>")
+	}
+
+	server.getJSON(t, "/search", url.Values{"q": {"python"}, "kind": {"user"}}, "", &results)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "tech_writer2", results[0].Title)
+	}
+	server.getJSON(t, "/search", url.Values{"q": {"the"}, "count": {"2"}}, "", &results)
+	assert.Len(t, results, 2)
+
+	// edited posts are found by their new content, and not their old one
+	token := server.loginAs(t, "dev_user1")
+	status, body := server.authRequest(t, http.MethodPut, "/posts/1", token, `{"content":"OpenAPI Toolkit now speaks GraphQL"}`)
+	assert.Equal(t, http.StatusOK, status, "%v", body)
+
+	server.getJSON(t, "/search", url.Values{"q": {"graph"}, "kind": {"post"}}, "", &results)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, int64(1), results[0].ID)
+		assert.Equal(t, "OpenAPI Toolkit now speaks <mark>GraphQL</mark>", results[0].Snippet)
+	}
+	server.getJSON(t, "/search", url.Values{"q": {"excited release"}, "kind": {"post"}}, "", &results)
+	assert.Empty(t, results)
+}
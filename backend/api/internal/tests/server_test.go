@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/api/internal/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerOnMemoryStore(t *testing.T) {
+	stores := store.NewMemory()
+	server := newTestServerWith(t, stores)
+	registerUser(t, stores, "alice")
+	registerUser(t, stores, "bob")
+	token := server.loginAs(t, "alice")
+
+	status, _ := server.authRequest(t, http.MethodPut, "/users/alice", "", `{"bio":"anonymous"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = server.authRequest(t, http.MethodPut, "/users/bob", token, `{"bio":"not alice"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = server.authRequest(t, http.MethodPut, "/users/alice", token, `{"bio":"written through the handler"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	user, err := stores.Users.QueryUsername("alice")
+	if assert.NoError(t, err) && assert.NotNil(t, user) {
+		assert.Equal(t, "written through the handler", user.Bio)
+	}
+
+	status, _ = server.authRequest(t, http.MethodPost, "/projects", token, `{"owner":1,"name":"devbits","description":"a project","links":[],"tags":["go"]}`)
+	assert.Equal(t, http.StatusCreated, status)
+	var project struct {
+		Name  string   `json:"name"`
+		Owner int64    `json:"owner"`
+		Tags  []string `json:"tags"`
+	}
+	server.getJSON(t, "/projects/1", nil, "", &project)
+	assert.Equal(t, "devbits", project.Name)
+	assert.Equal(t, int64(1), project.Owner)
+	assert.Equal(t, []string{"go"}, project.Tags)
+}
@@ -0,0 +1,675 @@
+package tests
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/store"
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// storeImplementations builds a fresh, empty instance of every store
+// implementation, so the same test can check they all behave alike.
+func storeImplementations(t *testing.T) map[string]store.Stores {
+	t.Helper()
+
+	db := openTempDatabase(t)
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return map[string]store.Stores{
+		"sql":    store.NewSQL(db),
+		"memory": store.NewMemory(),
+	}
+}
+
+func registerUser(t *testing.T, stores store.Stores, username string) int64 {
+	t.Helper()
+
+	registration := types.UserRegistration{User: types.User{Username: username, Links: []string{}}, Password: "password123"}
+	httpcode, err := stores.Auth.QueryRegisterUser(&registration)
+	if err != nil || httpcode != http.StatusCreated {
+		t.Fatalf("Failed to register %v: %v %v", username, httpcode, err)
+	}
+	id, err := stores.Users.GetUserIdByUsername(username)
+	if err != nil {
+		t.Fatalf("Failed to look up %v: %v", username, err)
+	}
+	return int64(id)
+}
+
+func TestStoreUsers(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			bob := registerUser(t, stores, "bob")
+
+			httpcode, err := stores.Auth.QueryRegisterUser(&types.UserRegistration{User: types.User{Username: "alice"}, Password: "password123"})
+			assert.Equal(t, http.StatusConflict, httpcode)
+			assert.EqualError(t, err, "Username 'alice' is already taken")
+
+			// unknown users can be told apart from failed lookups
+			_, err = stores.Users.GetUserIdByUsername("nobody")
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			userId, httpcode, err := stores.Auth.QueryVerifyLogin("alice", "password123")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			assert.Equal(t, alice, userId)
+			_, httpcode, _ = stores.Auth.QueryVerifyLogin("alice", "wrong password")
+			assert.Equal(t, http.StatusUnauthorized, httpcode)
+
+			httpcode, err = stores.Users.CreateNewUserFollow("alice", "bob")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			httpcode, _ = stores.Users.CreateNewUserFollow("alice", "bob")
+			assert.Equal(t, http.StatusConflict, httpcode)
+
+			followers, _, _, err := stores.Users.QueryGetUsersFollowersUsernames("bob", types.Page{})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"alice"}, followers)
+			following, _, _, err := stores.Users.QueryGetUsersFollowing("alice", types.Page{})
+			assert.NoError(t, err)
+			assert.Equal(t, []int{int(bob)}, following)
+
+			err = stores.Users.QueryUpdateUser("bob", map[string]interface{}{"bio": "hello", "username": "robert"})
+			assert.NoError(t, err)
+			user, err := stores.Users.QueryUsername("robert")
+			if assert.NoError(t, err) && assert.NotNil(t, user) {
+				assert.Equal(t, "hello", user.Bio)
+			}
+			_, _, err = stores.Auth.QueryVerifyLogin("robert", "password123")
+			assert.NoError(t, err, "login info should follow a rename")
+
+			httpcode, err = stores.Users.QuerySuspendUser("robert", true)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			_, httpcode, err = stores.Auth.QueryVerifyLogin("robert", "password123")
+			assert.Equal(t, http.StatusForbidden, httpcode)
+			assert.EqualError(t, err, "Account 'robert' is suspended")
+
+			deleted, err := stores.Users.QueryDeleteUser("robert", bob)
+			assert.NoError(t, err)
+			assert.Equal(t, int16(http.StatusOK), deleted)
+			deleted, _ = stores.Users.QueryDeleteUser("robert", bob)
+			assert.Equal(t, int16(http.StatusNotFound), deleted)
+			username, err := stores.Users.GetUsernameById(bob)
+			assert.NoError(t, err)
+			assert.Empty(t, username)
+		})
+	}
+}
+
+func TestStoreSessions(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+
+			assert.NoError(t, stores.Auth.QueryCreateTokenFamily(alice, "family"))
+			assert.NoError(t, stores.Auth.QueryStoreRefreshToken("family", "hash", time.Now().UTC().Add(time.Hour)))
+
+			caller, _, err := stores.Auth.QuerySessionUser("family")
+			if assert.NoError(t, err) {
+				assert.Equal(t, &types.Caller{ID: alice, Username: "alice", Role: "user"}, caller)
+			}
+
+			userId, familyId, httpcode, err := stores.Auth.QueryUseRefreshToken("hash")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			assert.Equal(t, alice, userId)
+			assert.Equal(t, "family", familyId)
+
+			// reusing a refresh token revokes the whole session
+			_, _, httpcode, err = stores.Auth.QueryUseRefreshToken("hash")
+			assert.Equal(t, http.StatusUnauthorized, httpcode)
+			assert.EqualError(t, err, "Refresh token has already been used, the session has been revoked")
+			_, httpcode, err = stores.Auth.QuerySessionUser("family")
+			assert.Equal(t, http.StatusUnauthorized, httpcode)
+			assert.EqualError(t, err, "Session has been revoked")
+
+			// a token used by many requests at once is rotated by one at most
+			assert.NoError(t, stores.Auth.QueryCreateTokenFamily(alice, "racing"))
+			assert.NoError(t, stores.Auth.QueryStoreRefreshToken("racing", "race-hash", time.Now().UTC().Add(time.Hour)))
+			var rotated atomic.Int32
+			var racers sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				racers.Add(1)
+				go func() {
+					defer racers.Done()
+					if _, _, httpcode, _ := stores.Auth.QueryUseRefreshToken("race-hash"); httpcode == http.StatusOK {
+						rotated.Add(1)
+					}
+				}()
+			}
+			racers.Wait()
+			assert.LessOrEqual(t, rotated.Load(), int32(1))
+
+			token, err := stores.Auth.QueryCreatePersonalToken(alice, &types.NewPersonalToken{Name: "ci", Scopes: []string{"read"}}, "pat-hash")
+			if !assert.NoError(t, err) {
+				return
+			}
+			caller, scopes, _, err := stores.Auth.QueryPersonalTokenUser("pat-hash")
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", caller.Username)
+			assert.Equal(t, []string{"read"}, scopes)
+
+			tokens, _, err := stores.Auth.QueryPersonalTokens(alice)
+			if assert.NoError(t, err) && assert.Len(t, tokens, 1) {
+				assert.Equal(t, token.ID, tokens[0].ID)
+				assert.NotNil(t, tokens[0].LastUsed)
+			}
+			httpcode, _ = stores.Auth.QueryDeletePersonalToken(alice+1, token.ID)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			httpcode, err = stores.Auth.QueryDeletePersonalToken(alice, token.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+		})
+	}
+}
+
+func TestStoreContent(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			bob := registerUser(t, stores, "bob")
+
+			first, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "first", Description: "a", Links: []string{}, Tags: []string{"go"}})
+			assert.NoError(t, err)
+			second, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "second", Description: "b", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+
+			projects, _, err := stores.Projects.QueryProjectsByUserId(int(alice))
+			if assert.NoError(t, err) && assert.Len(t, projects, 2) {
+				assert.Equal(t, []string{"go"}, projects[0].Tags)
+			}
+
+			tagged, _, _, err := stores.Projects.QueryProjects(types.ProjectFilter{Tags: []string{"Go"}, MatchAllTags: true}, types.Page{})
+			if assert.NoError(t, err) && assert.Len(t, tagged, 1) {
+				assert.Equal(t, first, tagged[0].ID)
+			}
+			tags, _, err := stores.Projects.QueryTagCounts()
+			assert.NoError(t, err)
+			assert.Equal(t, []types.TagCount{{Tag: "go", Count: 1}}, tags)
+
+			httpcode, err := stores.Projects.CreateProjectLike("bob", "2")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusCreated, httpcode)
+			httpcode, _ = stores.Projects.CreateProjectLike("bob", "2")
+			assert.Equal(t, http.StatusOK, httpcode, "liking twice should be a no-op")
+			httpcode, err = stores.Projects.CreateProjectLike("bob", "99")
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			assert.EqualError(t, err, "Project with id 99 does not exist")
+
+			liked, _, _, err := stores.Feed.GetProjectByLikesFeed(types.Page{Limit: 10})
+			if assert.NoError(t, err) && assert.Len(t, liked, 2) {
+				assert.Equal(t, second, liked[0].ID)
+				assert.Equal(t, int64(1), liked[0].Likes)
+			}
+
+			page, next, _, err := stores.Feed.GetProjectByLikesFeed(types.Page{Limit: 1})
+			if assert.NoError(t, err) && assert.Len(t, page, 1) && assert.NotNil(t, next) {
+				assert.Equal(t, second, page[0].ID)
+				page, next, _, err = stores.Feed.GetProjectByLikesFeed(types.Page{After: next, Limit: 1})
+				assert.NoError(t, err)
+				assert.Nil(t, next)
+				if assert.Len(t, page, 1) {
+					assert.Equal(t, first, page[0].ID)
+				}
+			}
+
+			// the second project was liked just now, so it is trending
+			trending, _, _, err := stores.Feed.GetProjectByTrendingFeed(types.Page{Limit: 10})
+			if assert.NoError(t, err) && assert.Len(t, trending, 2) {
+				assert.Equal(t, []int64{second, first}, []int64{trending[0].ID, trending[1].ID})
+			}
+
+			httpcode, err = stores.Projects.CreateNewProjectFollow("bob", "1")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			httpcode, _ = stores.Projects.CreateNewProjectFollow("bob", "1")
+			assert.Equal(t, http.StatusConflict, httpcode)
+			names, _, err := stores.Projects.QueryGetProjectFollowingNames("bob")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"first"}, names)
+			counts, _, err := stores.Projects.QueryProjectFollowerCounts()
+			assert.NoError(t, err)
+			assert.Equal(t, []types.Suggestion{{ID: first, Name: "first", Followers: 1}, {ID: second, Name: "second"}}, counts)
+
+			postId, err := stores.Posts.QueryCreatePost(&types.Post{User: alice, Project: first, Content: "hello"})
+			assert.NoError(t, err)
+			assert.NoError(t, stores.Posts.QueryUpdatePost(int(postId), map[string]interface{}{"content": "hello again"}))
+			post, err := stores.Posts.QueryPost(int(postId))
+			if assert.NoError(t, err) && assert.NotNil(t, post) {
+				assert.Equal(t, "hello again", post.Content)
+				assert.NotNil(t, post.EditedAt)
+			}
+			revisions, _, err := stores.Posts.QueryPostRevisions(int(postId))
+			if assert.NoError(t, err) && assert.Len(t, revisions, 1) {
+				assert.Equal(t, "hello", revisions[0].Content)
+			}
+			found, _, err := stores.Search.QuerySearch("HELL", "post", 10)
+			if assert.NoError(t, err) && assert.Len(t, found, 1) {
+				assert.Equal(t, postId, found[0].ID)
+				assert.Equal(t, "<mark>hello</mark> again", found[0].Snippet)
+			}
+			// bob follows the first project, so its posts are in his home feed
+			home, _, _, err := stores.Feed.GetPostByFollowingFeed(bob, types.Page{Limit: 10})
+			if assert.NoError(t, err) && assert.Len(t, home, 1) {
+				assert.Equal(t, postId, home[0].ID)
+			}
+			home, _, _, err = stores.Feed.GetPostByFollowingFeed(alice, types.Page{Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, home)
+			posts, _, err := stores.Posts.QueryPostsByProjectId(int(second))
+			assert.NoError(t, err)
+			assert.Equal(t, []types.Post{}, posts)
+
+			commentId, err := stores.Comments.QueryCreateCommentOnPost(types.Comment{User: alice, Content: "first!"}, int(postId))
+			assert.NoError(t, err)
+			replyId, err := stores.Comments.QueryCreateCommentOnComment(types.Comment{User: alice, Content: "reply"}, int(commentId))
+			assert.NoError(t, err)
+
+			replies, _, err := stores.Comments.QueryCommentsByCommentId(int(commentId))
+			if assert.NoError(t, err) && assert.Len(t, replies, 1) {
+				assert.Equal(t, replyId, replies[0].ID)
+			}
+			tree, next, _, err := stores.Comments.QueryCommentTreeByPostId(int(postId), types.ThreadQuery{Depth: 1, Sort: "new", Page: types.Page{Limit: 10}})
+			if assert.NoError(t, err) && assert.Len(t, tree, 1) {
+				assert.Equal(t, commentId, tree[0].ID)
+				assert.Empty(t, tree[0].Children)
+				assert.Equal(t, &types.Cursor{Parent: commentId}, tree[0].Replies, "replies deeper than asked for are left for later")
+			}
+			assert.Nil(t, next)
+			tree, _, _, err = stores.Comments.QueryCommentTreeByPostId(int(postId), types.ThreadQuery{Parent: commentId, Depth: 1, Sort: "top", Page: types.Page{Limit: 10}})
+			if assert.NoError(t, err) && assert.Len(t, tree, 1) {
+				assert.Equal(t, replyId, tree[0].ID)
+			}
+			kind, threadId, _, err := stores.Comments.QueryCommentThread(int(replyId))
+			assert.NoError(t, err)
+			assert.Equal(t, "post", kind)
+			assert.Equal(t, postId, threadId)
+			_, _, httpcode, err = stores.Comments.QueryCommentThread(9999)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			httpcode, editable, err := stores.Comments.QueryIsCommentEditable("1", time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			assert.True(t, editable)
+
+			_, err = stores.Comments.QueryUpdateCommentContent(int(commentId), "first", 0)
+			assert.NoError(t, err)
+			_, err = stores.Comments.QueryUpdateCommentContent(int(commentId), "first", 0)
+			assert.NoError(t, err, "saving the same content again is not an edit")
+			revisions, _, err = stores.Comments.QueryCommentRevisions(int(commentId))
+			if assert.NoError(t, err) && assert.Len(t, revisions, 1) {
+				assert.Equal(t, "first!", revisions[0].Content)
+			}
+			_, err = stores.Comments.QueryUpdateCommentContent(int(commentId), "first?", time.Nanosecond)
+			assert.EqualError(t, err, "Cannot update comment. More than 1ns have passed since posting.")
+
+			deleted, err := stores.Comments.QueryDeleteComment(int(commentId), alice)
+			assert.NoError(t, err)
+			assert.Equal(t, int16(http.StatusOK), deleted)
+			comments, _, _, err := stores.Comments.QueryCommentsByPostId(int(postId), types.Page{})
+			if assert.NoError(t, err) && assert.Len(t, comments, 1) {
+				assert.Equal(t, int64(-1), comments[0].User)
+				assert.Equal(t, "This comment was deleted.", comments[0].Content)
+				assert.Nil(t, comments[0].EditedAt)
+			}
+			revisions, _, err = stores.Comments.QueryCommentRevisions(int(commentId))
+			assert.NoError(t, err)
+			assert.Empty(t, revisions, "a deleted comment's history goes with it")
+
+			deleted, err = stores.Projects.QueryDeleteProject(int(second), alice)
+			assert.NoError(t, err)
+			assert.Equal(t, int16(http.StatusOK), deleted)
+			project, err := stores.Projects.QueryProject(int(second))
+			assert.NoError(t, err)
+			assert.Nil(t, project)
+		})
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			projectId, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "greetings", Description: "a", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+			for _, content := range []string{"hello there", "hello hello hello", "well hello", "goodbye"} {
+				_, err = stores.Posts.QueryCreatePost(&types.Post{User: alice, Project: projectId, Content: content})
+				assert.NoError(t, err)
+			}
+
+			// only the best matches are returned, each with its snippet
+			found, _, err := stores.Search.QuerySearch("hello", "post", 2)
+			if assert.NoError(t, err) && assert.Len(t, found, 2) {
+				assert.Equal(t, "<mark>hello</mark> <mark>hello</mark> <mark>hello</mark>", found[0].Snippet)
+				assert.Contains(t, found[1].Snippet, "<mark>hello</mark>")
+				assert.GreaterOrEqual(t, found[0].Rank, found[1].Rank)
+			}
+			found, _, err = stores.Search.QuerySearch("hello", "post", 10)
+			assert.NoError(t, err)
+			assert.Len(t, found, 3)
+		})
+	}
+}
+
+func TestStoreTombstones(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			bob := registerUser(t, stores, "bob")
+
+			projectId, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "first", Description: "a", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+			alicePost, err := stores.Posts.QueryCreatePost(&types.Post{User: alice, Project: projectId, Content: "hello"})
+			assert.NoError(t, err)
+			bobPost, err := stores.Posts.QueryCreatePost(&types.Post{User: bob, Project: projectId, Content: "hi"})
+			assert.NoError(t, err)
+			bobComment, err := stores.Comments.QueryCreateCommentOnPost(types.Comment{User: bob, Content: "nice"}, int(alicePost))
+			assert.NoError(t, err)
+			aliceComment, err := stores.Comments.QueryCreateCommentOnPost(types.Comment{User: alice, Content: "thanks"}, int(bobPost))
+			assert.NoError(t, err)
+			earlier, err := stores.Comments.QueryCreateCommentOnPost(types.Comment{User: alice, Content: "oops"}, int(alicePost))
+			assert.NoError(t, err)
+			reply, err := stores.Comments.QueryCreateCommentOnComment(types.Comment{User: bob, Content: "what?"}, int(earlier))
+			assert.NoError(t, err)
+			_, _, err = stores.Images.QuerySetUserPicture("alice", "https://blobs.example.com/users/1/picture.png")
+			assert.NoError(t, err)
+			_, _, err = stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: projectId, URL: "https://blobs.example.com/projects/1/image.png", ContentType: "image/png", Size: 1}, 10)
+			assert.NoError(t, err)
+
+			_, err = stores.Comments.QueryDeleteComment(int(earlier), alice)
+			assert.NoError(t, err)
+			tombstone, err := stores.Tombstones.QueryTombstone("comment", earlier)
+			if assert.NoError(t, err) && assert.NotNil(t, tombstone) {
+				assert.Equal(t, alice, tombstone.Owner)
+				assert.Equal(t, alice, tombstone.DeletedBy)
+			}
+
+			// a project takes its posts and every comment on them with it,
+			// all recorded as deleted by whoever deleted the project
+			deleted, err := stores.Projects.QueryDeleteProject(int(projectId), bob)
+			assert.NoError(t, err)
+			assert.Equal(t, int16(http.StatusOK), deleted)
+			tombstone, err = stores.Tombstones.QueryTombstone("post", bobPost)
+			if assert.NoError(t, err) && assert.NotNil(t, tombstone) {
+				assert.Equal(t, bob, tombstone.Owner)
+				assert.Equal(t, bob, tombstone.DeletedBy)
+			}
+			tombstone, err = stores.Tombstones.QueryTombstone("comment", aliceComment)
+			if assert.NoError(t, err) && assert.NotNil(t, tombstone) {
+				assert.Equal(t, alice, tombstone.Owner)
+				assert.Equal(t, bob, tombstone.DeletedBy)
+			}
+			post, err := stores.Posts.QueryPost(int(bobPost))
+			assert.NoError(t, err)
+			assert.Nil(t, post)
+			comment, err := stores.Comments.QueryComment(int(aliceComment))
+			if assert.NoError(t, err) && assert.NotNil(t, comment) {
+				assert.Equal(t, "This comment was deleted.", comment.Content)
+				assert.NotNil(t, comment.DeletedAt)
+			}
+			deleted, _ = stores.Projects.QueryDeleteProject(int(projectId), bob)
+			assert.Equal(t, int16(http.StatusNotFound), deleted)
+
+			// and brings them back, but not what was deleted on its own before
+			httpcode, err := stores.Tombstones.QueryRestore("project", projectId)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			posts, _, _, err := stores.Posts.QueryPostsByUserId(int(bob), types.Page{})
+			if assert.NoError(t, err) && assert.Len(t, posts, 1) {
+				assert.Equal(t, bobPost, posts[0].ID)
+			}
+			comment, _ = stores.Comments.QueryComment(int(bobComment))
+			if assert.NotNil(t, comment) {
+				assert.Equal(t, "nice", comment.Content)
+				assert.Nil(t, comment.DeletedAt)
+			}
+			comment, _ = stores.Comments.QueryComment(int(earlier))
+			if assert.NotNil(t, comment) {
+				assert.NotNil(t, comment.DeletedAt)
+			}
+			httpcode, err = stores.Tombstones.QueryRestore("project", projectId)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			assert.EqualError(t, err, "Deleted project with id 1 not found")
+
+			// a user takes everything in their projects with them too, and
+			// keeps their username until they are purged
+			deleted, err = stores.Users.QueryDeleteUser("alice", alice)
+			assert.NoError(t, err)
+			assert.Equal(t, int16(http.StatusOK), deleted)
+			user, err := stores.Users.QueryUsername("alice")
+			assert.NoError(t, err)
+			assert.Nil(t, user)
+			_, httpcode, _ = stores.Auth.QueryVerifyLogin("alice", "password123")
+			assert.Equal(t, http.StatusUnauthorized, httpcode)
+			httpcode, _ = stores.Auth.QueryRegisterUser(&types.UserRegistration{User: types.User{Username: "alice", Links: []string{}}, Password: "password123"})
+			assert.Equal(t, http.StatusConflict, httpcode)
+			posts, _, _, err = stores.Posts.QueryPostsByUserId(int(bob), types.Page{})
+			assert.NoError(t, err)
+			assert.Empty(t, posts, "bob's post was in alice's project")
+			tombstone, err = stores.Tombstones.QueryUserTombstone("alice")
+			if assert.NoError(t, err) && assert.NotNil(t, tombstone) {
+				assert.Equal(t, alice, tombstone.ID)
+			}
+
+			// the deleted comment with a reply keeps its place, emptied, and
+			// the uploads are handed back for their files to be deleted
+			purged, uploads, err := stores.Tombstones.QueryPurge(time.Now().Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(6), purged)
+			assert.ElementsMatch(t, []string{"https://blobs.example.com/users/1/picture.png", "https://blobs.example.com/projects/1/image.png"}, uploads)
+			tombstone, err = stores.Tombstones.QueryUserTombstone("alice")
+			assert.NoError(t, err)
+			assert.Nil(t, tombstone)
+			tombstone, err = stores.Tombstones.QueryTombstone("post", bobPost)
+			assert.NoError(t, err)
+			assert.Nil(t, tombstone)
+			comment, _ = stores.Comments.QueryComment(int(earlier))
+			assert.NotNil(t, comment)
+			comment, _ = stores.Comments.QueryComment(int(reply))
+			if assert.NotNil(t, comment) {
+				assert.Equal(t, "what?", comment.Content)
+			}
+			registerUser(t, stores, "alice")
+		})
+	}
+}
+
+func TestStoreNotifications(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			bob := registerUser(t, stores, "bob")
+			carol := registerUser(t, stores, "carol")
+
+			projectId, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "first", Description: "a", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+			postId, err := stores.Posts.QueryCreatePost(&types.Post{User: alice, Project: projectId, Content: "hello"})
+			assert.NoError(t, err)
+			strPostId := fmt.Sprint(postId)
+
+			_, err = stores.Posts.CreatePostLike("bob", strPostId)
+			assert.NoError(t, err)
+			_, err = stores.Posts.CreatePostLike("carol", strPostId)
+			assert.NoError(t, err)
+			_, err = stores.Posts.CreatePostLike("alice", strPostId)
+			assert.NoError(t, err)
+			_, err = stores.Users.CreateNewUserFollow("bob", "alice")
+			assert.NoError(t, err)
+			commentId, err := stores.Comments.QueryCreateCommentOnPost(types.Comment{User: carol, Content: "nice"}, int(postId))
+			assert.NoError(t, err)
+			reply := types.Comment{User: alice, Content: "thanks"}
+			reply.ParentComment.Int64, reply.ParentComment.Valid = commentId, true
+			_, err = stores.Comments.QueryCreateCommentOnPost(reply, int(postId))
+			assert.NoError(t, err)
+
+			messages := func(userId int64) []string {
+				t.Helper()
+				notifications, _, _, err := stores.Notifications.QueryNotifications(userId, types.Page{})
+				assert.NoError(t, err)
+				messages := []string{}
+				for _, notification := range notifications {
+					messages = append(messages, notification.Message)
+				}
+				return messages
+			}
+			unread := func(userId int64) int64 {
+				t.Helper()
+				unread, _, err := stores.Notifications.QueryUnreadNotificationCount(userId)
+				assert.NoError(t, err)
+				return unread
+			}
+
+			// a reply goes to the author of the comment rather than the post
+			assert.Equal(t, []string{"alice replied to your comment"}, messages(carol))
+
+			first, next, _, err := stores.Notifications.QueryNotifications(alice, types.Page{Limit: 2})
+			assert.NoError(t, err)
+			if assert.Len(t, first, 2) && assert.NotNil(t, next) {
+				assert.Equal(t, "carol commented on your post", first[0].Message)
+				assert.Equal(t, commentId, *first[0].Comment)
+				assert.Equal(t, "bob followed you", first[1].Message)
+			}
+			rest, next, _, err := stores.Notifications.QueryNotifications(alice, types.Page{Limit: 2, After: next})
+			assert.NoError(t, err)
+			assert.Nil(t, next)
+			if !assert.Len(t, rest, 1) {
+				return
+			}
+			assert.Equal(t, "carol and 1 other liked your post", rest[0].Message)
+			assert.Equal(t, int64(3), unread(alice))
+
+			httpcode, err := stores.Notifications.QueryMarkNotificationRead(carol, rest[0].ID)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			assert.EqualError(t, err, fmt.Sprintf("Notification with id %v not found", rest[0].ID))
+			httpcode, err = stores.Notifications.QueryMarkNotificationRead(alice, rest[0].ID)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			assert.Equal(t, int64(2), unread(alice))
+			_, err = stores.Notifications.QueryMarkAllNotificationsRead(alice)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), unread(alice))
+
+			// what deleted users did is hidden, and forgotten once they are purged
+			_, err = stores.Users.QueryDeleteUser("bob", bob)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"carol commented on your post", "carol liked your post"}, messages(alice))
+			_, _, err = stores.Tombstones.QueryPurge(time.Now().Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"carol commented on your post", "carol liked your post"}, messages(alice))
+			_, err = stores.Posts.QueryDeletePost(int(postId), alice)
+			assert.NoError(t, err)
+			_, _, err = stores.Tombstones.QueryPurge(time.Now().Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Empty(t, messages(alice))
+			assert.Empty(t, messages(carol))
+		})
+	}
+}
+
+func TestStoreWebhooks(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			projectId, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "hooked", Description: "a", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+
+			webhookId, err := stores.Webhooks.QueryCreateWebhook(&types.Webhook{Project: projectId, URL: "https://example.com/hook", Events: []string{"post.created"}, Secret: "secret"})
+			assert.NoError(t, err)
+			webhook, err := stores.Webhooks.QueryWebhook(webhookId)
+			assert.NoError(t, err)
+			if assert.NotNil(t, webhook) {
+				assert.Equal(t, "secret", webhook.Secret)
+				assert.Equal(t, []string{"post.created"}, webhook.Events)
+			}
+			listed, _, err := stores.Webhooks.QueryWebhooks(projectId)
+			assert.NoError(t, err)
+			if assert.Len(t, listed, 1) {
+				assert.Empty(t, listed[0].Secret)
+			}
+
+			// only the events a webhook subscribed to are queued for it
+			now := time.Now().UTC()
+			queued, _, err := stores.Webhooks.QueryQueueDeliveries(projectId, "project.liked", []byte(`{}`), now)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), queued)
+			queued, _, err = stores.Webhooks.QueryQueueDeliveries(projectId, "post.created", []byte(`{"n":1}`), now)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), queued)
+
+			due, _, err := stores.Webhooks.QueryDueDeliveries(now, 10)
+			assert.NoError(t, err)
+			if !assert.Len(t, due, 1) {
+				return
+			}
+			assert.Equal(t, "https://example.com/hook", due[0].URL)
+			assert.Equal(t, "secret", due[0].Secret)
+			assert.JSONEq(t, `{"n":1}`, string(due[0].Payload))
+
+			// a retry is not due until its next attempt
+			next := now.Add(time.Minute)
+			_, err = stores.Webhooks.QueryRecordAttempt(types.DeliveryAttempt{Delivery: due[0].ID, Status: database.DeliveryPending, ResponseCode: 500, Error: "boom", NextAttempt: &next, Time: now})
+			assert.NoError(t, err)
+			due, _, err = stores.Webhooks.QueryDueDeliveries(now, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+			due, _, err = stores.Webhooks.QueryDueDeliveries(next, 10)
+			assert.NoError(t, err)
+			if !assert.Len(t, due, 1) {
+				return
+			}
+			_, err = stores.Webhooks.QueryRecordAttempt(types.DeliveryAttempt{Delivery: due[0].ID, Status: database.DeliveryDelivered, ResponseCode: 204, Time: next})
+			assert.NoError(t, err)
+
+			redelivery, httpcode, err := stores.Webhooks.QueryRedeliver(webhookId, due[0].ID, next)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			_, httpcode, err = stores.Webhooks.QueryRedeliver(webhookId+1, due[0].ID, next)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			assert.EqualError(t, err, fmt.Sprintf("Delivery with id %v not found", due[0].ID))
+
+			deliveries, cursor, _, err := stores.Webhooks.QueryDeliveries(webhookId, types.Page{Limit: 1})
+			assert.NoError(t, err)
+			assert.NotNil(t, cursor)
+			if assert.Len(t, deliveries, 1) {
+				assert.Equal(t, redelivery, deliveries[0].ID)
+				assert.Equal(t, database.DeliveryPending, deliveries[0].Status)
+				assert.Equal(t, &due[0].ID, deliveries[0].RedeliveryOf)
+			}
+			deliveries, cursor, _, err = stores.Webhooks.QueryDeliveries(webhookId, types.Page{Limit: 1, After: cursor})
+			assert.NoError(t, err)
+			assert.Nil(t, cursor)
+			if assert.Len(t, deliveries, 1) {
+				assert.Equal(t, database.DeliveryDelivered, deliveries[0].Status)
+				assert.Equal(t, 2, deliveries[0].Attempts)
+				assert.Equal(t, 204, deliveries[0].ResponseCode)
+				assert.Empty(t, deliveries[0].Error)
+				assert.NotNil(t, deliveries[0].DeliveredDate)
+				assert.Nil(t, deliveries[0].NextAttempt)
+			}
+
+			// deleting a webhook drops what it still had to deliver
+			httpcode, err = stores.Webhooks.QueryDeleteWebhook(webhookId)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, httpcode)
+			due, _, err = stores.Webhooks.QueryDueDeliveries(next, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, due)
+			httpcode, err = stores.Webhooks.QueryDeleteWebhook(webhookId)
+			assert.Equal(t, http.StatusNotFound, httpcode)
+			assert.EqualError(t, err, fmt.Sprintf("Webhook with id %v not found", webhookId))
+		})
+	}
+}
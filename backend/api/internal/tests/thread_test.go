@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var thread_tests []TestCase = []TestCase{
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/by-project/1/tree?depth=2&sort=top",
+		Input:          "",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"items":[{"id":1,"user":1,"likes":5,"parent_comment":null,"created_on":"2024-12-23T00:00:00Z","content":"This is a fantastic project! Can't wait to contribute.","children":[{"id":5,"user":5,"likes":1,"parent_comment":1,"created_on":"2024-12-23T00:00:00Z","content":"I hope this toolkit will integrate with other Go tools soon!","children":[]}]},{"id":3,"user":4,"likes":4,"parent_comment":null,"created_on":"2024-12-23T00:00:00Z","content":"Great to see more open-source tools for API development!","children":[{"id":4,"user":3,"likes":2,"parent_comment":3,"created_on":"2024-12-23T00:00:00Z","content":"I agree, but the API specs seem a bit too complex for beginners.","children":[]}]},{"id":2,"user":2,"likes":3,"parent_comment":null,"created_on":"2024-12-23T00:00:00Z","content":"I love the concept, but I think the documentation could be improved.","children":[{"id":6,"user":3,"likes":1,"parent_comment":2,"created_on":"2024-12-23T00:00:00Z","content":"I agree, the documentation is lacking in detail.","children":[]}]}]}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/by-post/1/tree?sort=old",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to fetch comments: Invalid sort 'old', expected top or new"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/by-post/1/tree?depth=0",
+		Input:          "",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Depth must be between 1 and 10, got 0"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/by-post/999/tree",
+		Input:          "",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Post with id '999' not found"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/comments/by-project/999/tree",
+		Input:          "",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Project with id '999' not found"}`,
+	},
+}
+
+// threadShape writes a tree as ids with their children in brackets,
+// such as `1[5] 3[4]`, so whole trees can be compared at a glance.
+func threadShape(nodes []types.CommentNode) string {
+	var shapes []string
+	for _, node := range nodes {
+		shape := fmt.Sprint(node.ID)
+		if len(node.Children) > 0 {
+			shape += "[" + threadShape(node.Children) + "]"
+		}
+		if node.RepliesCursor != "" {
+			shape += "+"
+		}
+		shapes = append(shapes, shape)
+	}
+	return strings.Join(shapes, " ")
+}
+
+func testCommentThreads(t *testing.T, server *TestServer) {
+	// replies to project comments made on a post belong to the post's thread
+	var page types.PageResponse[types.CommentNode]
+	server.getJSON(t, "/comments/by-project/1/tree?sort=top", nil, "", &page)
+	assert.Equal(t, "1[5] 3[4] 2[6]", threadShape(page.Items))
+	assert.Empty(t, page.NextCursor)
+	server.getJSON(t, "/comments/by-post/1/tree?sort=top", nil, "", &page)
+	assert.Equal(t, "9 11 12 7 10 8", threadShape(page.Items))
+
+	token := server.loginAs(t, "dev_user1")
+	reply := func(parent int64, content string) int64 {
+		t.Helper()
+		status, body := server.authRequest(t, http.MethodPost, fmt.Sprintf("/comments/for-comment/%v", parent), token,
+			fmt.Sprintf(`{"user":1,"content":%q,"parent_comment":%v}`, content, parent))
+		assert.Equal(t, http.StatusCreated, status, "%v", body)
+		var id int64
+		fmt.Sscanf(body["message"].(string), "Reply created successfully with id %d", &id)
+		return id
+	}
+
+	// a chain of replies is cut at the depth asked for
+	first := reply(5, "first")
+	second := reply(first, "second")
+	third := reply(second, "third")
+	server.getJSON(t, "/comments/by-project/1/tree?depth=3", nil, "", &page)
+	assert.Equal(t, fmt.Sprintf("1[5[%v+]] 3[4] 2[6]", first), threadShape(page.Items))
+
+	// and picks up where it left off from the cut comment's replies_cursor
+	cut := page.Items[0].Children[0].Children[0]
+	server.getJSON(t, "/comments/by-project/1/tree?depth=3&count=5", url.Values{"cursor": {cut.RepliesCursor}}, "", &page)
+	assert.Equal(t, fmt.Sprintf("%v[%v]", second, third), threadShape(page.Items))
+
+	// every level is paged on its own
+	newer := reply(2, "newer")
+	newest := reply(2, "newest")
+	server.getJSON(t, "/comments/by-project/1/tree?depth=2&count=2&sort=new", nil, "", &page)
+	assert.Equal(t, fmt.Sprintf("3[4] 2[%v %v]+", newest, newer), threadShape(page.Items))
+	if assert.NotEmpty(t, page.NextCursor) {
+		var replies types.PageResponse[types.CommentNode]
+		server.getJSON(t, "/comments/by-project/1/tree?depth=2&count=2&sort=new", url.Values{"cursor": {page.Items[1].RepliesCursor}}, "", &replies)
+		assert.Equal(t, "6", threadShape(replies.Items))
+		assert.Empty(t, replies.NextCursor)
+
+		server.getJSON(t, "/comments/by-project/1/tree?depth=2&count=2&sort=new", url.Values{"cursor": {page.NextCursor}}, "", &page)
+		assert.Equal(t, "1[5+]", threadShape(page.Items))
+		assert.Empty(t, page.NextCursor)
+	}
+}
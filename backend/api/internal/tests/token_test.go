@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var token_tests []TestCase = []TestCase{
+	// managing tokens needs a login
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          "",
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          "",
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `[]`,
+	},
+	// and only the owner can see or create them
+	{
+		Method:         http.MethodGet,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          "",
+		Username:       "backend_guru4",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'backend_guru4' cannot act on behalf of 'ui_designer5'"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          `{"name":"ci","scopes":["posts:write"]}`,
+		Username:       "backend_guru4",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'backend_guru4' cannot act on behalf of 'ui_designer5'"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          `{"name":"ci","scopes":["admin"]}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to create token: Unknown scope 'admin', expected one of read, posts:write, projects:write"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          `{"name":"ci","scopes":[]}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to create token: At least one scope is required"}`,
+	},
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          `{"scopes":["read"]}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'NewPersonalToken.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`,
+	},
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/users/ui_designer5/tokens/9999",
+		Input:          "",
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"error":"Not Found","message":"Failed to revoke token: Token with id '9999' not found"}`,
+	},
+	{
+		Method:         http.MethodDelete,
+		Endpoint:       "/users/ui_designer5/tokens/abc",
+		Input:          "",
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to parse token_id: strconv.ParseInt: parsing \"abc\": invalid syntax"}`,
+	},
+	// personal access tokens still have to be real
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users/ui_designer5/tokens",
+		Input:          `{"name":"ci","scopes":["read"]}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+}
+
+// personal tokens are only handed out once, so like the token lifecycle
+// this flow is written out step by step instead of as test cases
+func testPersonalTokens(t *testing.T, server *TestServer) {
+	username := fmt.Sprintf("pat_user_%d", time.Now().UnixNano())
+	credentials := fmt.Sprintf(`{"username":%q,"password":"pat-password"}`, username)
+
+	status, _ := server.authRequest(t, http.MethodPost, "/auth/register", "", credentials)
+	assert.Equal(t, http.StatusCreated, status)
+
+	status, body := server.authRequest(t, http.MethodPost, "/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, status)
+	access, _ := tokenPair(t, body)
+
+	status, body = server.authRequest(t, http.MethodPost, "/users/"+username+"/tokens", access, `{"name":"ci","scopes":["posts:write","read"]}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "Created token 'ci'", body["message"])
+	personalToken, _ := body["token"].(string)
+	assert.Regexp(t, "^dbt_", personalToken)
+	tokenInfo, _ := body["token_info"].(map[string]interface{})
+	tokenId := tokenInfo["id"]
+	assert.Nil(t, tokenInfo["last_used_on"])
+
+	// the token is accepted on routes that ask for its scopes
+	status, body = server.authRequest(t, http.MethodPost, "/posts", personalToken, `{"user":1,"project":1,"content":"v1.2 released"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, fmt.Sprintf("User '%v' cannot create a post for another user", username), body["message"])
+
+	assert.Len(t, server.listPersonalTokens(t, username, personalToken), 1)
+
+	// but not without them
+	status, body = server.authRequest(t, http.MethodPost, "/projects", personalToken, `{"name":"CI project","description":"made by a bot","owner":1}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "Personal access token is missing the 'projects:write' scope", body["message"])
+
+	// and never on routes that do not list any scopes
+	status, body = server.authRequest(t, http.MethodPost, "/users/"+username+"/tokens", personalToken, `{"name":"escalate","scopes":["projects:write"]}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "Personal access tokens cannot be used on this route", body["message"])
+
+	// listing shows when the token was last used, but never the token itself
+	tokens := server.listPersonalTokens(t, username, access)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "ci", tokens[0]["name"])
+		assert.Equal(t, []interface{}{"posts:write", "read"}, tokens[0]["scopes"])
+		assert.NotNil(t, tokens[0]["last_used_on"])
+		assert.NotContains(t, tokens[0], "token")
+	}
+
+	// revoked tokens stop working right away
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/users/%v/tokens/%v", username, tokenId), access, "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = server.authRequest(t, http.MethodGet, "/users/"+username+"/tokens", personalToken, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "Failed to authenticate: Invalid personal access token", body["message"])
+
+	assert.Len(t, server.listPersonalTokens(t, username, access), 0)
+}
+
+// listPersonalTokens fetches a user's tokens, which come back as a JSON array
+func (server *TestServer) listPersonalTokens(t *testing.T, username string, access string) []map[string]interface{} {
+	t.Helper()
+
+	var tokens []map[string]interface{}
+	server.request(t, http.MethodGet, "/users/"+username+"/tokens", nil, access, "", &tokens)
+	return tokens
+}
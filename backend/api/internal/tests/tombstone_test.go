@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// backdateDeletion moves the deletion of a row back by `age`, past the restore window.
+func (server *TestServer) backdateDeletion(t *testing.T, table string, id int64, age time.Duration) {
+	t.Helper()
+	db := server.database(t)
+	query := fmt.Sprintf(`UPDATE %v SET deletion_date = ? WHERE id = ? AND deletion_date IS NOT NULL`, table)
+	if _, err := db.Exec(query, time.Now().UTC().Add(-age), id); err != nil {
+		t.Fatalf("Failed to backdate deletion of %v %v: %v", table, id, err)
+	}
+}
+
+func testTombstones(t *testing.T, server *TestServer) {
+	owner := server.loginAs(t, "dev_user1")
+	moderator := server.loginAs(t, "moderator7")
+	admin := server.loginAs(t, "site_admin6")
+	status := func(method string, endpoint string, token string) int {
+		t.Helper()
+		status, _ := server.authRequest(t, method, endpoint, token, "")
+		return status
+	}
+
+	// deleting a post hides it and the comments on it, except for their place in the thread
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/comments/12", owner))
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/posts/1", owner))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/posts/1", ""))
+	var comment types.Comment
+	server.getJSON(t, "/comments/7", nil, "", &comment)
+	assert.Equal(t, "This comment was deleted.", comment.Content)
+	assert.NotNil(t, comment.DeletedAt)
+
+	// only its owner or a moderator can bring it back
+	assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/posts/restore/1", server.loginAs(t, "tech_writer2")))
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/posts/restore/1", owner))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodPost, "/posts/restore/1", owner))
+	server.getJSON(t, "/comments/7", nil, "", &comment)
+	assert.Equal(t, "Awesome update! I'll try it out.", comment.Content)
+	server.getJSON(t, "/comments/12", nil, "", &comment)
+	assert.Equal(t, "This comment was deleted.", comment.Content, "comments deleted on their own stay deleted")
+
+	// after the restore window only moderators can
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/posts/1", owner))
+	server.backdateDeletion(t, "Posts", 1, 31*24*time.Hour)
+	statusCode, body := server.authRequest(t, http.MethodPost, "/posts/restore/1", owner, "")
+	assert.Equal(t, http.StatusGone, statusCode)
+	assert.Equal(t, "The post with id 1 was deleted too long ago to be restored", body["message"])
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/admin/posts/1/restore", moderator))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/posts/1", ""))
+
+	// what a moderator took down stays down until a moderator restores it
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/posts/1", moderator))
+	statusCode, body = server.authRequest(t, http.MethodPost, "/posts/restore/1", owner, "")
+	assert.Equal(t, http.StatusForbidden, statusCode)
+	assert.Equal(t, "The post with id 1 was not deleted by its owner and can only be restored by a moderator", body["message"])
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/admin/posts/1/restore", moderator))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/posts/1", ""))
+
+	// deleted users cannot log in and keep their username until purged
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/users/dev_user1", owner))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/users/dev_user1", ""))
+	assert.Equal(t, http.StatusNotFound, status(http.MethodGet, "/projects/1", ""))
+	statusCode, _ = server.authRequest(t, http.MethodPost, "/auth/login", "", fmt.Sprintf(`{"username":"dev_user1","password":%q}`, testPassword))
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+	statusCode, _ = server.authRequest(t, http.MethodPost, "/auth/register", "", fmt.Sprintf(`{"username":"dev_user1","password":%q,"links":[]}`, testPassword))
+	assert.Equal(t, http.StatusConflict, statusCode)
+
+	assert.Equal(t, http.StatusForbidden, status(http.MethodPost, "/admin/users/dev_user1/restore", moderator))
+	assert.Equal(t, http.StatusOK, status(http.MethodPost, "/admin/users/dev_user1/restore", admin))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/projects/1", ""))
+	statusCode, _ = server.authRequest(t, http.MethodPost, "/auth/login", "", fmt.Sprintf(`{"username":"dev_user1","password":%q}`, testPassword))
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	// purged items are gone for good
+	assert.Equal(t, http.StatusOK, status(http.MethodDelete, "/posts/3", server.loginAs(t, "data_scientist3")))
+	server.backdateDeletion(t, "Posts", 3, 100*24*time.Hour)
+	purged, _, err := server.Stores.Tombstones.QueryPurge(time.Now().UTC().Add(-90 * 24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, http.StatusNotFound, status(http.MethodPost, "/admin/posts/3/restore", moderator))
+}
@@ -0,0 +1,466 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/api/internal/blobs"
+	"backend/api/internal/config"
+	"backend/api/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testImage encodes a small image in `format`, png or jpeg
+func testImage(t *testing.T, format string) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 200, A: 255})
+	var encoded bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&encoded, img)
+	} else {
+		err = jpeg.Encode(&encoded, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return encoded.Bytes()
+}
+
+// upload sends `data` as the `field` of a multipart form and returns the
+// status along with the decoded response body
+func (server *TestServer) upload(t *testing.T, method string, endpoint string, token string, field string, data []byte) (int, map[string]interface{}) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	// the claimed type is ignored, the content decides
+	part, err := form.CreateFormFile(field, "upload.png")
+	if err == nil {
+		_, err = part.Write(data)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		t.Fatalf("Failed to build upload: %v", err)
+	}
+
+	req, err := http.NewRequest(method, server.URL+endpoint, &body)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("Expected a JSON response from %s %s: %v", method, endpoint, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+// fetchUpload gets an uploaded file from where the api serves it
+func (server *TestServer) fetchUpload(t *testing.T, url string) (int, string, []byte) {
+	t.Helper()
+
+	// the test server stands in for the public url of the api
+	resp, err := http.Get(server.URL + strings.TrimPrefix(url, config.Default().Mail.LinkURL))
+	if err != nil {
+		t.Fatalf("Failed to fetch %v: %v", url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), data
+}
+
+func testUploads(t *testing.T, server *TestServer) {
+	token := server.loginAs(t, "dev_user1")
+	pngImage := testImage(t, "png")
+	jpegImage := testImage(t, "jpeg")
+
+	// profile pictures
+	status, _ := server.upload(t, http.MethodPut, "/users/dev_user1/picture", "", "image", pngImage)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = server.upload(t, http.MethodPut, "/users/dev_user1/picture", server.loginAs(t, "tech_writer2"), "image", pngImage)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, _ = server.authRequest(t, http.MethodPut, "/users/dev_user1/picture", token, `{"picture":"x.png"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "file", pngImage)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "image", []byte("<svg onload=\"alert(1)\"></svg>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+	tooLarge := append(bytes.Clone(pngImage), make([]byte, 5<<20)...)
+	status, _ = server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "image", tooLarge)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	status, response := server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "image", pngImage)
+	if !assert.Equal(t, http.StatusOK, status, "%v", response) {
+		return
+	}
+	user := response["user"].(map[string]interface{})
+	picture := user["picture"].(string)
+	assert.True(t, strings.HasPrefix(picture, "http://localhost:8080/media/users/1/picture-"), picture)
+	assert.True(t, strings.HasSuffix(picture, ".png"), picture)
+
+	status, contentType, data := server.fetchUpload(t, picture)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "image/png", contentType)
+	assert.Equal(t, pngImage, data)
+	status, fetched := server.authRequest(t, http.MethodGet, "/users/dev_user1", "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, picture, fetched["picture"])
+
+	// a new picture replaces the old one, which is deleted
+	status, response = server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "image", jpegImage)
+	if assert.Equal(t, http.StatusOK, status, "%v", response) {
+		replaced := response["user"].(map[string]interface{})["picture"].(string)
+		assert.True(t, strings.HasSuffix(replaced, ".jpg"), replaced)
+		status, contentType, _ = server.fetchUpload(t, replaced)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "image/jpeg", contentType)
+	}
+	status, _, _ = server.fetchUpload(t, picture)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// replacing a picture never deletes another user's upload, even if
+	// the old picture pointed at one
+	status, response = server.upload(t, http.MethodPut, "/users/tech_writer2/picture", server.loginAs(t, "tech_writer2"), "image", pngImage)
+	if assert.Equal(t, http.StatusOK, status, "%v", response) {
+		theirs := response["user"].(map[string]interface{})["picture"].(string)
+		if _, _, err := server.Stores.Images.QuerySetUserPicture("dev_user1", theirs); !assert.NoError(t, err) {
+			return
+		}
+		status, _ = server.upload(t, http.MethodPut, "/users/dev_user1/picture", token, "image", pngImage)
+		assert.Equal(t, http.StatusOK, status)
+		status, _, _ = server.fetchUpload(t, theirs)
+		assert.Equal(t, http.StatusOK, status)
+	}
+
+	// project images, dev_user1 owns project 1
+	status, _ = server.upload(t, http.MethodPost, "/projects/1/images", server.loginAs(t, "tech_writer2"), "image", pngImage)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = server.upload(t, http.MethodPost, "/projects/999/images", token, "image", pngImage)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, response = server.upload(t, http.MethodPost, "/projects/1/images", token, "image", pngImage)
+	if !assert.Equal(t, http.StatusCreated, status, "%v", response) {
+		return
+	}
+	imageId := response["id"].(float64)
+	imageURL := response["url"].(string)
+	assert.Equal(t, float64(1), response["project"])
+	assert.Equal(t, "image/png", response["content_type"])
+	assert.Equal(t, float64(len(pngImage)), response["size"])
+	assert.True(t, strings.HasPrefix(imageURL, "http://localhost:8080/media/projects/1/"), imageURL)
+	status, _, data = server.fetchUpload(t, imageURL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, pngImage, data)
+
+	var images []types.ProjectImage
+	server.getJSON(t, "/projects/1/images", nil, "", &images)
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, imageURL, images[0].URL)
+	}
+
+	// images can only be deleted through their own project
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/projects/2/images/%v", imageId), server.loginAs(t, "tech_writer2"), "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/projects/1/images/%v", imageId), token, "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _, _ = server.fetchUpload(t, imageURL)
+	assert.Equal(t, http.StatusNotFound, status)
+	server.getJSON(t, "/projects/1/images", nil, "", &images)
+	assert.Empty(t, images)
+
+	// and projects only have room for so many
+	for i := 0; i < 10; i++ {
+		status, _ = server.upload(t, http.MethodPost, "/projects/1/images", token, "image", pngImage)
+		assert.Equal(t, http.StatusCreated, status)
+	}
+	status, response = server.upload(t, http.MethodPost, "/projects/1/images", token, "image", pngImage)
+	assert.Equal(t, http.StatusBadRequest, status, "%v", response)
+}
+
+// fakeS3 is a bucket of a local stand-in for S3, which checks the
+// signature of every request the way S3 does
+type fakeS3 struct {
+	*httptest.Server
+	bucket    string
+	secretKey string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T, bucket string, secretKey string) *fakeS3 {
+	t.Helper()
+
+	s3 := &fakeS3{bucket: bucket, secretKey: secretKey, objects: map[string][]byte{}, types: map[string]string{}}
+	s3.Server = httptest.NewServer(http.HandlerFunc(s3.serve))
+	t.Cleanup(s3.Close)
+	return s3
+}
+
+func (s3 *fakeS3) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if err := s3.verify(req, body); err != nil {
+		http.Error(w, fmt.Sprintf("<Error><Code>SignatureDoesNotMatch</Code><Message>%v</Message></Error>", err), http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(req.URL.Path, "/"+s3.bucket+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+	switch req.Method {
+	case http.MethodPut:
+		s3.objects[key] = body
+		s3.types[key] = req.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(s3.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// object returns what is stored under `key` along with its content type
+func (s3 *fakeS3) object(key string) ([]byte, string) {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+	return s3.objects[key], s3.types[key]
+}
+
+// verify recomputes the AWS Signature Version 4 of a request
+func (s3 *fakeS3) verify(req *http.Request, body []byte) error {
+	payloadHash := sha256.Sum256(body)
+	if req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return fmt.Errorf("payload hash does not match")
+	}
+	var credential, signedHeaders, signature string
+	authorization, _ := strings.CutPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	for _, field := range strings.Split(authorization, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+	_, scope, _ := strings.Cut(credential, "/")
+	date, _, _ := strings.Cut(scope, "/")
+	if !strings.Contains(signedHeaders, "host") || !strings.Contains(signedHeaders, "x-amz-date") {
+		return fmt.Errorf("host and date must be signed")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%v:%v\n", name, strings.TrimSpace(value))
+	}
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", req.Header.Get("X-Amz-Date"), scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + s3.secretKey)
+	for _, part := range append([]string{date}, strings.Split(scope, "/")[1:]...) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+func TestS3Storage(t *testing.T) {
+	s3 := newFakeS3(t, "uploads", "secret-key")
+	storage, err := blobs.NewS3(s3.URL, "eu-west-1", "uploads", "access-key", "secret-key", "", time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	url, err := storage.Put("users/1/picture-abc.png", "image/png", []byte("png data"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, s3.URL+"/uploads/users/1/picture-abc.png", url)
+	data, contentType := s3.object("users/1/picture-abc.png")
+	assert.Equal(t, []byte("png data"), data)
+	assert.Equal(t, "image/png", contentType)
+
+	key, ok := storage.Key(url)
+	assert.True(t, ok)
+	assert.Equal(t, "users/1/picture-abc.png", key)
+	_, ok = storage.Key("https://example.com/uploads/users/1/picture-abc.png")
+	assert.False(t, ok)
+
+	assert.NoError(t, storage.Delete(key))
+	data, _ = s3.object(key)
+	assert.Nil(t, data)
+	// deleting what is not there is fine
+	assert.NoError(t, storage.Delete(key))
+
+	// keys cannot escape the bucket
+	_, err = storage.Put("../other-bucket/x.png", "image/png", []byte("png data"))
+	assert.Error(t, err)
+
+	// a wrong secret is refused, and the answer ends up in the error
+	wrong, err := blobs.NewS3(s3.URL, "eu-west-1", "uploads", "access-key", "wrong-key", "https://cdn.example.com/", time.Second)
+	if assert.NoError(t, err) {
+		_, err = wrong.Put("users/1/picture-abc.png", "image/png", []byte("png data"))
+		assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+		key, ok := wrong.Key("https://cdn.example.com/users/1/picture-abc.png")
+		assert.True(t, ok)
+		assert.Equal(t, "users/1/picture-abc.png", key)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := blobs.NewLocal(t.TempDir(), "https://cdn.example.com/media/", "http://localhost:8080")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "", storage.ServePath())
+
+	url, err := storage.Put("projects/1/abc.png", "image/png", []byte("png data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/media/projects/1/abc.png", url)
+	key, ok := storage.Key(url)
+	assert.True(t, ok)
+	assert.Equal(t, "projects/1/abc.png", key)
+
+	for _, invalid := range []string{"", "/etc/passwd", "../abc.png", "projects/../../abc.png", "projects//abc.png", "projects/a b.png"} {
+		_, err := storage.Put(invalid, "image/png", []byte("png data"))
+		assert.Error(t, err, invalid)
+		_, ok := storage.Key("https://cdn.example.com/media/" + invalid)
+		assert.False(t, ok, invalid)
+	}
+
+	assert.NoError(t, storage.Delete(key))
+	assert.NoError(t, storage.Delete(key))
+
+	// blobs the api serves itself get absolute URLs too, and the paths
+	// they were once recorded with still find them
+	served, err := blobs.NewLocal(t.TempDir(), "", "https://api.example.com/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/media", served.ServePath())
+	url, err = served.Put("projects/1/abc.png", "image/png", []byte("png data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/media/projects/1/abc.png", url)
+	for _, url := range []string{url, "/media/projects/1/abc.png"} {
+		key, ok := served.Key(url)
+		assert.True(t, ok, url)
+		assert.Equal(t, "projects/1/abc.png", key, url)
+	}
+	_, ok = served.Key("https://elsewhere.example.com/media/projects/1/abc.png")
+	assert.False(t, ok)
+}
+
+func TestStoreImages(t *testing.T) {
+	for name, stores := range storeImplementations(t) {
+		t.Run(name, func(t *testing.T) {
+			alice := registerUser(t, stores, "alice")
+			project, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "pictured", Description: "a", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+			other, err := stores.Projects.QueryCreateProject(&types.Project{Owner: alice, Name: "other", Description: "b", Links: []string{}, Tags: []string{}})
+			assert.NoError(t, err)
+
+			previous, _, err := stores.Images.QuerySetUserPicture("alice", "/media/users/1/picture-a.png")
+			assert.NoError(t, err)
+			assert.Equal(t, "", previous)
+			previous, _, err = stores.Images.QuerySetUserPicture("alice", "/media/users/1/picture-b.png")
+			assert.NoError(t, err)
+			assert.Equal(t, "/media/users/1/picture-a.png", previous)
+			user, err := stores.Users.QueryUsername("alice")
+			if assert.NoError(t, err) && assert.NotNil(t, user) {
+				assert.Equal(t, "/media/users/1/picture-b.png", user.Picture)
+			}
+			_, code, err := stores.Images.QuerySetUserPicture("nobody", "/media/x.png")
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, code)
+
+			first, _, err := stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: project, URL: "/media/projects/1/a.png", ContentType: "image/png", Size: 10}, 2)
+			assert.NoError(t, err)
+			_, _, err = stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: other, URL: "/media/projects/2/b.png", ContentType: "image/png", Size: 20}, 2)
+			assert.NoError(t, err)
+			second, _, err := stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: project, URL: "/media/projects/1/c.gif", ContentType: "image/gif", Size: 30}, 2)
+			assert.NoError(t, err)
+
+			// a full project takes no more, whatever the caller counted
+			_, code, err = stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: project, URL: "/media/projects/1/d.png", ContentType: "image/png", Size: 40}, 2)
+			assert.EqualError(t, err, "Projects can have at most 2 images")
+			assert.Equal(t, http.StatusBadRequest, code)
+			_, code, err = stores.Images.QueryCreateProjectImage(&types.ProjectImage{Project: 9999, URL: "/media/projects/9999/e.png", ContentType: "image/png", Size: 50}, 2)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, code)
+
+			images, _, err := stores.Images.QueryProjectImages(project)
+			assert.NoError(t, err)
+			if assert.Len(t, images, 2) {
+				assert.Equal(t, first, images[0].ID)
+				assert.Equal(t, second, images[1].ID)
+				assert.Equal(t, "image/gif", images[1].ContentType)
+				assert.Equal(t, int64(30), images[1].Size)
+			}
+
+			_, err = stores.Images.QueryDeleteProjectImage(first)
+			assert.NoError(t, err)
+			code, err = stores.Images.QueryDeleteProjectImage(first)
+			assert.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, code)
+			image, err := stores.Images.QueryProjectImage(first)
+			assert.NoError(t, err)
+			assert.Nil(t, image)
+			image, err = stores.Images.QueryProjectImage(second)
+			assert.NoError(t, err)
+			if assert.NotNil(t, image) {
+				assert.Equal(t, "/media/projects/1/c.gif", image.URL)
+			}
+		})
+	}
+}
@@ -34,28 +34,37 @@ var user_tests []TestCase = []TestCase{
 	// create user
 	{
 		Method:         http.MethodPost,
-		Endpoint:       "/users",
-		Input:          `{"username": "new_user","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
+		Endpoint:       "/auth/register",
+		Input:          `{"username": "new_user","password":"password123","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
 		ExpectedStatus: http.StatusCreated,
-		ExpectedBody:   `{"message":"Created new user: 'new_user'"}`,
+		ExpectedBody:   `{"message":"Registered new user: 'new_user'"}`,
 	},
 
 	// creating user with same name
 	{
 		Method:         http.MethodPost,
-		Endpoint:       "/users",
-		Input:          `{"username": "new_user","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
-		ExpectedStatus: http.StatusInternalServerError,
-		ExpectedBody:   `{"error":"Internal Server Error","message":"Failed to create user: Failed to create user 'new_user': UNIQUE constraint failed: Users.username"}`,
+		Endpoint:       "/auth/register",
+		Input:          `{"username": "new_user","password":"password123","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
+		ExpectedStatus: http.StatusConflict,
+		ExpectedBody:   `{"error":"Conflict","message":"Failed to register user: Username 'new_user' is already taken"}`,
 	},
 
 	// creating user with no name
 	{
 		Method:         http.MethodPost,
-		Endpoint:       "/users",
-		Input:          `{"username": "","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
+		Endpoint:       "/auth/register",
+		Input:          `{"username": "","password":"password123","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
 		ExpectedStatus: http.StatusBadRequest,
-		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'User.Username' Error:Field validation for 'Username' failed on the 'required' tag"}`,
+		ExpectedBody:   `{"error":"Bad Request","message":"Failed to bind to JSON: Key: 'UserRegistration.User.Username' Error:Field validation for 'Username' failed on the 'required' tag"}`,
+	},
+
+	// users are only created by registering, never without credentials
+	{
+		Method:         http.MethodPost,
+		Endpoint:       "/users",
+		Input:          `{"username": "squatter","bio":"","links":[],"picture":""}`,
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `404 page not found`,
 	},
 
 	// updating user with no name
@@ -63,6 +72,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPut,
 		Endpoint:       "/users/new_user",
 		Input:          `{"username": ""}`,
+		Username:       "new_user",
 		ExpectedStatus: http.StatusInternalServerError,
 		ExpectedBody:   `{"error":"Internal Server Error","message":"Error updating user: Updated username cannot be empty!"}`,
 	},
@@ -71,18 +81,30 @@ var user_tests []TestCase = []TestCase{
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/users/dev_user1",
-		Input:          `{"username": "tech_writer2","bio":"This is a test user.","links":["https://example.com","https://another-link.com"],"picture":"https://example.com/profile.jpg"}`,
+		Input:          `{"username": "tech_writer2","bio":"This is a test user.","links":["https://example.com","https://another-link.com"]}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusInternalServerError,
 		ExpectedBody:   `{"error":"Internal Server Error","message":"Error updating user: Error checking rows affected: Error executing update query: UNIQUE constraint failed: Users.username"}`,
 	},
 
+	// pictures are only changed by uploading one
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/dev_user1",
+		Input:          `{"bio":"Pointing at someone else's upload.","picture":"/media/users/2/picture-0123456789abcdef.png"}`,
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"error":"Bad Request","message":"Field 'picture' is not allowed to be updated, upload a new picture instead"}`,
+	},
+
 	// updating all user fields
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/users/dev_user1",
-		Input:          `{"username": "new_user_updated","bio":"This is the test user's updated bio.","links":["https://example.com/updated","https://another-link-updated.com"],"picture":"https://example.com/updates_profile.jpg"}`,
+		Input:          `{"username": "new_user_updated","bio":"This is the test user's updated bio.","links":["https://example.com/updated","https://another-link-updated.com"]}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
-		ExpectedBody:   `{"message":"User updated successfully.","user":{"username":"new_user_updated","bio":"This is the test user's updated bio.","links":["https://example.com/updated","https://another-link-updated.com"],"created_on":"2023-12-13T00:00:00Z","picture":"https://example.com/updates_profile.jpg"}}`,
+		ExpectedBody:   `{"message":"User updated successfully.","user":{"username":"new_user_updated","bio":"This is the test user's updated bio.","links":["https://example.com/updated","https://another-link-updated.com"],"created_on":"2023-12-13T00:00:00Z","picture":"https://example.com/dev_user1.jpg"}}`,
 	},
 
     // update it back...
@@ -90,7 +112,8 @@ var user_tests []TestCase = []TestCase{
 	{
 		Method:         http.MethodPut,
 		Endpoint:       "/users/new_user_updated",
-		Input:          `{"username":"dev_user1","bio":"Full-stack developer passionate about open-source projects.","links":["https://github.com/dev_user1","https://devuser1.com"],"created_on":"2023-12-13T00:00:00Z","picture":"https://example.com/dev_user1.jpg"}`,
+		Input:          `{"username":"dev_user1","bio":"Full-stack developer passionate about open-source projects.","links":["https://github.com/dev_user1","https://devuser1.com"],"created_on":"2023-12-13T00:00:00Z"}`,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"User updated successfully.","user":{"username":"dev_user1","bio":"Full-stack developer passionate about open-source projects.","links":["https://github.com/dev_user1","https://devuser1.com"],"created_on":"2023-12-13T00:00:00Z","picture":"https://example.com/dev_user1.jpg"}}`,
 	},
@@ -100,6 +123,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/users/new_user",
 		Input:          ``,
+		Username:       "new_user",
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `{"message":"User 'new_user' deleted."}`,
 	},
@@ -109,13 +133,15 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodDelete,
 		Endpoint:       "/users/not_a_user",
 		Input:          ``,
-		ExpectedStatus: http.StatusNotFound,
-		ExpectedBody:   `{"error":"Not Found","message":"Failed to delete user: Deletion did not affect any records"}`,
+		Username:       "dev_user1",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'dev_user1' cannot act on behalf of 'not_a_user'"}`,
 	},
 	{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/dev_user1/follow/tech_writer2",
 		Input:          ``,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
         ExpectedBody:   `{"message":"dev_user1 now follows tech_writer2"}`,
 	},
@@ -123,6 +149,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/tech_writer2/follow/dev",
 		Input:          ``,
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
         ExpectedBody:   `{"error":"Not Found","message":"Failed to add follower: Cannot find user with username 'dev'"}`,
 	},
@@ -130,8 +157,9 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/tech/follow/dev_user1",
 		Input:          ``,
-		ExpectedStatus: http.StatusNotFound,
-        ExpectedBody:   `{"error":"Not Found","message":"Failed to add follower: Cannot find user with username 'tech'"}`,
+		Username:       "tech_writer2",
+		ExpectedStatus: http.StatusForbidden,
+        ExpectedBody:   `{"error":"Forbidden","message":"User 'tech_writer2' cannot act on behalf of 'tech'"}`,
 	},
 	{
 		Method:         http.MethodGet,
@@ -151,6 +179,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/dev_user1/unfollow/tech_writer2",
 		Input:          ``,
+		Username:       "dev_user1",
 		ExpectedStatus: http.StatusOK,
         ExpectedBody:   `{"message":"dev_user1 unfollowed tech_writer2"}`,
 	},	
@@ -158,6 +187,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/backend_guru4/unfollow/dev_user1",
 		Input:          ``,
+		Username:       "backend_guru4",
 		ExpectedStatus: http.StatusOK,
         ExpectedBody:   `{"message":"backend_guru4 unfollowed dev_user1"}`,
 	},
@@ -165,6 +195,7 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/tech_writer2/unfollow/dev",
 		Input:          ``,
+		Username:       "tech_writer2",
 		ExpectedStatus: http.StatusNotFound,
         ExpectedBody:   `{"error":"Not Found","message":"Failed to remove follower: Cannot find user with username 'dev'"}`,
 	},
@@ -172,8 +203,9 @@ var user_tests []TestCase = []TestCase{
 		Method:         http.MethodPost,
 		Endpoint:       "/users/tech/unfollow/dev_user1",
 		Input:          ``,
-		ExpectedStatus: http.StatusNotFound,
-        ExpectedBody:   `{"error":"Not Found","message":"Failed to remove follower: Cannot find user with username 'tech'"}`,
+		Username:       "tech_writer2",
+		ExpectedStatus: http.StatusForbidden,
+        ExpectedBody:   `{"error":"Forbidden","message":"User 'tech_writer2' cannot act on behalf of 'tech'"}`,
 	},
 	{
 		Method:         http.MethodGet,
@@ -189,4 +221,22 @@ var user_tests []TestCase = []TestCase{
 		ExpectedStatus: http.StatusOK,
 		ExpectedBody:   `null`,
 	},
+
+	// writes need a logged in caller
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/tech_writer2",
+		Input:          `{"bio":"Anonymous edit"}`,
+		ExpectedStatus: http.StatusUnauthorized,
+		ExpectedBody:   `{"error":"Unauthorized","message":"Missing or malformed Authorization header"}`,
+	},
+	// and callers can only write as themselves
+	{
+		Method:         http.MethodPut,
+		Endpoint:       "/users/tech_writer2",
+		Input:          `{"bio":"Edited by someone else"}`,
+		Username:       "ui_designer5",
+		ExpectedStatus: http.StatusForbidden,
+		ExpectedBody:   `{"error":"Forbidden","message":"User 'ui_designer5' cannot act on behalf of 'tech_writer2'"}`,
+	},
 }
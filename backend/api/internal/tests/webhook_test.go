@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/store"
+	"backend/api/internal/types"
+	"backend/api/internal/webhooks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a delivery as a receiver got it
+type receivedDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local endpoint for webhooks, which answers the first
+// `failures` deliveries with a 500 and every later one with a 200.
+type receiver struct {
+	*httptest.Server
+	failures   atomic.Int32
+	deliveries chan receivedDelivery
+}
+
+func newReceiver(t *testing.T, failures int32) *receiver {
+	t.Helper()
+
+	r := &receiver{deliveries: make(chan receivedDelivery, 16)}
+	r.failures.Store(failures)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.deliveries <- receivedDelivery{header: req.Header.Clone(), body: body}
+		if r.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// next waits for the receiver's next delivery.
+func (r *receiver) next(t *testing.T) receivedDelivery {
+	t.Helper()
+	select {
+	case delivery := <-r.deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a webhook delivery")
+		return receivedDelivery{}
+	}
+}
+
+// settledDeliveries waits until none of a webhook's deliveries are pending,
+// as they are recorded only after the receiver answered.
+func (server *TestServer) settledDeliveries(t *testing.T, token string, project int64, webhook int64) []types.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var page types.PageResponse[types.WebhookDelivery]
+		server.getJSON(t, fmt.Sprintf("/projects/%v/webhooks/%v/deliveries", project, webhook), nil, token, &page)
+		deliveries := page.Items
+		pending := false
+		for _, delivery := range deliveries {
+			pending = pending || delivery.Status == database.DeliveryPending
+		}
+		if !pending || time.Now().After(deadline) {
+			return deliveries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testWebhooks(t *testing.T, server *TestServer) {
+	owner := server.loginAs(t, "dev_user1")
+	other := server.loginAs(t, "tech_writer2")
+	hook := newReceiver(t, 0)
+	body := fmt.Sprintf(`{"url":%q,"events":["post.created","comment.created","project.followed"]}`, hook.URL)
+
+	// only the owner of an existing project can add webhooks, to known events
+	status, _ := server.authRequest(t, http.MethodPost, "/projects/1/webhooks", other, body)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = server.authRequest(t, http.MethodPost, "/projects/99/webhooks", owner, body)
+	assert.Equal(t, http.StatusNotFound, status)
+	for _, invalid := range []string{
+		`{"url":"ftp://example.com","events":["post.created"]}`,
+		`{"url":"/relative","events":["post.created"]}`,
+		`{"url":"https://example.com","events":["post.deleted"]}`,
+		`{"url":"https://example.com","events":["post.created","post.created"]}`,
+		`{"url":"https://example.com","events":[]}`,
+	} {
+		status, response := server.authRequest(t, http.MethodPost, "/projects/1/webhooks", owner, invalid)
+		assert.Equal(t, http.StatusBadRequest, status, "%v: %v", invalid, response)
+	}
+
+	status, created := server.authRequest(t, http.MethodPost, "/projects/1/webhooks", owner, body)
+	if !assert.Equal(t, http.StatusCreated, status, "%v", created) {
+		return
+	}
+	secret, _ := created["secret"].(string)
+	assert.NotEmpty(t, secret)
+	webhookId := int64(created["id"].(float64))
+
+	// the secret is only sent back when the webhook is created
+	var listed []types.Webhook
+	server.getJSON(t, "/projects/1/webhooks", nil, owner, &listed)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, hook.URL, listed[0].URL)
+		assert.Empty(t, listed[0].Secret)
+	}
+
+	act := func(token string, endpoint string, body string) {
+		t.Helper()
+		status, response := server.authRequest(t, http.MethodPost, endpoint, token, body)
+		assert.Less(t, status, 300, "%v: %v", endpoint, response)
+	}
+	// likes were not subscribed to, and nothing happens on other projects
+	fan := server.loginAs(t, "data_scientist3")
+	act(fan, "/projects/data_scientist3/likes/1", "")
+	act(owner, "/projects/dev_user1/follow/3", "")
+	act(fan, "/projects/data_scientist3/follow/1", "")
+	act(owner, "/posts", `{"user":1,"project":1,"content":"Hooked"}`)
+	act(other, "/comments/for-post/1", `{"user":2,"content":"Ship it"}`)
+
+	received := map[string]types.WebhookPayload{}
+	var followed receivedDelivery
+	for range 3 {
+		delivery := hook.next(t)
+		event := delivery.header.Get(webhooks.EventHeader)
+		assert.Equal(t, webhooks.Sign(secret, delivery.body), delivery.header.Get(webhooks.SignatureHeader))
+		assert.NotEmpty(t, delivery.header.Get(webhooks.DeliveryHeader))
+
+		var payload types.WebhookPayload
+		assert.NoError(t, json.Unmarshal(delivery.body, &payload))
+		assert.Equal(t, event, payload.Event)
+		assert.Equal(t, int64(1), payload.Project)
+		received[event] = payload
+		if event == webhooks.EventProjectFollowed {
+			followed = delivery
+		}
+	}
+	assert.Equal(t, "data_scientist3", received[webhooks.EventProjectFollowed].Actor)
+	assert.Equal(t, "dev_user1", received[webhooks.EventPostCreated].Actor)
+	assert.Equal(t, "Hooked", received[webhooks.EventPostCreated].Data.(map[string]interface{})["content"])
+	comment := received[webhooks.EventCommentCreated].Data.(map[string]interface{})
+	assert.Equal(t, "post", comment["thread"])
+	assert.Equal(t, float64(1), comment["thread_id"])
+	assert.NotContains(t, received, webhooks.EventProjectLiked)
+
+	deliveries := server.settledDeliveries(t, owner, 1, webhookId)
+	if !assert.Len(t, deliveries, 3) {
+		return
+	}
+	for _, delivery := range deliveries {
+		assert.Equal(t, database.DeliveryDelivered, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.ResponseCode)
+		assert.NotNil(t, delivery.DeliveredDate)
+	}
+
+	// a redelivery sends the same payload again as a delivery of its own
+	followedId := followed.header.Get(webhooks.DeliveryHeader)
+	status, response := server.authRequest(t, http.MethodPost, fmt.Sprintf("/projects/1/webhooks/%v/deliveries/%v/redeliver", webhookId, followedId), owner, "")
+	assert.Equal(t, http.StatusAccepted, status, "%v", response)
+	again := hook.next(t)
+	assert.Equal(t, followed.body, again.body)
+	assert.NotEqual(t, followedId, again.header.Get(webhooks.DeliveryHeader))
+
+	deliveries = server.settledDeliveries(t, owner, 1, webhookId)
+	if assert.Len(t, deliveries, 4) && assert.NotNil(t, deliveries[0].RedeliveryOf) {
+		assert.Equal(t, followedId, fmt.Sprint(*deliveries[0].RedeliveryOf))
+	}
+	status, _ = server.authRequest(t, http.MethodPost, fmt.Sprintf("/projects/1/webhooks/%v/deliveries/999/redeliver", webhookId), owner, "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// webhooks are only reachable through their own project
+	status, _ = server.authRequest(t, http.MethodPost, "/projects/2/webhooks", other, fmt.Sprintf(`{"url":%q,"events":["post.created"]}`, hook.URL))
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/projects/2/webhooks/%v", webhookId), other, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/projects/1/webhooks/%v", webhookId), other, "")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, _ = server.authRequest(t, http.MethodDelete, fmt.Sprintf("/projects/1/webhooks/%v", webhookId), owner, "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = server.authRequest(t, http.MethodGet, fmt.Sprintf("/projects/1/webhooks/%v/deliveries", webhookId), owner, "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	NewTestServer(t) // sets up the logger
+
+	webhookStore := store.NewMemory().Webhooks
+	// every webhook is put on a project of its own, so it gets one delivery
+	var project int64
+	queue := func(url string) int64 {
+		t.Helper()
+		project++
+		id, err := webhookStore.QueryCreateWebhook(&types.Webhook{Project: project, URL: url, Events: []string{webhooks.EventPostCreated}, Secret: "secret"})
+		assert.NoError(t, err)
+		_, _, err = webhookStore.QueryQueueDeliveries(project, webhooks.EventPostCreated, []byte(`{}`), time.Now().UTC())
+		assert.NoError(t, err)
+		return id
+	}
+	delivery := func(webhookId int64) types.WebhookDelivery {
+		t.Helper()
+		deliveries, _, _, err := webhookStore.QueryDeliveries(webhookId, types.Page{})
+		assert.NoError(t, err)
+		if !assert.Len(t, deliveries, 1) {
+			t.FailNow()
+		}
+		return deliveries[0]
+	}
+	// sends what is due until the delivery is settled, retries are only
+	// due once their backoff passed
+	settle := func(dispatcher *webhooks.Dispatcher, webhookId int64) types.WebhookDelivery {
+		t.Helper()
+		for range 100 {
+			dispatcher.DeliverDue()
+			if settled := delivery(webhookId); settled.Status != database.DeliveryPending {
+				return settled
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Delivery was never settled")
+		return types.WebhookDelivery{}
+	}
+
+	// a failed delivery is retried until it is accepted
+	flaky := newReceiver(t, 2)
+	dispatcher := webhooks.NewDispatcher(webhookStore, 3, time.Millisecond, time.Second, true)
+	settled := settle(dispatcher, queue(flaky.URL))
+	assert.Equal(t, database.DeliveryDelivered, settled.Status)
+	assert.Equal(t, 3, settled.Attempts)
+	assert.Equal(t, http.StatusOK, settled.ResponseCode)
+	assert.Empty(t, settled.Error)
+
+	// and given up on once it runs out of attempts
+	broken := newReceiver(t, 10)
+	settled = settle(dispatcher, queue(broken.URL))
+	assert.Equal(t, database.DeliveryFailed, settled.Status)
+	assert.Equal(t, 3, settled.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, settled.ResponseCode)
+	assert.Contains(t, settled.Error, "500")
+	assert.Nil(t, settled.DeliveredDate)
+
+	// retries back off, doubling every time
+	slow := newReceiver(t, 10)
+	dispatcher = webhooks.NewDispatcher(webhookStore, 3, time.Hour, time.Second, true)
+	slowId := queue(slow.URL)
+	assert.Equal(t, 1, dispatcher.DeliverDue())
+	assert.Equal(t, 0, dispatcher.DeliverDue())
+	retry := delivery(slowId)
+	assert.Equal(t, database.DeliveryPending, retry.Status)
+	if assert.NotNil(t, retry.NextAttempt) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *retry.NextAttempt, time.Minute)
+	}
+
+	// private addresses are refused unless allowed
+	local := newReceiver(t, 0)
+	dispatcher = webhooks.NewDispatcher(webhookStore, 1, time.Millisecond, time.Second, false)
+	settled = settle(dispatcher, queue(local.URL))
+	assert.Equal(t, database.DeliveryFailed, settled.Status)
+	assert.Contains(t, settled.Error, "non-public")
+	assert.Empty(t, local.deliveries)
+
+	// and so is the shared address space behind carrier-grade NATs
+	settled = settle(dispatcher, queue("http://100.64.0.1:8080/hook"))
+	assert.Equal(t, database.DeliveryFailed, settled.Status)
+	assert.Contains(t, settled.Error, "non-public address 100.64.0.1")
+}
@@ -0,0 +1,90 @@
+// Package trending ranks posts and projects by the attention they got
+// recently. Every like, comment and follow adds to an item's score, and
+// older interactions count for less and less as time passes.
+//
+// Scores are kept as the logarithm of the sum of every interaction's weight
+// grown by the time it happened, which orders items exactly like their
+// decayed sums would at any moment. That lets a score be updated once per
+// interaction and never touched again as it ages, instead of being
+// recomputed for every request.
+package trending
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// HalfLife is how long it takes for an interaction to count half as much.
+const HalfLife = 24 * time.Hour
+
+// how much each kind of interaction adds to a score, creating an item
+// counts as an interaction so new items start out ahead of old ones
+const (
+	CreatedWeight = 1.0
+	LikeWeight    = 1.0
+	CommentWeight = 2.0
+	FollowWeight  = 3.0
+)
+
+// scores are measured from this moment, any fixed moment would do
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Event is one interaction with an item.
+type Event struct {
+	Weight float64
+	Time   time.Time
+}
+
+// value is the score of a lone interaction.
+func value(event Event) float64 {
+	return math.Log(event.Weight) + event.Time.Sub(epoch).Hours()/HalfLife.Hours()*math.Ln2
+}
+
+// Initial returns the score of an item nobody has interacted with yet.
+func Initial(created time.Time) float64 {
+	return value(Event{Weight: CreatedWeight, Time: created})
+}
+
+// Add returns the score after an interaction.
+func Add(score float64, event Event) float64 {
+	other := value(event)
+	if other > score {
+		score, other = other, score
+	}
+	return score + math.Log1p(math.Exp(other-score))
+}
+
+// Remove returns the score after an interaction is taken back, such as a
+// like being removed. The score never drops below `floor`, the score the
+// item was created with, which rounding errors could otherwise push it past.
+func Remove(score float64, event Event, floor float64) float64 {
+	removed := value(event)
+	if removed >= score {
+		return floor
+	}
+	score += math.Log1p(-math.Exp(removed - score))
+	if math.IsNaN(score) || score < floor {
+		return floor
+	}
+	return score
+}
+
+// Score computes the score of an item from scratch. The events are added
+// oldest first, so the same events always add up to the same score.
+func Score(created time.Time, events []Event) float64 {
+	events = slices.Clone(events)
+	slices.SortFunc(events, func(a, b Event) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Weight, b.Weight)
+	})
+
+	score := Initial(created)
+	for _, event := range events {
+		score = Add(score, event)
+	}
+	return score
+}
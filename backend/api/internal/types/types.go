@@ -9,8 +9,8 @@ package types
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
-    "encoding/json"
 )
 
 type User struct {
@@ -33,6 +33,41 @@ type Project struct {
 	CreationDate time.Time `json:"creation_date"`
 }
 
+// the statuses a project can be in, by the names the api accepts for them
+var ProjectStatuses = map[string]int16{
+	"planned":  0,
+	"active":   1,
+	"archived": 2,
+}
+
+// the projects to find, zero values match every project
+type ProjectFilter struct {
+	Tags []string
+	// whether a project needs every tag or any one of them
+	MatchAllTags bool
+	Status       *int16
+	Owner        int64
+	// the order of the projects, time, likes or trending
+	Sort string
+}
+
+// how many projects use a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// an image uploaded to a project, served at its URL
+type ProjectImage struct {
+	ID          int64  `json:"id"`
+	Project     int64  `json:"project"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	// in bytes
+	Size         int64     `json:"size"`
+	CreationDate time.Time `json:"created_on"`
+}
+
 type Post struct {
 	ID           int64     `json:"id"`
 	User         int64     `json:"user" binding:"required"`
@@ -40,6 +75,8 @@ type Post struct {
 	Likes        int64     `json:"likes"`
 	Content      string    `json:"content" binding:"required"`
 	CreationDate time.Time `json:"created_on"`
+	// when the content last changed, nil if it never has
+	EditedAt *time.Time `json:"edited_at,omitempty"`
 }
 
 type Comment struct {
@@ -49,6 +86,299 @@ type Comment struct {
 	ParentComment NullableInt64 `json:"parent_comment" binding:"required"`
 	CreationDate  time.Time     `json:"created_on"`
 	Content       string        `json:"content" binding:"required"`
+	// when the content last changed, nil if it never has
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// when the comment was deleted, it stays in its thread with its
+	// author and content hidden so replies keep their parent
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// a deleted user, project, post or comment that has not been purged yet
+type Tombstone struct {
+	Kind string
+	ID   int64
+	// the user the item belongs to, the user themselves for users
+	Owner     int64
+	DeletedAt time.Time
+	// who deleted it, 0 if it was deleted before that was recorded
+	DeletedBy int64
+}
+
+// what a post or comment said before one of its edits
+type Revision struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+	// when the edit replacing this content was made
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// the notifications a user got about the same thing, like every like on
+// one of their posts, described by the latest of them
+type Notification struct {
+	// the latest notification of the group, marking it read marks the group read
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	// the post, project or comment acted on, or the followed user
+	Target int64 `json:"target"`
+	// the comment written by the latest actor, for comments and replies
+	Comment *int64 `json:"comment,omitempty"`
+	// the user who acted last and how many users acted in total
+	Actor   string `json:"actor"`
+	Actors  int64  `json:"actors"`
+	Message string `json:"message"`
+	Read    bool   `json:"read"`
+	// when the latest actor acted
+	CreationDate time.Time `json:"created_on"`
+}
+
+// a single notification as it was sent, before it is grouped with others
+type SentNotification struct {
+	// the user the notification is for
+	Recipient int64 `json:"-"`
+	Notification
+}
+
+// the like count of a post, project or comment after it changed
+type LikeCount struct {
+	Kind  string `json:"kind"`
+	ID    int64  `json:"id"`
+	Likes int64  `json:"likes"`
+}
+
+// a message sent down a comment thread socket, data depends on the type
+type ThreadEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// a message sent up a comment thread socket, typing is the only type so far
+type ThreadMessage struct {
+	Type string `json:"type"`
+	// the comment being replied to, nil when commenting on the thread itself
+	Parent *int64 `json:"parent,omitempty"`
+}
+
+// who is writing a comment on a thread
+type Typing struct {
+	User   string `json:"user"`
+	Parent *int64 `json:"parent,omitempty"`
+}
+
+// a page of notifications along with how many groups are still unread
+type NotificationPage struct {
+	Items      []Notification `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Unread     int64          `json:"unread"`
+}
+
+// how often users can be emailed a digest of their notifications, by the
+// names the api accepts for them
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+var DigestFrequencies = map[string]time.Duration{
+	DigestDaily:  24 * time.Hour,
+	DigestWeekly: 7 * 24 * time.Hour,
+}
+
+// where a user is emailed and how often they get a digest
+type EmailPreferences struct {
+	Email  string `json:"email" binding:"required"`
+	Digest string `json:"digest" binding:"required"`
+	// whether the owner followed the link emailed to the address, digests
+	// are only sent once they did. Set by the api, not the client
+	Confirmed bool `json:"confirmed"`
+}
+
+// what a user was notified of since their last digest
+type Digest struct {
+	User     int64
+	Username string
+	Email    string
+	// new follows of the user and their projects
+	Followers int64
+	// new likes of their posts, projects and comments
+	Likes int64
+	// new comments on their posts and projects and replies to their comments
+	Replies int64
+	// the groups of notifications behind the counts, latest first, capped
+	// so long digests stay readable
+	Highlights []Notification
+	// the latest notification the previous digest covered, and the latest
+	// one this digest covers
+	Since   int64
+	Through int64
+}
+
+// how to list a comment thread
+type ThreadQuery struct {
+	// the comment whose replies to list, 0 for the top of the thread
+	Parent int64
+	// how many levels of comments to include, counting the listed ones
+	Depth int
+	// the order of the comments on every level, top or new
+	Sort string
+	// the page of the listed comments, Limit also caps the replies on every other level
+	Page Page
+}
+
+// a comment of a thread along with its replies. Replies that did not fit
+// are left out, replies_cursor loads them, passed back as `cursor`
+type CommentNode struct {
+	Comment
+	Children      []CommentNode `json:"children"`
+	Replies       *Cursor       `json:"-"`
+	RepliesCursor string        `json:"replies_cursor,omitempty"`
+}
+
+// an item matching a search, its snippet marks the matched words with <mark>
+type SearchResult struct {
+	Kind    string  `json:"kind"`
+	ID      int64   `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// a user or project suggested while typing its name, following is
+// whether the caller follows it
+type Suggestion struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Followers int64  `json:"followers"`
+	Following bool   `json:"following"`
+}
+
+// the authenticated user behind a request, resolved from their token
+type Caller struct {
+	ID        int64
+	Username  string
+	Role      string
+	Suspended bool
+}
+
+// the account details only admins get to see
+type UserStatus struct {
+	Username       string     `json:"username"`
+	Role           string     `json:"role"`
+	SuspensionDate *time.Time `json:"suspended_on"`
+}
+
+type RoleUpdate struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type OwnerUpdate struct {
+	Owner int64 `json:"owner" binding:"required"`
+}
+
+type UserCredentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// the registration body is a regular user plus the password
+// that will be stored in UserLoginInfo
+type UserRegistration struct {
+	User
+	Password string `json:"password" binding:"required"`
+}
+
+type TokenPair struct {
+	TokenType         string    `json:"token_type"`
+	AccessToken       string    `json:"access_token"`
+	AccessExpiration  time.Time `json:"access_expires_on"`
+	RefreshToken      string    `json:"refresh_token"`
+	RefreshExpiration time.Time `json:"refresh_expires_on"`
+}
+
+// personal access tokens are only ever shown in full once, when they
+// are created, so listing them returns this metadata instead
+type PersonalToken struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	CreationDate time.Time  `json:"created_on"`
+	LastUsed     *time.Time `json:"last_used_on"`
+}
+
+type NewPersonalToken struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// a URL that is sent the events of a project it subscribed to
+type Webhook struct {
+	ID      int64    `json:"id"`
+	Project int64    `json:"project"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	// signs the payloads, it is only ever shown once, when the webhook is created
+	Secret       string    `json:"secret,omitempty"`
+	CreationDate time.Time `json:"created_on"`
+}
+
+type NewWebhook struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+}
+
+// what is sent to a webhook, data is the post, comment, like or follow
+type WebhookPayload struct {
+	Event        string      `json:"event"`
+	Project      int64       `json:"project"`
+	Actor        string      `json:"actor"`
+	CreationDate time.Time   `json:"created_on"`
+	Data         interface{} `json:"data"`
+}
+
+// a payload sent or to be sent to a webhook, along with how that went
+type WebhookDelivery struct {
+	ID      int64           `json:"id"`
+	Webhook int64           `json:"webhook"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	// pending, delivered or failed
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// the response to the latest attempt, 0 if there was none, and why it failed
+	ResponseCode int    `json:"response_code"`
+	Error        string `json:"error,omitempty"`
+	// the delivery this one sends again, nil unless it was redelivered
+	RedeliveryOf  *int64     `json:"redelivery_of,omitempty"`
+	CreationDate  time.Time  `json:"created_on"`
+	NextAttempt   *time.Time `json:"next_attempt_on,omitempty"`
+	DeliveredDate *time.Time `json:"delivered_on,omitempty"`
+}
+
+// a delivery that is due, along with where it goes and how it is signed
+type PendingDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// the outcome of trying to send a delivery
+type DeliveryAttempt struct {
+	Delivery     int64
+	Status       string
+	ResponseCode int
+	Error        string
+	// when to try again, nil unless the delivery is still pending
+	NextAttempt *time.Time
+	Time        time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 type ErrorResponse struct {
@@ -56,6 +386,30 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// the position of the last item of a page, by the value the list is
+// sorted on, with the id breaking ties between items with equal values
+type Cursor struct {
+	Time  time.Time `json:"t"`
+	Likes int64     `json:"l"`
+	Score float64   `json:"s,omitempty"`
+	ID    int64     `json:"i"`
+	// the comment whose replies are being paged through, in comment threads
+	Parent int64 `json:"p,omitempty"`
+}
+
+// a page of a list, the first Limit items that come after the After cursor.
+// A nil cursor starts at the top of the list and a Limit of 0 returns every item
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+// a page of a list as it is sent back, next_cursor is left out on the last page
+type PageResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 // we can implement this type...
 type NullableInt64 struct {
 	sql.NullInt64
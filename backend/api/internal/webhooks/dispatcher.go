@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"backend/api/internal/database"
+	"backend/api/internal/logger"
+	"backend/api/internal/types"
+)
+
+// how many due deliveries are sent per round
+const batchSize = 50
+
+// how much of a response body is read before the connection is dropped
+const maxResponseSize = 64 << 10
+
+// Store is what the dispatcher needs from the webhook store.
+type Store interface {
+	QueryDueDeliveries(now time.Time, limit int) ([]types.PendingDelivery, int, error)
+	QueryRecordAttempt(attempt types.DeliveryAttempt) (int, error)
+}
+
+// Dispatcher sends the queued deliveries of every webhook. Only one
+// should run per database, or deliveries may be sent twice.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	// a delivery is given up on after this many failed attempts, the
+	// first retry waits `backoff` and every later one twice as long
+	maxAttempts int
+	backoff     time.Duration
+	wake        chan struct{}
+}
+
+// NewDispatcher returns a dispatcher sending the deliveries of `store`.
+// Unless `allowPrivate` is set, deliveries to loopback, private and
+// link-local addresses are refused, so webhooks cannot be used to reach
+// services behind the api. Proxies set in the environment are ignored,
+// since a proxy would make the connections the checks cannot see.
+func NewDispatcher(store Store, maxAttempts int, backoff time.Duration, timeout time.Duration, allowPrivate bool) *Dispatcher {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// a redirect counts as a failed delivery rather than being followed
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// the shared address space carrier-grade NATs use, which the net
+// package does not count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// refusePrivate stops connections to addresses that are not on the public
+// internet. It runs once the address is resolved, so names that resolve
+// to a private address are caught as well.
+func refusePrivate(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("Refusing to deliver to non-public address %v", host)
+	}
+	return nil
+}
+
+// Wake has the dispatcher send what is due now instead of on its next
+// round, for deliveries that were just queued.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries every `interval`, and whenever it is woken,
+// until `ctx` is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// a full round may have left more behind
+		for d.DeliverDue() == batchSize {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends a round of due deliveries and records how each went.
+//
+// Returns:
+//   - int: How many deliveries were attempted.
+func (d *Dispatcher) DeliverDue() int {
+	due, _, err := d.store.QueryDueDeliveries(time.Now().UTC(), batchSize)
+	if err != nil {
+		logger.Log.Errorf("Failed to fetch due webhook deliveries: %v", err)
+		return 0
+	}
+	for _, pending := range due {
+		if _, err := d.store.QueryRecordAttempt(d.deliver(pending)); err != nil {
+			logger.Log.Errorf("Failed to record webhook delivery %v: %v", pending.ID, err)
+		}
+	}
+	return len(due)
+}
+
+// deliver sends a delivery once. Any 2xx response accepts it, anything
+// else is retried later, until the attempts run out.
+func (d *Dispatcher) deliver(pending types.PendingDelivery) types.DeliveryAttempt {
+	attempt := types.DeliveryAttempt{Delivery: pending.ID, Status: database.DeliveryDelivered, Time: time.Now().UTC()}
+
+	req, err := http.NewRequest(http.MethodPost, pending.URL, bytes.NewReader(pending.Payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "DevBits-Webhooks")
+		req.Header.Set(EventHeader, pending.Event)
+		req.Header.Set(DeliveryHeader, strconv.FormatInt(pending.ID, 10))
+		req.Header.Set(SignatureHeader, Sign(pending.Secret, pending.Payload))
+
+		var resp *http.Response
+		resp, err = d.client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+			resp.Body.Close()
+			attempt.ResponseCode = resp.StatusCode
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return attempt
+			}
+			err = fmt.Errorf("Unexpected response status %v", resp.Status)
+		}
+	}
+	attempt.Error = err.Error()
+
+	attempts := pending.Attempts + 1
+	if attempts >= d.maxAttempts {
+		attempt.Status = database.DeliveryFailed
+		return attempt
+	}
+	next := attempt.Time.Add(d.backoff << (attempts - 1))
+	attempt.Status = database.DeliveryPending
+	attempt.NextAttempt = &next
+	return attempt
+}
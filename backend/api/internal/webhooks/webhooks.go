@@ -0,0 +1,51 @@
+// The webhooks package delivers the events of projects to the URLs their
+// owners registered. Deliveries are queued in the store when the event
+// happens and sent by a Dispatcher in the background, each one signed
+// with its webhook's secret and retried with exponential backoff until it
+// is accepted or runs out of attempts.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+)
+
+// the events a webhook can subscribe to
+const (
+	EventPostCreated     = "post.created"
+	EventCommentCreated  = "comment.created"
+	EventProjectLiked    = "project.liked"
+	EventProjectFollowed = "project.followed"
+)
+
+var Events = []string{EventPostCreated, EventCommentCreated, EventProjectLiked, EventProjectFollowed}
+
+// the headers every delivery is sent with
+const (
+	EventHeader     = "X-DevBits-Event"
+	DeliveryHeader  = "X-DevBits-Delivery"
+	SignatureHeader = "X-DevBits-Signature"
+)
+
+// IsEvent reports whether `event` is one webhooks can subscribe to.
+func IsEvent(event string) bool {
+	return slices.Contains(Events, event)
+}
+
+// Sign returns the signature of a payload sent in SignatureHeader, the hex
+// encoded HMAC-SHA256 of the body keyed with the webhook's secret, so the
+// receiver can check the payload came from us untouched.
+//
+// Parameters:
+//   - secret: The secret of the webhook.
+//   - body: The payload as sent.
+//
+// Returns:
+//   - string: The signature, prefixed with `sha256=`.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,122 +1,109 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
 
+	"backend/api/internal/auth"
+	"backend/api/internal/blobs"
+	"backend/api/internal/config"
 	"backend/api/internal/database"
+	"backend/api/internal/events"
 	"backend/api/internal/handlers"
 	"backend/api/internal/logger"
+	"backend/api/internal/mail"
+	"backend/api/internal/store"
+	"backend/api/internal/webhooks"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const DEBUG bool = true
+func main() {
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a .yml or .toml config file")
+	flag.Parse()
 
-func HealthCheck(context *gin.Context) {
-	context.JSON(200, gin.H{"message": "API is running!"})
-}
+	log.SetOutput(os.Stdout)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
 
-func main() {
-	if DEBUG {
+	if cfg.Debug {
 		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
 	}
-	log.SetOutput(os.Stdout)
 	logger.InitLogger()
+	logger.SetLevel(cfg.LogLevel)
 
-	router := gin.Default()
-	router.HandleMethodNotAllowed = true
-
-	// Apply CORS middleware to the router
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8081"}, // Add your frontend URL (React Native or Web app)
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		AllowCredentials: true, // Allow cookies or authentication headers
-	}))
-
-	router.GET("/health", HealthCheck)
-
-	router.GET("/users/:username", handlers.GetUserByUsername)
-	router.POST("/users", handlers.CreateUser)
-	router.PUT("/users/:username", handlers.UpdateUserInfo)
-	router.DELETE("/users/:username", handlers.DeleteUser)
-
-	router.GET("/users/:username/followers", handlers.GetUsersFollowers)
-	router.GET("/users/:username/follows", handlers.GetUsersFollowing)
-	router.GET("/users/:username/followers/usernames", handlers.GetUsersFollowersUsernames)
-	router.GET("/users/:username/follows/usernames", handlers.GetUsersFollowingUsernames)
-
-	router.POST("/users/:username/follow/:new_follow", handlers.FollowUser)
-	router.POST("/users/:username/unfollow/:unfollow", handlers.UnfollowUser)
-
-	router.GET("/projects/:project_id", handlers.GetProjectById)
-	router.POST("/projects", handlers.CreateProject)
-	router.PUT("/projects/:project_id", handlers.UpdateProjectInfo)
-	router.DELETE("/projects/:project_id", handlers.DeleteProject)
-	router.GET("/projects/by-user/:user_id", handlers.GetProjectsByUserId)
-
-	router.GET("/projects/:project_id/followers", handlers.GetProjectFollowers)
-	router.GET("/projects/follows/:username", handlers.GetProjectFollowing)
-	router.GET("/projects/:project_id/followers/usernames", handlers.GetProjectFollowersUsernames)
-	router.GET("/projects/follows/:username/names", handlers.GetProjectFollowingNames)
-
-	router.POST("/projects/:username/follow/:project_id", handlers.FollowProject)
-	router.POST("/projects/:username/unfollow/:project_id", handlers.UnfollowProject)
-
-	router.POST("/projects/:username/likes/:project_id", handlers.LikeProject)
-	router.POST("/projects/:username/unlikes/:project_id", handlers.UnlikeProject)
-	router.GET("/projects/does-like/:username/:project_id", handlers.IsProjectLiked)
-
-	router.GET("/posts/:post_id", handlers.GetPostById)
-	router.POST("/posts", handlers.CreatePost)
-	router.PUT("/posts/:post_id", handlers.UpdatePostInfo)
-	router.DELETE("/posts/:post_id", handlers.DeletePost)
-
-	router.GET("/posts/by-user/:user_id", handlers.GetPostsByUserId)
-	router.GET("/posts/by-project/:project_id", handlers.GetPostsByProjectId)
-
-	router.POST("/posts/:username/likes/:post_id", handlers.LikePost)
-	router.POST("/posts/:username/unlikes/:post_id", handlers.UnlikePost)
-	router.GET("/posts/does-like/:username/:post_id", handlers.IsPostLiked)
-
-	router.POST("/comments/for-post/:post_id", handlers.CreateCommentOnPost)
-	router.POST("/comments/for-project/:project_id", handlers.CreateCommentOnProject)
-	router.POST("/comments/for-comment/:comment_id", handlers.CreateCommentOnComment)
-	router.GET("/comments/:comment_id", handlers.GetCommentById)
-	router.PUT("/comments/:comment_id", handlers.UpdateCommentContent)
-	router.DELETE("/comments/:comment_id", handlers.DeleteComment)
-
-	router.GET("/comments/by-user/:user_id", handlers.GetCommentsByUserId)
-	router.GET("/comments/by-post/:post_id", handlers.GetCommentsByPostId)
-	router.GET("/comments/by-project/:project_id", handlers.GetCommentsByProjectId)
-	router.GET("/comments/by-comment/:comment_id", handlers.GetCommentsByCommentId)
+	// `api migrate ...` manages the schema and exits without serving
+	if flag.NArg() > 0 {
+		if flag.Arg(0) != "migrate" {
+			log.Fatalf("FATAL: Unknown command '%v', %v", flag.Arg(0), migrateUsage)
+		}
+		db := database.Connect(cfg.Database.DSN, cfg.Database.Driver)
+		if err := runMigrateCommand(db, flag.Args()[1:]); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		return
+	}
 
-	router.POST("/comments/:username/likes/:comment_id", handlers.LikeComment)
-	router.POST("/comments/:username/unlikes/:comment_id", handlers.UnlikeComment)
-	router.GET("/comments/does-like/:username/:comment_id", handlers.IsCommentLiked)
-	router.GET("/comments/can-edit/:comment_id", handlers.IsCommentEditable)
+	// set before the digester signs unsubscribe links
+	tokenSecret := cfg.Auth.TokenSecret
+	if tokenSecret == "" {
+		// only allowed in debug mode, tokens signed with a throwaway key
+		// stop working once the server restarts
+		tokenSecret, _ = auth.NewRandomToken()
+		log.Println("WARNING: auth.token_secret is not set, using a random signing key")
+	}
+	auth.SetSigningKey([]byte(tokenSecret))
+	auth.AccessTokenDuration = cfg.Auth.AccessTokenTTL.Duration
+	auth.RefreshTokenDuration = cfg.Auth.RefreshTokenTTL.Duration
+
+	db := database.Connect(cfg.Database.DSN, cfg.Database.Driver)
+	applyMigrationsOnStart(db, cfg.Database.AutoMigrate)
+	stores, err := store.WithAutocomplete(store.NewSQL(db))
+	if err != nil {
+		log.Fatalf("FATAL: Failed to build the autocomplete index: %v", err)
+	}
+	stores, err = store.WithEvents(stores, events.NewBus(cfg.Events.History, cfg.Events.KeepAlive.Duration))
+	if err != nil {
+		log.Fatalf("FATAL: Failed to start publishing events: %v", err)
+	}
+	dispatcher := webhooks.NewDispatcher(stores.Webhooks, cfg.Webhooks.MaxAttempts, cfg.Webhooks.RetryBackoff.Duration,
+		cfg.Webhooks.Timeout.Duration, cfg.Webhooks.AllowPrivateAddresses)
+	stores = store.WithWebhooks(stores, dispatcher)
+	go dispatcher.Run(context.Background(), cfg.Webhooks.PollInterval.Duration)
+	mailer := newMailer(cfg.Mail)
+	go mail.NewDigester(stores.Digests, mailer, cfg.Mail.LinkURL).Run(context.Background(), cfg.Mail.DigestInterval.Duration)
+	storage, err := newStorage(cfg.Storage, cfg.Mail.LinkURL)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to set up upload storage: %v", err)
+	}
+	go purgeDeleted(stores.Tombstones, storage, cfg.Database.PurgeAfter.Duration, cfg.Database.PurgeInterval.Duration)
+	server := handlers.NewServer(stores, storage, mailer, cfg.Mail.LinkURL, cfg.Limits)
+	router := handlers.NewRouter(server, cfg.CORS.AllowedOrigins)
 
-	router.GET("/feed/posts", handlers.GetPostsFeed)
-	router.GET("/feed/projects", handlers.GetProjectsFeed)
+	router.Run(cfg.ListenAddress)
+}
 
-	var dbinfo, dbtype string
-	if DEBUG {
-		dbinfo = "./api/internal/database/dev.sqlite3"
-		dbtype = "sqlite3"
-	} else {
-		dbinfo = os.Getenv("DB_INFO")
-		dbtype = os.Getenv("DB_TYPE")
-		if dbinfo == "" {
-			log.Fatalln("FATAL: debug mode is false and 'DB_INFO' doesn't exist!")
-		}
-		if dbtype == "" {
-			log.Fatalln("FATAL: debug mode is false and 'DB_TYPE' doesn't exist!")
-		}
+// newMailer returns the mailer the config asks for.
+func newMailer(cfg config.MailConfig) mail.Mailer {
+	if cfg.Driver == "smtp" {
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From, cfg.SMTPTimeout.Duration)
 	}
-	database.Connect(dbinfo, dbtype)
+	return mail.LogMailer{}
+}
 
-	router.Run("localhost:8080")
+// newStorage returns the blob storage the config asks for. Uploads the
+// api serves itself get URLs under `apiURL`, its public URL.
+func newStorage(cfg config.StorageConfig, apiURL string) (blobs.Storage, error) {
+	if cfg.Driver == "s3" {
+		return blobs.NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.PublicURL, cfg.S3Timeout.Duration)
+	}
+	return blobs.NewLocal(cfg.Directory, cfg.PublicURL, apiURL)
 }
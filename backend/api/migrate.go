@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"backend/api/internal/database"
+)
+
+const migrateUsage = "usage: api [-config file] migrate status|up|down [steps]"
+
+// runMigrateCommand handles `api migrate ...`, which lets a database be
+// inspected and moved between schema versions without starting the server.
+func runMigrateCommand(db *database.Database, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		if len(args) != 1 {
+			return fmt.Errorf(migrateUsage)
+		}
+		statuses, err := database.QueryMigrationStatus(db)
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedDate != nil {
+				applied = "applied " + status.AppliedDate.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30v %v\n", status.Version, status.Name, applied)
+		}
+		return err
+
+	case "up":
+		if len(args) != 1 {
+			return fmt.Errorf(migrateUsage)
+		}
+		ran, err := database.MigrateUp(db)
+		logMigrations("Applied", ran)
+		return err
+
+	case "down":
+		// rolling back drops data, so only the latest migration is undone
+		// unless more steps are asked for explicitly
+		steps := 1
+		if len(args) == 2 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("Failed to parse steps: %v", err)
+			}
+			steps = parsed
+		} else if len(args) != 1 {
+			return fmt.Errorf(migrateUsage)
+		}
+		ran, err := database.MigrateDown(db, steps)
+		logMigrations("Rolled back", ran)
+		return err
+	}
+
+	return fmt.Errorf("Unknown migrate command '%v', %v", args[0], migrateUsage)
+}
+
+func logMigrations(action string, migrations []database.Migration) {
+	if len(migrations) == 0 {
+		log.Printf("%v no migrations", action)
+		return
+	}
+	for _, migration := range migrations {
+		log.Printf("%v migration %04d_%v", action, migration.Version, migration.Name)
+	}
+}
+
+// applyMigrationsOnStart brings the schema up to date when auto_migrate is
+// set. Otherwise the database is never changed without someone asking for
+// it, and the server refuses to start on a schema that is behind, since
+// everything it builds on startup, like the autocomplete index, needs the
+// latest tables.
+func applyMigrationsOnStart(db *database.Database, autoMigrate bool) {
+	if autoMigrate {
+		ran, err := database.MigrateUp(db)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to apply migrations: %v", err)
+		}
+		logMigrations("Applied", ran)
+		backfillTrendingScores(db)
+		return
+	}
+
+	statuses, err := database.QueryMigrationStatus(db)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to check migrations: %v", err)
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedDate == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		log.Fatalf("FATAL: The database has %v pending migrations, run `api migrate up` or set database.auto_migrate", pending)
+	}
+	backfillTrendingScores(db)
+}
+
+// backfillTrendingScores computes the trending scores the database is
+// missing, such as every score right after the migration adding them.
+// The feeds still work without them, so failing is only worth a warning.
+func backfillTrendingScores(db *database.Database) {
+	computed, err := db.BackfillTrendingScores()
+	if err != nil {
+		log.Printf("WARNING: Failed to compute trending scores: %v", err)
+	} else if computed > 0 {
+		log.Printf("Computed %v trending scores", computed)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"time"
+
+	"backend/api/internal/blobs"
+	"backend/api/internal/logger"
+	"backend/api/internal/store"
+)
+
+// purgeDeleted deletes for good everything that was deleted more than
+// `after` ago, once on startup and then every `interval`, along with the
+// files uploaded for it. It never returns.
+func purgeDeleted(tombstones store.TombstoneStore, storage blobs.Storage, after time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, uploads, err := tombstones.QueryPurge(time.Now().UTC().Add(-after))
+		if err != nil {
+			logger.Log.Errorf("Failed to purge deleted items: %v", err)
+		} else if purged > 0 {
+			logger.Log.Infof("Purged %v deleted items", purged)
+		}
+		deleteUploads(storage, uploads)
+		<-ticker.C
+	}
+}
+
+// deleteUploads removes the files served at `urls` from the blob storage.
+// Pictures linked from elsewhere are left alone, and failing to delete
+// only leaves a file behind, so it is logged and the rest carry on.
+func deleteUploads(storage blobs.Storage, urls []string) {
+	for _, url := range urls {
+		key, ok := storage.Key(url)
+		if !ok {
+			continue
+		}
+		if err := storage.Delete(key); err != nil {
+			logger.Log.Errorf("Failed to delete blob '%v': %v", key, err)
+		}
+	}
+}